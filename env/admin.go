@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type reloadResponse struct {
+	Generation uint64 `json:"generation"`
+	Reloaded   bool   `json:"reloaded"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ReloadHandler forces a reload on POST, for setups without file events
+// such as ConfigMap symlink swaps. Requests must send
+// "Authorization: Bearer <token>"; with an empty token every request is
+// refused. Mount it at /-/reload.
+func (s *Store) ReloadHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !checkBearer(w, r, token) {
+			return
+		}
+
+		err := s.Reload(r.Context())
+		resp := reloadResponse{Generation: s.Generation(), Reloaded: err == nil}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err != nil {
+			resp.Error = err.Error()
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// checkBearer writes the error response and returns false unless r carries
+// token. An empty token refuses every request.
+func checkBearer(w http.ResponseWriter, r *http.Request, token string) bool {
+	if token == "" {
+		http.Error(w, "admin token not configured", http.StatusForbidden)
+		return false
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReloadHandler(t *testing.T) {
+	decryptor, calls := versionDecryptor(3)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(handler http.Handler, method, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/-/reload", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	handler := store.ReloadHandler("s3cret")
+
+	for _, tt := range []struct {
+		name    string
+		handler http.Handler
+		method  string
+		auth    string
+		code    int
+	}{
+		{"GET", handler, http.MethodGet, "Bearer s3cret", http.StatusMethodNotAllowed},
+		{"no token", handler, http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", handler, http.MethodPost, "Bearer nope", http.StatusUnauthorized},
+		{"unconfigured", store.ReloadHandler(""), http.MethodPost, "Bearer ", http.StatusForbidden},
+	} {
+		if rec := send(tt.handler, tt.method, tt.auth); rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("refused requests reloaded the store (%d decryptions)", calls.Load())
+	}
+
+	rec := send(handler, http.MethodPost, "Bearer s3cret")
+	var resp reloadResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || !resp.Reloaded || resp.Generation != 2 {
+		t.Errorf("reload: status %d, %+v", rec.Code, resp)
+	}
+
+	// The third decryption fails; the store keeps generation 2.
+	rec = send(handler, http.MethodPost, "Bearer s3cret")
+	resp = reloadResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusInternalServerError || resp.Reloaded || resp.Generation != 2 || resp.Error == "" {
+		t.Errorf("failed reload: status %d, %+v", rec.Code, resp)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+// requireSOPS skips tests that encrypt through the real sops binary.
+func requireSOPS(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sops"); err != nil {
+		t.Skip("sops is not installed")
+	}
+}
+
+func TestAgeFixtureLoad(t *testing.T) {
+	requireSOPS(t)
+	fixture, err := sopstest.NewAgeFixture()
+	if err != nil {
+		t.Fatalf("NewAgeFixture() error = %v", err)
+	}
+	t.Cleanup(func() { fixture.Close() })
+
+	path, err := fixture.Encrypt("config.sops.env", []byte("DB_PASSWORD=test\nAPI_KEY=sk-test\n"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-test") {
+		t.Error("fixture was not encrypted")
+	}
+	config, err := LoadSOPSEnv(path)
+	if err != nil {
+		t.Fatalf("LoadSOPSEnv() error = %v", err)
+	}
+	if config.APIKey != "sk-test" {
+		t.Errorf("APIKey = %q, want sk-test", config.APIKey)
+	}
+}
+
+func TestWriteEncryptedTemp(t *testing.T) {
+	requireSOPS(t)
+	fixture, err := sopstest.NewAgeFixture()
+	if err != nil {
+		t.Fatalf("NewAgeFixture() error = %v", err)
+	}
+	t.Cleanup(func() { fixture.Close() })
+
+	tests := []struct {
+		name, content string
+		want          string
+		wantErr       bool
+	}{
+		{"plain", "DB_PORT=5432\n", "5432", false},
+		{"quoted", "DB_PORT=\"5433\"\n", "5433", false},
+		{"unterminated quote", "DB_PORT=\"5432\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := sopstest.WriteEncryptedTemp(t, "dotenv", tt.content, fixture.Recipient)
+			config, err := LoadSOPSEnv(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSOPSEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.DBPort != tt.want {
+				t.Errorf("DBPort = %q, want %q", config.DBPort, tt.want)
+			}
+		})
+	}
+}
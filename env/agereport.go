@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgeReport says how long encrypted files, and the keys of env files, have
+// gone without rotation.
+type AgeReport struct {
+	Generated time.Time `json:"generated"`
+	Files     []FileAge `json:"files"`
+}
+
+type FileAge struct {
+	File string `json:"file"`
+	// LastModified is the sops lastmodified metadata, updated whenever the
+	// file is edited or its data key rotated.
+	LastModified time.Time `json:"last_modified,omitzero"`
+	LastCommit   time.Time `json:"last_commit,omitzero"`
+	Commit       string    `json:"commit,omitempty"`
+	AgeDays      int       `json:"age_days"`
+	Keys         []KeyAge  `json:"keys,omitempty"`
+}
+
+// KeyAge dates a key by the commit that introduced its current ciphertext,
+// using the sops lastmodified of that revision, which is when it was
+// edited. sops keeps the ciphertext of unchanged values on edit, so a new
+// ciphertext means a new value, or a rotated data key.
+type KeyAge struct {
+	Key     string    `json:"key"`
+	Rotated time.Time `json:"rotated"`
+	Commit  string    `json:"commit,omitempty"`
+	AgeDays int       `json:"age_days"`
+	// Uncommitted keys changed in the working tree since the last commit.
+	Uncommitted bool `json:"uncommitted,omitempty"`
+}
+
+func runAgeReport(args []string) error {
+	fs := flag.NewFlagSet("age-report", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	sortBy := fs.String("sort", "age", "sort files and keys by age, oldest first, or by name")
+	maxAge := fs.Duration("max-age", 0, "exit non-zero if a file or key is older than this, e.g. 2160h for 90 days")
+	maxCommits := fs.Int("max-commits", 500, "how far back to look in git log per file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sortBy != "age" && *sortBy != "name" {
+		return fmt.Errorf("-sort must be age or name, not %q", *sortBy)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		var err error
+		if files, err = trackedSOPSFiles(); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return errors.New("no *.sops.* files tracked by git, pass the files to report on")
+		}
+	}
+
+	now := time.Now()
+	report := &AgeReport{Generated: now.UTC()}
+	for _, file := range files {
+		age, err := fileAge(file, now, *maxCommits)
+		if err != nil {
+			return err
+		}
+		report.Files = append(report.Files, *age)
+	}
+	sortAgeReport(report, *sortBy)
+
+	if *asJSON || jsonOutput() {
+		if err := writeJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printAgeReport(report)
+	}
+
+	if *maxAge > 0 {
+		if stale := report.olderThan(*maxAge); len(stale) > 0 {
+			return fmt.Errorf("not rotated in %d days: %s", int(maxAge.Hours()/24), strings.Join(stale, ", "))
+		}
+	}
+	return nil
+}
+
+func trackedSOPSFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed, pass the files to report on: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Fields(string(out)) {
+		if sopsFileName(file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func fileAge(file string, now time.Time, maxCommits int) (*FileAge, error) {
+	meta, err := readSOPSMetadata(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	age := &FileAge{File: file}
+	if modified, err := time.Parse(time.RFC3339, meta.LastModified); err == nil {
+		age.LastModified = modified.UTC()
+	}
+
+	commits := fileCommits(file, maxCommits)
+	if len(commits) > 0 {
+		age.LastCommit, age.Commit = commits[0].time, commits[0].hash
+	}
+	age.AgeDays = ageDays(now, cmp.Or(age.LastModified, age.LastCommit))
+
+	if filepath.Ext(file) != ".env" {
+		return age, nil
+	}
+	current, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	age.Keys = keyAges(file, envCiphertexts(current), commits, now, age.LastModified)
+	return age, nil
+}
+
+type fileCommit struct {
+	hash string
+	time time.Time
+}
+
+// fileCommits lists the commits that touched file, newest first. Outside a
+// git repository there are none.
+func fileCommits(file string, max int) []fileCommit {
+	out, err := exec.Command("git", "log", "-n", strconv.Itoa(max), "--format=%H %ct", "--", file).Output()
+	if err != nil {
+		return nil
+	}
+	var commits []fileCommit
+	for line := range strings.Lines(string(out)) {
+		hash, unix, ok := strings.Cut(strings.TrimSpace(line), " ")
+		seconds, err := strconv.ParseInt(unix, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		commits = append(commits, fileCommit{hash: hash, time: time.Unix(seconds, 0).UTC()})
+	}
+	return commits
+}
+
+// keyAges walks back through the commits while each key's ciphertext
+// stays the same. A key is as old as the oldest revision in that run.
+func keyAges(file string, current map[string]string, commits []fileCommit, now, modified time.Time) []KeyAge {
+	ages := make(map[string]*KeyAge, len(current))
+	open := make(map[string]bool, len(current))
+	for key := range current {
+		ages[key] = &KeyAge{Key: key, Rotated: modified, Uncommitted: true}
+		open[key] = true
+	}
+
+	for _, commit := range commits {
+		if len(open) == 0 {
+			break
+		}
+		data, err := exec.Command("git", "show", commit.hash+":./"+filepath.ToSlash(file)).Output()
+		if err != nil {
+			break // renamed or moved before this commit
+		}
+		edited := commit.time
+		if meta, err := parseEnvMetadata(data); err == nil {
+			if t, err := time.Parse(time.RFC3339, meta.LastModified); err == nil && t.Before(edited) {
+				edited = t.UTC()
+			}
+		}
+		old := envCiphertexts(data)
+		for key := range open {
+			if old[key] != current[key] {
+				delete(open, key)
+				continue
+			}
+			ages[key].Rotated, ages[key].Commit, ages[key].Uncommitted = edited, commit.hash, false
+		}
+	}
+
+	keys := make([]KeyAge, 0, len(ages))
+	for _, age := range ages {
+		age.AgeDays = ageDays(now, age.Rotated)
+		keys = append(keys, *age)
+	}
+	return keys
+}
+
+// envCiphertexts maps the keys of an encrypted env file to their ENC[...]
+// values, leaving out sops metadata.
+func envCiphertexts(data []byte) map[string]string {
+	values := map[string]string{}
+	for line := range bytes.Lines(data) {
+		key, value, ok := strings.Cut(strings.TrimSpace(string(line)), "=")
+		if !ok || strings.HasPrefix(key, "sops_") || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func ageDays(now, t time.Time) int {
+	if t.IsZero() {
+		return 0
+	}
+	return int(now.Sub(t).Hours() / 24)
+}
+
+func sortAgeReport(report *AgeReport, by string) {
+	byAge := func(a, b int, an, bn string) int {
+		return cmp.Or(cmp.Compare(b, a), cmp.Compare(an, bn))
+	}
+	for i := range report.Files {
+		slices.SortFunc(report.Files[i].Keys, func(a, b KeyAge) int {
+			if by == "name" {
+				return cmp.Compare(a.Key, b.Key)
+			}
+			return byAge(a.AgeDays, b.AgeDays, a.Key, b.Key)
+		})
+	}
+	slices.SortFunc(report.Files, func(a, b FileAge) int {
+		if by == "name" {
+			return cmp.Compare(a.File, b.File)
+		}
+		return byAge(a.AgeDays, b.AgeDays, a.File, b.File)
+	})
+}
+
+func (r *AgeReport) olderThan(max time.Duration) []string {
+	days := int(max.Hours() / 24)
+	var stale []string
+	for _, f := range r.Files {
+		if len(f.Keys) == 0 && f.AgeDays > days {
+			stale = append(stale, f.File)
+		}
+		for _, k := range f.Keys {
+			if k.AgeDays > days {
+				stale = append(stale, f.File+":"+k.Key)
+			}
+		}
+	}
+	return stale
+}
+
+func printAgeReport(report *AgeReport) {
+	for _, f := range report.Files {
+		fmt.Printf("📅 %s: %d days", f.File, f.AgeDays)
+		if !f.LastModified.IsZero() {
+			fmt.Printf(", sops lastmodified %s", f.LastModified.Format(time.DateOnly))
+		}
+		if f.Commit != "" {
+			fmt.Printf(", last commit %s %s", f.Commit[:min(len(f.Commit), 8)], f.LastCommit.Format(time.DateOnly))
+		}
+		fmt.Println()
+		for _, k := range f.Keys {
+			since := k.Rotated.Format(time.DateOnly)
+			switch {
+			case k.Uncommitted:
+				since += ", not committed yet"
+			case k.Commit != "":
+				since += " in " + k.Commit[:min(len(k.Commit), 8)]
+			}
+			fmt.Printf("   %-32s %5d days (since %s)\n", k.Key, k.AgeDays, since)
+		}
+	}
+}
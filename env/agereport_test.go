@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitRepo makes a git repository in a temporary directory and changes into
+// it.
+func gitRepo(t *testing.T) (commit func(date time.Time, files map[string]string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+	git := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git(nil, "init", "-q")
+	return func(date time.Time, files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stamp := date.Format(time.RFC3339)
+		git(nil, "add", "-A")
+		git([]string{
+			"GIT_AUTHOR_DATE=" + stamp, "GIT_COMMITTER_DATE=" + stamp,
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		}, "commit", "-q", "-m", "update")
+	}
+}
+
+func agedEnvFile(modified time.Time, password string) string {
+	return "DB_PASSWORD=ENC[AES256_GCM,data:" + password + "]\n" +
+		"API_KEY=ENC[AES256_GCM,data:unchanged]\n" +
+		"sops_lastmodified=" + modified.Format(time.RFC3339) + "\n" +
+		"sops_mac=ENC[AES256_GCM,data:mac]\n"
+}
+
+func TestAgeReport(t *testing.T) {
+	commit := gitRepo(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	created, rotated := now.AddDate(0, 0, -200), now.AddDate(0, 0, -10)
+	commit(created, map[string]string{"config.sops.env": agedEnvFile(created, "v1")})
+	commit(rotated, map[string]string{"config.sops.env": agedEnvFile(rotated, "v2")})
+
+	previous := outputFormat
+	outputFormat = "json"
+	defer func() { outputFormat = previous }()
+	var runErr error
+	out := captureStdout(t, func() { runErr = runAgeReport(nil) })
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	var report AgeReport
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if len(report.Files) != 1 || report.Files[0].File != "config.sops.env" || report.Files[0].AgeDays != 10 {
+		t.Fatalf("files = %+v", report.Files)
+	}
+	keys := report.Files[0].Keys
+	if len(keys) != 2 || keys[0].Key != "API_KEY" || keys[0].AgeDays != 200 || !keys[0].Rotated.Equal(created) {
+		t.Errorf("oldest key = %+v, want API_KEY from 200 days ago", keys[0])
+	}
+	if keys[1].Key != "DB_PASSWORD" || keys[1].AgeDays != 10 || keys[1].Commit == "" {
+		t.Errorf("newest key = %+v, want DB_PASSWORD from the last commit", keys[1])
+	}
+
+	captureStdout(t, func() { runErr = runAgeReport([]string{"-max-age", "2160h"}) })
+	if runErr == nil || runErr.Error() != "not rotated in 90 days: config.sops.env:API_KEY" {
+		t.Errorf("-max-age = %v", runErr)
+	}
+
+	// A working tree edit is dated by lastmodified and marked uncommitted.
+	os.WriteFile("config.sops.env", []byte(agedEnvFile(now, "v3")), 0o600)
+	outputFormat = previous
+	text := string(captureStdout(t, func() { runErr = runAgeReport(nil) }))
+	if runErr != nil || !strings.Contains(text, "DB_PASSWORD") || !strings.Contains(text, "not committed yet") {
+		t.Errorf("after an edit: %v\n%s", runErr, text)
+	}
+}
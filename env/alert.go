@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultAlertThreshold is how many decryptions in a row must fail before
+// an alert is sent, so a single KMS hiccup doesn't page anyone.
+const DefaultAlertThreshold = 3
+
+// alertQueueSize bounds the alerts waiting for delivery. Alerts are only
+// sent on transitions, so a full queue means the webhook is down and
+// dropping a few more is better than stalling a load.
+const alertQueueSize = 16
+
+// Alerter posts to a Slack or Microsoft Teams incoming webhook when
+// decryption of a file keeps failing, and once more when it recovers. The
+// message names the file, host and error category only. Delivery happens
+// in the background, in order, so a slow webhook never delays a load.
+type Alerter struct {
+	url       string
+	payload   func(text string) any
+	threshold int
+	http      *http.Client
+	queue     chan alert
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// NewSlackAlerter alerts through a Slack incoming webhook after threshold
+// consecutive failures, or DefaultAlertThreshold if it is zero.
+func NewSlackAlerter(webhookURL string, threshold int) *Alerter {
+	return newAlerter(webhookURL, threshold, func(text string) any {
+		return map[string]string{"text": text}
+	})
+}
+
+// NewTeamsAlerter alerts through a Teams Workflows webhook as an Adaptive
+// Card.
+func NewTeamsAlerter(webhookURL string, threshold int) *Alerter {
+	return newAlerter(webhookURL, threshold, func(text string) any {
+		return map[string]any{
+			"type": "message",
+			"attachments": []any{map[string]any{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    []any{map[string]any{"type": "TextBlock", "text": text, "wrap": true}},
+				},
+			}},
+		}
+	})
+}
+
+func newAlerter(url string, threshold int, payload func(string) any) *Alerter {
+	if threshold <= 0 {
+		threshold = DefaultAlertThreshold
+	}
+	a := &Alerter{
+		url:       url,
+		payload:   payload,
+		threshold: threshold,
+		http:      &http.Client{Timeout: 10 * time.Second},
+		queue:     make(chan alert, alertQueueSize),
+		failures:  make(map[string]int),
+	}
+	go a.deliver()
+	return a
+}
+
+// WithAlerter reports every load of the file to a, so repeated failures at
+// startup or in a watcher raise an alert.
+func WithAlerter(a *Alerter) Option {
+	return func(o *loadOptions) {
+		o.alerter = a
+	}
+}
+
+// Observe records the outcome of one decryption of file. Loaders call it
+// when given WithAlerter; custom retry loops can call it directly. It never
+// blocks: the alert is queued, or dropped with a warning if the queue is
+// full.
+func (a *Alerter) Observe(file string, err error) {
+	a.mu.Lock()
+	failures := a.failures[file]
+	if err == nil {
+		delete(a.failures, file)
+	} else {
+		a.failures[file] = failures + 1
+	}
+	a.mu.Unlock()
+
+	host, _ := os.Hostname()
+	switch {
+	case err != nil && failures+1 == a.threshold:
+		a.enqueue(fmt.Sprintf("🚨 Decryption of %s on %s is failing (%d in a row): %s", file, host, a.threshold, ErrorCategory(err)))
+	case err == nil && failures >= a.threshold:
+		a.enqueue(fmt.Sprintf("✅ Decryption of %s on %s succeeded again after %d failures", file, host, failures))
+	}
+}
+
+// alert is one queued message, or with flushed set, a marker Flush waits on.
+type alert struct {
+	text    string
+	flushed chan struct{}
+}
+
+func (a *Alerter) enqueue(text string) {
+	select {
+	case a.queue <- alert{text: text}:
+	default:
+		slog.Warn("dropping decryption alert, the webhook is not keeping up")
+	}
+}
+
+// Flush waits until every alert queued before it has been sent, or ctx is
+// done. Call it before exiting so an alert about a failed start isn't lost.
+func (a *Alerter) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case a.queue <- alert{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Alerter) deliver() {
+	for alert := range a.queue {
+		if alert.flushed != nil {
+			close(alert.flushed)
+			continue
+		}
+		a.send(alert.text)
+	}
+}
+
+func (a *Alerter) send(text string) {
+	body, err := json.Marshal(a.payload(text))
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("failed to send decryption alert", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.http.Do(req)
+	if err != nil {
+		slog.Warn("failed to send decryption alert", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("failed to send decryption alert", "status", resp.Status)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-sops-env/sopstest"
+)
+
+func TestAlerterObservesLoads(t *testing.T) {
+	loaders := map[string]func(filename string, opts ...Option) error{
+		"LoadSOPSEnv": func(filename string, opts ...Option) error {
+			_, err := LoadSOPSEnv(filename, opts...)
+			return err
+		},
+		"LoadSOPSEnvToSystem": LoadSOPSEnvToSystem,
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GO_SOPS_ALERT_TEST", "")
+			var mu sync.Mutex
+			var alerts []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload map[string]string
+				json.NewDecoder(r.Body).Decode(&payload)
+				mu.Lock()
+				alerts = append(alerts, payload["text"])
+				mu.Unlock()
+			}))
+			defer server.Close()
+			alerter := NewSlackAlerter(server.URL, 2)
+
+			fake := sopstest.NewFake()
+			fake.SetError("config.sops.env", &DecryptError{File: "config.sops.env", Kind: ErrNoMatchingKeys})
+			for range 2 {
+				if err := load("config.sops.env", WithDecryptor(fake), WithAlerter(alerter)); err == nil {
+					t.Fatal("load succeeded with a failing decryptor")
+				}
+			}
+			fake.SetFile("config.sops.env", map[string]string{"GO_SOPS_ALERT_TEST": "ok"})
+			if err := load("config.sops.env", WithDecryptor(fake), WithAlerter(alerter)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := alerter.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(alerts) != 2 || !strings.Contains(alerts[0], "is failing (2 in a row)") || !strings.Contains(alerts[1], "succeeded again") {
+				t.Errorf("alerts = %q, want a failure and a recovery", alerts)
+			}
+		})
+	}
+}
+
+func TestAlerterDoesNotBlockOnSlowWebhook(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	alerter := NewSlackAlerter(server.URL, 1)
+
+	failed := &DecryptError{File: "config.sops.env", Kind: ErrNoMatchingKeys}
+	start := time.Now()
+	// Each failure of a new file is an alert; more than the queue holds
+	// must be dropped rather than wait for the stuck webhook.
+	for i := range 2 * alertQueueSize {
+		alerter.Observe(fmt.Sprintf("config-%d.sops.env", i), failed)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Observe took %v with a stuck webhook", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := alerter.Flush(ctx); err == nil {
+		t.Fatal("Flush returned before the stuck webhook answered")
+	}
+
+	close(release)
+	if err := alerter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if received == 0 || received > alertQueueSize+1 {
+		t.Errorf("webhook received %d alerts, want 1 to %d", received, alertQueueSize+1)
+	}
+}
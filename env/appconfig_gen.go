@@ -0,0 +1,211 @@
+// Code generated by go-sops gen from config.manifest.yaml. DO NOT EDIT.
+
+package main
+
+import (
+	"net/url"
+)
+
+// AppConfig is a typed view of config.sops.env, checked against config.manifest.yaml
+// when it is loaded.
+type AppConfig struct {
+	env                    *EnvConfig
+	dbHost                 string
+	dbPort                 int
+	dbName                 string
+	dbUser                 string
+	dbPassword             Secret
+	dbMaxConnections       int
+	redisURL               Secret
+	redisPassword          Secret
+	jwtSecret              Secret
+	apiKey                 Secret
+	stripeSecretKey        Secret
+	sendGridAPIKey         Secret
+	googleClientID         string
+	googleClientSecret     Secret
+	gitHubClientID         string
+	gitHubClientSecret     Secret
+	webhookURL             *url.URL
+	notificationServiceURL *url.URL
+	sentryDSN              Secret
+	environment            string
+	debug                  bool
+	logLevel               string
+	encryptionKey          Secret
+	signingKey             Secret
+}
+
+// LoadAppConfig decrypts filename, config.sops.env if empty, and reads every
+// key of the manifest, reporting all missing and invalid ones at once.
+func LoadAppConfig(filename string, opts ...Option) (*AppConfig, error) {
+	if filename == "" {
+		filename = "config.sops.env"
+	}
+	env, err := LoadSOPSEnv(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewAppConfig(env)
+}
+
+// NewAppConfig reads the manifest's keys from an already loaded config.
+func NewAppConfig(env *EnvConfig) (*AppConfig, error) {
+	r := &manifestReader{env: env}
+	c := &AppConfig{
+		env:                    env,
+		dbHost:                 r.String("DB_HOST", "", true),
+		dbPort:                 r.Int("DB_PORT", "5432", false),
+		dbName:                 r.String("DB_NAME", "", true),
+		dbUser:                 r.String("DB_USER", "", true),
+		dbPassword:             r.Secret("DB_PASSWORD", "", false),
+		dbMaxConnections:       r.Int("DB_MAX_CONNECTIONS", "10", false),
+		redisURL:               r.Secret("REDIS_URL", "", false),
+		redisPassword:          r.Secret("REDIS_PASSWORD", "", false),
+		jwtSecret:              r.Secret("JWT_SECRET", "", true),
+		apiKey:                 r.Secret("API_KEY", "", false),
+		stripeSecretKey:        r.Secret("STRIPE_SECRET_KEY", "", false),
+		sendGridAPIKey:         r.Secret("SENDGRID_API_KEY", "", false),
+		googleClientID:         r.String("GOOGLE_CLIENT_ID", "", false),
+		googleClientSecret:     r.Secret("GOOGLE_CLIENT_SECRET", "", false),
+		gitHubClientID:         r.String("GITHUB_CLIENT_ID", "", false),
+		gitHubClientSecret:     r.Secret("GITHUB_CLIENT_SECRET", "", false),
+		webhookURL:             r.URL("WEBHOOK_URL", "", false),
+		notificationServiceURL: r.URL("NOTIFICATION_SERVICE_URL", "", false),
+		sentryDSN:              r.Secret("SENTRY_DSN", "", false),
+		environment:            r.String("ENVIRONMENT", "development", false),
+		debug:                  r.Bool("DEBUG", "", false),
+		logLevel:               r.String("LOG_LEVEL", "info", false, "debug", "info", "warn", "error"),
+		encryptionKey:          r.Secret("ENCRYPTION_KEY", "", false),
+		signingKey:             r.Secret("SIGNING_KEY", "", false),
+	}
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Env returns the config the accessors read from.
+func (c *AppConfig) Env() *EnvConfig {
+	return c.env
+}
+
+// DBHost returns DB_HOST.
+func (c *AppConfig) DBHost() string {
+	return c.dbHost
+}
+
+// DBPort returns DB_PORT, 5432 if unset.
+func (c *AppConfig) DBPort() int {
+	return c.dbPort
+}
+
+// DBName returns DB_NAME.
+func (c *AppConfig) DBName() string {
+	return c.dbName
+}
+
+// DBUser returns DB_USER.
+func (c *AppConfig) DBUser() string {
+	return c.dbUser
+}
+
+// DBPassword returns DB_PASSWORD.
+func (c *AppConfig) DBPassword() Secret {
+	return c.dbPassword
+}
+
+// DBMaxConnections returns DB_MAX_CONNECTIONS, 10 if unset.
+func (c *AppConfig) DBMaxConnections() int {
+	return c.dbMaxConnections
+}
+
+// RedisURL returns REDIS_URL. It may hold the password, so it is a Secret.
+func (c *AppConfig) RedisURL() Secret {
+	return c.redisURL
+}
+
+// RedisPassword returns REDIS_PASSWORD.
+func (c *AppConfig) RedisPassword() Secret {
+	return c.redisPassword
+}
+
+// JWTSecret returns JWT_SECRET.
+func (c *AppConfig) JWTSecret() Secret {
+	return c.jwtSecret
+}
+
+// APIKey returns API_KEY.
+func (c *AppConfig) APIKey() Secret {
+	return c.apiKey
+}
+
+// StripeSecretKey returns STRIPE_SECRET_KEY.
+func (c *AppConfig) StripeSecretKey() Secret {
+	return c.stripeSecretKey
+}
+
+// SendGridAPIKey returns SENDGRID_API_KEY.
+func (c *AppConfig) SendGridAPIKey() Secret {
+	return c.sendGridAPIKey
+}
+
+// GoogleClientID returns GOOGLE_CLIENT_ID.
+func (c *AppConfig) GoogleClientID() string {
+	return c.googleClientID
+}
+
+// GoogleClientSecret returns GOOGLE_CLIENT_SECRET.
+func (c *AppConfig) GoogleClientSecret() Secret {
+	return c.googleClientSecret
+}
+
+// GitHubClientID returns GITHUB_CLIENT_ID.
+func (c *AppConfig) GitHubClientID() string {
+	return c.gitHubClientID
+}
+
+// GitHubClientSecret returns GITHUB_CLIENT_SECRET.
+func (c *AppConfig) GitHubClientSecret() Secret {
+	return c.gitHubClientSecret
+}
+
+// WebhookURL returns WEBHOOK_URL.
+func (c *AppConfig) WebhookURL() *url.URL {
+	return c.webhookURL
+}
+
+// NotificationServiceURL returns NOTIFICATION_SERVICE_URL.
+func (c *AppConfig) NotificationServiceURL() *url.URL {
+	return c.notificationServiceURL
+}
+
+// SentryDSN returns SENTRY_DSN.
+func (c *AppConfig) SentryDSN() Secret {
+	return c.sentryDSN
+}
+
+// Environment returns ENVIRONMENT, development if unset.
+func (c *AppConfig) Environment() string {
+	return c.environment
+}
+
+// Debug returns DEBUG.
+func (c *AppConfig) Debug() bool {
+	return c.debug
+}
+
+// LogLevel returns LOG_LEVEL, info if unset.
+func (c *AppConfig) LogLevel() string {
+	return c.logLevel
+}
+
+// EncryptionKey returns ENCRYPTION_KEY.
+func (c *AppConfig) EncryptionKey() Secret {
+	return c.encryptionKey
+}
+
+// SigningKey returns SIGNING_KEY.
+func (c *AppConfig) SigningKey() Secret {
+	return c.signingKey
+}
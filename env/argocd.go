@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// argoCDPlugin is the ConfigManagementPlugin for the repo-server sidecar,
+// mounted as /home/argocd/cmp-server/config/plugin.yaml.
+const argoCDPlugin = `apiVersion: argoproj.io/v1alpha1
+kind: ConfigManagementPlugin
+metadata:
+  name: go-sops
+spec:
+  discover:
+    find:
+      command: [go-sops, argocd-cmp, discover]
+  generate:
+    command: [go-sops, argocd-cmp, generate]
+`
+
+// runArgoCDCMP implements an Argo CD config management plugin. Argo CD runs
+// it in the application's source directory: discover decides whether the
+// plugin applies, by printing anything, and generate prints the manifests.
+func runArgoCDCMP(args []string) error {
+	usage := errors.New("usage: argocd-cmp discover|generate|plugin")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "discover":
+		files, err := findSOPSFiles(".")
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		return nil
+	case "generate":
+		return runArgoCDGenerate(args[1:])
+	case "plugin":
+		fmt.Print(argoCDPlugin)
+		return nil
+	}
+	return usage
+}
+
+// runArgoCDGenerate fills the ${sops:KEY} placeholders of the manifests
+// from every encrypted file in the source directory. The manifests are the
+// other yaml and json files there, or the output of the command after --:
+//
+//	go-sops argocd-cmp generate -- kustomize build .
+func runArgoCDGenerate(args []string) error {
+	fs := flag.NewFlagSet("argocd-cmp generate", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "application source directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := findSOPSFiles(*dir)
+	if err != nil {
+		return err
+	}
+	// Later files override earlier ones, in path order.
+	values := map[string]string{}
+	options := newLoadOptions(nil)
+	for _, file := range files {
+		fileValues, err := readSOPSValues(context.Background(), filepath.Join(*dir, file), options)
+		if err != nil {
+			return err
+		}
+		maps.Copy(values, fileValues)
+	}
+
+	var manifests []byte
+	if render := fs.Args(); len(render) > 0 {
+		cmd := exec.Command(render[0], render[1:]...)
+		cmd.Dir = *dir
+		cmd.Stderr = os.Stderr
+		if manifests, err = cmd.Output(); err != nil {
+			return fmt.Errorf("%s failed: %w", render[0], err)
+		}
+	} else if manifests, err = readManifests(*dir); err != nil {
+		return err
+	}
+	return postRender(bytes.NewReader(manifests), os.Stdout, values)
+}
+
+// findSOPSFiles lists the encrypted env, yaml and json files below dir,
+// relative to it and in path order. Hidden directories like .git are
+// skipped.
+func findSOPSFiles(dir string) ([]string, error) {
+	var files []string
+	err := walkSource(dir, func(path string) {
+		if sopsFileName(path) && sopsSourceExt(path) {
+			files = append(files, path)
+		}
+	})
+	return files, err
+}
+
+// readManifests joins the yaml and json files below dir that aren't
+// encrypted into one multi-document stream.
+func readManifests(dir string) ([]byte, error) {
+	var manifests bytes.Buffer
+	var readErr error
+	err := walkSource(dir, func(path string) {
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return
+		}
+		if sopsFileName(path) || filepath.Base(path) == ".sops.yaml" || readErr != nil {
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			readErr = err
+			return
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return
+		}
+		if manifests.Len() > 0 {
+			manifests.WriteString("---\n")
+		}
+		manifests.Write(data)
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			manifests.WriteString("\n")
+		}
+	})
+	if err == nil {
+		err = readErr
+	}
+	return manifests.Bytes(), err
+}
+
+func walkSource(dir string, visit func(path string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		visit(rel)
+		return nil
+	})
+}
+
+func sopsSourceExt(path string) bool {
+	switch filepath.Ext(path) {
+	case ".env", ".dotenv", ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readSOPSValues decrypts an env file into its keys, or a yaml or json
+// file into its values keyed by dot path, like storage.psql.password.
+func readSOPSValues(ctx context.Context, filename string, options *loadOptions) (map[string]string, error) {
+	if ext := filepath.Ext(filename); ext == ".env" || ext == ".dotenv" {
+		return readSOPSEnvMap(ctx, filename, options)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	data, _, err := decryptGuarded(ctx, filename, options, buf)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	values := map[string]string{}
+	if len(doc.Content) > 0 {
+		walkKeyPaths(doc.Content[0], nil, func(path keyPath, leaf *yaml.Node) {
+			if leaf != nil {
+				values[path.dot()] = leaf.Value
+			}
+		})
+	}
+	return values, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArgoCDCMP(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"config.sops.env":       "DB_PASSWORD=hunter22\nTOKEN=from-env\n",
+		"secrets/app.sops.yaml": "db:\n  user: app\nTOKEN: from-yaml\n",
+		".git/old.sops.env":     "DB_PASSWORD=stale\n",
+		".sops.yaml":            "creation_rules: []\n",
+		"deployment.yaml":       "kind: Deployment\nenv:\n  password: ${sops:DB_PASSWORD}\n  user: ${sops:db.user}\n",
+		"secret.json":           `{"kind": "Secret", "data": {"token": "${sops:TOKEN|base64}"}}`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("discover", func(t *testing.T) {
+		t.Chdir(dir)
+		var err error
+		out := captureStdout(t, func() { err = runArgoCDCMP([]string{"discover"}) })
+		want := "config.sops.env\n" + filepath.Join("secrets", "app.sops.yaml") + "\n"
+		if err != nil || string(out) != want {
+			t.Errorf("discover = %q, %v; want %q", out, err, want)
+		}
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		var err error
+		out := string(captureStdout(t, func() { err = runArgoCDCMP([]string{"generate", "-dir", dir}) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The yaml file sorts after the env file, so its TOKEN wins.
+		for _, want := range []string{`password: "hunter22"`, `user: "app"`, `"token": "ZnJvbS15YW1s"`, "---"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("generate is missing %q:\n%s", want, out)
+			}
+		}
+		if strings.Contains(out, "creation_rules") {
+			t.Errorf("generate included .sops.yaml:\n%s", out)
+		}
+	})
+
+	t.Run("generate command", func(t *testing.T) {
+		var err error
+		out := string(captureStdout(t, func() {
+			err = runArgoCDCMP([]string{"generate", "-dir", dir, "--", "cat", "deployment.yaml"})
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `password: "hunter22"`) || strings.Contains(out, "Secret") {
+			t.Errorf("generate -- cat deployment.yaml =\n%s", out)
+		}
+	})
+
+	t.Run("plugin", func(t *testing.T) {
+		out := string(captureStdout(t, func() { runArgoCDCMP([]string{"plugin"}) }))
+		if out != argoCDPlugin {
+			t.Errorf("plugin =\n%s", out)
+		}
+	})
+
+	if err := runArgoCDCMP([]string{"render"}); err == nil {
+		t.Error("an unknown subcommand should fail with the usage")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// AuditEvent records that code read a secret. It never carries the value.
+// Owner comes from the key's provenance, if the file has any.
+type AuditEvent struct {
+	Key    string    `json:"key"`
+	Owner  string    `json:"owner,omitempty"`
+	Caller string    `json:"caller"`
+	Time   time.Time `json:"time"`
+}
+
+type AuditSink interface {
+	RecordAccess(event AuditEvent)
+}
+
+type AuditFunc func(event AuditEvent)
+
+func (f AuditFunc) RecordAccess(event AuditEvent) {
+	f(event)
+}
+
+type jsonAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonAuditSink) RecordAccess(event AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(event)
+}
+
+var (
+	auditMu   sync.RWMutex
+	auditSink AuditSink
+)
+
+func SetAuditSink(sink AuditSink) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditSink = sink
+}
+
+// auditAccess reports the caller skip frames above itself.
+func auditAccess(key, owner string, skip int) {
+	auditMu.RLock()
+	sink := auditSink
+	auditMu.RUnlock()
+
+	if sink == nil {
+		return
+	}
+
+	caller := "unknown"
+	if pc, file, line, ok := runtime.Caller(skip + 1); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			caller = fn.Name() + " (" + caller + ")"
+		}
+	}
+
+	sink.RecordAccess(AuditEvent{Key: key, Owner: owner, Caller: caller, Time: time.Now().UTC()})
+}
+
+// auditRemoteAccess records a read made on behalf of a remote client.
+func auditRemoteAccess(key, owner, client string) {
+	auditMu.RLock()
+	sink := auditSink
+	auditMu.RUnlock()
+
+	if sink != nil {
+		sink.RecordAccess(AuditEvent{Key: key, Owner: owner, Caller: client, Time: time.Now().UTC()})
+	}
+}
+
+func (c *EnvConfig) Get(key string) string {
+	value := c.values[key]
+	c.recordKey(key)
+	if DefaultMaskPolicy.IsSecretValue(key, value) {
+		auditAccess(key, c.provenance.owner(key), 1)
+	}
+	return value
+}
+
+// Lookup is Get that also reports whether the file assigned the key at
+// all, telling KEY= apart from a missing KEY. See WithEmptyAsUnset.
+func (c *EnvConfig) Lookup(key string) (string, bool) {
+	value, ok := c.values[key]
+	c.recordKey(key)
+	if ok && DefaultMaskPolicy.IsSecretValue(key, value) {
+		auditAccess(key, c.provenance.owner(key), 1)
+	}
+	return value, ok
+}
+
+// recordKey tells the decryptor that produced c, if it is a KeyRecorder,
+// that key was read. Unlike auditing it covers every key, secret or not.
+func (c *EnvConfig) recordKey(key string) {
+	if c.recorder != nil {
+		c.recorder.RecordKey(c.file, key)
+	}
+}
+
+func keyRecorder(options *loadOptions) KeyRecorder {
+	recorder, _ := options.decryptor.(KeyRecorder)
+	return recorder
+}
+
+func Getenv(key string) string {
+	value := os.Getenv(key)
+	if DefaultMaskPolicy.IsSecretValue(key, value) {
+		auditAccess(key, "", 1)
+	}
+	return value
+}
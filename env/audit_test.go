@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAuditRecordsSecretReads(t *testing.T) {
+	var events []AuditEvent
+	SetAuditSink(AuditFunc(func(event AuditEvent) { events = append(events, event) }))
+	t.Cleanup(func() { SetAuditSink(nil) })
+
+	config := &EnvConfig{envState: envState{values: map[string]string{"DB_HOST": "db", "DB_PASSWORD": "hunter22"}}}
+	config.Get("DB_HOST")
+	config.Get("DB_PASSWORD")
+	config.Lookup("DB_PASSWORD")
+	config.Lookup("API_KEY")
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (secret reads only): %+v", len(events), events)
+	}
+	for _, event := range events {
+		if event.Key != "DB_PASSWORD" {
+			t.Errorf("event key = %q", event.Key)
+		}
+		if !strings.Contains(event.Caller, "TestAuditRecordsSecretReads") {
+			t.Errorf("caller = %q, want this test", event.Caller)
+		}
+	}
+}
+
+func TestJSONAuditSinkOmitsValues(t *testing.T) {
+	var out bytes.Buffer
+	SetAuditSink(NewJSONAuditSink(&out))
+	t.Cleanup(func() { SetAuditSink(nil) })
+
+	t.Setenv("APP_TOKEN", "tok-0123456789")
+	if got := Getenv("APP_TOKEN"); got != "tok-0123456789" {
+		t.Fatalf("Getenv() = %q", got)
+	}
+	if strings.Contains(out.String(), "tok-0123456789") {
+		t.Errorf("audit log carries the value: %s", out.String())
+	}
+	var event AuditEvent
+	if err := json.Unmarshal(out.Bytes(), &event); err != nil {
+		t.Fatalf("audit log is not one JSON event: %v\n%s", err, out.String())
+	}
+	if event.Key != "APP_TOKEN" || event.Time.IsZero() {
+		t.Errorf("event = %+v", event)
+	}
+}
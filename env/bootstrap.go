@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// MustLoadSOPSEnv is LoadSOPSEnv for package-level variables and tests: it
+// panics if the file doesn't load.
+func MustLoadSOPSEnv(filename string, opts ...Option) *EnvConfig {
+	config, err := LoadSOPSEnv(filename, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("go-sops: load %s: %v", filename, err))
+	}
+	return config
+}
+
+// Bootstrap loads filename for main. If it fails, Bootstrap prints the
+// error, sops' full stderr and a hint to stderr and exits with status 1,
+// so mains don't each format their own log.Fatalf.
+func Bootstrap(filename string, opts ...Option) *EnvConfig {
+	config, err := LoadSOPSEnv(filename, opts...)
+	if err != nil {
+		writeStartupError(os.Stderr, filename, err)
+		os.Exit(1)
+	}
+	return config
+}
+
+func writeStartupError(w io.Writer, filename string, err error) {
+	fmt.Fprintf(w, "❌ Failed to load %s\n", filename)
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(w, "   %s\n", strings.TrimRight(line, " \t"))
+	}
+
+	var decryptErr *DecryptError
+	if !errors.As(err, &decryptErr) {
+		return
+	}
+	if decryptErr.Stderr != "" {
+		fmt.Fprintln(w, "   sops said:")
+		for _, line := range strings.Split(DefaultRedactor.Redact(decryptErr.Stderr), "\n") {
+			fmt.Fprintf(w, "     %s\n", line)
+		}
+	}
+	if decryptErr.Kind != ErrSOPSNotInstalled {
+		fmt.Fprintf(w, "💡 run `go-sops doctor %s` to check sops, keys and KMS access\n", filename)
+	}
+}
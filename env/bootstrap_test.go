@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestMustLoadSOPSEnv(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DB_HOST": "db"})
+	if config := MustLoadSOPSEnv("config.sops.env", WithDecryptor(fake)); config.DBHost != "db" {
+		t.Errorf("DBHost = %q", config.DBHost)
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.HasPrefix(msg, "go-sops: load missing.sops.env: ") {
+			t.Errorf("panic = %q", msg)
+		}
+	}()
+	fake.SetError("missing.sops.env", &DecryptError{File: "missing.sops.env", Kind: ErrFileNotFound})
+	MustLoadSOPSEnv("missing.sops.env", WithDecryptor(fake))
+	t.Error("MustLoadSOPSEnv() returned for a missing file")
+}
+
+func TestWriteStartupError(t *testing.T) {
+	DefaultRedactor.Add("startup-secret-value")
+	var out bytes.Buffer
+	writeStartupError(&out, "config.sops.env", &DecryptError{
+		File:     "config.sops.env",
+		ExitCode: 128,
+		Stderr:   "Failed to get the data key\nkey startup-secret-value rejected",
+		Kind:     ErrNoMatchingKeys,
+	})
+	for _, want := range []string{
+		"❌ Failed to load config.sops.env\n",
+		"   sops said:\n     Failed to get the data key\n     key [REDACTED] rejected\n",
+		"💡 run `go-sops doctor config.sops.env`",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "startup-secret-value") {
+		t.Errorf("output leaks a secret:\n%s", out.String())
+	}
+
+	out.Reset()
+	writeStartupError(&out, "config.sops.env", &DecryptError{File: "config.sops.env", Kind: ErrSOPSNotInstalled})
+	if strings.Contains(out.String(), "doctor") {
+		t.Errorf("doctor suggested without sops:\n%s", out.String())
+	}
+
+	out.Reset()
+	writeStartupError(&out, "config.sops.env", errors.New("DB_HOST: required\nDB_PORT: not a number"))
+	if want := "   DB_HOST: required\n   DB_PORT: not a number\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("output = %q, want every line indented", out.String())
+	}
+}
+
+func TestBootstrapExits(t *testing.T) {
+	if filename := os.Getenv("GO_SOPS_TEST_BOOTSTRAP"); filename != "" {
+		Bootstrap(filename)
+		return
+	}
+	missing := filepath.Join(t.TempDir(), "config.sops.env")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBootstrapExits$")
+	cmd.Env = append(os.Environ(), "GO_SOPS_TEST_BOOTSTRAP="+missing)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Bootstrap() exited with %v, want status 1", err)
+	}
+	if !strings.Contains(stderr.String(), "❌ Failed to load "+missing) {
+		t.Errorf("stderr = %s", stderr.String())
+	}
+}
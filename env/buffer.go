@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffers larger than this are dropped instead of pooled, so one huge file
+// doesn't pin its memory for the life of the process.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool recycles the buffers sops output is read into, so a watcher
+// reloading every few seconds doesn't regrow them on each decryption.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer wipes the buffer's whole capacity before pooling it, since it
+// held decrypted secrets.
+func putBuffer(b *bytes.Buffer) {
+	data := b.Bytes()
+	clear(data[:cap(data)])
+	b.Reset()
+	if b.Cap() <= maxPooledBuffer {
+		bufferPool.Put(b)
+	}
+}
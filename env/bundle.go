@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const bundleManifestName = "bundle.json"
+
+// ErrBundleCorrupt means a bundle doesn't match its manifest. The manifest
+// isn't signed, so this catches damaged or incomplete archives, not a
+// deliberate change: whoever rewrites a member can rewrite its checksum too.
+var ErrBundleCorrupt = errors.New("bundle member does not match its manifest")
+
+// BundleManifest lists the files of a bundle. It is the first entry of the
+// archive and can be read without any key.
+type BundleManifest struct {
+	Version int          `json:"version"`
+	Created time.Time    `json:"created"`
+	Files   []BundleFile `json:"files"`
+}
+
+// BundleFile is one encrypted file of a bundle. Name is its path inside the
+// bundle, as it was packed.
+type BundleFile struct {
+	Name     string   `json:"name"`
+	Size     int64    `json:"size"`
+	SHA256   string   `json:"sha256"`
+	Backends []string `json:"backends"`
+}
+
+// PackBundle writes the sops-encrypted files to one gzipped tar archive at
+// out, with a manifest. The files stay encrypted, each with its own
+// recipients; plaintext files are refused. Relative paths keep their
+// directories inside the bundle, others are stored under their base name.
+func PackBundle(out string, files ...string) (*BundleManifest, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no files to bundle")
+	}
+	manifest := &BundleManifest{Version: 1, Created: time.Now().UTC()}
+	contents := make([][]byte, 0, len(files))
+	seen := map[string]bool{}
+	for _, file := range files {
+		name := filepath.ToSlash(filepath.Clean(file))
+		if !filepath.IsLocal(file) {
+			name = filepath.Base(file)
+		}
+		if name == bundleManifestName || seen[name] {
+			return nil, fmt.Errorf("%s: two files would be stored as %s", file, name)
+		}
+		seen[name] = true
+
+		meta, err := readSOPSMetadata(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not sops-encrypted: %w", file, err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, BundleFile{
+			Name:     name,
+			Size:     int64(len(data)),
+			SHA256:   hex.EncodeToString(sum[:]),
+			Backends: meta.Backends,
+		})
+		contents = append(contents, data)
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries := append([][]byte{encoded}, contents...)
+	for i, data := range entries {
+		name := bundleManifestName
+		if i > 0 {
+			name = manifest.Files[i-1].Name
+		}
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(out, archive.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// readBundle returns the manifest and the encrypted members of a bundle,
+// checked against the manifest's hashes.
+func readBundle(filename string) (*BundleManifest, map[string][]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a bundle: %w", filename, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *BundleManifest
+	members := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		if manifest == nil {
+			if header.Name != bundleManifestName {
+				return nil, nil, fmt.Errorf("%s is not a bundle: no %s", filename, bundleManifestName)
+			}
+			manifest = &BundleManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid manifest: %w", filename, err)
+			}
+			continue
+		}
+		members[header.Name] = data
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%s is not a bundle: no %s", filename, bundleManifestName)
+	}
+
+	for _, file := range manifest.Files {
+		data, ok := members[file.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s is missing", ErrBundleCorrupt, file.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, nil, fmt.Errorf("%w: %s has changed", ErrBundleCorrupt, file.Name)
+		}
+	}
+	if len(members) != len(manifest.Files) {
+		return nil, nil, fmt.Errorf("%w: it holds files the manifest doesn't list", ErrBundleCorrupt)
+	}
+	return manifest, members, nil
+}
+
+// Bundle is a decrypted bundle, mounted as a read-only fs.FS. Files are
+// named without their .sops part, so certs/tls.sops.crt reads as
+// certs/tls.crt:
+//
+//	bundle, err := OpenBundle(ctx, "app.bundle")
+//	cert, err := fs.ReadFile(bundle, "certs/tls.crt")
+//	env, err := fs.ReadFile(bundle, "config.env")
+//
+// The plaintext only lives in memory.
+type Bundle struct {
+	manifest *BundleManifest
+	files    map[string][]byte
+}
+
+// OpenBundle checks every member of the bundle against its manifest and
+// decrypts it with the same options as the loaders. Each member is
+// written, still encrypted, to a temporary directory under its own name
+// for sops, so a custom Decryptor sees that path.
+func OpenBundle(ctx context.Context, filename string, opts ...Option) (*Bundle, error) {
+	options := newLoadOptions(opts)
+	manifest, members, err := readBundle(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "go-sops-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	b := &Bundle{manifest: manifest, files: make(map[string][]byte, len(members))}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for i, file := range manifest.Files {
+		member := filepath.Join(dir, fmt.Sprint(i), path.Base(file.Name))
+		if err := os.MkdirAll(filepath.Dir(member), 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(member, members[file.Name], 0o600); err != nil {
+			return nil, err
+		}
+
+		buf.Reset()
+		if err := decryptSOPSFile(ctx, member, options, buf); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		name := plaintextName(file.Name)
+		if _, ok := b.files[name]; ok {
+			return nil, fmt.Errorf("%s: two files in the bundle decrypt to %s", filename, name)
+		}
+		b.files[name] = bytes.Clone(buf.Bytes())
+	}
+	return b, nil
+}
+
+// Manifest returns the manifest the bundle was checked against.
+func (b *Bundle) Manifest() *BundleManifest {
+	return b.manifest
+}
+
+func (b *Bundle) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := b.files[name]; ok {
+		return &bundleFile{info: b.info(name, int64(len(data)), false), Reader: bytes.NewReader(data)}, nil
+	}
+	entries, err := b.ReadDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &bundleDir{info: b.info(name, 0, true), entries: entries}, nil
+}
+
+func (b *Bundle) ReadFile(name string) ([]byte, error) {
+	data, ok := b.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	return bytes.Clone(data), nil
+}
+
+// ReadDir lists a directory, which exists as long as a file is under it.
+func (b *Bundle) ReadDir(name string) ([]fs.DirEntry, error) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := map[string]fs.FileInfo{}
+	for file, data := range b.files {
+		rest, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			children[child] = b.info(child, 0, true)
+		} else {
+			children[child] = b.info(child, int64(len(data)), false)
+		}
+	}
+	if len(children) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range slices.Sorted(maps.Keys(children)) {
+		entries = append(entries, fs.FileInfoToDirEntry(children[child]))
+	}
+	return entries, nil
+}
+
+func (b *Bundle) info(name string, size int64, dir bool) fs.FileInfo {
+	return bundleInfo{name: path.Base(name), size: size, dir: dir, modTime: b.manifest.Created}
+}
+
+type bundleInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i bundleInfo) Name() string       { return i.name }
+func (i bundleInfo) Size() int64        { return i.size }
+func (i bundleInfo) ModTime() time.Time { return i.modTime }
+func (i bundleInfo) IsDir() bool        { return i.dir }
+func (i bundleInfo) Sys() any           { return nil }
+
+func (i bundleInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o500
+	}
+	return 0o400
+}
+
+type bundleFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *bundleFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *bundleFile) Close() error               { return nil }
+
+type bundleDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *bundleDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *bundleDir) Close() error               { return nil }
+
+func (d *bundleDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *bundleDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// runBundle packs encrypted files into a bundle or lists one's manifest.
+func runBundle(args []string) error {
+	if len(args) > 0 && args[0] == "list" {
+		if len(args) != 2 {
+			return errors.New("usage: bundle list app.bundle")
+		}
+		manifest, _, err := readBundle(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📦 %s, packed %s\n", args[1], manifest.Created.Format(time.RFC3339))
+		for _, file := range manifest.Files {
+			fmt.Printf("   %-32s %8d bytes  %s\n", file.Name, file.Size, strings.Join(file.Backends, ","))
+		}
+		return nil
+	}
+	if len(args) == 0 || args[0] != "pack" {
+		return errors.New("usage: bundle pack -o app.bundle files... | bundle list app.bundle")
+	}
+
+	fs := flag.NewFlagSet("bundle pack", flag.ContinueOnError)
+	out := fs.String("o", "secrets.bundle", "bundle to write")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	manifest, err := PackBundle(*out, fs.Args()...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📦 Packed %d encrypted files into %s\n", len(manifest.Files), *out)
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestBundle writes a bundle of members with a manifest listing files.
+func writeTestBundle(t *testing.T, files []BundleFile, members map[string]string) string {
+	t.Helper()
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest, err := json.Marshal(BundleManifest{Version: 1, Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(bundleManifestName, manifest)
+	for name, data := range members {
+		write(name, []byte(data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.bundle")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func bundleFileOf(name, data string) BundleFile {
+	sum := sha256.Sum256([]byte(data))
+	return BundleFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func TestReadBundleCorrupt(t *testing.T) {
+	const env = "DB_PASSWORD=ENC[AES256_GCM,data:x]\n"
+	tests := []struct {
+		name    string
+		files   []BundleFile
+		members map[string]string
+		wantErr string
+	}{
+		{
+			name:    "changed",
+			files:   []BundleFile{bundleFileOf("config.sops.env", env)},
+			members: map[string]string{"config.sops.env": env[:10]},
+			wantErr: "config.sops.env has changed",
+		},
+		{
+			name:    "missing",
+			files:   []BundleFile{bundleFileOf("config.sops.env", env), bundleFileOf("app.sops.yaml", "a: b\n")},
+			members: map[string]string{"config.sops.env": env},
+			wantErr: "app.sops.yaml is missing",
+		},
+		{
+			name:    "unlisted",
+			files:   []BundleFile{bundleFileOf("config.sops.env", env)},
+			members: map[string]string{"config.sops.env": env, "extra.sops.env": env},
+			wantErr: "it holds files the manifest doesn't list",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readBundle(writeTestBundle(t, tt.files, tt.members))
+			if !errors.Is(err, ErrBundleCorrupt) {
+				t.Fatalf("readBundle() error = %v, want ErrBundleCorrupt", err)
+			}
+			if want := ErrBundleCorrupt.Error() + ": " + tt.wantErr; err.Error() != want {
+				t.Errorf("readBundle() error = %q, want %q", err, want)
+			}
+			if got := ErrorCategory(err); got != "bundle_corrupt" {
+				t.Errorf("ErrorCategory() = %q, want bundle_corrupt", got)
+			}
+		})
+	}
+
+	files := []BundleFile{bundleFileOf("config.sops.env", env)}
+	_, members, err := readBundle(writeTestBundle(t, files, map[string]string{"config.sops.env": env}))
+	if err != nil {
+		t.Fatalf("readBundle() error = %v", err)
+	}
+	if members["config.sops.env"] == nil {
+		t.Error("readBundle() members lack config.sops.env")
+	}
+}
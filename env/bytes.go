@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// GetBytes base64-decodes the value of key, for secrets such as a service
+// account JSON or a DER certificate stored as base64. Standard and URL-safe
+// alphabets are accepted, with or without padding, and line breaks from
+// `base64` output are ignored. The error never includes the value.
+func (c *EnvConfig) GetBytes(key string) ([]byte, error) {
+	value, ok := c.Lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	return decodeBase64(key, value)
+}
+
+func decodeBase64(key, value string) ([]byte, error) {
+	value = strings.Join(strings.Fields(value), "")
+	value = strings.TrimRight(value, "=")
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(value, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	data, err := encoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidValue(key, "base64 value")
+	}
+	return data, nil
+}
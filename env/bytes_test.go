@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGetBytes(t *testing.T) {
+	data := []byte("{\"type\": \"service_account\"}\xfb\xff")
+	std := base64.StdEncoding.EncodeToString(data)
+	config := &EnvConfig{envState: envState{values: map[string]string{
+		"STD":     std,
+		"URL":     base64.RawURLEncoding.EncodeToString(data),
+		"WRAPPED": std[:12] + "\n" + std[12:24] + "\r\n  " + std[24:],
+		"BAD":     "not*base64",
+	}}}
+
+	for _, key := range []string{"STD", "URL", "WRAPPED"} {
+		got, err := config.GetBytes(key)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("GetBytes(%q) = %q, %v", key, got, err)
+		}
+	}
+	if _, err := config.GetBytes("MISSING"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("GetBytes(MISSING) = %v, want ErrKeyNotFound", err)
+	}
+	_, err := config.GetBytes("BAD")
+	if err == nil || err.Error() != "BAD is not a valid base64 value" {
+		t.Errorf("GetBytes(BAD) = %v", err)
+	}
+}
+
+func TestManifestBase64Keys(t *testing.T) {
+	config := &EnvConfig{envState: envState{values: map[string]string{
+		"CERT": base64.StdEncoding.EncodeToString([]byte{0x30, 0x82}),
+		"KEY":  "not*base64",
+	}}}
+	r := &manifestReader{env: config}
+	if got := r.Bytes("CERT", "", true); !bytes.Equal(got, []byte{0x30, 0x82}) {
+		t.Errorf("Bytes(CERT) = %x", got)
+	}
+	r.Bytes("KEY", "", false)
+	r.Bytes("CA", "", true)
+	err := r.err()
+	if !errors.Is(err, ErrKeyNotFound) || !strings.Contains(err.Error(), "KEY is not a valid base64 value") {
+		t.Errorf("err() = %v", err)
+	}
+	if err := checkManifestValue(ManifestKey{Name: "KEY", Type: "base64"}, "not*base64"); err == nil {
+		t.Error("checkManifestValue() accepted invalid base64")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// CanaryEvent reports that a canary value reached a log line. Canaries are
+// values no code should ever log, so any event means a leak path exists.
+type CanaryEvent struct {
+	Key  string
+	Time time.Time
+}
+
+// WithCanaries marks keys whose values are honeytokens. Their values are
+// always redacted, and the redactor's canary handler fires when one appears
+// in log output.
+func WithCanaries(keys ...string) Option {
+	return func(o *loadOptions) {
+		o.canaries = append(o.canaries, keys...)
+	}
+}
+
+func (r *Redactor) AddCanary(key, value string) {
+	if value == "" {
+		return
+	}
+	r.Add(value)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.canaries == nil {
+		r.canaries = make(map[string]string)
+	}
+	r.canaries[value] = key
+}
+
+// OnCanary sets the function called when a canary value is redacted. The
+// default logs an error through slog.
+func (r *Redactor) OnCanary(handler func(CanaryEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCanary = handler
+}
+
+func (r *Redactor) checkCanaries(s string) {
+	r.mu.RLock()
+	var tripped []string
+	for value, key := range r.canaries {
+		if strings.Contains(s, value) {
+			tripped = append(tripped, key)
+		}
+	}
+	handler := r.onCanary
+	r.mu.RUnlock()
+
+	for _, key := range tripped {
+		event := CanaryEvent{Key: key, Time: time.Now().UTC()}
+		if handler != nil {
+			handler(event)
+			continue
+		}
+		// Written to the default logger, whose handler may itself redact;
+		// the key name is not a secret, so this cannot trip again.
+		slog.Error("canary secret emitted to logs", "canary_key", key)
+	}
+}
+
+func registerCanaries(envMap map[string]string, keys []string) {
+	for _, key := range keys {
+		if value, ok := envMap[key]; ok {
+			DefaultRedactor.AddCanary(key, value)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestCanaryTripsOnLogging(t *testing.T) {
+	var events []CanaryEvent
+	DefaultRedactor.OnCanary(func(event CanaryEvent) { events = append(events, event) })
+	t.Cleanup(func() { DefaultRedactor.OnCanary(nil) })
+
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"LEGACY_BUCKET": "canary-bucket-7f3a", "DB_HOST": "db"})
+	if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake), WithCanaries("LEGACY_BUCKET")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&out, nil), DefaultRedactor))
+	logger.Info("connected", "host", "db")
+	if len(events) != 0 {
+		t.Fatalf("canary tripped by a clean line: %+v", events)
+	}
+
+	logger.Info("listing canary-bucket-7f3a")
+	if strings.Contains(out.String(), "canary-bucket-7f3a") {
+		t.Errorf("canary value reached the log: %s", out.String())
+	}
+	if len(events) != 1 || events[0].Key != "LEGACY_BUCKET" || events[0].Time.IsZero() {
+		t.Errorf("events = %+v, want one for LEGACY_BUCKET", events)
+	}
+}
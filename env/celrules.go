@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrRuleFailed = errors.New("config rule failed")
+
+// Rule is a CEL expression the config must satisfy, such as
+//
+//	has(env.REDIS_PASSWORD) || env.ENVIRONMENT == 'dev'
+//	int(env.DB_PORT) > 0 && int(env.DB_PORT) < 65536
+//
+// env maps every key of the file to its value, as strings.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Expr    string `yaml:"expr"`
+	Message string `yaml:"message"`
+}
+
+// Rules is a compiled set of Rule, for teams that want validation without
+// a policy engine. See WithRules.
+type Rules struct {
+	rules    []Rule
+	programs []cel.Program
+}
+
+// RuleFailure is a rule that rejected the config, or a field whose cel or
+// validate tag did.
+type RuleFailure struct {
+	Rule    string `json:"rule"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// RuleError lists the rules a config failed.
+type RuleError struct {
+	File     string
+	Failures []RuleFailure
+}
+
+func (e *RuleError) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		if f.Key != "" {
+			messages = append(messages, f.Key+": "+f.Message)
+		} else {
+			messages = append(messages, f.Message)
+		}
+	}
+	if e.File == "" {
+		return "config rules failed: " + strings.Join(messages, "; ")
+	}
+	return fmt.Sprintf("%s fails config rules: %s", e.File, strings.Join(messages, "; "))
+}
+
+func (e *RuleError) Unwrap() error {
+	return ErrRuleFailed
+}
+
+var envRuleEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("env", cel.MapType(cel.StringType, cel.StringType)))
+})
+
+// NewRules compiles rules. A rule without a message fails with its
+// expression.
+func NewRules(rules ...Rule) (*Rules, error) {
+	env, err := envRuleEnv()
+	if err != nil {
+		return nil, err
+	}
+	r := &Rules{}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		program, err := compileRule(env, rule.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.Name, err)
+		}
+		r.rules = append(r.rules, rule)
+		r.programs = append(r.programs, program)
+	}
+	return r, nil
+}
+
+// LoadRules reads a rules file:
+//
+//	rules:
+//	  - name: redis-auth
+//	    expr: has(env.REDIS_PASSWORD) || env.ENVIRONMENT == 'dev'
+//	    message: REDIS_PASSWORD is required outside dev
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	rules, err := NewRules(file.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func compileRule(env *cel.Env, expr string) (cel.Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("%q returns %s, not bool", expr, ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// WithRules rejects configs that fail a rule, with a *RuleError.
+func WithRules(rules *Rules) Option {
+	return func(o *loadOptions) {
+		o.rules = rules
+	}
+}
+
+// Check evaluates every rule against envMap.
+func (r *Rules) Check(envMap map[string]string) []RuleFailure {
+	var failures []RuleFailure
+	for i, program := range r.programs {
+		rule := r.rules[i]
+		if ok, err := evalRule(program, map[string]any{"env": envMap}); !ok {
+			// A conversion error can quote the value it failed on.
+			message := maskValues(ruleMessage(rule.Message, rule.Expr, err), envMap)
+			failures = append(failures, RuleFailure{Rule: rule.Name, Message: message})
+		}
+	}
+	return failures
+}
+
+func applyRules(filename string, envMap map[string]string, options *loadOptions) error {
+	if options.rules == nil {
+		return nil
+	}
+	if failures := options.rules.Check(envMap); len(failures) > 0 {
+		return &RuleError{File: filename, Failures: failures}
+	}
+	return nil
+}
+
+// evalRule treats an evaluation error, like a missing key, as a failure.
+func evalRule(program cel.Program, vars map[string]any) (bool, error) {
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("returned %v, not a bool", out.Value())
+	}
+	return ok, nil
+}
+
+func ruleMessage(message, expr string, err error) string {
+	if message == "" {
+		message = "fails " + expr
+	}
+	if err != nil {
+		message += " (" + err.Error() + ")"
+	}
+	return message
+}
+
+// fieldRules caches compiled cel tags by expression.
+var fieldRules sync.Map
+
+var fieldRuleEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("self", cel.DynType))
+})
+
+// checkFieldRules evaluates the cel tags of the fields ProcessSOPSEnv
+// filled, with self bound to the field's value:
+//
+//	Port    int           `cel:"self > 0 && self < 65536"`
+//	Timeout time.Duration `cel:"self <= duration('30s')" cel_message:"at most 30s"`
+//
+// Evaluation errors can quote the value, so secrets of envMap are masked
+// in the messages as in Rules.Check.
+func checkFieldRules(fields []envconfigField, envMap map[string]string) ([]RuleFailure, error) {
+	var failures []RuleFailure
+	for _, info := range fields {
+		expr := info.tags.Get("cel")
+		if expr == "" {
+			continue
+		}
+		program, err := fieldRule(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid cel tag: %w", info.name, err)
+		}
+		if ok, err := evalRule(program, map[string]any{"self": celValue(info.field)}); !ok {
+			failures = append(failures, RuleFailure{Rule: expr, Key: info.key, Message: maskValues(ruleMessage(info.tags.Get("cel_message"), expr, err), envMap)})
+		}
+	}
+	return failures, nil
+}
+
+func fieldRule(expr string) (cel.Program, error) {
+	if program, ok := fieldRules.Load(expr); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := fieldRuleEnv()
+	if err != nil {
+		return nil, err
+	}
+	program, err := compileRule(env, expr)
+	if err != nil {
+		return nil, err
+	}
+	fieldRules.Store(expr, program)
+	return program, nil
+}
+
+// celValue converts named types, like a string-based enum, to their
+// underlying kind, which is what CEL knows.
+func celValue(field reflect.Value) any {
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Bool:
+		return field.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if d, ok := field.Interface().(time.Duration); ok {
+			return d
+		}
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.Uint()
+	case reflect.Float32, reflect.Float64:
+		return field.Float()
+	}
+	return field.Interface()
+}
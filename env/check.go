@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// checkReport is what check found, printed as JSON with --output json.
+type checkReport struct {
+	File     string        `json:"file"`
+	Keys     int           `json:"keys"`
+	Manifest string        `json:"manifest,omitempty"`
+	Schema   *SchemaReport `json:"schema,omitempty"`
+	Expiries []checkExpiry `json:"expiries"`
+}
+
+// checkFailure is printed with --output json instead of a checkReport
+// when the file can't be checked at all, e.g. because it doesn't decrypt.
+type checkFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+type checkExpiry struct {
+	Key     string `json:"key"`
+	Expires string `json:"expires"`
+	// Status is expired, expiring (within -within) or ok.
+	Status   string `json:"status"`
+	DaysLeft int    `json:"days_left"`
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to check")
+	within := fs.Duration("within", DefaultExpiryWindow, "warn about secrets expiring within this duration")
+	manifest := fs.String("manifest", "", "fail on keys the manifest doesn't declare and declared keys the file lacks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		if jsonOutput() {
+			if jsonErr := writeJSON(checkFailure{File: *filename, Error: err.Error()}); jsonErr != nil {
+				return jsonErr
+			}
+		}
+		return err
+	}
+
+	envMap, err := readSOPSEnvMap(context.Background(), *filename, newLoadOptions(nil))
+	if err != nil {
+		return fail(err)
+	}
+	expiries, err := extractExpiries(*filename, envMap)
+	if err != nil {
+		return fail(err)
+	}
+	report := &checkReport{File: *filename, Keys: len(envMap), Manifest: *manifest, Expiries: []checkExpiry{}}
+	if *manifest != "" {
+		m, err := LoadManifest(*manifest)
+		if err != nil {
+			return fail(err)
+		}
+		report.Schema = m.Schema().Check(*filename, envMap)
+	}
+	deadline := time.Now().Add(*within)
+	for _, e := range expiries {
+		status := "ok"
+		switch {
+		case e.Expired():
+			status = "expired"
+		case e.Expires.Before(deadline):
+			status = "expiring"
+		}
+		report.Expiries = append(report.Expiries, checkExpiry{
+			Key:      e.Key,
+			Expires:  e.Expires.Format(time.DateOnly),
+			Status:   status,
+			DaysLeft: int(time.Until(e.Expires).Hours() / 24),
+		})
+	}
+
+	if jsonOutput() {
+		if err := writeJSON(report); err != nil {
+			return err
+		}
+	} else {
+		report.print()
+	}
+	return report.err()
+}
+
+func (r *checkReport) print() {
+	fmt.Printf("✅ %s decrypts (%d keys)\n", r.File, r.Keys)
+	if r.Schema != nil {
+		if r.Schema.OK() {
+			fmt.Printf("✅ every key matches %s\n", r.Manifest)
+		}
+		for _, key := range r.Schema.Unused {
+			fmt.Printf("  ⚠️  %s is not in %s, remove it if nothing reads it\n", key, r.Manifest)
+		}
+		for _, key := range r.Schema.Missing {
+			fmt.Printf("  ❌ %s is required by %s but not set\n", key, r.Manifest)
+		}
+	}
+	if len(r.Expiries) == 0 {
+		fmt.Println("ℹ️  no expiry dates set, add KEY" + ExpiresSuffix + "=YYYY-MM-DD to track rotation")
+		return
+	}
+
+	fmt.Println("\n📅 Secret expiry:")
+	for _, e := range r.Expiries {
+		switch e.Status {
+		case "expired":
+			fmt.Printf("  ❌ %s expired on %s\n", e.Key, e.Expires)
+		case "expiring":
+			fmt.Printf("  ⚠️  %s expires on %s (%d days)\n", e.Key, e.Expires, e.DaysLeft)
+		default:
+			fmt.Printf("  ✅ %s expires on %s\n", e.Key, e.Expires)
+		}
+	}
+}
+
+func (r *checkReport) err() error {
+	var errs []error
+	if r.Schema != nil && !r.Schema.OK() {
+		errs = append(errs, fmt.Errorf("%d unused and %d missing key(s) in %s", len(r.Schema.Unused), len(r.Schema.Missing), r.File))
+	}
+	expired := 0
+	for _, e := range r.Expiries {
+		if e.Status == "expired" {
+			expired++
+		}
+	}
+	if expired > 0 {
+		errs = append(errs, fmt.Errorf("%d expired secret(s) in %s", expired, r.File))
+	}
+	return errors.Join(errs...)
+}
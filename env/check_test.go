@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// captureStdout returns what run writes to os.Stdout.
+func captureStdout(t *testing.T, run func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	run()
+	w.Close()
+	return <-done
+}
+
+func TestCheckJSONReportsDecryptFailure(t *testing.T) {
+	installSOPSScript(t, `echo "Failed to get the data key required to decrypt the SOPS file." >&2; exit 128`)
+	previous := outputFormat
+	outputFormat = "json"
+	defer func() { outputFormat = previous }()
+
+	file := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(file, []byte("KEY=ENC[...]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var runErr error
+	out := captureStdout(t, func() { runErr = runCheck([]string{"-f", file}) })
+	if runErr == nil {
+		t.Fatal("check of a file that doesn't decrypt succeeded")
+	}
+
+	var failure checkFailure
+	if err := json.Unmarshal(out, &failure); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, out)
+	}
+	if failure.File != file || failure.Error != runErr.Error() {
+		t.Errorf("JSON = %+v, want file %s and error %q", failure, file, runErr)
+	}
+}
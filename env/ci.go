@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// runGHA exports the decrypted keys to later steps of a GitHub Actions job.
+// Secret values are masked first, so the runner hides them from logs even
+// if a later step prints them.
+func runGHA(args []string) error {
+	fs := flag.NewFlagSet("gha", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to export")
+	toEnv := fs.Bool("env", true, "write the keys to $GITHUB_ENV")
+	toOutput := fs.Bool("output", false, "write the keys to $GITHUB_OUTPUT as step outputs")
+	maskAll := fs.Bool("mask-all", false, "mask every value, not only those classified as secrets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envMap, err := readSOPSEnvMap(context.Background(), *filename, newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+	keys := slices.Sorted(maps.Keys(envMap))
+
+	for _, key := range keys {
+		if *maskAll || DefaultMaskPolicy.IsSecretValue(key, envMap[key]) {
+			writeGHAMask(os.Stdout, envMap[key])
+		}
+	}
+
+	targets := map[string]bool{"GITHUB_ENV": *toEnv, "GITHUB_OUTPUT": *toOutput}
+	for _, name := range []string{"GITHUB_ENV", "GITHUB_OUTPUT"} {
+		if !targets[name] {
+			continue
+		}
+		path := os.Getenv(name)
+		if path == "" {
+			return fmt.Errorf("$%s is not set, run this inside a GitHub Actions step", name)
+		}
+		if err := appendGHAFile(path, keys, envMap); err != nil {
+			return fmt.Errorf("failed to write $%s: %w", name, err)
+		}
+	}
+
+	fmt.Printf("✅ Exported %d keys from %s\n", len(keys), *filename)
+	return nil
+}
+
+// writeGHAMask masks each line separately, since the runner matches masks
+// line by line.
+func writeGHAMask(w io.Writer, value string) {
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			fmt.Fprintf(w, "::add-mask::%s\n", ghaEscapeData(line))
+		}
+	}
+}
+
+// ghaEscaper escapes the data of a workflow command. The runner decodes
+// %25, %0D and %0A, so without it a secret containing them would have a
+// different string masked and show up in logs.
+var ghaEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+func ghaEscapeData(s string) string {
+	return ghaEscaper.Replace(s)
+}
+
+// appendGHAFile writes KEY<<DELIM blocks, which hold any value including
+// multi-line ones.
+func appendGHAFile(path string, keys []string, envMap map[string]string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, key := range keys {
+		value := envMap[key]
+		delim, err := ghaDelimiter(value)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", key, delim, value, delim)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ghaDelimiter(value string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	delim := "ghadelimiter_" + hex.EncodeToString(b)
+	if strings.Contains(value, delim) {
+		return "", errors.New("value contains the generated delimiter")
+	}
+	return delim, nil
+}
+
+// GitLab rejects dotenv reports over this size; the default instance limit
+// on the number of variables is gitlabDotenvMaxVars.
+const (
+	gitlabDotenvMaxSize = 5 << 10
+	gitlabDotenvMaxVars = 20
+)
+
+// runGitLabDotenv writes a report for artifacts:reports:dotenv, so later
+// jobs in the pipeline inherit the decrypted variables.
+func runGitLabDotenv(args []string) error {
+	fs := flag.NewFlagSet("gitlab-dotenv", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to export")
+	out := fs.String("o", "deploy.env", "report file to write")
+	only := fs.String("keys", "", "comma-separated keys to export, defaults to all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envMap, err := readSOPSEnvMap(context.Background(), *filename, newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+
+	keys := slices.Sorted(maps.Keys(envMap))
+	if *only != "" {
+		keys = nil
+		for _, key := range strings.Split(*only, ",") {
+			key = strings.TrimSpace(key)
+			if _, ok := envMap[key]; !ok {
+				return fmt.Errorf("key %s not found in %s", key, *filename)
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	report, err := gitlabDotenv(keys, envMap)
+	if err != nil {
+		return err
+	}
+	if len(report) > gitlabDotenvMaxSize {
+		return fmt.Errorf("report is %d bytes, GitLab accepts at most %d; select fewer keys with -keys", len(report), gitlabDotenvMaxSize)
+	}
+	if len(keys) > gitlabDotenvMaxVars {
+		slog.Warn("dotenv report exceeds GitLab's default variable limit", "variables", len(keys), "limit", gitlabDotenvMaxVars)
+	}
+
+	if err := os.WriteFile(*out, report, 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %d keys from %s to %s\n", len(keys), *filename, *out)
+	return nil
+}
+
+// gitlabDotenv renders KEY=VALUE lines in the subset GitLab parses: no
+// comments, no export, no multi-line values and keys of [A-Za-z0-9_] only.
+func gitlabDotenv(keys []string, envMap map[string]string) ([]byte, error) {
+	var b strings.Builder
+	for _, key := range keys {
+		value := envMap[key]
+		if !validGitLabKey(key) {
+			return nil, fmt.Errorf("key %s is not a valid GitLab variable name", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("key %s has a multi-line value, which GitLab dotenv reports don't support", key)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+func validGitLabKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
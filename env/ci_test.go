@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteGHAMask(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "hunter2", "::add-mask::hunter2\n"},
+		{"percent", "p%25ss%0Aword%", "::add-mask::p%2525ss%250Aword%25\n"},
+		{"carriage return inside", "a\rb", "::add-mask::a%0Db\n"},
+		{"lines", "line 1\r\n\nline 2\n", "::add-mask::line 1\n::add-mask::line 2\n"},
+		{"blank", "  ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeGHAMask(&b, tt.value)
+			if b.String() != tt.want {
+				t.Errorf("writeGHAMask(%q) = %q, want %q", tt.value, b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGitLabDotenv(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	report := filepath.Join(dir, "deploy.env")
+	os.WriteFile(filename, []byte("DB_HOST=db\nDB_PASSWORD='a#b c'\nCERT=\"line1\\nline2\"\nAPP.NAME=x\n"), 0o600)
+
+	captureStdout(t, func() {
+		if err := runGitLabDotenv([]string{"-f", filename, "-o", report, "-keys", "DB_PASSWORD, DB_HOST"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	data, _ := os.ReadFile(report)
+	if string(data) != "DB_PASSWORD=a#b c\nDB_HOST=db\n" {
+		t.Errorf("report = %q", data)
+	}
+	if info, _ := os.Stat(report); info.Mode().Perm() != 0o600 {
+		t.Errorf("report mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	for keys, want := range map[string]string{
+		"CERT":     "multi-line value",
+		"APP.NAME": "not a valid GitLab variable name",
+		"API_KEY":  "key API_KEY not found",
+	} {
+		err := runGitLabDotenv([]string{"-f", filename, "-o", filepath.Join(dir, "other.env"), "-keys", keys})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("-keys %s: error = %v, want %q", keys, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.env")); err == nil {
+		t.Error("a rejected report was written")
+	}
+}
+
+func TestGitLabDotenvSizeLimit(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	os.WriteFile(filename, []byte("BIG="+strings.Repeat("x", gitlabDotenvMaxSize)+"\n"), 0o600)
+	err := runGitLabDotenv([]string{"-f", filename, "-o", filepath.Join(dir, "deploy.env")})
+	if err == nil || !strings.Contains(err.Error(), "GitLab accepts at most") {
+		t.Errorf("error = %v, want the size limit", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"age-report": {
+		usage: "age-report [-json] [-sort age|name] [-max-age 2160h] [files...]",
+		run:   runAgeReport,
+	},
+	"argocd-cmp": {
+		usage: "argocd-cmp discover|generate [-dir .] [-- kustomize build .]|plugin",
+		run:   runArgoCDCMP,
+	},
+	"bundle": {
+		usage: "bundle pack [-o secrets.bundle] files... | bundle list secrets.bundle",
+		run:   runBundle,
+	},
+	"check": {
+		usage: "check [-f config.sops.env] [-within 336h] [-manifest config.manifest.yaml]",
+		run:   runCheck,
+	},
+	"completion": {
+		usage: "completion bash|zsh|fish",
+		run:   runCompletion,
+	},
+	"csi-provider": {
+		usage: "csi-provider [-socket path] [-root /sops] [-namespaced=true]",
+		run:   runCSIProvider,
+	},
+	"direnv": {
+		usage: "direnv [-watch .sops.yaml] [files...]",
+		run:   runDirenv,
+	},
+	"doctor": {
+		usage: "doctor [-no-decrypt] [-timeout 2m] [files...]",
+		run:   runDoctor,
+	},
+	"exec": {
+		usage: "exec -f config.sops.env [-watch] [-signal HUP] -- <command> [args...]",
+		run:   runExec,
+	},
+	"gen": {
+		usage: "gen [-manifest config.manifest.yaml] [-envconfig] [-o appconfig_gen.go] [-package main]",
+		run:   runGen,
+	},
+	"get": {
+		usage: "get [-f config.sops.env] <key>",
+		run:   runGet,
+	},
+	"gha": {
+		usage: "gha [-f config.sops.env] [-env=true] [-output] [-mask-all]",
+		run:   runGHA,
+	},
+	"gitlab-dotenv": {
+		usage: "gitlab-dotenv [-f config.sops.env] [-o deploy.env] [-keys A,B]",
+		run:   runGitLabDotenv,
+	},
+	"gitops-diff": {
+		usage: "gitops-diff [-base origin/main] [-head HEAD] [-webhook url] [-github-pr N] [files...]",
+		run:   runGitOpsDiff,
+	},
+	"helm-postrender": {
+		usage: "helm-postrender [-f config.sops.env] < manifests.yaml",
+		run:   runHelmPostRender,
+	},
+	"import": {
+		usage: "import vault -path secret/app [-o config.sops.env] [-recipients age1...] [-upper] [-force]",
+		run:   runImport,
+	},
+	"init": {
+		usage: "init [-dir .] [-aws-kms arn] [-gcp-kms resource-id] [-force]",
+		run:   runInit,
+	},
+	"k8s-init": {
+		usage: "k8s-init [-src /sops] [-dst /secrets] [-mode 0400]",
+		run:   runK8sInit,
+	},
+	"keys": {
+		usage: "keys [-f config.sops.env] [-format dot|extract] [-all]",
+		run:   runKeys,
+	},
+	"lambda-extension": {
+		usage: "lambda-extension [-f /opt/config.sops.env] [-addr 127.0.0.1:2775]",
+		run:   runLambdaExtension,
+	},
+	"migrate": {
+		usage: "migrate [-recipients age1...] [-pgp fingerprint] [-force] <files...>",
+		run:   runMigrate,
+	},
+	"nomad": {
+		usage: "nomad -f config.sops.env [-destination path] [-change-mode restart] [-task name] [-once]",
+		run:   runNomad,
+	},
+	"operator": {
+		usage: "operator [-api-server url] [-namespace ns] [-interval 1m] [-allow-git ns=repo,...] [-allow-keys ns=key,...] [-print-crd]",
+		run:   runOperator,
+	},
+	"scan": {
+		usage: "scan [files...]",
+		run:   runScan,
+	},
+	"syncd": {
+		usage: "syncd -remote vault:secret/app [-f config.sops.env] [-pull] [-prefer file|remote] [-interval 30s] [-once] [-report sync.json]",
+		run:   runSyncd,
+	},
+	"tf-external": {
+		usage: "tf-external < query.json",
+		run:   runTFExternal,
+	},
+	"view": {
+		usage: "view [-f config.sops.env] [-meta config.meta.yaml]",
+		run:   runView,
+	},
+	"warm": {
+		usage: "warm [-f config.sops.env] [-o config.warm]",
+		run:   runWarm,
+	},
+	"watch": {
+		usage: "watch -f config.sops.env [-signal HUP] -- <command> [args...]",
+		run:   runWatch,
+	},
+}
+
+func runCommand(name string, args []string) error {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(args)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: go-sops [--output text|json] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun without a command to start the demo.")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/stripe/stripe-go/v82"
+)
+
+var (
+	ErrUnknownClient       = errors.New("no client registered")
+	ErrClientNotConfigured = errors.New("client not configured")
+)
+
+// ClientProvider builds one third-party client from the decrypted config.
+// Keys lists the env keys it needs; the client is reported as not
+// configured while any of them is empty.
+type ClientProvider struct {
+	Keys []string
+	New  func(config *EnvConfig) (any, error)
+}
+
+var (
+	clientsMu       sync.RWMutex
+	clientProviders = make(map[string]ClientProvider)
+)
+
+// RegisterClient makes a provider available to every Clients under name.
+// Like sql.Register, it panics if name is taken or New is nil, so call it
+// from init.
+func RegisterClient(name string, p ClientProvider) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if p.New == nil {
+		panic("go-sops: RegisterClient provider is nil for " + name)
+	}
+	if _, dup := clientProviders[name]; dup {
+		panic("go-sops: RegisterClient called twice for " + name)
+	}
+	clientProviders[name] = p
+}
+
+func init() {
+	RegisterClient("stripe", ClientProvider{
+		Keys: []string{"STRIPE_SECRET_KEY"},
+		New: func(config *EnvConfig) (any, error) {
+			return stripe.NewClient(config.StripeSecretKey), nil
+		},
+	})
+	RegisterClient("sendgrid", ClientProvider{
+		Keys: []string{"SENDGRID_API_KEY"},
+		New: func(config *EnvConfig) (any, error) {
+			return sendgrid.NewSendClient(config.SendGridAPIKey), nil
+		},
+	})
+}
+
+// Clients builds registered clients on first use and keeps them until the
+// config they were built from is replaced, so a reload with a rotated key
+// yields a new client.
+type Clients struct {
+	source func() *EnvConfig
+
+	mu     sync.Mutex
+	config *EnvConfig
+	built  map[string]any
+}
+
+// NewClients returns a registry over a fixed config.
+func NewClients(config *EnvConfig) *Clients {
+	return &Clients{source: func() *EnvConfig { return config }}
+}
+
+// Clients returns the registry for the store's current config.
+func (s *Store) Clients() *Clients {
+	s.clientsOnce.Do(func() {
+		s.clients = &Clients{source: s.Config}
+	})
+	return s.clients
+}
+
+func (c *Clients) Get(name string) (any, error) {
+	clientsMu.RLock()
+	p, ok := clientProviders[name]
+	clientsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrUnknownClient)
+	}
+
+	config := c.source()
+	if config == nil {
+		return nil, ErrNoConfig
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config != config {
+		c.config = config
+		c.built = make(map[string]any)
+	}
+	if client, ok := c.built[name]; ok {
+		return client, nil
+	}
+
+	for _, key := range p.Keys {
+		if config.values[key] == "" {
+			return nil, fmt.Errorf("%s: %s is not set: %w", name, key, ErrClientNotConfigured)
+		}
+	}
+	client, err := p.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", name, err)
+	}
+	c.built[name] = client
+	return client, nil
+}
+
+// ClientAs is Get with the result asserted to the provider's client type.
+func ClientAs[T any](c *Clients, name string) (T, error) {
+	var zero T
+	client, err := c.Get(name)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := client.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s client is %T, not %T", name, client, zero)
+	}
+	return typed, nil
+}
+
+func (c *Clients) Stripe() (*stripe.Client, error) {
+	return ClientAs[*stripe.Client](c, "stripe")
+}
+
+func (c *Clients) SendGrid() (*sendgrid.Client, error) {
+	return ClientAs[*sendgrid.Client](c, "sendgrid")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type testClient struct{ key string }
+
+var (
+	registerTestClient sync.Once
+	testClientBuilds   atomic.Int32
+)
+
+func TestClientsFollowReloads(t *testing.T) {
+	registerTestClient.Do(func() {
+		RegisterClient("test", ClientProvider{
+			Keys: []string{"TEST_CLIENT_KEY"},
+			New: func(config *EnvConfig) (any, error) {
+				testClientBuilds.Add(1)
+				return &testClient{key: config.Get("TEST_CLIENT_KEY")}, nil
+			},
+		})
+	})
+	testClientBuilds.Store(0)
+	store, rotate := rotatableStore(t, "TEST_CLIENT_KEY=k1\nSTRIPE_SECRET_KEY=sk_test_1\n")
+	clients := store.Clients()
+
+	first, err := ClientAs[*testClient](clients, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := ClientAs[*testClient](clients, "test"); again != first || testClientBuilds.Load() != 1 {
+		t.Errorf("client rebuilt without a reload (%d builds)", testClientBuilds.Load())
+	}
+
+	rotate("TEST_CLIENT_KEY=k2\n")
+	second, err := ClientAs[*testClient](clients, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || second.key != "k2" {
+		t.Errorf("after the reload got %+v, want a client with k2", second)
+	}
+	if _, err := clients.Stripe(); !errors.Is(err, ErrClientNotConfigured) {
+		t.Errorf("Stripe() without its key: %v, want ErrClientNotConfigured", err)
+	}
+	if _, err := clients.Get("nope"); !errors.Is(err, ErrUnknownClient) {
+		t.Errorf("Get(nope) error = %v, want ErrUnknownClient", err)
+	}
+	if _, err := ClientAs[string](clients, "test"); err == nil {
+		t.Error("ClientAs accepted the wrong type")
+	}
+}
+
+func TestRegisterClientTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterClient did not panic on a taken name")
+		}
+	}()
+	RegisterClient("stripe", ClientProvider{New: func(*EnvConfig) (any, error) { return nil, nil }})
+}
+
+func TestNewClientsStripe(t *testing.T) {
+	config := &EnvConfig{StripeSecretKey: "sk_test_1", envState: envState{values: map[string]string{"STRIPE_SECRET_KEY": "sk_test_1"}}}
+	if client, err := NewClients(config).Stripe(); err != nil || client == nil {
+		t.Errorf("Stripe() = %v, %v", client, err)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runKeys lists the key paths of an encrypted file. sops leaves keys in
+// plaintext, so nothing is decrypted, which keeps it fast enough for shell
+// completion and usable without access to the keys.
+func runKeys(args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env, yaml or json file")
+	format := fs.String("format", "dot", `dot for storage.psql.host, extract for sops --extract and set, like ["storage"]["psql"]["host"]`)
+	all := fs.Bool("all", false, "list the maps and lists containing keys too, not only the values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "dot" && *format != "extract" {
+		return fmt.Errorf("-format must be dot or extract, not %q", *format)
+	}
+
+	paths, err := fileKeyPaths(*filename, *all)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		type key struct {
+			Path    string `json:"path"`
+			Extract string `json:"extract"`
+		}
+		keys := make([]key, 0, len(paths))
+		for _, path := range paths {
+			keys = append(keys, key{Path: path.dot(), Extract: path.extract()})
+		}
+		return writeJSON(keys)
+	}
+	for _, path := range paths {
+		if *format == "extract" {
+			fmt.Println(path.extract())
+		} else {
+			fmt.Println(path.dot())
+		}
+	}
+	return nil
+}
+
+// runGet decrypts a file and prints the value at one key path, in the dot
+// form keys lists, like storage.psql.host.
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env, yaml or json file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: get [-f config.sops.env] <key>")
+	}
+	value, err := getValue(context.Background(), *filename, fs.Arg(0), newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func getValue(ctx context.Context, filename, key string, options *loadOptions) (string, error) {
+	values, err := readSOPSValues(ctx, filename, options)
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("%s has no value at %s", filename, key)
+	}
+	return value, nil
+}
+
+// keyPath is a path into a file, of map keys and list indexes.
+type keyPath []any
+
+func (p keyPath) extract() string {
+	var b strings.Builder
+	for _, part := range p {
+		if key, ok := part.(string); ok {
+			b.WriteString("[" + strconv.Quote(key) + "]")
+		} else {
+			fmt.Fprintf(&b, "[%d]", part)
+		}
+	}
+	return b.String()
+}
+
+func (p keyPath) dot() string {
+	var b strings.Builder
+	for i, part := range p {
+		if key, ok := part.(string); ok {
+			if i > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(key)
+		} else {
+			fmt.Fprintf(&b, "[%d]", part)
+		}
+	}
+	return b.String()
+}
+
+func fileKeyPaths(filename string, all bool) ([]keyPath, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(filename) {
+	case ".env", ".dotenv":
+		var paths []keyPath
+		for key := range envCiphertexts(data) {
+			paths = append(paths, keyPath{key})
+		}
+		sort.Slice(paths, func(i, j int) bool { return paths[i][0].(string) < paths[j][0].(string) })
+		return paths, nil
+	case ".ini":
+		return nil, errors.New("listing the keys of ini files is not supported")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	var paths []keyPath
+	if len(doc.Content) > 0 {
+		walkKeyPaths(doc.Content[0], nil, func(path keyPath, leaf *yaml.Node) {
+			if leaf != nil || all {
+				paths = append(paths, path)
+			}
+		})
+	}
+	return paths, nil
+}
+
+// walkKeyPaths visits the paths below node in file order, leaving out the
+// top-level sops metadata. Maps and lists are visited with a nil leaf
+// before their contents.
+func walkKeyPaths(node *yaml.Node, path keyPath, visit func(path keyPath, leaf *yaml.Node)) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	var children []*yaml.Node
+	var parts []any
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; len(path) > 0 || key != "sops" {
+				parts = append(parts, key)
+				children = append(children, node.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			parts = append(parts, i)
+			children = append(children, child)
+		}
+	default:
+		visit(path, node)
+		return
+	}
+	if len(path) > 0 {
+		visit(path, nil)
+	}
+	for i, child := range children {
+		walkKeyPaths(child, append(slices.Clip(path), parts[i]), visit)
+	}
+}
+
+// runCompletion prints a completion script. Besides the commands of
+// go-sops, it completes the keys for get and gitlab-dotenv -keys. Key
+// paths for sops --extract, set and unset are completed only once the
+// user registers them, so sops keeps its own completion otherwise.
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: completion bash|zsh|fish")
+	}
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = "autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("unknown shell %q, expected bash, zsh or fish", args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+const bashCompletion = `# go-sops completion for bash and zsh: eval "$(go-sops completion bash)"
+
+# _go_sops_file prints the last existing file on the command line, which
+# may come after the word being completed.
+_go_sops_file() {
+    local i file=""
+    for (( i = 1; i < ${#COMP_WORDS[@]}; i++ )); do
+        (( i != COMP_CWORD )) && [[ -f ${COMP_WORDS[i]} ]] && file=${COMP_WORDS[i]}
+    done
+    printf '%s' "$file"
+}
+
+# _go_sops_env_file prints the file given with -f, or the default.
+_go_sops_env_file() {
+    local file=config.sops.env i
+    for (( i = 1; i < COMP_CWORD; i++ )); do
+        [[ ${COMP_WORDS[i]} == -f ]] && file=${COMP_WORDS[i+1]}
+    done
+    printf '%s' "$file"
+}
+
+_go_sops_commands() {
+    go-sops help 2>&1 | awk '/^  [a-z]/ { print $1 }'
+}
+
+# _go_sops_paths completes sops paths like '["storage"]["psql"]', quoted.
+_go_sops_paths() {
+    local cur=$1 file=$2 path
+    COMPREPLY=()
+    [[ -n $file ]] || return
+    while IFS= read -r path; do
+        [[ "'$path'" == "'${cur#\'}"* ]] && COMPREPLY+=("'$path'")
+    done < <(go-sops keys -all -format extract -f "$file" 2>/dev/null)
+}
+
+_go_sops() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+    if (( COMP_CWORD == 1 )); then
+        COMPREPLY=($(compgen -W "$(_go_sops_commands) help" -- "$cur"))
+        return
+    fi
+    case $prev in
+    -keys)
+        COMPREPLY=($(compgen -W "$(go-sops keys -f "$(_go_sops_env_file)" 2>/dev/null)" -- "${cur##*,}"))
+        if [[ $cur == *,* ]]; then
+            COMPREPLY=("${COMPREPLY[@]/#/${cur%,*},}")
+        fi
+        ;;
+    -f)
+        COMPREPLY=($(compgen -f -- "$cur"))
+        ;;
+    *)
+        if [[ ${COMP_WORDS[1]} == get && $cur != -* ]]; then
+            COMPREPLY=($(compgen -W "$(go-sops keys -f "$(_go_sops_env_file)" 2>/dev/null)" -- "$cur"))
+        else
+            COMPREPLY=($(compgen -f -- "$cur"))
+        fi
+        ;;
+    esac
+}
+
+# _go_sops_sops completes sops itself. It isn't registered, so it doesn't
+# replace the completion sops may already have; to use it instead, add:
+#
+#   complete -o default -F _go_sops_sops sops
+_go_sops_sops() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+    if [[ $prev == --extract ]]; then
+        _go_sops_paths "$cur" "$(_go_sops_file)"
+        return
+    fi
+    case ${COMP_WORDS[1]} in
+    set | unset)
+        if (( COMP_CWORD == 3 )); then
+            _go_sops_paths "$cur" "${COMP_WORDS[2]}"
+            return
+        fi
+        ;;
+    esac
+    COMPREPLY=($(compgen -f -- "$cur"))
+}
+
+complete -o default -F _go_sops go-sops
+`
+
+const fishCompletion = `# go-sops completion for fish: go-sops completion fish | source
+
+function __go_sops_file
+    set -l file
+    for word in (commandline -o)[2..-1]
+        test -f $word; and set file $word
+    end
+    echo $file
+end
+
+function __go_sops_env_file
+    set -l words (commandline -opc)
+    set -l file config.sops.env
+    for i in (seq (count $words))
+        if test $words[$i] = -f; and test $i -lt (count $words)
+            set file $words[(math $i + 1)]
+        end
+    end
+    echo $file
+end
+
+function __go_sops_get_key
+    set -l words (commandline -opc)
+    test "$words[2]" = get; and test "$words[-1]" != -f
+end
+
+function __go_sops_set_path
+    set -l words (commandline -opc)
+    test (count $words) -eq 3; and contains -- $words[2] set unset
+end
+
+complete -c go-sops -n __fish_use_subcommand -f -a "(go-sops help 2>&1 | awk '/^  [a-z]/ { print \$1 }') help"
+complete -c go-sops -o keys -x -a "(go-sops keys -f (__go_sops_env_file) 2>/dev/null)"
+complete -c go-sops -n __go_sops_get_key -f -a "(go-sops keys -f (__go_sops_env_file) 2>/dev/null)"
+
+# __go_sops_sops_completions adds key paths to the completion of sops
+# --extract, set and unset. Call it after this script to use them.
+function __go_sops_sops_completions
+    complete -c sops -l extract -x -a "(go-sops keys -all -format extract -f (__go_sops_file) 2>/dev/null)"
+    complete -c sops -n __go_sops_set_path -x -a "(go-sops keys -all -format extract -f (commandline -opc)[3] 2>/dev/null)"
+end
+`
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestGetValue(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DB_PASSWORD": "secret"})
+	yamlFile := DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return []byte("storage:\n  psql:\n    host: db\n    ports: [5432]\n"), nil
+	})
+	tests := []struct {
+		name      string
+		decryptor Decryptor
+		filename  string
+		key       string
+		want      string
+		wantErr   string
+	}{
+		{"env key", fake, "config.sops.env", "DB_PASSWORD", "secret", ""},
+		{"yaml path", yamlFile, "config.sops.yaml", "storage.psql.host", "db", ""},
+		{"yaml list", yamlFile, "config.sops.yaml", "storage.psql.ports[0]", "5432", ""},
+		{"map", yamlFile, "config.sops.yaml", "storage.psql", "", "config.sops.yaml has no value at storage.psql"},
+		{"missing", fake, "config.sops.env", "API_KEY", "", "config.sops.env has no value at API_KEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getValue(context.Background(), tt.filename, tt.key, newLoadOptions([]Option{WithDecryptor(tt.decryptor)}))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("getValue() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getValue() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("getValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	// go-sops is stubbed so keys lists fixed paths for the file given.
+	script := bashCompletion + `
+go-sops() { [[ $1 == keys && $3 == app.sops.yaml ]] && printf '%s\n' storage.psql.host storage.psql.port api.key; }
+complete -p sops >/dev/null 2>&1 && echo "sops completion registered"
+COMP_WORDS=(go-sops get -f app.sops.yaml storage.p) COMP_CWORD=4
+_go_sops
+printf '%s\n' "${COMPREPLY[@]}"
+`
+	out, err := exec.Command(bash, "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash: %v\n%s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), "storage.psql.host\nstorage.psql.port"; got != want {
+		t.Errorf("completion output =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+var ErrNoConfig = errors.New("store has no config loaded")
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialProvider returns the credentials to use for the next
+// connection. Implementations backed by a Store follow rotations.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialRefresher is implemented by providers that can re-read their
+// source, e.g. after a connection was refused because the password was
+// rotated before the watcher noticed.
+type CredentialRefresher interface {
+	Refresh(ctx context.Context) error
+}
+
+type storeCredentials struct {
+	store *Store
+	pick  func(*EnvConfig) Credentials
+}
+
+func (c *storeCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	config := c.store.Config()
+	if config == nil {
+		return Credentials{}, ErrNoConfig
+	}
+	return c.pick(config), nil
+}
+
+func (c *storeCredentials) Refresh(ctx context.Context) error {
+	return c.store.Reload(ctx)
+}
+
+// DBCredentials follows DB_USER and DB_PASSWORD in the store.
+func (s *Store) DBCredentials() CredentialProvider {
+	return &storeCredentials{store: s, pick: func(c *EnvConfig) Credentials {
+		return Credentials{Username: c.DBUser, Password: c.DBPassword}
+	}}
+}
+
+// RedisCredentials follows REDIS_PASSWORD, with the username taken from
+// REDIS_URL when it has one (Redis 6 ACL users).
+func (s *Store) RedisCredentials() CredentialProvider {
+	return &storeCredentials{store: s, pick: func(c *EnvConfig) Credentials {
+		creds := Credentials{Password: c.RedisPassword}
+		if u, err := url.Parse(c.RedisURL); err == nil && u.User != nil {
+			creds.Username = u.User.Username()
+			if password, ok := u.User.Password(); ok && creds.Password == "" {
+				creds.Password = password
+			}
+		}
+		return creds
+	}}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"gopkg.in/yaml.v3"
+)
+
+// The Secrets Store CSI driver calls providers over a unix socket with this
+// protobuf service. The few messages it uses are encoded by hand so the
+// module doesn't depend on the driver's generated code:
+//
+//	service v1alpha1.CSIDriverProvider {
+//	  rpc Version(VersionRequest) returns (VersionResponse);
+//	  rpc Mount(MountRequest) returns (MountResponse);
+//	}
+const (
+	csiProviderService = "v1alpha1.CSIDriverProvider"
+	csiProviderName    = "go-sops"
+	csiPodNamespace    = "csi.storage.k8s.io/pod.namespace"
+)
+
+type csiVersionRequest struct {
+	Version string // 1
+}
+
+type csiVersionResponse struct {
+	Version        string // 1
+	RuntimeName    string // 2
+	RuntimeVersion string // 3
+}
+
+type csiMountRequest struct {
+	Attributes  string             // 1, JSON object
+	Secrets     string             // 2, JSON object
+	TargetPath  string             // 3
+	Permission  string             // 4, JSON number
+	CurrentObjs []csiObjectVersion // 5
+}
+
+type csiMountResponse struct {
+	ObjectVersions []csiObjectVersion // 1
+	ErrorCode      string             // 2, Error.code
+	Files          []csiFile          // 3
+}
+
+type csiObjectVersion struct {
+	ID      string // 1
+	Version string // 2
+}
+
+type csiFile struct {
+	Path     string // 1
+	Mode     int32  // 2
+	Contents []byte // 3
+}
+
+// csiObject is one entry of the SecretProviderClass "objects" parameter.
+type csiObject struct {
+	// File is the encrypted file, relative to the provider's root.
+	File string `yaml:"file"`
+	// Key mounts a single variable of a dotenv file instead of the whole
+	// plaintext.
+	Key string `yaml:"key"`
+	// Path is the name inside the mount, defaulting to Key or the
+	// plaintext file name.
+	Path string `yaml:"path"`
+}
+
+type CSIProvider struct {
+	root       string
+	namespaced bool
+	options    *loadOptions
+}
+
+// NewCSIProvider serves encrypted files below root. With namespaced set,
+// a pod only sees files below root/<its namespace>.
+func NewCSIProvider(root string, namespaced bool, opts ...Option) *CSIProvider {
+	return &CSIProvider{root: root, namespaced: namespaced, options: newLoadOptions(opts)}
+}
+
+func (p *CSIProvider) Register(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: csiProviderService,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Version",
+				Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+					req := new(csiVersionRequest)
+					if err := dec(req); err != nil {
+						return nil, err
+					}
+					return &csiVersionResponse{Version: "v1alpha1", RuntimeName: csiProviderName, RuntimeVersion: "v1"}, nil
+				},
+			},
+			{
+				MethodName: "Mount",
+				Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+					req := new(csiMountRequest)
+					if err := dec(req); err != nil {
+						return nil, err
+					}
+					return p.mount(ctx, req)
+				},
+			},
+		},
+	}, p)
+}
+
+func (p *CSIProvider) mount(ctx context.Context, req *csiMountRequest) (*csiMountResponse, error) {
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(req.Attributes), &attributes); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid attributes: %v", err)
+	}
+	var mode int32 = 0o400
+	if req.Permission != "" {
+		if err := json.Unmarshal([]byte(req.Permission), &mode); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid permission: %v", err)
+		}
+	}
+
+	var objects []csiObject
+	if err := yaml.Unmarshal([]byte(attributes["objects"]), &objects); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid objects parameter: %v", err)
+	}
+	if len(objects) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the objects parameter lists no files")
+	}
+
+	root := p.root
+	if p.namespaced {
+		namespace := attributes[csiPodNamespace]
+		if namespace == "" || strings.ContainsAny(namespace, `/\`) || namespace == ".." {
+			return nil, status.Error(codes.InvalidArgument, "missing pod namespace")
+		}
+		root = filepath.Join(root, namespace)
+	}
+
+	resp := new(csiMountResponse)
+	for _, obj := range objects {
+		file, err := p.mountObject(ctx, root, obj, mode)
+		if err != nil {
+			slog.Warn("CSI mount failed", "namespace", attributes[csiPodNamespace], "file", obj.File, "error", err)
+			return nil, status.Error(codes.Internal, DefaultRedactor.Redact(err.Error()))
+		}
+		resp.Files = append(resp.Files, file.csiFile)
+		resp.ObjectVersions = append(resp.ObjectVersions, csiObjectVersion{ID: file.Path, Version: file.version})
+	}
+	return resp, nil
+}
+
+type mountedFile struct {
+	csiFile
+	version string
+}
+
+func (p *CSIProvider) mountObject(ctx context.Context, root string, obj csiObject, mode int32) (*mountedFile, error) {
+	if obj.File == "" {
+		return nil, errors.New("object without a file")
+	}
+	filename := filepath.Join(root, filepath.FromSlash(path.Clean("/"+obj.File)))
+
+	outPath := obj.Path
+	if outPath == "" {
+		outPath = obj.Key
+	}
+	if outPath == "" {
+		outPath = plaintextName(path.Base(obj.File))
+	}
+	if !filepath.IsLocal(outPath) {
+		return nil, fmt.Errorf("path %q must stay inside the mount", outPath)
+	}
+
+	encrypted, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, &DecryptError{File: obj.File, Kind: ErrFileNotFound}
+	}
+	sum := sha256.Sum256(encrypted)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := decryptSOPSFile(ctx, filename, p.options, buf); err != nil {
+		return nil, err
+	}
+
+	var contents []byte
+	if obj.Key != "" {
+		entries, err := parseDotenv(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse env file: %w", err)
+		}
+		envMap, _ := dotenvMap(entries)
+		DefaultRedactor.AddEnv(envMap)
+		value, ok := envMap[obj.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in %s", obj.Key, obj.File)
+		}
+		contents = []byte(value)
+	} else {
+		contents = append(contents, buf.Bytes()...)
+	}
+
+	return &mountedFile{
+		csiFile: csiFile{Path: outPath, Mode: mode, Contents: contents},
+		version: hex.EncodeToString(sum[:8]),
+	}, nil
+}
+
+func runCSIProvider(args []string) error {
+	fs := flag.NewFlagSet("csi-provider", flag.ContinueOnError)
+	socket := fs.String("socket", "/etc/kubernetes/secrets-store-csi-providers/go-sops.sock", "unix socket the CSI driver connects to")
+	root := fs.String("root", "/sops", "directory with the encrypted files")
+	namespaced := fs.Bool("namespaced", true, "only serve files below <root>/<pod namespace>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := os.Remove(*socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	lis, err := net.Listen("unix", *socket)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.ForceServerCodec(csiCodec{}))
+	NewCSIProvider(*root, *namespaced).Register(server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	slog.Info("CSI provider listening", "socket", *socket, "root", *root)
+	return server.Serve(lis)
+}
+
+// csiCodec encodes the provider messages as protobuf.
+type csiCodec struct{}
+
+type wireMessage interface {
+	appendWire(b []byte) []byte
+}
+
+type wireParser interface {
+	parseWire(b []byte) error
+}
+
+func (csiCodec) Name() string { return "proto" }
+
+func (csiCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return m.appendWire(nil), nil
+}
+
+func (csiCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireParser)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T", v)
+	}
+	return m.parseWire(data)
+}
+
+// consumeFields calls fn for every length-delimited field in b and skips
+// the rest; all fields the provider reads are strings or messages.
+func consumeFields(b []byte, fn func(num protowire.Number, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, m wireMessage) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.appendWire(nil))
+}
+
+func (r *csiVersionRequest) parseWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, value []byte) error {
+		if num == 1 {
+			r.Version = string(value)
+		}
+		return nil
+	})
+}
+
+func (r *csiVersionResponse) appendWire(b []byte) []byte {
+	b = appendString(b, 1, r.Version)
+	b = appendString(b, 2, r.RuntimeName)
+	return appendString(b, 3, r.RuntimeVersion)
+}
+
+func (r *csiMountRequest) parseWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			r.Attributes = string(value)
+		case 2:
+			r.Secrets = string(value)
+		case 3:
+			r.TargetPath = string(value)
+		case 4:
+			r.Permission = string(value)
+		case 5:
+			var ov csiObjectVersion
+			if err := ov.parseWire(value); err != nil {
+				return err
+			}
+			r.CurrentObjs = append(r.CurrentObjs, ov)
+		}
+		return nil
+	})
+}
+
+func (r *csiMountResponse) appendWire(b []byte) []byte {
+	for i := range r.ObjectVersions {
+		b = appendMessage(b, 1, &r.ObjectVersions[i])
+	}
+	if r.ErrorCode != "" {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendString(nil, 1, r.ErrorCode))
+	}
+	for i := range r.Files {
+		b = appendMessage(b, 3, &r.Files[i])
+	}
+	return b
+}
+
+func (o *csiObjectVersion) parseWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			o.ID = string(value)
+		case 2:
+			o.Version = string(value)
+		}
+		return nil
+	})
+}
+
+func (o *csiObjectVersion) appendWire(b []byte) []byte {
+	b = appendString(b, 1, o.ID)
+	return appendString(b, 2, o.Version)
+}
+
+func (f *csiFile) appendWire(b []byte) []byte {
+	b = appendString(b, 1, f.Path)
+	if f.Mode != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(f.Mode))
+	}
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	return protowire.AppendBytes(b, f.Contents)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testMountRequest and testMountResponse are the driver's side of Mount.
+type testMountRequest struct {
+	Attributes, Permission string
+}
+
+func (r *testMountRequest) appendWire(b []byte) []byte {
+	b = appendString(b, 1, r.Attributes)
+	b = appendString(b, 3, "/var/lib/kubelet/pods/x/volumes/mnt")
+	return appendString(b, 4, r.Permission)
+}
+
+type testMountResponse struct {
+	files    map[string]string
+	modes    map[string]uint64
+	versions map[string]string
+}
+
+func (r *testMountResponse) parseWire(b []byte) error {
+	r.files, r.modes, r.versions = map[string]string{}, map[string]uint64{}, map[string]string{}
+	return consumeFields(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			var ov csiObjectVersion
+			if err := ov.parseWire(value); err != nil {
+				return err
+			}
+			r.versions[ov.ID] = ov.Version
+		case 3:
+			var path, contents string
+			var mode uint64
+			for len(value) > 0 {
+				num, typ, n := protowire.ConsumeTag(value)
+				value = value[n:]
+				if typ == protowire.VarintType {
+					mode, n = protowire.ConsumeVarint(value)
+				} else {
+					var v []byte
+					v, n = protowire.ConsumeBytes(value)
+					if num == 1 {
+						path = string(v)
+					} else {
+						contents = string(v)
+					}
+				}
+				if n < 0 {
+					return protowire.ParseError(n)
+				}
+				value = value[n:]
+			}
+			r.files[path], r.modes[path] = contents, mode
+		}
+		return nil
+	})
+}
+
+func newCSIClient(t *testing.T, provider *CSIProvider) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ForceServerCodec(csiCodec{}))
+	provider.Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///csi",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(csiCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func mountAttributes(namespace, objects string) string {
+	attrs, _ := json.Marshal(map[string]string{csiPodNamespace: namespace, "objects": objects})
+	return string(attrs)
+}
+
+func TestCSIProviderMount(t *testing.T) {
+	installFakeSOPSBinary(t)
+	root := t.TempDir()
+	for _, dir := range []string{"team-a", "team-b"} {
+		os.MkdirAll(filepath.Join(root, dir), 0o700)
+		os.WriteFile(filepath.Join(root, dir, "config.sops.env"), []byte("DB_HOST=db\nDB_PASSWORD="+dir+"-pass\n"), 0o600)
+	}
+	conn := newCSIClient(t, NewCSIProvider(root, true))
+
+	resp := new(testMountResponse)
+	req := &testMountRequest{
+		Attributes: mountAttributes("team-a", "- file: config.sops.env\n- file: config.sops.env\n  key: DB_PASSWORD\n  path: db/password\n"),
+		Permission: "288", // 0440
+	}
+	if err := conn.Invoke(context.Background(), "/"+csiProviderService+"/Mount", req, resp); err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+	if got := resp.files["config.env"]; got != "DB_HOST=db\nDB_PASSWORD=team-a-pass\n" {
+		t.Errorf("config.env = %q", got)
+	}
+	if got := resp.files["db/password"]; got != "team-a-pass" {
+		t.Errorf("db/password = %q", got)
+	}
+	if resp.modes["db/password"] != 0o440 || len(resp.versions["config.env"]) != 16 {
+		t.Errorf("modes %v, versions %v", resp.modes, resp.versions)
+	}
+
+	tests := []struct {
+		name, attributes string
+		code             codes.Code
+	}{
+		{"no namespace", mountAttributes("", "- file: config.sops.env\n"), codes.InvalidArgument},
+		{"parent namespace", mountAttributes("..", "- file: team-a/config.sops.env\n"), codes.InvalidArgument},
+		{"no objects", mountAttributes("team-a", ""), codes.InvalidArgument},
+		// A file path can't climb out of the pod's namespace.
+		{"other namespace", mountAttributes("team-a", "- file: ../team-b/config.sops.env\n  key: DB_PASSWORD\n"), codes.Internal},
+		{"escaping path", mountAttributes("team-a", "- file: config.sops.env\n  path: ../x\n"), codes.Internal},
+		{"missing key", mountAttributes("team-a", "- file: config.sops.env\n  key: API_KEY\n"), codes.Internal},
+	}
+	for _, tt := range tests {
+		err := conn.Invoke(context.Background(), "/"+csiProviderService+"/Mount", &testMountRequest{Attributes: tt.attributes}, new(testMountResponse))
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+)
+
+// NonSecret returns the keys the policy does not classify as secret.
+func (c *EnvConfig) NonSecret(policy *MaskPolicy) map[string]string {
+	public := make(map[string]string)
+	for key, value := range c.values {
+		if !policy.IsSecretValue(key, value) {
+			public[key] = value
+		}
+	}
+	return public
+}
+
+func (s *Store) nonSecret(policy *MaskPolicy) map[string]any {
+	status := s.Status()
+	snapshot := map[string]any{
+		"file":       status.File,
+		"generation": status.Generation,
+	}
+	if config := s.Config(); config != nil {
+		snapshot["config"] = config.NonSecret(policy)
+	}
+	return snapshot
+}
+
+// PublishExpvar exposes the non-secret config under name in /debug/vars.
+// Like expvar.Publish, it panics if name is already in use.
+func (s *Store) PublishExpvar(name string, policy *MaskPolicy) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return s.nonSecret(policy)
+	}))
+}
+
+func (s *Store) DebugHandler(policy *MaskPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s.nonSecret(policy))
+	})
+}
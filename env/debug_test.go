@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugExportsNonSecretConfig(t *testing.T) {
+	decryptor := DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return []byte("DB_HOST=db.internal\nDB_PASSWORD=hunter22\nSTRIPE_WEBHOOK=sk_live_0123456789abcdef\n"), nil
+	})
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	store.DebugHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	if strings.Contains(rec.Body.String(), "hunter22") || strings.Contains(rec.Body.String(), "sk_live") {
+		t.Errorf("debug output carries a secret:\n%s", rec.Body)
+	}
+	var body struct {
+		Generation uint64            `json:"generation"`
+		Config     map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if body.Generation != 1 || len(body.Config) != 1 || body.Config["DB_HOST"] != "db.internal" {
+		t.Errorf("debug output = %+v, want only DB_HOST", body)
+	}
+
+	store.PublishExpvar("test_sops_config", nil)
+	published := expvar.Get("test_sops_config").String()
+	if !strings.Contains(published, "db.internal") || strings.Contains(published, "hunter22") {
+		t.Errorf("expvar = %s", published)
+	}
+}
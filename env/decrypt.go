@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultDecryptTimeout = time.Minute
+	DefaultKillGrace      = 5 * time.Second
+)
+
+var tracer = otel.Tracer("go-sops-env")
+
+// decryptSOPSFile writes the plaintext of filename to out.
+func decryptSOPSFile(ctx context.Context, filename string, options *loadOptions, out *bytes.Buffer) error {
+	ctx, span := tracer.Start(ctx, "sops.decrypt", trace.WithAttributes(
+		attribute.String("sops.file", filename),
+		attribute.String("sops.decryptor", decryptorName(options.decryptor)),
+	))
+	defer span.End()
+
+	if span.IsRecording() {
+		if meta, err := readSOPSMetadata(filename); err == nil {
+			span.SetAttributes(
+				attribute.StringSlice("sops.backends", meta.Backends),
+				attribute.Int("sops.key_groups", meta.KeyGroups),
+			)
+		}
+	}
+
+	if _, err := os.Stat(filename); err != nil && options.decryptor == nil {
+		err = &DecryptError{File: filename, Kind: ErrFileNotFound}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "file not found")
+		return err
+	}
+
+	if err := checkPermissions(filename, options); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "insecure file permissions")
+		return err
+	}
+
+	// path is what gets decrypted: filename, or with a signature the
+	// private copy of the bytes that were verified, so the file can't be
+	// swapped between the check and sops reading it.
+	path := filename
+	if options.signature != nil {
+		verified, cleanup, err := options.signature.verifiedCopy(ctx, filename)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "signature verification failed")
+			return err
+		}
+		defer cleanup()
+		path = verified
+	}
+
+	if options.fips {
+		if err := checkFIPS(path); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "algorithm policy violation")
+			return err
+		}
+	}
+
+	traceBackend(ctx, filename, options)
+	run := func() error {
+		start := time.Now()
+		var err error
+		switch {
+		case options.decryptor != nil:
+			var plaintext []byte
+			plaintext, err = options.decryptor.Decrypt(ctx, path)
+			out.Write(plaintext)
+		case options.parallelUnwrap:
+			err = runSOPSParallel(ctx, path, options, out)
+		default:
+			err = runSOPS(ctx, options, out, "-d", path)
+		}
+		DefaultMetrics.observeDecrypt(filename, start, err)
+		return err
+	}
+
+	var err error
+	if options.warmCache != nil {
+		err = decryptWithCache(ctx, path, options, out, run)
+	} else {
+		err = run()
+	}
+	if err != nil {
+		err = newDecryptError(filename, err)
+		checkMACTamper(filename, err, options)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "decryption failed")
+		return err
+	}
+	return nil
+}
+
+// decryptGuarded decrypts filename into buf, applying options.guard if set.
+// The returned data is only valid until buf is reused. The bool reports
+// that it is the last good result served while the circuit is open.
+func decryptGuarded(ctx context.Context, filename string, options *loadOptions, buf *bytes.Buffer) ([]byte, bool, error) {
+	if options.guard == nil {
+		err := decryptSOPSFile(ctx, filename, options, buf)
+		return buf.Bytes(), false, err
+	}
+	return options.guard.Do(filename, func() ([]byte, error) {
+		if err := decryptSOPSFile(ctx, filename, options, buf); err != nil {
+			return nil, err
+		}
+		// The guard keeps its own copy as the last good result.
+		return bytes.Clone(buf.Bytes()), nil
+	})
+}
+
+// runSOPS runs sops in its own process group so that on timeout or
+// cancellation the gpg/age helpers it spawned are stopped along with it:
+// first SIGTERM, then SIGKILL once the grace period is over.
+func runSOPS(ctx context.Context, options *loadOptions, stdout *bytes.Buffer, args ...string) error {
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	stderr := getBuffer()
+	defer putBuffer(stderr)
+
+	cmd := exec.Command("sops", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = options.killGrace
+	if options.fips {
+		cmd.Env = fipsEnv()
+	}
+	if len(options.sopsEnv) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, options.sopsEnv...)
+	}
+	setProcessGroup(cmd)
+
+	started, err := startWaited(cmd)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		started()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitErr.Stderr = bytes.Clone(stderr.Bytes())
+			}
+			return err
+		}
+		return nil
+
+	case <-ctx.Done():
+		terminateProcessGroup(cmd)
+		select {
+		case <-done:
+		case <-time.After(options.killGrace):
+			killProcessGroup(cmd)
+			<-done
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errDecryptTimeout
+		}
+		return fmt.Errorf("decryption cancelled: %w", ctx.Err())
+	}
+}
+
+var errDecryptTimeout = errors.New("sops timed out")
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"go-sops-env/sopstest"
+)
+
+func TestDecryptSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	fake := sopstest.NewFake()
+	fake.SetFile("traced.sops.env", map[string]string{"DB_PASSWORD": "hunter22"})
+	fake.SetError("broken.sops.env", errors.New("no key"))
+	if _, err := LoadSOPSEnv("traced.sops.env", WithDecryptor(fake)); err != nil {
+		t.Fatalf("LoadSOPSEnv() error = %v", err)
+	}
+	if _, err := LoadSOPSEnv("broken.sops.env", WithDecryptor(fake)); err == nil {
+		t.Fatal("LoadSOPSEnv() succeeded with a failing decryptor")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	for i, want := range []struct {
+		file   string
+		status codes.Code
+	}{{"traced.sops.env", codes.Unset}, {"broken.sops.env", codes.Error}} {
+		span := spans[i]
+		if span.Name() != "sops.decrypt" {
+			t.Errorf("span name = %q", span.Name())
+		}
+		attrs := map[string]string{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if attrs["sops.file"] != want.file {
+			t.Errorf("sops.file = %q, want %q", attrs["sops.file"], want.file)
+		}
+		if span.Status().Code != want.status {
+			t.Errorf("%s: status = %v, want %v", want.file, span.Status().Code, want.status)
+		}
+		for _, value := range attrs {
+			if value == "hunter22" {
+				t.Errorf("span carries a secret value: %v", attrs)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+var ErrInProcessUnavailable = errors.New("in-process decryption needs a build with -tags sopslib")
+
+// Decryptor turns an encrypted file into plaintext. Implementations should
+// return a *DecryptError so callers can match the usual Err* kinds; errors
+// matching fs.ErrNotExist become ErrFileNotFound, and other errors are
+// wrapped as "failed to decrypt". With a signature option,
+// filename is the private copy of the file that was verified.
+type Decryptor interface {
+	Decrypt(ctx context.Context, filename string) ([]byte, error)
+}
+
+// KeyRecorder can be implemented by a Decryptor to learn which keys are
+// read from the configs it produced, through EnvConfig.Get, Lookup, All
+// and Sorted. sopstest.Fake uses it to assert which keys a test touched.
+type KeyRecorder interface {
+	RecordKey(filename, key string)
+}
+
+// DecryptorFunc adapts a function, such as a test stub, to Decryptor.
+type DecryptorFunc func(ctx context.Context, filename string) ([]byte, error)
+
+func (f DecryptorFunc) Decrypt(ctx context.Context, filename string) ([]byte, error) {
+	return f(ctx, filename)
+}
+
+// WithDecryptor replaces running the sops binary with d. The loaders still
+// apply guards, warm caches, metrics and tracing around it; options that
+// configure the sops process, like WithParallelUnwrap, no longer apply.
+// The file is not required to exist locally, so d may fetch it from
+// elsewhere.
+func WithDecryptor(d Decryptor) Option {
+	return func(o *loadOptions) {
+		o.decryptor = d
+	}
+}
+
+// NewExecDecryptor returns the default decryptor, which runs the sops
+// binary with the timeouts and environment from opts. It is useful as the
+// inner decryptor of a wrapper.
+func NewExecDecryptor(opts ...Option) Decryptor {
+	options := newLoadOptions(opts)
+	options.decryptor = nil
+	return DecryptorFunc(func(ctx context.Context, filename string) ([]byte, error) {
+		var out bytes.Buffer
+		var err error
+		if options.parallelUnwrap {
+			err = runSOPSParallel(ctx, filename, options, &out)
+		} else {
+			err = runSOPS(ctx, options, &out, "-d", filename)
+		}
+		if err != nil {
+			return nil, newDecryptError(filename, err)
+		}
+		return out.Bytes(), nil
+	})
+}
+
+// NewInProcessDecryptor decrypts with the sops Go packages instead of the
+// binary, for images that don't ship sops. Builds without the sopslib tag
+// leave those packages out and return ErrInProcessUnavailable.
+func NewInProcessDecryptor() Decryptor {
+	return DecryptorFunc(decryptInProcess)
+}
+
+func decryptorName(d Decryptor) string {
+	if d == nil {
+		return "exec"
+	}
+	return "custom"
+}
@@ -0,0 +1,9 @@
+//go:build !sopslib
+
+package main
+
+import "context"
+
+func decryptInProcess(ctx context.Context, filename string) ([]byte, error) {
+	return nil, ErrInProcessUnavailable
+}
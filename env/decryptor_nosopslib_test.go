@@ -0,0 +1,19 @@
+//go:build !sopslib
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInProcessDecryptorUnavailable(t *testing.T) {
+	_, err := LoadSOPSEnv("config.sops.env", WithDecryptor(NewInProcessDecryptor()))
+	if !errors.Is(err, ErrInProcessUnavailable) {
+		t.Errorf("LoadSOPSEnv() = %v, want ErrInProcessUnavailable", err)
+	}
+	if _, err := NewInProcessDecryptor().Decrypt(context.Background(), "config.sops.env"); !errors.Is(err, ErrInProcessUnavailable) {
+		t.Errorf("Decrypt() = %v", err)
+	}
+}
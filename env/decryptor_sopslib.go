@@ -0,0 +1,48 @@
+//go:build sopslib
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/decrypt"
+)
+
+// decryptInProcess can't interrupt the sops library, so on cancellation it
+// returns while the decryption finishes in the background.
+func decryptInProcess(ctx context.Context, filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &DecryptError{File: filename, Kind: ErrFileNotFound}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		plaintext []byte
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		plaintext, err := decrypt.DataWithFormat(data, formats.FormatForPath(filename))
+		done <- result{plaintext, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			msg := r.err.Error()
+			return nil, &DecryptError{File: filename, Stderr: msg, Kind: classifySOPSFailure(0, msg)}
+		}
+		return r.plaintext, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &DecryptError{File: filename, Kind: ErrDecryptTimeout}
+		}
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCustomDecryptorErrors(t *testing.T) {
+	notExist := DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return nil, fs.ErrNotExist
+	})
+	_, err := LoadSOPSEnv("remote/config.sops.env", WithDecryptor(notExist))
+	var decryptErr *DecryptError
+	if !errors.As(err, &decryptErr) || decryptErr.Kind != ErrFileNotFound || decryptErr.File != "remote/config.sops.env" {
+		t.Errorf("fs.ErrNotExist became %#v", err)
+	}
+
+	vaultDown := errors.New("vault sealed")
+	failing := DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return nil, vaultDown
+	})
+	_, err = LoadSOPSEnv("remote/config.sops.env", WithDecryptor(failing))
+	if !errors.Is(err, vaultDown) || !strings.Contains(err.Error(), "failed to decrypt remote/config.sops.env") {
+		t.Errorf("a custom error became %v", err)
+	}
+}
+
+// cachingDecryptor is the kind of wrapper users build around
+// NewExecDecryptor.
+type cachingDecryptor struct {
+	inner Decryptor
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+func (c *cachingDecryptor) Decrypt(ctx context.Context, filename string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data, ok := c.cache[filename]; ok {
+		return bytes.Clone(data), nil
+	}
+	data, err := c.inner.Decrypt(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[filename] = bytes.Clone(data)
+	return data, nil
+}
+
+func TestWrappedExecDecryptor(t *testing.T) {
+	runs := countingSOPS(t)
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(filename, []byte("DB_PASSWORD=hunter22\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	decryptor := &cachingDecryptor{inner: NewExecDecryptor(), cache: make(map[string][]byte)}
+
+	for range 3 {
+		config, err := LoadSOPSEnv(filename, WithDecryptor(decryptor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.DBPassword != "hunter22" {
+			t.Errorf("DBPassword = %q", config.DBPassword)
+		}
+	}
+	if n := runs(); n != 1 {
+		t.Errorf("sops ran %d times, want 1", n)
+	}
+
+	if _, err := NewExecDecryptor().Decrypt(context.Background(), filepath.Join(t.TempDir(), "missing.sops.env")); err == nil {
+		t.Error("NewExecDecryptor() decrypted a missing file")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Deprecation renames Old to New while services migrate. After Sunset (if
+// set), loading a file that still uses Old fails.
+type Deprecation struct {
+	Old    string
+	New    string
+	Sunset time.Time
+}
+
+type DeprecatedKeyError struct {
+	File string
+	Deprecation
+}
+
+func (e *DeprecatedKeyError) Error() string {
+	return fmt.Sprintf("%s: key %s was removed on %s, rename it to %s",
+		e.File, e.Old, e.Sunset.Format(time.DateOnly), e.New)
+}
+
+func WithDeprecations(deprecations ...Deprecation) Option {
+	return func(o *loadOptions) {
+		o.deprecations = append(o.deprecations, deprecations...)
+	}
+}
+
+func applyDeprecations(filename string, envMap map[string]string, deprecations []Deprecation) error {
+	now := time.Now()
+
+	for _, d := range deprecations {
+		value, ok := envMap[d.Old]
+		if !ok {
+			continue
+		}
+
+		if !d.Sunset.IsZero() && now.After(d.Sunset) {
+			return &DeprecatedKeyError{File: filename, Deprecation: d}
+		}
+
+		attrs := []any{"file", filename, "old", d.Old, "new", d.New}
+		if !d.Sunset.IsZero() {
+			attrs = append(attrs, "sunset", d.Sunset.Format(time.DateOnly))
+		}
+
+		if _, exists := envMap[d.New]; exists {
+			slog.Warn("deprecated config key ignored, replacement already set", attrs...)
+		} else {
+			slog.Warn("deprecated config key in use", attrs...)
+			envMap[d.New] = value
+		}
+		delete(envMap, d.Old)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go-sops-env/sopstest"
+)
+
+func TestWithDeprecations(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	fake := sopstest.NewFake()
+	fake.SetFile("old.sops.env", map[string]string{"REDIS_ADDR": "redis://old"})
+	fake.SetFile("both.sops.env", map[string]string{"REDIS_ADDR": "redis://old", "REDIS_URL": "redis://new"})
+	renamed := Deprecation{Old: "REDIS_ADDR", New: "REDIS_URL"}
+
+	config, err := LoadSOPSEnv("old.sops.env", WithDecryptor(fake), WithDeprecations(renamed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Get("REDIS_URL"); got != "redis://old" {
+		t.Errorf("REDIS_URL = %q, want the deprecated key's value", got)
+	}
+	if _, ok := config.Lookup("REDIS_ADDR"); ok {
+		t.Error("the deprecated key is still set")
+	}
+	if !strings.Contains(logs.String(), "deprecated config key in use") || !strings.Contains(logs.String(), "old=REDIS_ADDR") {
+		t.Errorf("logs = %q, want a deprecation warning", logs.String())
+	}
+
+	logs.Reset()
+	config, err = LoadSOPSEnv("both.sops.env", WithDecryptor(fake), WithDeprecations(renamed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Get("REDIS_URL"); got != "redis://new" {
+		t.Errorf("REDIS_URL = %q, want the replacement to win", got)
+	}
+	if !strings.Contains(logs.String(), "replacement already set") {
+		t.Errorf("logs = %q, want the ignored key reported", logs.String())
+	}
+}
+
+func TestDeprecationSunset(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("old.sops.env", map[string]string{"REDIS_ADDR": "redis://old"})
+	fake.SetFile("new.sops.env", map[string]string{"REDIS_URL": "redis://new"})
+	sunset := Deprecation{Old: "REDIS_ADDR", New: "REDIS_URL", Sunset: time.Now().Add(-24 * time.Hour)}
+
+	_, err := LoadSOPSEnv("old.sops.env", WithDecryptor(fake), WithDeprecations(sunset))
+	var deprecatedErr *DeprecatedKeyError
+	if !errors.As(err, &deprecatedErr) || deprecatedErr.Old != "REDIS_ADDR" {
+		t.Fatalf("LoadSOPSEnv() error = %v, want a *DeprecatedKeyError", err)
+	}
+	if _, err := LoadSOPSEnv("new.sops.env", WithDecryptor(fake), WithDeprecations(sunset)); err != nil {
+		t.Errorf("a migrated file failed after the sunset: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithDevFallback reads the plaintext file next to the encrypted one,
+// config.env for config.sops.env and then .env, when the encrypted file is
+// missing or sops isn't installed. It is for local development: it refuses
+// when ENVIRONMENT is production, in the process environment or in the
+// plaintext file, and logs a warning every time it is used.
+func WithDevFallback() Option {
+	return func(o *loadOptions) {
+		o.devFallback = true
+	}
+}
+
+func isProduction(env string) bool {
+	env = strings.ToLower(strings.TrimSpace(env))
+	return env == "production" || env == "prod"
+}
+
+// readDevFallback returns the plaintext to use instead of filename and the
+// file it came from, or cause if the fallback doesn't apply.
+func readDevFallback(filename string, cause error) ([]byte, string, error) {
+	if !errors.Is(cause, ErrFileNotFound) && !errors.Is(cause, ErrSOPSNotInstalled) {
+		return nil, "", cause
+	}
+	if isProduction(os.Getenv("ENVIRONMENT")) || isProduction(os.Getenv("APP_ENV")) {
+		return nil, "", cause
+	}
+
+	dir := filepath.Dir(filename)
+	candidates := []string{filepath.Join(dir, plaintextName(filepath.Base(filename))), filepath.Join(dir, ".env")}
+	for _, path := range candidates {
+		if path == filename {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		entries, err := parseDotenv(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		envMap, _ := dotenvMap(entries)
+		if isProduction(envMap["ENVIRONMENT"]) {
+			return nil, "", fmt.Errorf("%w; not falling back to %s because it sets ENVIRONMENT=%s", cause, path, envMap["ENVIRONMENT"])
+		}
+
+		log.Printf("⚠️⚠️⚠️ DEV FALLBACK: %v", cause)
+		log.Printf("⚠️⚠️⚠️ DEV FALLBACK: loading PLAINTEXT secrets from %s instead of %s. Never use this outside local development.", path, filename)
+		return data, path, nil
+	}
+	return nil, "", cause
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &logs
+}
+
+func TestDevFallback(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("APP_ENV", "")
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	if err := os.WriteFile(filepath.Join(dir, "config.env"), []byte("DB_HOST=localhost\nDB_PASSWORD=plain-dev-pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadSOPSEnv(filename); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("without WithDevFallback: %v, want ErrFileNotFound", err)
+	}
+
+	logs := captureLog(t)
+	config, err := LoadSOPSEnv(filename, WithDevFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "plain-dev-pass" {
+		t.Errorf("DBPassword = %q", config.DBPassword)
+	}
+	if !strings.Contains(logs.String(), "DEV FALLBACK") || !strings.Contains(logs.String(), "config.env") {
+		t.Errorf("no warning logged: %s", logs)
+	}
+
+	t.Setenv("ENVIRONMENT", "Production")
+	if _, err := LoadSOPSEnv(filename, WithDevFallback()); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("with ENVIRONMENT=production: %v, want ErrFileNotFound", err)
+	}
+}
+
+func TestDevFallbackRefusesProductionFile(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("APP_ENV", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("ENVIRONMENT=prod\nDB_PASSWORD=prod-pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	captureLog(t)
+
+	_, err := LoadSOPSEnv(filepath.Join(dir, "config.sops.env"), WithDevFallback())
+	if !errors.Is(err, ErrFileNotFound) || !strings.Contains(err.Error(), "ENVIRONMENT=prod") {
+		t.Errorf("LoadSOPSEnv() = %v, want a refusal wrapping ErrFileNotFound", err)
+	}
+}
+
+func TestDevFallbackOnlyForMissingFileOrSOPS(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("APP_ENV", "")
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	if err := os.WriteFile(filepath.Join(dir, "config.env"), []byte("DB_PASSWORD=plain-dev-pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	decryptor := DecryptorFunc(func(_ context.Context, filename string) ([]byte, error) {
+		return nil, &DecryptError{File: filename, Kind: ErrNoMatchingKeys}
+	})
+
+	if _, err := LoadSOPSEnv(filename, WithDecryptor(decryptor), WithDevFallback()); !errors.Is(err, ErrNoMatchingKeys) {
+		t.Errorf("LoadSOPSEnv() = %v, want the decryption error", err)
+	}
+
+	// An encrypted file that exists falls back only when sops is missing.
+	if err := os.WriteFile(filename, []byte("sops: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", t.TempDir())
+	captureLog(t)
+	config, err := LoadSOPSEnv(filename, WithDevFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "plain-dev-pass" {
+		t.Errorf("DBPassword = %q", config.DBPassword)
+	}
+}
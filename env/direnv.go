@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// runDirenv prints the decrypted keys as shell exports for an .envrc:
+//
+//	eval "$(go-sops direnv config.sops.env)"
+//
+// The watch_file lines come first and are printed even if decryption fails,
+// so direnv reloads once the file is fixed.
+func runDirenv(args []string) error {
+	fs := flag.NewFlagSet("direnv", flag.ContinueOnError)
+	watch := fs.String("watch", "", "comma-separated extra files that should trigger a reload, like .sops.yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"config.sops.env"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	watched := slices.Clone(files)
+	if *watch != "" {
+		watched = append(watched, strings.Split(*watch, ",")...)
+	}
+	for _, file := range watched {
+		fmt.Fprintf(w, "watch_file %s\n", shellQuote(strings.TrimSpace(file)))
+	}
+
+	// Later files override earlier ones, as with several dotenv calls.
+	envMap := map[string]string{}
+	for _, file := range files {
+		values, err := readSOPSEnvMap(context.Background(), file, newLoadOptions(nil))
+		if err != nil {
+			return err
+		}
+		maps.Copy(envMap, values)
+	}
+	return writeShellExports(w, envMap)
+}
+
+// writeShellExports writes export lines in key order. Keys that aren't
+// shell variable names, like a.b, are skipped with a warning.
+func writeShellExports(w io.Writer, envMap map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(envMap)) {
+		if !shellName(key) {
+			slog.Warn("skipping key that is not a shell variable name", "variable", key)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", key, shellQuote(envMap[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote single-quotes s, which keeps every character literal, even
+// newlines, $ and backticks.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellName(key string) bool {
+	for i, r := range key {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return key != ""
+}
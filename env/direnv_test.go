@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirenv(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.sops.env")
+	local := filepath.Join(dir, "local.sops.env")
+	os.WriteFile(base, []byte("DB_HOST=db\nDB_PASSWORD=\"it's $HOME `id`\\nline two\"\nbad.key=x\n"), 0o600)
+	os.WriteFile(local, []byte("DB_HOST=localhost\n"), 0o600)
+
+	var runErr error
+	out := string(captureStdout(t, func() { runErr = runDirenv([]string{"-watch", ".sops.yaml", base, local}) }))
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	wantWatch := "watch_file '" + base + "'\nwatch_file '" + local + "'\nwatch_file '.sops.yaml'\n"
+	if !strings.HasPrefix(out, wantWatch) {
+		t.Errorf("output does not start with the watch_file lines:\n%s", out)
+	}
+	if strings.Contains(out, "bad.key") {
+		t.Errorf("output exports a key that is not a shell name:\n%s", out)
+	}
+
+	// What direnv would see after eval.
+	script := "watch_file() { :; }\n" + out + `printf '%s|%s' "$DB_HOST" "$DB_PASSWORD"`
+	got, err := exec.Command("sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("eval: %v\n%s", err, out)
+	}
+	if want := "localhost|it's $HOME `id`\nline two"; string(got) != want {
+		t.Errorf("after eval: %q, want %q", got, want)
+	}
+}
+
+func TestDirenvWatchesBrokenFile(t *testing.T) {
+	installSOPSScript(t, "echo 'Failed to get the data key' >&2\nexit 128\n")
+	file := filepath.Join(t.TempDir(), "config.sops.env")
+	os.WriteFile(file, []byte("DB_HOST=db\n"), 0o600)
+
+	var runErr error
+	out := string(captureStdout(t, func() { runErr = runDirenv([]string{file}) }))
+	if runErr == nil {
+		t.Error("runDirenv() succeeded with a file that does not decrypt")
+	}
+	if out != "watch_file '"+file+"'\n" {
+		t.Errorf("output = %q, want only the watch_file line", out)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+)
+
+// doctorCheck is one line of the doctor report.
+type doctorCheck struct {
+	status string // PASS, WARN, FAIL or SKIP
+	name   string
+	detail string
+}
+
+type doctorReport struct {
+	checks []doctorCheck
+}
+
+func (r *doctorReport) add(status, name, detail string, args ...any) {
+	r.checks = append(r.checks, doctorCheck{status: status, name: name, detail: fmt.Sprintf(detail, args...)})
+}
+
+func (r *doctorReport) failures() int {
+	n := 0
+	for _, c := range r.checks {
+		if c.status == "FAIL" {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *doctorReport) print() {
+	icons := map[string]string{"PASS": "✅", "WARN": "⚠️ ", "FAIL": "❌", "SKIP": "➖"}
+	for _, c := range r.checks {
+		fmt.Printf("  %s %-4s %s: %s\n", icons[c.status], c.status, c.name, c.detail)
+	}
+}
+
+func (r *doctorReport) writeJSON() error {
+	type check struct {
+		Status string `json:"status"`
+		Name   string `json:"name"`
+		Detail string `json:"detail"`
+	}
+	checks := make([]check, 0, len(r.checks))
+	for _, c := range r.checks {
+		checks = append(checks, check{Status: c.status, Name: c.name, Detail: c.detail})
+	}
+	return writeJSON(map[string]any{"checks": checks, "failures": r.failures()})
+}
+
+// runDoctor checks everything decryption depends on, from the sops binary
+// to each file's keys and KMS endpoints, and prints a PASS/FAIL report.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	dialTimeout := fs.Duration("dial-timeout", 3*time.Second, "how long to wait for a KMS or vault endpoint")
+	decryptTimeout := fs.Duration("timeout", DefaultDecryptTimeout, "how long each test decryption may take")
+	skipDecrypt := fs.Bool("no-decrypt", false, "don't try to decrypt the files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		matches, _ := filepath.Glob("*.sops.*")
+		for _, match := range matches {
+			// Skip .sops.yaml, the creation rules.
+			if !strings.HasPrefix(match, ".") {
+				files = append(files, match)
+			}
+		}
+	}
+
+	report := &doctorReport{}
+	if !jsonOutput() {
+		fmt.Println("🩺 go-sops doctor")
+	}
+	checkSOPSBinary(report)
+
+	ctx := context.Background()
+	options := newLoadOptions([]Option{WithTimeout(*decryptTimeout)})
+	if len(files) == 0 {
+		report.add("WARN", "files", "no *.sops.* files here, pass the files to check")
+	}
+	for _, file := range files {
+		checkFile(ctx, report, file, options, *dialTimeout, *skipDecrypt)
+	}
+
+	if jsonOutput() {
+		if err := report.writeJSON(); err != nil {
+			return err
+		}
+	} else {
+		report.print()
+	}
+	if n := report.failures(); n > 0 {
+		return fmt.Errorf("%d check(s) failed", n)
+	}
+	if !jsonOutput() {
+		fmt.Println("✅ All checks passed")
+	}
+	return nil
+}
+
+func checkSOPSBinary(report *doctorReport) {
+	path, err := exec.LookPath("sops")
+	if err != nil {
+		report.add("FAIL", "sops", "not found in $PATH, install it from https://github.com/getsops/sops/releases")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version", "--disable-version-check").Output()
+	if err != nil {
+		// Releases before 3.8 don't know --disable-version-check.
+		output, err = exec.CommandContext(ctx, path, "--version").Output()
+	}
+	if err != nil {
+		report.add("FAIL", "sops", "%s --version failed: %v", path, err)
+		return
+	}
+	version := strings.TrimSpace(firstLine(string(output)))
+	report.add("PASS", "sops", "%s (%s)", path, version)
+}
+
+func checkFile(ctx context.Context, report *doctorReport, file string, options *loadOptions, dialTimeout time.Duration, skipDecrypt bool) {
+	meta, err := readSOPSMetadata(file)
+	if err != nil {
+		report.add("FAIL", file, "%v", err)
+		return
+	}
+	report.add("PASS", file, "sops %s metadata, backends %s", meta.Version, strings.Join(meta.Backends, ", "))
+	for _, problem := range filePermissionProblems(file, false) {
+		report.add("WARN", file, "%s", problem)
+	}
+
+	for _, backend := range meta.Backends {
+		checkBackendKeys(report, file, backend, meta.Recipients[backend])
+		for _, endpoint := range backendEndpoints(backend, meta.Recipients[backend]) {
+			checkEndpoint(report, file, backend, endpoint, dialTimeout)
+		}
+	}
+
+	if skipDecrypt {
+		report.add("SKIP", file, "decryption not tried (-no-decrypt)")
+		return
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	start := time.Now()
+	if err := decryptSOPSFile(ctx, file, options, buf); err != nil {
+		report.add("FAIL", file, "does not decrypt (%s): %v", ErrorCategory(err), err)
+		return
+	}
+	report.add("PASS", file, "decrypts in %s", time.Since(start).Round(time.Millisecond))
+}
+
+// checkBackendKeys looks for the local key or credentials a backend needs.
+func checkBackendKeys(report *doctorReport, file, backend string, recipients []string) {
+	name := file + " " + backend
+	switch backend {
+	case "age":
+		checkAgeIdentity(report, name, recipients)
+	case "pgp":
+		checkPGPKeys(report, name, recipients)
+	case "kms":
+		if detectAWSIdentity() {
+			report.add("PASS", name, "AWS credentials found")
+		} else {
+			report.add("WARN", name, "no AWS credentials in the environment or ~/.aws, fine only on an instance or pod with a role")
+		}
+	case "gcp_kms":
+		if detectGCloudIdentity() {
+			report.add("PASS", name, "Google application default credentials found")
+		} else {
+			report.add("WARN", name, "no Google credentials, run `gcloud auth application-default login` or rely on the metadata server")
+		}
+	case "azure_kv":
+		if os.Getenv("AZURE_CLIENT_ID") != "" || homeFileExists(".azure", "azureProfile.json") {
+			report.add("PASS", name, "Azure credentials found")
+		} else {
+			report.add("WARN", name, "no Azure credentials, run `az login` or set AZURE_CLIENT_ID")
+		}
+	case "hc_vault":
+		if os.Getenv("VAULT_TOKEN") != "" || homeFileExists(".vault-token") {
+			report.add("PASS", name, "Vault token found")
+		} else {
+			report.add("FAIL", name, "no Vault token, set VAULT_TOKEN or run `vault login`")
+		}
+	}
+}
+
+func checkAgeIdentity(report *doctorReport, name string, recipients []string) {
+	source, keys := "$SOPS_AGE_KEY", []byte(os.Getenv("SOPS_AGE_KEY"))
+	if len(keys) == 0 {
+		source = ageKeyFile()
+		if source == "" {
+			report.add("FAIL", name, "no age key, set SOPS_AGE_KEY_FILE or create ~/.config/sops/age/keys.txt")
+			return
+		}
+		var err error
+		if keys, err = os.ReadFile(source); err != nil {
+			report.add("FAIL", name, "cannot read %s: %v", source, err)
+			return
+		}
+		for _, problem := range filePermissionProblems(source, true) {
+			report.add("WARN", name, "%s", problem)
+		}
+	}
+	identities, _ := age.ParseIdentities(bytes.NewReader(keys))
+
+	for _, identity := range identities {
+		if x, ok := identity.(*age.X25519Identity); ok && slices.Contains(recipients, x.Recipient().String()) {
+			report.add("PASS", name, "%s holds the key for %s", source, x.Recipient())
+			return
+		}
+	}
+	report.add("FAIL", name, "%s has %d key(s), none for the file's recipients %s", source, len(identities), strings.Join(recipients, ", "))
+}
+
+func checkPGPKeys(report *doctorReport, name string, fingerprints []string) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		report.add("FAIL", name, "gpg not found in $PATH")
+		return
+	}
+	for _, fp := range fingerprints {
+		if exec.Command("gpg", "--list-secret-keys", fp).Run() == nil {
+			report.add("PASS", name, "secret key %s is in the keyring", fp)
+			if exec.Command("gpg-connect-agent", "--no-autostart", "/bye").Run() != nil {
+				report.add("WARN", name, "gpg-agent is not running, passphrase prompts may hang in non-interactive shells")
+			}
+			return
+		}
+	}
+	report.add("FAIL", name, "none of the secret keys %s is in the keyring", strings.Join(fingerprints, ", "))
+}
+
+// backendEndpoints lists the host:port pairs a backend talks to.
+func backendEndpoints(backend string, recipients []string) []string {
+	var endpoints []string
+	add := func(endpoint string) {
+		if endpoint != "" && !slices.Contains(endpoints, endpoint) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	for _, r := range recipients {
+		switch backend {
+		case "kms":
+			// arn:aws:kms:REGION:ACCOUNT:key/ID
+			if parts := strings.Split(r, ":"); len(parts) > 3 && parts[3] != "" {
+				add("kms." + parts[3] + ".amazonaws.com:443")
+			}
+		case "gcp_kms":
+			add("cloudkms.googleapis.com:443")
+		case "azure_kv", "hc_vault":
+			add(urlEndpoint(r))
+		}
+	}
+	return endpoints
+}
+
+func urlEndpoint(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
+
+func checkEndpoint(report *doctorReport, file, backend, endpoint string, timeout time.Duration) {
+	name := file + " " + backend
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", endpoint, timeout)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			report.add("FAIL", name, "cannot resolve %s: %v", endpoint, err)
+		} else {
+			report.add("FAIL", name, "%s unreachable: %v", endpoint, err)
+		}
+		return
+	}
+	conn.Close()
+	report.add("PASS", name, "%s reachable in %s", endpoint, time.Since(start).Round(time.Millisecond))
+}
+
+func homeFileExists(elem ...string) bool {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(append([]string{home}, elem...)...))
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestDoctor(t *testing.T) {
+	installSOPSScript(t, `[ "$1" = --version ] && { echo "sops 3.9.0"; exit 0; }
+for last; do :; done
+sed -n '/^sops_/!p' "$last"
+`)
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := age.GenerateX25519Identity()
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+	t.Setenv("VAULT_TOKEN", "doctor-token")
+
+	vault, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.sops.env")
+	bad := filepath.Join(dir, "bad.sops.env")
+	metadata := "sops_version=3.9.0\nsops_mac=ENC[AES256_GCM,data:x]\n"
+	os.WriteFile(good, []byte("DB_PASSWORD=hunter22\n"+metadata+
+		"sops_age__list_0__map_recipient="+identity.Recipient().String()+"\n"+
+		"sops_hc_vault__list_0__map_vault_address=http://"+vault.Addr().String()+"\n"), 0o600)
+	os.WriteFile(bad, []byte("DB_PASSWORD=hunter22\n"+metadata+
+		"sops_age__list_0__map_recipient="+other.Recipient().String()+"\n"), 0o600)
+
+	var runErr error
+	out := string(captureStdout(t, func() { runErr = runDoctor([]string{"-dial-timeout=1s", good, bad}) }))
+	if runErr == nil || runErr.Error() != "1 check(s) failed" {
+		t.Errorf("runDoctor() = %v, want one failure", runErr)
+	}
+	for _, want := range []string{
+		"PASS sops: ",
+		"PASS " + good + " age: $SOPS_AGE_KEY holds the key",
+		"PASS " + good + " hc_vault: Vault token found",
+		"PASS " + good + " hc_vault: " + vault.Addr().String() + " reachable",
+		"PASS " + good + ": decrypts in",
+		"FAIL " + bad + " age: $SOPS_AGE_KEY has 1 key(s), none for the file's recipients " + other.Recipient().String(),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "hunter22") {
+		t.Errorf("report shows a decrypted value:\n%s", out)
+	}
+
+	previous := outputFormat
+	outputFormat = "json"
+	defer func() { outputFormat = previous }()
+	out = string(captureStdout(t, func() { runErr = runDoctor([]string{"-no-decrypt", good}) }))
+	if runErr != nil {
+		t.Errorf("runDoctor(-no-decrypt) = %v", runErr)
+	}
+	var report struct {
+		Checks []struct {
+			Status string `json:"status"`
+			Name   string `json:"name"`
+		} `json:"checks"`
+		Failures int `json:"failures"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("JSON report: %v\n%s", err, out)
+	}
+	if report.Failures != 0 || report.Checks[len(report.Checks)-1].Status != "SKIP" {
+		t.Errorf("JSON report = %+v", report)
+	}
+}
+
+func TestDoctorWithoutSOPS(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	var runErr error
+	out := string(captureStdout(t, func() { runErr = runDoctor([]string{"-no-decrypt", filepath.Join(t.TempDir(), "missing.sops.env")}) }))
+	if runErr == nil || !strings.Contains(out, "FAIL sops: not found in $PATH") {
+		t.Errorf("runDoctor() = %v:\n%s", runErr, out)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrAmbiguousLine is returned under WithStrictParsing for a line the
+// parser would otherwise skip or read in a way a shell might not.
+var ErrAmbiguousLine = errors.New("ambiguous line")
+
+// envEntry is one KEY=value assignment. Line is where it starts.
+type envEntry struct {
+	Key   string
+	Value string
+	// Line and End are the first and last line of the assignment, which
+	// differ for quoted values spanning lines.
+	Line int
+	End  int
+}
+
+// parseDotenv is the one parser behind every loader. It understands:
+//
+//   - blank lines and # comment lines
+//   - an optional "export " prefix
+//   - unquoted values, trimmed, with an inline comment after " #" removed
+//   - 'single quoted' values, taken literally
+//   - "double quoted" values with \n, \r, \t, \", \\, \$ and \` escapes,
+//     and a backslash before a line break joining the lines
+//   - adjacent quoted parts concatenated as in a shell, so 'it'"'"'s' is it's
+//
+// Quoted values may span lines. Values are never variable-expanded, so a
+// password containing $ is loaded as written. Backslashes in unquoted
+// values are kept, unlike in a shell, so Windows paths and passwords with
+// \ survive. Lines that are not assignments are skipped.
+func parseDotenv(data []byte) ([]envEntry, error) {
+	return dotenvOptions{}.parse(data)
+}
+
+// dotenvOptions changes how parseDotenv reads a file; the zero value is
+// the default behaviour described there.
+type dotenvOptions struct {
+	literalHash  bool
+	emptyIsUnset bool
+	strict       bool
+	duplicates   DuplicatePolicy
+}
+
+// WithoutInlineComments keeps a # in an unquoted value instead of treating
+// " #" as the start of a comment, so KEY=abc #123 loads as "abc #123".
+// Whole-line comments and comments after a closing quote still work.
+func WithoutInlineComments() Option {
+	return func(o *loadOptions) {
+		o.dotenv.literalHash = true
+	}
+}
+
+// WithEmptyAsUnset skips assignments with an empty value, KEY= and KEY="",
+// as if the line were not there. Lookup then reports the key as missing
+// and defaults apply, instead of an empty string overriding them.
+func WithEmptyAsUnset() Option {
+	return func(o *loadOptions) {
+		o.dotenv.emptyIsUnset = true
+	}
+}
+
+// WithStrictParsing makes lines the parser would skip or guess at an error
+// wrapping ErrAmbiguousLine: lines that are not KEY=value, invalid key
+// names, unquoted values with spaces, quotes or a leading #, and text after
+// a closing quote that is not a comment.
+func WithStrictParsing() Option {
+	return func(o *loadOptions) {
+		o.dotenv.strict = true
+	}
+}
+
+func (o dotenvOptions) parse(data []byte) ([]envEntry, error) {
+	p := &dotenvParser{src: string(data), opts: o}
+	if err := p.checkEncoding(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]envEntry, 0, strings.Count(p.src, "\n")+1)
+	for !p.eof() {
+		entry, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// dotenvMap collapses entries into a map, the last assignment of a key
+// winning, and returns the keys in order of first appearance.
+func dotenvMap(entries []envEntry) (map[string]string, []string) {
+	file, _ := dotenvOptions{}.collect(entries)
+	return file.values, file.keys
+}
+
+// DuplicatePolicy decides which assignment of a key that appears more than
+// once in a file is used.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLastWins uses the last assignment, as a shell would.
+	DuplicateLastWins DuplicatePolicy = iota
+	// DuplicateFirstWins uses the first assignment.
+	DuplicateFirstWins
+	// DuplicateError fails the load with ErrDuplicateKey.
+	DuplicateError
+)
+
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// DuplicateKey is a key assigned more than once, with the lines it is on.
+type DuplicateKey struct {
+	Key   string `json:"key"`
+	Lines []int  `json:"lines"`
+}
+
+// WithDuplicateKeys sets what happens when a key is assigned more than
+// once. Whatever the policy, the duplicates are logged and reported by
+// EnvConfig.Duplicates, so a shadowed value doesn't go unnoticed.
+func WithDuplicateKeys(policy DuplicatePolicy) Option {
+	return func(o *loadOptions) {
+		o.dotenv.duplicates = policy
+	}
+}
+
+// collect is dotenvMap under the duplicate policy. It also records the
+// line each value came from and the duplicated keys, in order of first
+// appearance.
+func (o dotenvOptions) collect(entries []envEntry) (*decodedEnv, error) {
+	envMap := make(map[string]string, len(entries))
+	keys := make([]string, 0, len(entries))
+	lines := make(map[string]int, len(entries))
+	var dups []DuplicateKey
+	for _, e := range entries {
+		line, seen := lines[e.Key]
+		if !seen {
+			lines[e.Key] = e.Line
+			keys = append(keys, e.Key)
+			envMap[e.Key] = e.Value
+			continue
+		}
+
+		i := slices.IndexFunc(dups, func(d DuplicateKey) bool { return d.Key == e.Key })
+		if i < 0 {
+			dups = append(dups, DuplicateKey{Key: e.Key, Lines: []int{line}})
+			i = len(dups) - 1
+		}
+		dups[i].Lines = append(dups[i].Lines, e.Line)
+		if o.duplicates != DuplicateFirstWins {
+			envMap[e.Key] = e.Value
+			lines[e.Key] = e.Line
+		}
+	}
+
+	if len(dups) > 0 && o.duplicates == DuplicateError {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateKey, formatDuplicates(dups))
+	}
+	return &decodedEnv{values: envMap, keys: keys, lines: lines, duplicates: dups}, nil
+}
+
+// formatDuplicates renders duplicates as "A (lines 1, 4), B (lines 2, 9)".
+func formatDuplicates(dups []DuplicateKey) string {
+	parts := make([]string, len(dups))
+	for i, d := range dups {
+		lines := make([]string, len(d.Lines))
+		for j, line := range d.Lines {
+			lines[j] = strconv.Itoa(line)
+		}
+		parts[i] = fmt.Sprintf("%s (lines %s)", d.Key, strings.Join(lines, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+	opts dotenvOptions
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// checkEncoding drops a UTF-8 BOM and rejects files that aren't UTF-8,
+// naming the first bad line. Files saved on Windows otherwise load with a
+// BOM glued to the first key and \r on every value.
+func (p *dotenvParser) checkEncoding() error {
+	if strings.HasPrefix(p.src, "\xff\xfe") || strings.HasPrefix(p.src, "\xfe\xff") {
+		return errors.New("file is UTF-16, save it as UTF-8")
+	}
+	p.src = strings.TrimPrefix(p.src, "\ufeff")
+	if utf8.ValidString(p.src) {
+		return nil
+	}
+	line := 1
+	for i, r := range p.src {
+		if r == '\n' {
+			line++
+		}
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(p.src[i:]); size <= 1 {
+				break
+			}
+		}
+	}
+	return fmt.Errorf("line %d: invalid UTF-8, save the file as UTF-8", line)
+}
+
+// readLine returns the next line without its line ending, LF or CRLF.
+func (p *dotenvParser) readLine() string {
+	p.line++
+	rest := p.src[p.pos:]
+	line, _, found := strings.Cut(rest, "\n")
+	if found {
+		p.pos += len(line) + 1
+	} else {
+		p.pos = len(p.src)
+	}
+	return strings.TrimSuffix(line, "\r")
+}
+
+func (p *dotenvParser) next() (envEntry, bool, error) {
+	line := strings.TrimSpace(p.readLine())
+	start := p.line
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return envEntry{}, false, nil
+	}
+	if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		line = strings.TrimSpace(rest)
+	}
+
+	key, rest, ok := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !ok {
+		return p.skip(start, "not a KEY=value assignment")
+	}
+	if !validEnvKey(key) {
+		return p.skip(start, fmt.Sprintf("invalid key %q", key))
+	}
+	rest = strings.TrimLeft(rest, " \t")
+
+	if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+		return p.unquoted(key, rest, start)
+	}
+
+	var value string
+	for first := true; rest != "" && (rest[0] == '"' || rest[0] == '\''); first = false {
+		quote := rest[0]
+		body := rest[1:]
+		end := closingQuote(body, quote)
+		for end < 0 {
+			if p.eof() {
+				return envEntry{}, false, fmt.Errorf("line %d: unterminated quoted value for %s", start, key)
+			}
+			body += "\n" + p.readLine()
+			end = closingQuote(body, quote)
+		}
+
+		part := body[:end]
+		if quote == '"' {
+			part = unescapeDoubleQuoted(part)
+		}
+		// A single part stays a substring of the source, sharing its memory.
+		if first {
+			value = part
+		} else {
+			value += part
+		}
+		rest = body[end+1:]
+	}
+
+	if trailing := strings.TrimLeft(rest, " \t"); trailing != "" && trailing[0] != '#' && p.opts.strict {
+		return envEntry{}, false, fmt.Errorf("line %d: %w: unexpected %q after the closing quote of %s", start, ErrAmbiguousLine, trailing, key)
+	}
+	if value == "" && p.opts.emptyIsUnset {
+		return envEntry{}, false, nil
+	}
+	return envEntry{Key: key, Value: value, Line: start, End: p.line}, true, nil
+}
+
+func (p *dotenvParser) unquoted(key, rest string, start int) (envEntry, bool, error) {
+	value := strings.TrimSpace(rest)
+	if !p.opts.literalHash {
+		value = stripInlineComment(rest)
+	}
+
+	if p.opts.strict {
+		var problem string
+		switch {
+		case !p.opts.literalHash && strings.HasPrefix(value, "#"):
+			problem = "value starts with #, quote it or remove the space before the comment"
+		case strings.ContainsAny(value, " \t"):
+			problem = "unquoted value contains whitespace, quote it"
+		case strings.ContainsAny(value, `"'`):
+			problem = "unquoted value contains a quote"
+		}
+		if problem != "" {
+			return envEntry{}, false, fmt.Errorf("line %d: %w: %s: %s", start, ErrAmbiguousLine, key, problem)
+		}
+	}
+	if value == "" && p.opts.emptyIsUnset {
+		return envEntry{}, false, nil
+	}
+	return envEntry{Key: key, Value: value, Line: start, End: p.line}, true, nil
+}
+
+// skip ignores a line that is not an assignment, or rejects it when strict.
+func (p *dotenvParser) skip(line int, reason string) (envEntry, bool, error) {
+	if p.opts.strict {
+		return envEntry{}, false, fmt.Errorf("line %d: %w: %s", line, ErrAmbiguousLine, reason)
+	}
+	return envEntry{}, false, nil
+}
+
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func stripInlineComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+func closingQuote(body string, quote byte) int {
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDoubleQuoted(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$', '`':
+			b.WriteByte(value[i])
+		case '\n':
+			// Line continuation, as in a shell.
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// quoteDotenvValue renders value so that parseDotenv reads it back as is,
+// on a single line.
+func quoteDotenvValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n\"'`\\$#=") {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$', '`':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []envEntry
+	}{
+		{
+			name:  "unquoted",
+			input: "A=1\nB = two words \n",
+			want:  []envEntry{{"A", "1", 1, 1}, {"B", "two words", 2, 2}},
+		},
+		{
+			name:  "comments and blank lines",
+			input: "# header\n\n  # indented\nA=1\n",
+			want:  []envEntry{{"A", "1", 4, 4}},
+		},
+		{
+			name:  "inline comment",
+			input: "A=abc #comment\nB=abc#not-a-comment\nC=\"x\" # after quote\n",
+			want:  []envEntry{{"A", "abc", 1, 1}, {"B", "abc#not-a-comment", 2, 2}, {"C", "x", 3, 3}},
+		},
+		{
+			name:  "export prefix",
+			input: "export A=1\nexport\tB=2\nexported=3\n",
+			want:  []envEntry{{"A", "1", 1, 1}, {"B", "2", 2, 2}, {"exported", "3", 3, 3}},
+		},
+		{
+			name:  "single quotes are literal",
+			input: `A='$HOME \n "x" # y'` + "\n",
+			want:  []envEntry{{"A", `$HOME \n "x" # y`, 1, 1}},
+		},
+		{
+			name:  "double quote escapes",
+			input: `A="a\nb\tc\r\"d\" \\ \$HOME \` + "`" + `x\` + "`" + ` \q"` + "\n",
+			want:  []envEntry{{"A", "a\nb\tc\r\"d\" \\ $HOME `x` \\q", 1, 1}},
+		},
+		{
+			name:  "no variable expansion",
+			input: "A=pa$$word\nB=\"${HOME}\"\n",
+			want:  []envEntry{{"A", "pa$$word", 1, 1}, {"B", "${HOME}", 2, 2}},
+		},
+		{
+			name:  "backslashes kept unquoted",
+			input: `A=C:\Users\app` + "\n",
+			want:  []envEntry{{"A", `C:\Users\app`, 1, 1}},
+		},
+		{
+			name:  "multiline double quoted",
+			input: "A=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nB=2\n",
+			want:  []envEntry{{"A", "-----BEGIN KEY-----\nabc\n-----END KEY-----", 1, 3}, {"B", "2", 4, 4}},
+		},
+		{
+			name:  "multiline single quoted",
+			input: "A='line 1\nline 2'\n",
+			want:  []envEntry{{"A", "line 1\nline 2", 1, 2}},
+		},
+		{
+			name:  "line continuation",
+			input: "A=\"abc\\\ndef\"\n",
+			want:  []envEntry{{"A", "abcdef", 1, 2}},
+		},
+		{
+			name:  "adjacent quoted parts",
+			input: `A='it'"'"'s'` + "\n" + `B="a"'$b'"c"` + "\n",
+			want:  []envEntry{{"A", "it's", 1, 1}, {"B", "a$bc", 2, 2}},
+		},
+		{
+			name:  "empty values",
+			input: "A=\nB=\"\"\nC=''\n",
+			want:  []envEntry{{"A", "", 1, 1}, {"B", "", 2, 2}, {"C", "", 3, 3}},
+		},
+		{
+			name:  "equals in value",
+			input: "A=a=b=c\n",
+			want:  []envEntry{{"A", "a=b=c", 1, 1}},
+		},
+		{
+			name:  "non-assignments skipped",
+			input: "just text\n1BAD=x\nA=1\n",
+			want:  []envEntry{{"A", "1", 3, 3}},
+		},
+		{
+			name:  "dots and dashes in keys",
+			input: "app.db-host=x\n",
+			want:  []envEntry{{"app.db-host", "x", 1, 1}},
+		},
+		{
+			name:  "CRLF and BOM",
+			input: "\ufeffA=1\r\nB=\"x\"\r\n",
+			want:  []envEntry{{"A", "1", 1, 1}, {"B", "x", 2, 2}},
+		},
+		{
+			name:  "no trailing newline",
+			input: "A=1",
+			want:  []envEntry{{"A", "1", 1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotenv([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseDotenv() error = %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    dotenvOptions
+		wantErr string
+		is      error
+	}{
+		{
+			name:    "unterminated double quote",
+			input:   "A=1\nB=\"abc\nC=2\n",
+			wantErr: "line 2: unterminated quoted value for B",
+		},
+		{
+			name:    "unterminated single quote",
+			input:   "A='abc",
+			wantErr: "line 1: unterminated quoted value for A",
+		},
+		{
+			name:    "escaped closing quote",
+			input:   `A="abc\"` + "\n",
+			wantErr: "line 1: unterminated quoted value for A",
+		},
+		{
+			name:    "invalid UTF-8",
+			input:   "A=1\nB=2\nC=\xff\n",
+			wantErr: "line 3: invalid UTF-8",
+		},
+		{
+			name:    "UTF-16",
+			input:   "\xff\xfeA\x00=\x001\x00",
+			wantErr: "file is UTF-16",
+		},
+		{
+			name:    "strict not an assignment",
+			input:   "A=1\n\njust text\n",
+			opts:    dotenvOptions{strict: true},
+			wantErr: "line 3: ambiguous line: not a KEY=value assignment",
+			is:      ErrAmbiguousLine,
+		},
+		{
+			name:    "strict invalid key",
+			input:   "1A=1\n",
+			opts:    dotenvOptions{strict: true},
+			wantErr: `line 1: ambiguous line: invalid key "1A"`,
+			is:      ErrAmbiguousLine,
+		},
+		{
+			name:    "strict unquoted whitespace",
+			input:   "A=two words\n",
+			opts:    dotenvOptions{strict: true},
+			wantErr: "line 1: ambiguous line: A: unquoted value contains whitespace",
+			is:      ErrAmbiguousLine,
+		},
+		{
+			name:    "strict leading hash",
+			input:   "A= #x\n",
+			opts:    dotenvOptions{strict: true},
+			wantErr: "line 1: ambiguous line: A: value starts with #",
+			is:      ErrAmbiguousLine,
+		},
+		{
+			name:    "strict text after closing quote",
+			input:   "A=1\nB=\"x\ny\" z\n",
+			opts:    dotenvOptions{strict: true},
+			wantErr: `line 2: ambiguous line: unexpected "z" after the closing quote of B`,
+			is:      ErrAmbiguousLine,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.opts.parse([]byte(tt.input))
+			if err == nil {
+				t.Fatalf("parse() error = nil, want %q", tt.wantErr)
+			}
+			if !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("parse() error = %q, want prefix %q", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("parse() error = %v, want errors.Is %v", err, tt.is)
+			}
+		})
+	}
+}
+
+func TestParseDotenvOptions(t *testing.T) {
+	input := "A=abc #123\nB=\nC=\"\"\nD=1\n"
+	tests := []struct {
+		name string
+		opts dotenvOptions
+		want []envEntry
+	}{
+		{"default", dotenvOptions{}, []envEntry{{"A", "abc", 1, 1}, {"B", "", 2, 2}, {"C", "", 3, 3}, {"D", "1", 4, 4}}},
+		{"literal hash", dotenvOptions{literalHash: true}, []envEntry{{"A", "abc #123", 1, 1}, {"B", "", 2, 2}, {"C", "", 3, 3}, {"D", "1", 4, 4}}},
+		{"empty as unset", dotenvOptions{emptyIsUnset: true}, []envEntry{{"A", "abc", 1, 1}, {"D", "1", 4, 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.parse([]byte(input))
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectDuplicates(t *testing.T) {
+	entries, err := parseDotenv([]byte("A=1\nB=x\nA=2\nA=3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		policy  DuplicatePolicy
+		want    string
+		wantErr string
+	}{
+		{"last wins", DuplicateLastWins, "3", ""},
+		{"first wins", DuplicateFirstWins, "1", ""},
+		{"error", DuplicateError, "", "duplicate key: A (lines 1, 3, 4)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := dotenvOptions{duplicates: tt.policy}.collect(entries)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || !errors.Is(err, ErrDuplicateKey) {
+					t.Fatalf("collect() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("collect() error = %v", err)
+			}
+			if got := file.values["A"]; got != tt.want {
+				t.Errorf("A = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(file.keys, []string{"A", "B"}) {
+				t.Errorf("keys = %q, want [A B]", file.keys)
+			}
+			want := []DuplicateKey{{Key: "A", Lines: []int{1, 3, 4}}}
+			if !reflect.DeepEqual(file.duplicates, want) {
+				t.Errorf("duplicates = %v, want %v", file.duplicates, want)
+			}
+		})
+	}
+}
+
+func TestQuoteDotenvValueRoundTrip(t *testing.T) {
+	values := []string{
+		"",
+		"plain",
+		"two words",
+		"it's",
+		`say "hi"`,
+		"pa$$word",
+		`C:\path\to`,
+		"line 1\nline 2\r\n",
+		"tab\there",
+		"#not a comment",
+		"a=b",
+		"`cmd`",
+		"ünïcode",
+	}
+	for _, value := range values {
+		quoted := quoteDotenvValue(value)
+		if strings.ContainsAny(quoted, "\n") {
+			t.Errorf("quoteDotenvValue(%q) = %q spans lines", value, quoted)
+		}
+		entries, err := parseDotenv([]byte("KEY=" + quoted + "\n"))
+		if err != nil {
+			t.Fatalf("parseDotenv(%q) error = %v", quoted, err)
+		}
+		if len(entries) != 1 || entries[0].Value != value {
+			t.Errorf("round trip of %q through %q = %q", value, quoted, entries)
+		}
+	}
+}
+
+// benchmarkEnvFile is a config.sops.env-sized file after decryption, with
+// n assignments in the styles the parser handles.
+func benchmarkEnvFile(n int) []byte {
+	var b strings.Builder
+	b.WriteString("# generated for benchmarks\n\n")
+	for i := range n {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "KEY_%d=value-%d\n", i, i)
+		case 1:
+			fmt.Fprintf(&b, "export KEY_%d=\"quoted value %d with \\\"escapes\\\"\\n\"\n", i, i)
+		case 2:
+			fmt.Fprintf(&b, "KEY_%d='single $quoted %d' # comment\n", i, i)
+		case 3:
+			fmt.Fprintf(&b, "KEY_%d=\"-----BEGIN KEY-----\nline %d\n-----END KEY-----\"\n", i, i)
+		}
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkParseDotenv(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		data := benchmarkEnvFile(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if _, err := parseDotenv(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// plaintextDecryptor returns content for every file, for tests of how the
+// loaders parse it.
+func plaintextDecryptor(content string) Decryptor {
+	return DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return []byte(content), nil
+	})
+}
+
+func TestLoadSOPSEnvToSystemParsesShellSyntax(t *testing.T) {
+	unsetForTest(t, "SHELL_HOST", "SHELL_PASSWORD", "SHELL_KEY", "SHELL_LITERAL")
+	content := "export SHELL_HOST=db.internal\n" +
+		"SHELL_PASSWORD='pa$$ \"word\"'\n" +
+		"SHELL_KEY=\"-----BEGIN KEY-----\\nabc\\n-----END KEY-----\"\n" +
+		"SHELL_LITERAL='a\\nb'\n"
+	if err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(plaintextDecryptor(content))); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"SHELL_HOST":     "db.internal",
+		"SHELL_PASSWORD": `pa$$ "word"`,
+		"SHELL_KEY":      "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+		"SHELL_LITERAL":  `a\nb`,
+	}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestLoadSOPSEnvCommentAndEmptyOptions(t *testing.T) {
+	decryptor := WithDecryptor(plaintextDecryptor("DB_PASSWORD=abc #123\nDB_HOST=\n"))
+
+	config, err := LoadSOPSEnv("config.sops.env", decryptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "abc" {
+		t.Errorf("DBPassword = %q, want the comment stripped", config.DBPassword)
+	}
+	if value, ok := config.Lookup("DB_HOST"); !ok || value != "" {
+		t.Errorf(`Lookup("DB_HOST") = %q, %v; want "", true`, value, ok)
+	}
+	if _, ok := config.Lookup("DB_PORT"); ok {
+		t.Error(`Lookup("DB_PORT") found a key the file doesn't set`)
+	}
+
+	config, err = LoadSOPSEnv("config.sops.env", decryptor, WithoutInlineComments(), WithEmptyAsUnset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "abc #123" {
+		t.Errorf("DBPassword = %q under WithoutInlineComments", config.DBPassword)
+	}
+	if _, ok := config.Lookup("DB_HOST"); ok {
+		t.Error("DB_HOST= is set under WithEmptyAsUnset")
+	}
+}
+
+func TestLoadSOPSEnvStrictParsing(t *testing.T) {
+	decryptor := WithDecryptor(plaintextDecryptor("DB_HOST=db\nDB_PASSWORD=two words\n"))
+
+	config, err := LoadSOPSEnv("config.sops.env", decryptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBHost != "db" {
+		t.Errorf("DBHost = %q", config.DBHost)
+	}
+
+	_, err = LoadSOPSEnv("config.sops.env", decryptor, WithStrictParsing())
+	if !errors.Is(err, ErrAmbiguousLine) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadSOPSEnv() under WithStrictParsing = %v, want ErrAmbiguousLine on line 2", err)
+	}
+}
+
+func TestLoadSOPSEnvWindowsText(t *testing.T) {
+	content := "\ufeffDB_HOST=db\r\nDB_PASSWORD=\"hunter22\"\r\nDB_USER=app\r\n"
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(plaintextDecryptor(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBHost != "db" || config.DBPassword != "hunter22" || config.DBUser != "app" {
+		t.Errorf("loaded host %q, password %q, user %q", config.DBHost, config.DBPassword, config.DBUser)
+	}
+
+	_, err = LoadSOPSEnv("config.sops.env", WithDecryptor(plaintextDecryptor("DB_HOST=db\nDB_PASSWORD=caf\xe9\n")))
+	if err == nil || !strings.Contains(err.Error(), "line 2: invalid UTF-8") {
+		t.Errorf("LoadSOPSEnv() with Latin-1 text = %v", err)
+	}
+	_, err = LoadSOPSEnv("config.sops.env", WithDecryptor(plaintextDecryptor("\xff\xfeD\x00B\x00")))
+	if err == nil || !strings.Contains(err.Error(), "UTF-16") {
+		t.Errorf("LoadSOPSEnv() with UTF-16 text = %v", err)
+	}
+}
+
+func TestLoadSOPSEnvDuplicateKeys(t *testing.T) {
+	decryptor := WithDecryptor(plaintextDecryptor("DB_PASSWORD=first\nDB_HOST=db\nDB_PASSWORD=second\n"))
+
+	config, err := LoadSOPSEnv("config.sops.env", decryptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "second" {
+		t.Errorf("DBPassword = %q, want the last value by default", config.DBPassword)
+	}
+	want := []DuplicateKey{{Key: "DB_PASSWORD", Lines: []int{1, 3}}}
+	if !reflect.DeepEqual(config.Duplicates(), want) {
+		t.Errorf("Duplicates() = %v, want %v", config.Duplicates(), want)
+	}
+
+	config, err = LoadSOPSEnv("config.sops.env", decryptor, WithDuplicateKeys(DuplicateFirstWins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBPassword != "first" {
+		t.Errorf("DBPassword = %q under DuplicateFirstWins", config.DBPassword)
+	}
+
+	_, err = LoadSOPSEnv("config.sops.env", decryptor, WithDuplicateKeys(DuplicateError))
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("LoadSOPSEnv() under DuplicateError = %v", err)
+	}
+	if err == nil || strings.Contains(err.Error(), "first") || strings.Contains(err.Error(), "second") {
+		t.Errorf("error %v should name the key, not its values", err)
+	}
+}
+
+func TestEnvConfigOrderedIteration(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	content := "ZETA=1\nALPHA=2\nOLD_NAME=3\nMIDDLE=4\nALPHA=5\n"
+	config, err := LoadSOPSEnv("config.sops.env",
+		WithDecryptor(plaintextDecryptor(content)),
+		WithDeprecations(Deprecation{Old: "OLD_NAME", New: "NEW_NAME"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := config.Keys(), []string{"ZETA", "ALPHA", "MIDDLE", "NEW_NAME"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	var all []string
+	for key, value := range config.All() {
+		all = append(all, key+"="+value)
+	}
+	if want := []string{"ZETA=1", "ALPHA=5", "MIDDLE=4", "NEW_NAME=3"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("All() = %q, want %q", all, want)
+	}
+	var sorted []string
+	for key := range config.Sorted() {
+		sorted = append(sorted, key)
+	}
+	if want := []string{"ALPHA", "MIDDLE", "NEW_NAME", "ZETA"}; !reflect.DeepEqual(sorted, want) {
+		t.Errorf("Sorted() = %q, want %q", sorted, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// systemKeys remembers what LoadSOPSEnvToSystem has set, so a later drift
+// report can tell keys removed from the file apart from unrelated variables.
+var (
+	systemKeysMu sync.Mutex
+	systemKeys   = make(map[string]bool)
+)
+
+func rememberSystemKey(key string) {
+	systemKeysMu.Lock()
+	defer systemKeysMu.Unlock()
+	systemKeys[key] = true
+}
+
+type Drift struct {
+	File    string
+	Added   []string
+	Removed []string
+	Changed []DriftChange
+}
+
+// DriftChange holds masked values only.
+type DriftChange struct {
+	Key     string
+	Current string
+	File    string
+}
+
+func (d *Drift) HasDrift() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+func (d *Drift) String() string {
+	if !d.HasDrift() {
+		return fmt.Sprintf("✅ environment matches %s", d.File)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ environment differs from %s:\n", d.File)
+	for _, key := range d.Added {
+		fmt.Fprintf(&b, "  + %s (in file, not in environment)\n", key)
+	}
+	for _, key := range d.Removed {
+		fmt.Fprintf(&b, "  - %s (in environment, no longer in file)\n", key)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "  ~ %s: %s -> %s\n", c.Key, c.Current, c.File)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// DriftReport compares the decrypted file against the current process
+// environment. Added keys are in the file but not set, changed keys are set
+// to a different value, and removed keys were set by LoadSOPSEnvToSystem but
+// are gone from the file.
+func DriftReport(filename string, opts ...Option) (*Drift, error) {
+	options := newLoadOptions(opts)
+
+	envMap, err := readSOPSEnvMap(context.Background(), filename, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyDeprecations(filename, envMap, options.deprecations); err != nil {
+		return nil, err
+	}
+	if _, err := extractExpiries(filename, envMap); err != nil {
+		return nil, err
+	}
+
+	systemEnvMu.RLock()
+	defer systemEnvMu.RUnlock()
+
+	drift := &Drift{File: filename}
+	for key, value := range envMap {
+		current, ok := os.LookupEnv(key)
+		switch {
+		case !ok:
+			drift.Added = append(drift.Added, key)
+		case current != value:
+			drift.Changed = append(drift.Changed, DriftChange{
+				Key:     key,
+				Current: DefaultMaskPolicy.MaskValue(key, current),
+				File:    DefaultMaskPolicy.MaskValue(key, value),
+			})
+		}
+	}
+
+	systemKeysMu.Lock()
+	for key := range systemKeys {
+		if _, inFile := envMap[key]; inFile {
+			continue
+		}
+		if _, ok := os.LookupEnv(key); ok {
+			drift.Removed = append(drift.Removed, key)
+		}
+	}
+	systemKeysMu.Unlock()
+
+	sort.Strings(drift.Added)
+	sort.Strings(drift.Removed)
+	sort.Slice(drift.Changed, func(i, j int) bool { return drift.Changed[i].Key < drift.Changed[j].Key })
+	return drift, nil
+}
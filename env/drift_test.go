@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+// unsetForTest unsets keys and restores them after the test.
+func unsetForTest(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestDriftReport(t *testing.T) {
+	unsetForTest(t, "DRIFT_HOST", "DRIFT_PASSWORD", "DRIFT_OLD", "DRIFT_NEW")
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DRIFT_HOST": "db", "DRIFT_PASSWORD": "hunter22", "DRIFT_OLD": "x"})
+	if err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake)); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := DriftReport("config.sops.env", WithDecryptor(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift.HasDrift() {
+		t.Fatalf("drift right after loading: %s", drift)
+	}
+
+	fake.SetFile("config.sops.env", map[string]string{"DRIFT_HOST": "db", "DRIFT_PASSWORD": "hunter23", "DRIFT_NEW": "y"})
+	drift, err = DriftReport("config.sops.env", WithDecryptor(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(drift.Added, []string{"DRIFT_NEW"}) || !slices.Equal(drift.Removed, []string{"DRIFT_OLD"}) {
+		t.Errorf("Added = %q, Removed = %q", drift.Added, drift.Removed)
+	}
+	want := []DriftChange{{Key: "DRIFT_PASSWORD", Current: "hu****22", File: "hu****23"}}
+	if !slices.Equal(drift.Changed, want) {
+		t.Errorf("Changed = %+v, want %+v", drift.Changed, want)
+	}
+	if report := drift.String(); strings.Contains(report, "hunter2") {
+		t.Errorf("report shows a secret:\n%s", report)
+	}
+}
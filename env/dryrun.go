@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DryRunReport describes what a load would do without exposing any value.
+type DryRunReport struct {
+	File    string
+	Entries []DryRunEntry
+}
+
+type DryRunEntry struct {
+	Key string
+	// Type is the kind of value inferred from its text: bool, int, float,
+	// duration, url, json or string.
+	Type string
+	// Source is where the value comes from, including a deprecated key it
+	// was renamed from.
+	Source string
+	// Field is the EnvConfig field the key maps to, if any.
+	Field  string
+	Secret bool
+	// Overrides reports that the process environment already holds a
+	// different value for the key.
+	Overrides bool
+	// Skipped reports that WithAllowedKeys/WithDeniedKeys keep the key out
+	// of the process environment.
+	Skipped bool
+}
+
+// WithDryRun makes the loaders decrypt and map the file as usual but fill
+// report instead of setting variables or returning values. LoadSOPSEnv
+// returns an empty EnvConfig.
+func WithDryRun(report *DryRunReport) Option {
+	return func(o *loadOptions) {
+		o.dryRun = report
+	}
+}
+
+func keySet(envMap map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(envMap))
+	for key := range envMap {
+		keys[key] = true
+	}
+	return keys
+}
+
+// envConfigFields maps variable names to EnvConfig field names.
+var envConfigFields = func() map[string]string {
+	fields := make(map[string]string)
+	t := reflect.TypeOf(EnvConfig{})
+	for i := range t.NumField() {
+		if key := t.Field(i).Tag.Get("env"); key != "" {
+			fields[key] = t.Field(i).Name
+		}
+	}
+	return fields
+}()
+
+// renamedKeys maps each key WithDeprecations filled in to the old key its
+// value came from. original is the file's keys before the renames.
+func renamedKeys(original map[string]bool, deprecations []Deprecation) map[string]string {
+	renamedFrom := make(map[string]string)
+	for _, d := range deprecations {
+		if original[d.Old] && !original[d.New] {
+			renamedFrom[d.New] = d.Old
+		}
+	}
+	return renamedFrom
+}
+
+func fillDryRunReport(filename string, original map[string]bool, envMap map[string]string, options *loadOptions) {
+	renamedFrom := renamedKeys(original, options.deprecations)
+
+	report := DryRunReport{File: filename}
+	for key, value := range envMap {
+		entry := DryRunEntry{
+			Key:     key,
+			Type:    inferType(value),
+			Source:  filename,
+			Field:   envConfigFields[key],
+			Secret:  DefaultMaskPolicy.IsSecretValue(key, value),
+			Skipped: !options.systemKeyAllowed(key),
+		}
+		if old, ok := renamedFrom[key]; ok {
+			entry.Source = fmt.Sprintf("%s (renamed from %s)", filename, old)
+		}
+		if current, ok := os.LookupEnv(key); ok && current != value {
+			entry.Overrides = true
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Key < report.Entries[j].Key })
+	*options.dryRun = report
+}
+
+func inferType(value string) string {
+	if _, err := strconv.ParseBool(value); err == nil {
+		return "bool"
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "float"
+	}
+	if _, err := time.ParseDuration(value); err == nil {
+		return "duration"
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		return "url"
+	}
+	if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+		if json.Valid([]byte(value)) {
+			return "json"
+		}
+	}
+	return "string"
+}
+
+func (r *DryRunReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧪 dry run of %s (%d keys):\n", r.File, len(r.Entries))
+	for _, e := range r.Entries {
+		var flags []string
+		if e.Secret {
+			flags = append(flags, "secret")
+		}
+		if e.Overrides {
+			flags = append(flags, "overrides env")
+		}
+		if e.Skipped {
+			flags = append(flags, "skipped by filter")
+		}
+		if e.Field != "" {
+			flags = append(flags, "field "+e.Field)
+		}
+		fmt.Fprintf(&b, "  %s (%s) from %s", e.Key, e.Type, e.Source)
+		if len(flags) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(flags, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestDryRunSetsNothing(t *testing.T) {
+	unsetForTest(t, "DRYRUN_PORT", "DRYRUN_URL", "DB_PASSWORD", "DRYRUN_OLD", "DRYRUN_NEW")
+	t.Setenv("DRYRUN_PORT", "80")
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{
+		"DRYRUN_PORT": "8080", "DRYRUN_URL": "https://api.example.com", "DB_PASSWORD": "hunter22", "DRYRUN_OLD": "1s",
+	})
+
+	var report DryRunReport
+	err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake), WithDryRun(&report),
+		WithDeprecations(Deprecation{Old: "DRYRUN_OLD", New: "DRYRUN_NEW"}), WithDeniedKeys("DRYRUN_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DRYRUN_PORT") != "80" {
+		t.Error("dry run changed DRYRUN_PORT")
+	}
+	if _, ok := os.LookupEnv("DB_PASSWORD"); ok {
+		t.Error("dry run set DB_PASSWORD")
+	}
+
+	want := []DryRunEntry{
+		{Key: "DB_PASSWORD", Type: "string", Source: "config.sops.env", Field: "DBPassword", Secret: true},
+		{Key: "DRYRUN_NEW", Type: "duration", Source: "config.sops.env (renamed from DRYRUN_OLD)"},
+		{Key: "DRYRUN_PORT", Type: "int", Source: "config.sops.env", Overrides: true},
+		{Key: "DRYRUN_URL", Type: "url", Source: "config.sops.env", Skipped: true},
+	}
+	if !slices.Equal(report.Entries, want) {
+		t.Errorf("Entries =\n%+v\nwant\n%+v", report.Entries, want)
+	}
+	if out := report.String(); strings.Contains(out, "hunter22") || strings.Contains(out, "8080") {
+		t.Errorf("report shows values:\n%s", out)
+	}
+}
+
+func TestDryRunLoadReturnsEmptyConfig(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DB_HOST": "db"})
+	var report DryRunReport
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake), WithDryRun(&report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Get("DB_HOST") != "" || len(report.Entries) != 1 {
+		t.Errorf("DB_HOST = %q, report %+v", config.Get("DB_HOST"), report)
+	}
+}
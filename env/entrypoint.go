@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ExitCodeError asks main to exit with Code without logging, so the
+// wrapper's exit status is the child's.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+type supervisorConfig struct {
+	filename     string
+	args         []string
+	watch        bool
+	reloadSignal os.Signal
+	interval     time.Duration
+	grace        time.Duration
+	alerter      *Alerter
+}
+
+func parseSupervisorFlags(name string, args []string, watch bool) (*supervisorConfig, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to load")
+	signalName := fs.String("signal", "", "send this signal on change instead of restarting (e.g. HUP, USR1)")
+	interval := fs.Duration("interval", time.Second, "how often to check the file for changes")
+	grace := fs.Duration("grace", 10*time.Second, "how long to wait for the child to exit before killing it")
+	alertSlack := fs.String("alert-slack", "", "Slack incoming webhook to alert when decryption keeps failing")
+	alertTeams := fs.String("alert-teams", "", "Teams workflow webhook to alert when decryption keeps failing")
+	alertAfter := fs.Int("alert-after", DefaultAlertThreshold, "consecutive failures before alerting, 1 to alert on a failed start")
+	watchFlag := &watch
+	if !watch {
+		watchFlag = fs.Bool("watch", false, "restart or signal the child when the file changes")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := &supervisorConfig{filename: *filename, args: fs.Args(), watch: *watchFlag, interval: *interval, grace: *grace}
+	switch {
+	case *alertSlack != "":
+		cfg.alerter = NewSlackAlerter(*alertSlack, *alertAfter)
+	case *alertTeams != "":
+		cfg.alerter = NewTeamsAlerter(*alertTeams, *alertAfter)
+	}
+	if len(cfg.args) == 0 {
+		return nil, fmt.Errorf("%s: missing command, use: %s -f config.sops.env -- <command> [args...]", name, name)
+	}
+	if *signalName != "" {
+		sig, err := parseSignal(*signalName)
+		if err != nil {
+			return nil, err
+		}
+		cfg.reloadSignal = sig
+	}
+	return cfg, nil
+}
+
+// runExec is meant to be a container ENTRYPOINT: it forwards every signal
+// to the child, reaps zombies when running as PID 1 and exits with the
+// child's exit code.
+func runExec(args []string) error {
+	cfg, err := parseSupervisorFlags("exec", args, false)
+	if err != nil {
+		return err
+	}
+	return supervise(cfg)
+}
+
+// supervise runs the child with the decrypted env until it exits. The
+// child gets every signal we receive; after a terminating one it has the
+// grace period to exit before it is killed.
+func supervise(cfg *supervisorConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs)
+	defer signal.Stop(sigs)
+
+	env, err := readSOPSEnvMap(ctx, cfg.filename, newLoadOptions(nil))
+	cfg.observe(err)
+	if err != nil {
+		cfg.flushAlerts()
+		return err
+	}
+
+	r := newReaper()
+	child, err := startChild(cfg.args, env, r)
+	if err != nil {
+		return err
+	}
+
+	var changes <-chan struct{}
+	if cfg.watch {
+		changes = watchFile(ctx, cfg.filename, cfg.interval)
+		log.Printf("👀 Watching %s for changes", cfg.filename)
+	}
+
+	var killTimer <-chan time.Time
+	for {
+		select {
+		case sig := <-sigs:
+			if ignoredSignals[sig] {
+				continue
+			}
+			if err := child.signal(sig); err != nil {
+				continue
+			}
+			if terminatingSignals[sig] && killTimer == nil {
+				killTimer = time.After(cfg.grace)
+			}
+
+		case <-killTimer:
+			log.Printf("⚠️ %s did not exit within %s, killing it", cfg.args[0], cfg.grace)
+			child.cmd.Process.Kill()
+
+		case <-child.done:
+			if child.code != 0 {
+				return &ExitCodeError{Code: child.code}
+			}
+			return nil
+
+		case <-changes:
+			log.Printf("🔄 %s changed", cfg.filename)
+
+			if cfg.reloadSignal != nil {
+				if err := child.signal(cfg.reloadSignal); err != nil {
+					log.Printf("⚠️ failed to signal child: %v", err)
+				}
+				continue
+			}
+
+			env, err := readSOPSEnvMap(ctx, cfg.filename, newLoadOptions(nil))
+			DefaultMetrics.observeReload(cfg.filename, err)
+			cfg.observe(err)
+			if err != nil {
+				log.Printf("⚠️ keeping current process, reload failed: %v", err)
+				continue
+			}
+
+			child.stop(syscall.SIGTERM, cfg.grace)
+			child, err = startChild(cfg.args, env, r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (cfg *supervisorConfig) observe(err error) {
+	if cfg.alerter != nil {
+		cfg.alerter.Observe(cfg.filename, err)
+	}
+}
+
+// flushAlerts gives a queued alert time to go out before the entrypoint
+// exits.
+func (cfg *supervisorConfig) flushAlerts() {
+	if cfg.alerter == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := cfg.alerter.Flush(ctx); err != nil {
+		log.Printf("⚠️ alert not delivered before exit: %v", err)
+	}
+}
+
+// exitOnError exits with the child's status for an ExitCodeError and logs
+// anything else.
+func exitOnError(err error) {
+	var exit *ExitCodeError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
+	log.Fatalf("Error: %v", err)
+}
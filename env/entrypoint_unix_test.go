@@ -0,0 +1,81 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startExec runs the exec entrypoint with a child shell script in the
+// background. The script gets the path of a scratch file as $0 and must
+// write "ready" to it once its traps are set.
+func startExec(t *testing.T, script string, flags ...string) (<-chan error, string) {
+	t.Helper()
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.sops.env")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(file, []byte("EXEC_KEY=from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := append([]string{"-f", file}, flags...)
+	args = append(args, "--", "sh", "-c", script, out)
+
+	done := make(chan error, 1)
+	go func() { done <- runExec(args) }()
+	return done, out
+}
+
+func waitExec(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("the entrypoint did not exit")
+		return nil
+	}
+}
+
+func TestExecPassesEnvAndExitCode(t *testing.T) {
+	done, out := startExec(t, `echo "$EXEC_KEY" > "$0"; exit 7`)
+	var exit *ExitCodeError
+	if err := waitExec(t, done); !errors.As(err, &exit) || exit.Code != 7 {
+		t.Errorf("runExec() error = %v, want exit status 7", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "from-file\n" {
+		t.Errorf("child saw EXEC_KEY=%q", data)
+	}
+}
+
+func TestExecForwardsSignals(t *testing.T) {
+	done, out := startExec(t, `trap 'echo usr1 >> "$0"; exit 0' USR1; echo ready > "$0"; while :; do sleep 0.01; done`)
+	waitForFile(t, out, "ready\n")
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	if err := waitExec(t, done); err != nil {
+		t.Errorf("runExec() error = %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "ready\nusr1\n" {
+		t.Errorf("child wrote %q, want the USR1 it was forwarded", data)
+	}
+}
+
+func TestExecKillsAfterGrace(t *testing.T) {
+	done, out := startExec(t, `trap '' TERM; echo ready > "$0"; while :; do sleep 0.01; done`, "-grace", "200ms")
+	waitForFile(t, out, "ready\n")
+	start := time.Now()
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	var exit *ExitCodeError
+	if err := waitExec(t, done); !errors.As(err, &exit) || exit.Code != 128+int(syscall.SIGKILL) {
+		t.Errorf("runExec() error = %v, want the status of a SIGKILL", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("child killed after %v, before the grace period", elapsed)
+	}
+}
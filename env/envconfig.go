@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSpecification matches envconfig's error for a spec that is not
+// a pointer to a struct.
+var ErrInvalidSpecification = errors.New("specification must be a struct pointer")
+
+// EnvconfigParseError mirrors envconfig.ParseError.
+type EnvconfigParseError struct {
+	KeyName   string
+	FieldName string
+	TypeName  string
+	Value     string
+	Err       error
+}
+
+// Error masks secret values with DefaultMaskPolicy, in the message and in
+// the details, where strconv and time quote the value they failed on.
+func (e *EnvconfigParseError) Error() string {
+	value := DefaultMaskPolicy.MaskValue(e.KeyName, e.Value)
+	details := fmt.Sprint(e.Err)
+	if value != e.Value {
+		details = "invalid value"
+		var numErr *strconv.NumError
+		if errors.As(e.Err, &numErr) {
+			details = "strconv." + numErr.Func + ": " + numErr.Err.Error()
+		}
+	}
+	return fmt.Sprintf("envconfig.Process: assigning %[1]s to %[2]s: converting '%[3]s' to type %[4]s. details: %[5]s",
+		e.KeyName, e.FieldName, value, e.TypeName, details)
+}
+
+func (e *EnvconfigParseError) Unwrap() error {
+	return e.Err
+}
+
+// ProcessSOPSEnv fills spec from the decrypted file the way
+// envconfig.Process(prefix, spec) fills it from the environment, honouring
+// the envconfig, default, required, ignored and split_words tags. Nothing
+// is written to the process environment.
+func ProcessSOPSEnv(filename, prefix string, spec any, opts ...Option) error {
+	return ProcessSOPSEnvContext(context.Background(), filename, prefix, spec, opts...)
+}
+
+func ProcessSOPSEnvContext(ctx context.Context, filename, prefix string, spec any, opts ...Option) error {
+	options := newLoadOptions(opts)
+
+	envMap, err := readSOPSEnvMap(ctx, filename, options)
+	if err != nil {
+		return err
+	}
+	if err := applyDeprecations(filename, envMap, options.deprecations); err != nil {
+		return err
+	}
+	if err := applyExpiry(filename, envMap, options); err != nil {
+		return err
+	}
+	if err := applyPolicy(ctx, filename, envMap, options); err != nil {
+		return err
+	}
+	if err := applyRules(filename, envMap, options); err != nil {
+		return err
+	}
+	registerCanaries(envMap, options.canaries)
+	applySchema(filename, envMap, options)
+
+	err = processEnvconfig(ctx, prefix, spec, envMap, options)
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		ruleErr.File = filename
+	}
+	return err
+}
+
+var (
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+type envconfigField struct {
+	name  string
+	key   string
+	alt   string
+	field reflect.Value
+	tags  reflect.StructTag
+	owner reflect.Value // the struct holding field, for cross-field checks
+}
+
+func gatherEnvconfigFields(prefix string, spec any) ([]envconfigField, error) {
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Pointer {
+		return nil, ErrInvalidSpecification
+	}
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return nil, ErrInvalidSpecification
+	}
+	typeOfSpec := s.Type()
+
+	var fields []envconfigField
+	for i := range s.NumField() {
+		f := s.Field(i)
+		ftype := typeOfSpec.Field(i)
+		if !f.CanSet() || isTrue(ftype.Tag.Get("ignored")) {
+			continue
+		}
+
+		for f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				if f.Type().Elem().Kind() != reflect.Struct {
+					break
+				}
+				f.Set(reflect.New(f.Type().Elem()))
+			}
+			f = f.Elem()
+		}
+
+		info := envconfigField{
+			name:  ftype.Name,
+			alt:   strings.ToUpper(ftype.Tag.Get("envconfig")),
+			field: f,
+			tags:  ftype.Tag,
+			owner: s,
+		}
+		info.key = info.name
+
+		if isTrue(ftype.Tag.Get("split_words")) && info.alt == "" {
+			words := gatherRegexp.FindAllStringSubmatch(ftype.Name, -1)
+			if len(words) > 0 {
+				var name []string
+				for _, words := range words {
+					if m := acronymRegexp.FindStringSubmatch(words[0]); len(m) == 3 {
+						name = append(name, m[1], m[2])
+					} else {
+						name = append(name, words[0])
+					}
+				}
+				info.key = strings.Join(name, "_")
+			}
+		}
+		if info.alt != "" {
+			info.key = info.alt
+		}
+		if prefix != "" {
+			info.key = prefix + "_" + info.key
+		}
+		info.key = strings.ToUpper(info.key)
+
+		if f.Kind() == reflect.Struct && !isTextUnmarshaler(f) {
+			innerPrefix := prefix
+			if !ftype.Anonymous {
+				innerPrefix = info.key
+			}
+			inner, err := gatherEnvconfigFields(innerPrefix, f.Addr().Interface())
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inner...)
+			continue
+		}
+		fields = append(fields, info)
+	}
+	return fields, nil
+}
+
+func processEnvconfig(ctx context.Context, prefix string, spec any, envMap map[string]string, options *loadOptions) error {
+	fields, err := gatherEnvconfigFields(prefix, spec)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range fields {
+		key := info.key
+		value, ok := envMap[key]
+		if !ok && info.alt != "" {
+			key = info.alt
+			value, ok = envMap[key]
+		}
+
+		def := info.tags.Get("default")
+		if def != "" && !ok {
+			value = def
+		}
+
+		from := "file"
+		if !ok {
+			from = "default"
+			if def == "" {
+				from = "unset"
+			}
+		}
+		options.trace(ctx, "variable mapped", "variable", key, "field", info.name, "type", info.field.Type().String(), "from", from)
+
+		if !ok && def == "" {
+			if isTrue(info.tags.Get("required")) {
+				return fmt.Errorf("required key %s missing value", key)
+			}
+			continue
+		}
+
+		if err := setEnvconfigField(value, info.field); err != nil {
+			return &EnvconfigParseError{
+				KeyName:   info.key,
+				FieldName: info.name,
+				TypeName:  info.field.Type().String(),
+				Value:     value,
+				Err:       err,
+			}
+		}
+	}
+	return checkFieldTags(fields, envMap)
+}
+
+// checkFieldTags applies the validate tags, then the cel tags, and reports
+// the failures of both together.
+func checkFieldTags(fields []envconfigField, envMap map[string]string) error {
+	failures, err := checkFieldValidators(fields)
+	if err != nil {
+		return err
+	}
+	celFailures, err := checkFieldRules(fields, envMap)
+	if err != nil {
+		return err
+	}
+	if failures = append(failures, celFailures...); len(failures) > 0 {
+		return &RuleError{Failures: failures}
+	}
+	return nil
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+func isTextUnmarshaler(field reflect.Value) bool {
+	if !field.CanAddr() {
+		return false
+	}
+	_, ok := field.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+func setEnvconfigField(value string, field reflect.Value) error {
+	typ := field.Type()
+
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+		if field.IsNil() {
+			field.Set(reflect.New(typ))
+		}
+		field = field.Elem()
+	}
+
+	if field.CanAddr() {
+		if t, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return t.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Kind() == reflect.Int64 && typ.PkgPath() == "time" && typ.Name() == "Duration" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 0, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		sl := reflect.MakeSlice(typ, 0, 0)
+		if typ.Elem().Kind() == reflect.Uint8 {
+			sl = reflect.ValueOf([]byte(value))
+		} else if strings.TrimSpace(value) != "" {
+			vals := strings.Split(value, ",")
+			sl = reflect.MakeSlice(typ, len(vals), len(vals))
+			for i, val := range vals {
+				if err := setEnvconfigField(val, sl.Index(i)); err != nil {
+					return err
+				}
+			}
+		}
+		field.Set(sl)
+	case reflect.Map:
+		mp := reflect.MakeMap(typ)
+		if strings.TrimSpace(value) != "" {
+			for _, pair := range strings.Split(value, ",") {
+				k, v, ok := strings.Cut(pair, ":")
+				if !ok {
+					return fmt.Errorf("invalid map item: %q", pair)
+				}
+				key := reflect.New(typ.Key()).Elem()
+				if err := setEnvconfigField(k, key); err != nil {
+					return err
+				}
+				val := reflect.New(typ.Elem()).Elem()
+				if err := setEnvconfigField(v, val); err != nil {
+					return err
+				}
+				mp.SetMapIndex(key, val)
+			}
+		}
+		field.Set(mp)
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Code generated by go-sops gen -envconfig from config.manifest.yaml. DO NOT EDIT.
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// EnvConfig holds the keys config.manifest.yaml declares, as the strings
+// config.sops.env contains. Other keys of the file are available through Get.
+type EnvConfig struct {
+	DBHost           string `env:"DB_HOST" validate:"hostname"`
+	DBPort           string `env:"DB_PORT" validate:"port"`
+	DBName           string `env:"DB_NAME"`
+	DBUser           string `env:"DB_USER"`
+	DBPassword       string `env:"DB_PASSWORD"`
+	DBMaxConnections string `env:"DB_MAX_CONNECTIONS"`
+
+	RedisURL      string `env:"REDIS_URL" validate:"url"`
+	RedisPassword string `env:"REDIS_PASSWORD"`
+
+	JWTSecret       string `env:"JWT_SECRET"`
+	APIKey          string `env:"API_KEY"`
+	StripeSecretKey string `env:"STRIPE_SECRET_KEY"`
+	SendGridAPIKey  string `env:"SENDGRID_API_KEY"`
+
+	GoogleClientID     string `env:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `env:"GOOGLE_CLIENT_SECRET"`
+	GitHubClientID     string `env:"GITHUB_CLIENT_ID"`
+	GitHubClientSecret string `env:"GITHUB_CLIENT_SECRET"`
+
+	WebhookURL             string `env:"WEBHOOK_URL" validate:"url"`
+	NotificationServiceURL string `env:"NOTIFICATION_SERVICE_URL" validate:"url"`
+	SentryDSN              string `env:"SENTRY_DSN" validate:"url"`
+
+	Environment string `env:"ENVIRONMENT"`
+	Debug       string `env:"DEBUG"`
+	LogLevel    string `env:"LOG_LEVEL"`
+
+	EncryptionKey string `env:"ENCRYPTION_KEY"`
+	SigningKey    string `env:"SIGNING_KEY"`
+
+	envState
+}
+
+// envConfigKeys lists the keys of EnvConfig in manifest order.
+var envConfigKeys = []string{
+	"DB_HOST",
+	"DB_PORT",
+	"DB_NAME",
+	"DB_USER",
+	"DB_PASSWORD",
+	"DB_MAX_CONNECTIONS",
+	"REDIS_URL",
+	"REDIS_PASSWORD",
+	"JWT_SECRET",
+	"API_KEY",
+	"STRIPE_SECRET_KEY",
+	"SENDGRID_API_KEY",
+	"GOOGLE_CLIENT_ID",
+	"GOOGLE_CLIENT_SECRET",
+	"GITHUB_CLIENT_ID",
+	"GITHUB_CLIENT_SECRET",
+	"WEBHOOK_URL",
+	"NOTIFICATION_SERVICE_URL",
+	"SENTRY_DSN",
+	"ENVIRONMENT",
+	"DEBUG",
+	"LOG_LEVEL",
+	"ENCRYPTION_KEY",
+	"SIGNING_KEY",
+}
+
+// setFields copies the keys of EnvConfig from envMap.
+func (c *EnvConfig) setFields(envMap map[string]string) {
+	c.DBHost = envMap["DB_HOST"]
+	c.DBPort = envMap["DB_PORT"]
+	c.DBName = envMap["DB_NAME"]
+	c.DBUser = envMap["DB_USER"]
+	c.DBPassword = envMap["DB_PASSWORD"]
+	c.DBMaxConnections = envMap["DB_MAX_CONNECTIONS"]
+	c.RedisURL = envMap["REDIS_URL"]
+	c.RedisPassword = envMap["REDIS_PASSWORD"]
+	c.JWTSecret = envMap["JWT_SECRET"]
+	c.APIKey = envMap["API_KEY"]
+	c.StripeSecretKey = envMap["STRIPE_SECRET_KEY"]
+	c.SendGridAPIKey = envMap["SENDGRID_API_KEY"]
+	c.GoogleClientID = envMap["GOOGLE_CLIENT_ID"]
+	c.GoogleClientSecret = envMap["GOOGLE_CLIENT_SECRET"]
+	c.GitHubClientID = envMap["GITHUB_CLIENT_ID"]
+	c.GitHubClientSecret = envMap["GITHUB_CLIENT_SECRET"]
+	c.WebhookURL = envMap["WEBHOOK_URL"]
+	c.NotificationServiceURL = envMap["NOTIFICATION_SERVICE_URL"]
+	c.SentryDSN = envMap["SENTRY_DSN"]
+	c.Environment = envMap["ENVIRONMENT"]
+	c.Debug = envMap["DEBUG"]
+	c.LogLevel = envMap["LOG_LEVEL"]
+	c.EncryptionKey = envMap["ENCRYPTION_KEY"]
+	c.SigningKey = envMap["SIGNING_KEY"]
+}
+
+// PrintConfig prints the keys of config to stdout with EmojiPrinter.
+func PrintConfig(config *EnvConfig, policy *MaskPolicy) {
+	FprintConfig(os.Stdout, EmojiPrinter, config, policy)
+}
+
+// FprintConfig writes the keys of config to w by group, masked by policy.
+// Keys that are neither set nor required are left out.
+func FprintConfig(w io.Writer, printer Printer, config *EnvConfig, policy *MaskPolicy) {
+	printer.Title(w, "🔓 Successfully loaded and decrypted environment configuration:")
+
+	printer.Section(w, "📊 Database Configuration")
+	printer.Value(w, "DB_HOST", policy.MaskValue("DB_HOST", config.DBHost))
+	if config.DBPort != "" {
+		printer.Value(w, "DB_PORT", policy.MaskValue("DB_PORT", config.DBPort))
+	}
+	printer.Value(w, "DB_NAME", policy.MaskValue("DB_NAME", config.DBName))
+	printer.Value(w, "DB_USER", policy.MaskValue("DB_USER", config.DBUser))
+	if config.DBPassword != "" {
+		printer.Value(w, "DB_PASSWORD", policy.MaskValue("DB_PASSWORD", config.DBPassword))
+	}
+	if config.DBMaxConnections != "" {
+		printer.Value(w, "DB_MAX_CONNECTIONS", policy.MaskValue("DB_MAX_CONNECTIONS", config.DBMaxConnections))
+	}
+
+	printer.Section(w, "🔴 Redis Configuration")
+	if config.RedisURL != "" {
+		printer.Value(w, "REDIS_URL", policy.MaskValue("REDIS_URL", config.RedisURL))
+	}
+	if config.RedisPassword != "" {
+		printer.Value(w, "REDIS_PASSWORD", policy.MaskValue("REDIS_PASSWORD", config.RedisPassword))
+	}
+
+	printer.Section(w, "🔐 API Keys & Secrets")
+	printer.Value(w, "JWT_SECRET", policy.MaskValue("JWT_SECRET", config.JWTSecret))
+	if config.APIKey != "" {
+		printer.Value(w, "API_KEY", policy.MaskValue("API_KEY", config.APIKey))
+	}
+	if config.StripeSecretKey != "" {
+		printer.Value(w, "STRIPE_SECRET_KEY", policy.MaskValue("STRIPE_SECRET_KEY", config.StripeSecretKey))
+	}
+	if config.SendGridAPIKey != "" {
+		printer.Value(w, "SENDGRID_API_KEY", policy.MaskValue("SENDGRID_API_KEY", config.SendGridAPIKey))
+	}
+
+	printer.Section(w, "🔑 OAuth Credentials")
+	if config.GoogleClientID != "" {
+		printer.Value(w, "GOOGLE_CLIENT_ID", policy.MaskValue("GOOGLE_CLIENT_ID", config.GoogleClientID))
+	}
+	if config.GoogleClientSecret != "" {
+		printer.Value(w, "GOOGLE_CLIENT_SECRET", policy.MaskValue("GOOGLE_CLIENT_SECRET", config.GoogleClientSecret))
+	}
+	if config.GitHubClientID != "" {
+		printer.Value(w, "GITHUB_CLIENT_ID", policy.MaskValue("GITHUB_CLIENT_ID", config.GitHubClientID))
+	}
+	if config.GitHubClientSecret != "" {
+		printer.Value(w, "GITHUB_CLIENT_SECRET", policy.MaskValue("GITHUB_CLIENT_SECRET", config.GitHubClientSecret))
+	}
+
+	printer.Section(w, "🌐 External Services")
+	if config.WebhookURL != "" {
+		printer.Value(w, "WEBHOOK_URL", policy.MaskValue("WEBHOOK_URL", config.WebhookURL))
+	}
+	if config.NotificationServiceURL != "" {
+		printer.Value(w, "NOTIFICATION_SERVICE_URL", policy.MaskValue("NOTIFICATION_SERVICE_URL", config.NotificationServiceURL))
+	}
+	if config.SentryDSN != "" {
+		printer.Value(w, "SENTRY_DSN", policy.MaskValue("SENTRY_DSN", config.SentryDSN))
+	}
+
+	printer.Section(w, "⚙️ Environment Settings")
+	if config.Environment != "" {
+		printer.Value(w, "ENVIRONMENT", policy.MaskValue("ENVIRONMENT", config.Environment))
+	}
+	if config.Debug != "" {
+		printer.Value(w, "DEBUG", policy.MaskValue("DEBUG", config.Debug))
+	}
+	if config.LogLevel != "" {
+		printer.Value(w, "LOG_LEVEL", policy.MaskValue("LOG_LEVEL", config.LogLevel))
+	}
+
+	printer.Section(w, "🔒 Encryption Keys")
+	if config.EncryptionKey != "" {
+		printer.Value(w, "ENCRYPTION_KEY", policy.MaskValue("ENCRYPTION_KEY", config.EncryptionKey))
+	}
+	if config.SigningKey != "" {
+		printer.Value(w, "SIGNING_KEY", policy.MaskValue("SIGNING_KEY", config.SigningKey))
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrSOPSNotInstalled = errors.New("sops is not installed")
+	ErrFileNotFound     = errors.New("encrypted file not found")
+	ErrNoMatchingKeys   = errors.New("no key available to decrypt the file")
+	ErrMACMismatch      = errors.New("MAC mismatch")
+	ErrNotEncrypted     = errors.New("file has no sops metadata")
+	ErrDecryptTimeout   = errors.New("decryption timed out")
+	ErrDecryptFailed    = errors.New("sops failed")
+)
+
+// Exit codes from sops' cmd/sops/codes package.
+const (
+	sopsExitCouldNotReadInputFile = 2
+	sopsExitErrorDecryptingMac    = 24
+	sopsExitMacMismatch           = 51
+	sopsExitMacNotFound           = 52
+	sopsExitNoFileSpecified       = 100
+	sopsExitCouldNotRetrieveKey   = 128
+)
+
+var decryptHints = map[error]string{
+	ErrSOPSNotInstalled: "install it with `brew install sops` or see https://github.com/getsops/sops/releases",
+	ErrFileNotFound:     "check the path and working directory",
+	ErrNoMatchingKeys:   "import the GPG/age key listed in the file's metadata, or check your cloud credentials",
+	ErrMACMismatch:      "the file was modified outside sops; restore it from git or re-encrypt it",
+	ErrNotEncrypted:     "encrypt it first with `sops -e -i <file>`",
+	ErrDecryptTimeout:   "a pinentry prompt or unreachable KMS may be blocking sops, or raise the limit with WithTimeout",
+}
+
+type DecryptError struct {
+	File     string
+	ExitCode int
+	Stderr   string
+	Kind     error
+}
+
+func (e *DecryptError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to decrypt %s: %v", e.File, e.Kind)
+	if e.ExitCode > 0 {
+		fmt.Fprintf(&b, " (exit status %d)", e.ExitCode)
+	}
+	if e.Stderr != "" && e.Kind == ErrDecryptFailed {
+		fmt.Fprintf(&b, ": %s", firstLine(e.Stderr))
+	}
+	if hint, ok := decryptHints[e.Kind]; ok {
+		fmt.Fprintf(&b, ", %s", hint)
+	}
+	return b.String()
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.Kind
+}
+
+func newDecryptError(filename string, err error) error {
+	var decryptErr *DecryptError
+	if errors.As(err, &decryptErr) {
+		return err
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return &DecryptError{File: filename, Kind: ErrSOPSNotInstalled}
+	}
+	if errors.Is(err, errDecryptTimeout) {
+		return &DecryptError{File: filename, Kind: ErrDecryptTimeout}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return &DecryptError{File: filename, Kind: ErrFileNotFound}
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to decrypt %s: %w", filename, err)
+	}
+
+	stderr := strings.TrimSpace(string(exitErr.Stderr))
+	return &DecryptError{
+		File:     filename,
+		ExitCode: exitErr.ExitCode(),
+		Stderr:   stderr,
+		Kind:     classifySOPSFailure(exitErr.ExitCode(), stderr),
+	}
+}
+
+func classifySOPSFailure(code int, stderr string) error {
+	lower := strings.ToLower(stderr)
+
+	switch {
+	case code == sopsExitMacMismatch, strings.Contains(lower, "mac mismatch"):
+		return ErrMACMismatch
+	case code == sopsExitCouldNotRetrieveKey, strings.Contains(lower, "failed to get the data key"),
+		strings.Contains(lower, "error getting data key"):
+		return ErrNoMatchingKeys
+	case strings.Contains(lower, "metadata not found"), code == sopsExitMacNotFound:
+		return ErrNotEncrypted
+	case strings.Contains(lower, "no such file"), strings.Contains(lower, "non-existent file"),
+		code == sopsExitCouldNotReadInputFile, code == sopsExitNoFileSpecified:
+		return ErrFileNotFound
+	case code == sopsExitErrorDecryptingMac:
+		return ErrMACMismatch
+	default:
+		return ErrDecryptFailed
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
+
+var errorCategories = []struct {
+	err      error
+	category string
+}{
+	{ErrSOPSNotInstalled, "sops_not_installed"},
+	{ErrFileNotFound, "file_not_found"},
+	{ErrNoMatchingKeys, "no_matching_keys"},
+	{ErrMACMismatch, "mac_mismatch"},
+	{ErrNotEncrypted, "not_encrypted"},
+	{ErrDecryptTimeout, "timeout"},
+	{ErrDecryptFailed, "sops_failed"},
+	{ErrCircuitOpen, "circuit_open"},
+	{ErrSignatureInvalid, "signature_invalid"},
+	{ErrAlgorithmNotApproved, "fips"},
+	{ErrFIPSModeDisabled, "fips"},
+	{ErrInsecurePermissions, "insecure_permissions"},
+	{ErrBundleCorrupt, "bundle_corrupt"},
+	{ErrPolicyViolation, "policy_violation"},
+	{ErrRuleFailed, "rule_failed"},
+}
+
+// ErrorCategory names the kind of a load error for alerts and events. It
+// never includes sops' stderr or anything else that could echo file
+// contents.
+func ErrorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, c := range errorCategories {
+		if errors.Is(err, c.err) {
+			return c.category
+		}
+	}
+	return "other"
+}
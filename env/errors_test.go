@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSOPSFailuresAreClassified(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		kind     error
+		category string
+	}{
+		{"mac mismatch", "echo 'MAC mismatch. File has 1234, computed 5678' >&2; exit 51", ErrMACMismatch, "mac_mismatch"},
+		{"no key", "echo 'Failed to get the data key required to decrypt the SOPS file.' >&2; exit 128", ErrNoMatchingKeys, "no_matching_keys"},
+		{"not encrypted", "echo 'sops metadata not found' >&2; exit 1", ErrNotEncrypted, "not_encrypted"},
+		{"other", "echo 'something odd happened' >&2; echo 'detail' >&2; exit 3", ErrDecryptFailed, "sops_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installSOPSScript(t, tt.script+"\n")
+			filename := filepath.Join(t.TempDir(), "config.sops.env")
+			if err := os.WriteFile(filename, []byte("DB_PASSWORD=ENC[...]\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadSOPSEnv(filename)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("LoadSOPSEnv() error = %v, want %v", err, tt.kind)
+			}
+			var decryptErr *DecryptError
+			if !errors.As(err, &decryptErr) || decryptErr.File != filename || decryptErr.ExitCode == 0 || decryptErr.Stderr == "" {
+				t.Errorf("error = %#v, want the file, exit code and stderr", decryptErr)
+			}
+			if got := ErrorCategory(err); got != tt.category {
+				t.Errorf("ErrorCategory() = %q, want %q", got, tt.category)
+			}
+			// Only unrecognized failures quote sops, and then one line.
+			if quoted := strings.Contains(err.Error(), "something odd happened"); quoted != (tt.kind == ErrDecryptFailed) {
+				t.Errorf("Error() = %q", err)
+			}
+			if strings.Contains(err.Error(), "detail") {
+				t.Errorf("Error() quotes more than the first stderr line: %q", err)
+			}
+		})
+	}
+}
+
+func TestSOPSNotInstalled(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(filename, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSOPSEnv(filename); !errors.Is(err, ErrSOPSNotInstalled) {
+		t.Errorf("LoadSOPSEnv() error = %v, want ErrSOPSNotInstalled", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExpiresSuffix marks metadata keys holding a secret's expiry date, e.g.
+// JWT_SECRET__expires=2025-09-01. They are removed from the loaded config.
+const ExpiresSuffix = "__expires"
+
+const DefaultExpiryWindow = 14 * 24 * time.Hour
+
+type SecretExpiry struct {
+	File    string
+	Key     string
+	Expires time.Time
+}
+
+func (e SecretExpiry) Expired() bool {
+	return !time.Now().Before(e.Expires)
+}
+
+// WithExpiryWarnings calls handler for every secret that has expired or
+// expires within window. The default warns through slog two weeks ahead.
+func WithExpiryWarnings(window time.Duration, handler func(SecretExpiry)) Option {
+	return func(o *loadOptions) {
+		o.expiryWindow = window
+		o.onExpiry = handler
+	}
+}
+
+// extractExpiries removes the expiry metadata keys from envMap and returns
+// the parsed dates, soonest first.
+func extractExpiries(filename string, envMap map[string]string) ([]SecretExpiry, error) {
+	var expiries []SecretExpiry
+
+	for key, value := range envMap {
+		secretKey, ok := strings.CutSuffix(key, ExpiresSuffix)
+		if !ok || secretKey == "" {
+			continue
+		}
+		delete(envMap, key)
+
+		expires, err := parseExpiry(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s: %w", filename, key, err)
+		}
+		expiries = append(expiries, SecretExpiry{File: filename, Key: secretKey, Expires: expires})
+	}
+
+	sort.Slice(expiries, func(i, j int) bool { return expiries[i].Expires.Before(expiries[j].Expires) })
+	return expiries, nil
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func applyExpiry(filename string, envMap map[string]string, options *loadOptions) error {
+	expiries, err := extractExpiries(filename, envMap)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(options.expiryWindow)
+	for _, e := range expiries {
+		if e.Expires.After(deadline) {
+			continue
+		}
+		if options.onExpiry != nil {
+			options.onExpiry(e)
+			continue
+		}
+
+		attrs := []any{"file", e.File, "key", e.Key, "expires", e.Expires.Format(time.DateOnly)}
+		if e.Expired() {
+			slog.Warn("secret has expired, rotate it", attrs...)
+		} else {
+			slog.Warn("secret expires soon, rotate it", attrs...)
+		}
+	}
+	return nil
+}
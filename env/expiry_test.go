@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-sops-env/sopstest"
+)
+
+func TestWithExpiryWarnings(t *testing.T) {
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format(time.DateOnly) }
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{
+		"JWT_SECRET":           "a",
+		"JWT_SECRET__expires":  day(3),
+		"API_KEY":              "b",
+		"API_KEY__expires":     day(-1),
+		"STRIPE_KEY":           "c",
+		"STRIPE_KEY__expires":  day(60),
+		"DB_PASSWORD__expires": time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339),
+	})
+
+	var warned []SecretExpiry
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake),
+		WithExpiryWarnings(7*24*time.Hour, func(e SecretExpiry) { warned = append(warned, e) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, e := range warned {
+		keys = append(keys, e.Key)
+	}
+	// Soonest first; STRIPE_KEY is outside the window.
+	if got := strings.Join(keys, ","); got != "API_KEY,DB_PASSWORD,JWT_SECRET" {
+		t.Errorf("warned about %s", got)
+	}
+	if len(warned) == 3 && (!warned[0].Expired() || warned[2].Expired()) {
+		t.Errorf("Expired() = %v, %v", warned[0].Expired(), warned[2].Expired())
+	}
+	if _, ok := config.Lookup("JWT_SECRET__expires"); ok {
+		t.Error("expiry metadata left in the config")
+	}
+	if config.Get("JWT_SECRET") != "a" {
+		t.Error("the secret itself was dropped")
+	}
+}
+
+func TestInvalidExpiry(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"API_KEY__expires": "soon"})
+	if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake)); err == nil || !strings.Contains(err.Error(), "API_KEY__expires") {
+		t.Errorf("error = %v, want the bad expiry key named", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultFlagPrefix = "FLAG_"
+
+// FeatureFlags reads the keys of a config that start with a prefix as
+// feature flags, so flags can live in the same encrypted file as secrets:
+//
+//	FLAG_NEW_CHECKOUT=true
+//	FLAG_SEARCH_V2=25%
+//	FLAG_CHECKOUT_THEME=blue
+//	FLAG_PRICING_PAGE=control:50,annual:30,monthly:20
+//
+// Flag names are given without the prefix and are case-insensitive, with
+// - and . read as _, so "new-checkout" is FLAG_NEW_CHECKOUT.
+type FeatureFlags struct {
+	source func() *EnvConfig
+	prefix string
+
+	mu          sync.Mutex
+	subscribers []func(FlagChange)
+	cancel      func()
+}
+
+// FlagChange is a flag whose value changed on reload. From or To is empty
+// when the flag was added or removed.
+type FlagChange struct {
+	Name string
+	From string
+	To   string
+}
+
+// NewFeatureFlags reads flags from the store's current config, so a reload
+// changes them, and passes changes to Subscribe. An empty prefix is
+// DefaultFlagPrefix.
+func NewFeatureFlags(store *Store, prefix string) *FeatureFlags {
+	f := &FeatureFlags{source: store.Config, prefix: flagPrefix(prefix)}
+	f.cancel = store.subscribe(f.changed)
+	return f
+}
+
+// FeatureFlagsFrom reads flags from a fixed config.
+func FeatureFlagsFrom(config *EnvConfig, prefix string) *FeatureFlags {
+	return &FeatureFlags{source: func() *EnvConfig { return config }, prefix: flagPrefix(prefix)}
+}
+
+func flagPrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultFlagPrefix
+	}
+	return prefix
+}
+
+func (f *FeatureFlags) key(name string) string {
+	return f.prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+func (f *FeatureFlags) raw(name string) (string, bool) {
+	config := f.source()
+	if config == nil {
+		return "", false
+	}
+	value, ok := config.values[f.key(name)]
+	return strings.TrimSpace(value), ok && strings.TrimSpace(value) != ""
+}
+
+// Bool returns a true/false, on/off, yes/no or 1/0 flag, or def when it is
+// unset or not a boolean. A percentage counts as true only at 100%.
+func (f *FeatureFlags) Bool(name string, def bool) bool {
+	value, ok := f.raw(name)
+	if !ok {
+		return def
+	}
+	if enabled, ok := parseFlagBool(value); ok {
+		return enabled
+	}
+	if percent, ok := parseFlagPercent(value); ok {
+		return percent >= 100
+	}
+	return def
+}
+
+// Enabled is Bool with false as the default.
+func (f *FeatureFlags) Enabled(name string) bool {
+	return f.Bool(name, false)
+}
+
+// EnabledFor rolls a flag out by percentage: FLAG_X=25% is on for a stable
+// quarter of subjects, such as user IDs. The same subject always gets the
+// same answer, and raising the percentage only adds subjects. Boolean
+// flags ignore the subject.
+func (f *FeatureFlags) EnabledFor(name, subject string) bool {
+	value, ok := f.raw(name)
+	if !ok {
+		return false
+	}
+	if percent, ok := parseFlagPercent(value); ok {
+		return flagBucket(f.key(name), subject) < percent
+	}
+	enabled, _ := parseFlagBool(value)
+	return enabled
+}
+
+// Variant returns a string flag, or def when it is unset. For a weighted
+// flag like control:50,treatment:50 it returns the first variant.
+func (f *FeatureFlags) Variant(name, def string) string {
+	value, ok := f.raw(name)
+	if !ok {
+		return def
+	}
+	if variants, ok := parseFlagVariants(value); ok {
+		return variants[0].name
+	}
+	return value
+}
+
+// VariantFor picks a variant of a weighted flag for subject, stable across
+// calls and processes. Weights are relative and need not add up to 100.
+// Unweighted flags return their value.
+func (f *FeatureFlags) VariantFor(name, subject, def string) string {
+	value, ok := f.raw(name)
+	if !ok {
+		return def
+	}
+	variants, ok := parseFlagVariants(value)
+	if !ok {
+		return value
+	}
+	var total float64
+	for _, v := range variants {
+		total += v.weight
+	}
+	bucket := flagBucket(f.key(name), subject) / 100 * total
+	for _, v := range variants {
+		if bucket < v.weight {
+			return v.name
+		}
+		bucket -= v.weight
+	}
+	return variants[len(variants)-1].name
+}
+
+// All returns every flag by name, without the prefix.
+func (f *FeatureFlags) All() map[string]string {
+	config := f.source()
+	if config == nil {
+		return nil
+	}
+	return flagValues(config, f.prefix)
+}
+
+// Subscribe calls fn for each flag that changes when the store loads a new
+// generation, including rollbacks. Call the returned function to stop.
+// Flags from FeatureFlagsFrom never change.
+func (f *FeatureFlags) Subscribe(fn func(FlagChange)) (cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, fn)
+	i := len(f.subscribers) - 1
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.subscribers[i] = nil
+	}
+}
+
+// Close stops following the store.
+func (f *FeatureFlags) Close() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
+
+func (f *FeatureFlags) changed(previous, current *EnvConfig) {
+	if previous == nil || current == nil {
+		return
+	}
+	from, to := flagValues(previous, f.prefix), flagValues(current, f.prefix)
+	var changes []FlagChange
+	for _, name := range slices.Sorted(maps.Keys(mergeKeySets(from, to))) {
+		if from[name] != to[name] {
+			changes = append(changes, FlagChange{Name: name, From: from[name], To: to[name]})
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	subscribers := slices.Clone(f.subscribers)
+	f.mu.Unlock()
+	for _, change := range changes {
+		for _, fn := range subscribers {
+			if fn != nil {
+				fn(change)
+			}
+		}
+	}
+}
+
+func flagValues(config *EnvConfig, prefix string) map[string]string {
+	flags := map[string]string{}
+	for key, value := range config.values {
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+			flags[name] = strings.TrimSpace(value)
+		}
+	}
+	return flags
+}
+
+func parseFlagBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "on", "yes", "1", "enabled":
+		return true, true
+	case "false", "off", "no", "0", "disabled":
+		return false, true
+	}
+	return false, false
+}
+
+func parseFlagPercent(value string) (float64, bool) {
+	number, ok := strings.CutSuffix(value, "%")
+	if !ok {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || percent < 0 {
+		return 0, false
+	}
+	return min(percent, 100), true
+}
+
+type flagVariant struct {
+	name   string
+	weight float64
+}
+
+func parseFlagVariants(value string) ([]flagVariant, bool) {
+	if !strings.Contains(value, ":") {
+		return nil, false
+	}
+	var variants []flagVariant
+	for part := range strings.SplitSeq(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), ":")
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if !ok || err != nil || w < 0 || name == "" {
+			return nil, false
+		}
+		variants = append(variants, flagVariant{name: strings.TrimSpace(name), weight: w})
+	}
+	return variants, len(variants) > 0
+}
+
+// flagBucket places subject in [0, 100) for the flag, by hash, so buckets
+// are independent between flags.
+func flagBucket(key, subject string) float64 {
+	sum := sha256.Sum256([]byte(key + "\x00" + subject))
+	return float64(binary.BigEndian.Uint64(sum[:8])%10000) / 100
+}
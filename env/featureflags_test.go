@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	config := &EnvConfig{envState: envState{values: map[string]string{
+		"FLAG_NEW_CHECKOUT":   "on",
+		"FLAG_SEARCH_V2":      "25%",
+		"FLAG_FULL_ROLLOUT":   "100%",
+		"FLAG_CHECKOUT_THEME": " blue ",
+		"FLAG_PRICING_PAGE":   "control:50,annual:30,monthly:20",
+		"FLAG_BROKEN":         "maybe",
+		"DB_PASSWORD":         "hunter22",
+	}}}
+	flags := FeatureFlagsFrom(config, "")
+
+	if !flags.Enabled("new-checkout") || !flags.Enabled("full.rollout") || flags.Enabled("search-v2") || flags.Enabled("missing") {
+		t.Error("Enabled() misread a boolean or percentage flag")
+	}
+	if !flags.Bool("broken", true) || flags.Bool("broken", false) {
+		t.Error("Bool() did not fall back to the default for a non-boolean value")
+	}
+	if got := flags.Variant("checkout_theme", "red"); got != "blue" {
+		t.Errorf("Variant(checkout_theme) = %q", got)
+	}
+	if got := flags.Variant("pricing-page", ""); got != "control" {
+		t.Errorf("Variant(pricing-page) = %q, want the first variant", got)
+	}
+	if got := flags.Variant("missing", "red"); got != "red" {
+		t.Errorf("Variant(missing) = %q", got)
+	}
+	if _, ok := flags.All()["NEW_CHECKOUT"]; !ok || len(flags.All()) != 6 {
+		t.Errorf("All() = %v", flags.All())
+	}
+}
+
+func TestFeatureFlagRollout(t *testing.T) {
+	config := &EnvConfig{envState: envState{values: map[string]string{
+		"FLAG_SEARCH_V2":    "25%",
+		"FLAG_PRICING_PAGE": "control:50,annual:30,monthly:20",
+	}}}
+	flags := FeatureFlagsFrom(config, "")
+
+	const subjects = 10000
+	enabled := 0
+	variants := map[string]int{}
+	for i := range subjects {
+		user := fmt.Sprintf("user-%d", i)
+		if flags.EnabledFor("search-v2", user) {
+			enabled++
+		}
+		if flags.EnabledFor("search-v2", user) != flags.EnabledFor("search-v2", user) {
+			t.Fatalf("EnabledFor(%s) is not stable", user)
+		}
+		variants[flags.VariantFor("pricing-page", user, "")]++
+	}
+	near := func(got int, want float64) bool { return math.Abs(float64(got)/subjects-want) < 0.03 }
+	if !near(enabled, 0.25) {
+		t.Errorf("25%% rollout enabled %d of %d subjects", enabled, subjects)
+	}
+	if !near(variants["control"], 0.5) || !near(variants["annual"], 0.3) || !near(variants["monthly"], 0.2) {
+		t.Errorf("variant split = %v", variants)
+	}
+
+	// Raising the percentage only adds subjects.
+	for i := range 1000 {
+		user := fmt.Sprintf("user-%d", i)
+		config.values["FLAG_SEARCH_V2"] = "25%"
+		before := flags.EnabledFor("search-v2", user)
+		config.values["FLAG_SEARCH_V2"] = "50%"
+		if before && !flags.EnabledFor("search-v2", user) {
+			t.Fatalf("%s lost the flag when the rollout grew", user)
+		}
+	}
+}
+
+func TestFeatureFlagSubscribe(t *testing.T) {
+	store, rotate := rotatableStore(t, "FLAG_A=on\nFLAG_B=10%\nDB_PASSWORD=hunter22\n")
+	flags := NewFeatureFlags(store, "")
+	defer flags.Close()
+	var changes []FlagChange
+	cancel := flags.Subscribe(func(c FlagChange) { changes = append(changes, c) })
+
+	rotate("FLAG_A=on\nFLAG_B=50%\nFLAG_C=blue\nDB_PASSWORD=rotated\n")
+	want := []FlagChange{{Name: "B", From: "10%", To: "50%"}, {Name: "C", To: "blue"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+	if flags.Variant("c", "") != "blue" {
+		t.Error("flags did not follow the reload")
+	}
+
+	cancel()
+	rotate("FLAG_A=off\n")
+	if len(changes) != 2 {
+		t.Errorf("got %d changes after cancel", len(changes)-2)
+	}
+}
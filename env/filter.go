@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log/slog"
+	"path"
+)
+
+// WithAllowedKeys limits LoadSOPSEnvToSystem to keys matching one of the
+// patterns (exact names or path.Match globs such as "DB_*").
+func WithAllowedKeys(patterns ...string) Option {
+	return func(o *loadOptions) {
+		o.allowedKeys = append(o.allowedKeys, patterns...)
+	}
+}
+
+// WithDeniedKeys keeps keys matching one of the patterns out of the process
+// environment. Denied keys are skipped even if they are also allowed.
+func WithDeniedKeys(patterns ...string) Option {
+	return func(o *loadOptions) {
+		o.deniedKeys = append(o.deniedKeys, patterns...)
+	}
+}
+
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *loadOptions) systemKeyAllowed(key string) bool {
+	if matchesAny(o.deniedKeys, key) {
+		return false
+	}
+	return len(o.allowedKeys) == 0 || matchesAny(o.allowedKeys, key)
+}
+
+func warnSkippedKeys(filename string, skipped []string) {
+	if len(skipped) > 0 {
+		slog.Warn("keys not set in the process environment by key filter", "file", filename, "keys", skipped)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestKeyFilterForSystemEnv(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	unsetForTest(t, "FILTER_DB_HOST", "FILTER_DB_PASSWORD", "FILTER_PATH", "FILTER_APP")
+
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{
+		"FILTER_DB_HOST": "db", "FILTER_DB_PASSWORD": "hunter22", "FILTER_PATH": "/evil", "FILTER_APP": "x",
+	})
+	err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake),
+		WithAllowedKeys("FILTER_DB_*", "FILTER_PATH"), WithDeniedKeys("FILTER_PATH", "*_PASSWORD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"FILTER_DB_HOST": true, "FILTER_DB_PASSWORD": false, "FILTER_PATH": false, "FILTER_APP": false} {
+		if _, ok := os.LookupEnv(key); ok != want {
+			t.Errorf("%s set = %v, want %v", key, ok, want)
+		}
+	}
+	if !strings.Contains(logs.String(), "keys not set in the process environment") || !strings.Contains(logs.String(), "FILTER_APP") {
+		t.Errorf("logs = %q, want the skipped keys listed", logs.String())
+	}
+	if strings.Contains(logs.String(), "hunter22") {
+		t.Errorf("logs carry a value: %q", logs.String())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/fips140"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrAlgorithmNotApproved = errors.New("algorithm not FIPS 140 approved")
+	ErrFIPSModeDisabled     = errors.New("go crypto is not in FIPS mode, run with GODEBUG=fips140=on or build with GOEXPERIMENT=boringcrypto")
+)
+
+// Key backends are judged by the algorithm they wrap the data key with.
+// pgp is rejected because the key algorithm cannot be verified from the
+// file's metadata.
+var fipsBackends = map[string]struct {
+	algorithm string
+	approved  bool
+}{
+	"kms":      {"AWS KMS", true},
+	"gcp_kms":  {"GCP Cloud KMS", true},
+	"azure_kv": {"Azure Key Vault", true},
+	"hc_vault": {"Vault Transit", true},
+	"age":      {"X25519/ChaCha20-Poly1305", false},
+	"pgp":      {"OpenPGP", false},
+}
+
+var fipsCiphers = []string{"AES256_GCM"}
+
+type AlgorithmError struct {
+	File      string
+	Component string
+	Algorithm string
+}
+
+func (e *AlgorithmError) Error() string {
+	return fmt.Sprintf("%s: %s uses %s, which is not FIPS 140 approved", e.File, e.Component, e.Algorithm)
+}
+
+func (e *AlgorithmError) Unwrap() error {
+	return ErrAlgorithmNotApproved
+}
+
+// WithFIPS refuses to decrypt unless this binary's Go crypto runs in FIPS
+// mode and the file uses only approved key backends and data ciphers. sops
+// is started with GODEBUG=fips140=on.
+func WithFIPS() Option {
+	return func(o *loadOptions) {
+		o.fips = true
+	}
+}
+
+func fipsCryptoEnabled() bool {
+	return fips140.Enabled() || boringCryptoEnabled()
+}
+
+func checkFIPS(filename string) error {
+	if !fipsCryptoEnabled() {
+		return fmt.Errorf("%s: %w", filename, ErrFIPSModeDisabled)
+	}
+
+	meta, err := readSOPSMetadata(filename)
+	if err != nil {
+		return fmt.Errorf("%s: cannot verify algorithms: %w", filename, err)
+	}
+
+	for _, backend := range meta.Backends {
+		if b := fipsBackends[backend]; !b.approved {
+			algorithm := b.algorithm
+			if algorithm == "" {
+				algorithm = "an unknown algorithm"
+			}
+			return &AlgorithmError{File: filename, Component: "key backend " + backend, Algorithm: algorithm}
+		}
+	}
+
+	for _, cipher := range meta.Ciphers {
+		if !slices.Contains(fipsCiphers, cipher) {
+			return &AlgorithmError{File: filename, Component: "data encryption", Algorithm: cipher}
+		}
+	}
+	return nil
+}
+
+func fipsEnv() []string {
+	env := os.Environ()
+	for i, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "GODEBUG="); ok {
+			env[i] = "GODEBUG=" + value + ",fips140=on"
+			return env
+		}
+	}
+	return append(env, "GODEBUG=fips140=on")
+}
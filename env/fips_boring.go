@@ -0,0 +1,12 @@
+//go:build boringcrypto
+
+package main
+
+import (
+	"crypto/boring"
+	_ "crypto/tls/fipsonly"
+)
+
+func boringCryptoEnabled() bool {
+	return boring.Enabled()
+}
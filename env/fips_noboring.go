@@ -0,0 +1,7 @@
+//go:build !boringcrypto
+
+package main
+
+func boringCryptoEnabled() bool {
+	return false
+}
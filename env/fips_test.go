@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFIPSFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const kmsFile = "DB_PASSWORD=ENC[AES256_GCM,data:x]\nsops_kms__list_0__map_arn=arn:aws:kms:eu-west-1:1:key/k\nsops_mac=ENC[AES256_GCM,data:y]\n"
+
+func TestFIPSRequiresFIPSMode(t *testing.T) {
+	if fipsCryptoEnabled() {
+		t.Skip("running in FIPS mode")
+	}
+	_, err := LoadSOPSEnv(writeFIPSFile(t, kmsFile), WithFIPS())
+	if !errors.Is(err, ErrFIPSModeDisabled) || ErrorCategory(err) != "fips" {
+		t.Errorf("error = %v, want ErrFIPSModeDisabled", err)
+	}
+}
+
+func TestFIPSAlgorithms(t *testing.T) {
+	if !fipsCryptoEnabled() {
+		// FIPS mode is fixed at startup, so rerun this test in a process
+		// that has it.
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFIPSAlgorithms$", "-test.v")
+		cmd.Env = append(os.Environ(), "GODEBUG=fips140=on")
+		out, err := cmd.CombinedOutput()
+		if strings.Contains(string(out), "--- SKIP: TestFIPSAlgorithms") {
+			t.Skip("skipped in FIPS mode")
+		}
+		if err != nil || !strings.Contains(string(out), "--- PASS: TestFIPSAlgorithms") {
+			t.Fatalf("FIPS run failed: %v\n%s", err, out)
+		}
+		return
+	}
+
+	installSOPSScript(t, "echo \"GODEBUG_SEEN=$GODEBUG\"\n")
+	config, err := LoadSOPSEnv(writeFIPSFile(t, kmsFile), WithFIPS())
+	if err != nil {
+		t.Fatalf("KMS with AES256_GCM: %v", err)
+	}
+	if got := config.Get("GODEBUG_SEEN"); !strings.Contains(got, "fips140=on") {
+		t.Errorf("sops ran with GODEBUG=%q, want fips140=on", got)
+	}
+
+	tests := []struct{ name, content, component string }{
+		{"age", strings.Replace(kmsFile, "sops_kms__list_0__map_arn=arn:aws:kms:eu-west-1:1:key/k", "sops_age__list_0__map_recipient=age1x", 1), "key backend age"},
+		{"cipher", strings.Replace(kmsFile, "ENC[AES256_GCM,data:x]", "ENC[CHACHA20,data:x]", 1), "data encryption"},
+	}
+	for _, tt := range tests {
+		_, err := LoadSOPSEnv(writeFIPSFile(t, tt.content), WithFIPS())
+		var algErr *AlgorithmError
+		if !errors.As(err, &algErr) || algErr.Component != tt.component || !errors.Is(err, ErrAlgorithmNotApproved) {
+			t.Errorf("%s: error = %v, want an *AlgorithmError for %s", tt.name, err, tt.component)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// BindFlags makes the environment and the SOPS file the flag defaults, so
+// the precedence is CLI > environment > SOPS file. A flag named db-host
+// reads DB_HOST. Call it before fs.Parse, like before cobra's Execute:
+// --help then shows the file's values, with secrets masked, and a flag set
+// this way counts as given for cobra's MarkFlagRequired. Slice flags are
+// filled with Replace, so --tags x on the command line replaces the file's
+// list instead of appending to it.
+func BindFlags(fs *pflag.FlagSet, cfg *EnvConfig) error {
+	if fs.Parsed() {
+		return errors.New("BindFlags must be called before the flags are parsed")
+	}
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil {
+			return
+		}
+
+		key := flagEnvKey(f.Name)
+		value, ok := os.LookupEnv(key)
+		if !ok && cfg != nil {
+			value, ok = cfg.values[key]
+		}
+		if !ok {
+			return
+		}
+
+		if setErr := setFlagDefault(f, value); setErr != nil {
+			err = fmt.Errorf("invalid value for --%s from %s: %w", f.Name, key, setErr)
+			return
+		}
+		f.DefValue = DefaultMaskPolicy.MaskValue(key, f.Value.String())
+		f.Changed = true
+	})
+	return err
+}
+
+// setFlagDefault sets f to value without making a later Set on the
+// command line append to it.
+func setFlagDefault(f *pflag.Flag, value string) error {
+	slice, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return f.Value.Set(value)
+	}
+	items, err := csv.NewReader(strings.NewReader(value)).Read()
+	if err != nil {
+		return err
+	}
+	return slice.Replace(items)
+}
+
+func flagEnvKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
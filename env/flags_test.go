@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestBindFlags(t *testing.T) {
+	t.Setenv("DB_HOST", "env-host")
+	cfg := &EnvConfig{envState: envState{values: map[string]string{
+		"DB_HOST":     "file-host",
+		"DB_PASSWORD": "hunter2",
+		"TAGS":        "a,b",
+		"HOSTS":       "file-1",
+	}}}
+
+	fs := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	host := fs.String("db-host", "localhost", "")
+	password := fs.String("db-password", "", "")
+	tags := fs.StringSlice("tags", nil, "")
+	hosts := fs.StringArray("hosts", nil, "")
+	if err := BindFlags(fs, cfg); err != nil {
+		t.Fatalf("BindFlags() error = %v", err)
+	}
+	if err := fs.Parse([]string{"--hosts", "cli-1", "--hosts", "cli-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindFlags(fs, cfg); err == nil {
+		t.Error("BindFlags() after Parse error = nil")
+	}
+
+	if *host != "env-host" {
+		t.Errorf("db-host = %q, want the environment's env-host", *host)
+	}
+	if *password != "hunter2" {
+		t.Errorf("db-password = %q, want the file's hunter2", *password)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(*tags, want) {
+		t.Errorf("tags = %q, want %q", *tags, want)
+	}
+	if want := []string{"cli-1", "cli-2"}; !reflect.DeepEqual(*hosts, want) {
+		t.Errorf("hosts = %q, want only the command line's %q", *hosts, want)
+	}
+	if got := fs.Lookup("db-password").DefValue; got == "hunter2" {
+		t.Error("db-password default is not masked")
+	}
+}
+
+func TestBindFlagsCobra(t *testing.T) {
+	cfg := &EnvConfig{envState: envState{values: map[string]string{
+		"DB_HOST":     "file-host",
+		"DB_PASSWORD": "hunter2",
+	}}}
+	newCommand := func() (*cobra.Command, *bytes.Buffer) {
+		var out bytes.Buffer
+		cmd := &cobra.Command{Use: "app", RunE: func(*cobra.Command, []string) error { return nil }}
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.Flags().String("db-host", "localhost", "database host")
+		cmd.Flags().String("db-password", "", "database password")
+		cmd.MarkFlagRequired("db-password")
+		if err := BindFlags(cmd.Flags(), cfg); err != nil {
+			t.Fatal(err)
+		}
+		return cmd, &out
+	}
+
+	cmd, _ := newCommand()
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("Execute() with the required flag from the file error = %v", err)
+	}
+
+	cmd, out := newCommand()
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	help := out.String()
+	if !strings.Contains(help, `(default "file-host")`) {
+		t.Errorf("--help doesn't show the file's db-host:\n%s", help)
+	}
+	if strings.Contains(help, "hunter2") {
+		t.Errorf("--help shows the db-password:\n%s", help)
+	}
+}
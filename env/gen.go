@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:generate go run . gen -manifest config.manifest.yaml -o appconfig_gen.go
+//go:generate go run . gen -manifest config.manifest.yaml -envconfig -o envconfig_gen.go
+
+// runGen writes typed accessors and a loader for a manifest. Use it from a
+// //go:generate directive so the generated file is refreshed whenever the
+// manifest changes. With -envconfig it writes this package's EnvConfig,
+// its mapping and PrintConfig instead.
+func runGen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	manifestPath := fs.String("manifest", "config.manifest.yaml", "manifest to generate from")
+	out := fs.String("o", "", "file to write, defaults to <type>_gen.go in lower case")
+	pkg := fs.String("package", "main", "package of the generated file")
+	envConfig := fs.Bool("envconfig", false, "generate EnvConfig and PrintConfig rather than accessors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	m, err := LoadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	generate, name := generateAccessors, strings.ToLower(m.Type)+"_gen.go"
+	if *envConfig {
+		generate, name = generateEnvConfig, "envconfig_gen.go"
+	}
+	code, err := generate(m, filepath.Base(*manifestPath), *pkg)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = name
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %s (%d keys) from %s\n", *out, len(m.Keys), *manifestPath)
+	return nil
+}
+
+func generateAccessors(m *Manifest, source, pkg string) ([]byte, error) {
+	var imports []string
+	for _, k := range m.Keys {
+		switch k.Type {
+		case "duration":
+			imports = appendUnique(imports, "time")
+		case "url":
+			imports = appendUnique(imports, "net/url")
+		}
+	}
+
+	var buf bytes.Buffer
+	err := accessorTemplate.Execute(&buf, map[string]any{
+		"Source":   source,
+		"Package":  pkg,
+		"Imports":  imports,
+		"Manifest": m,
+	})
+	if err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return code, nil
+}
+
+// generateEnvConfig writes EnvConfig with one string field per key, in
+// manifest order, along with the code that fills it, the key list of
+// PrintSystemEnvVars and PrintConfig.
+func generateEnvConfig(m *Manifest, source, pkg string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := envConfigTemplate.Execute(&buf, map[string]any{
+		"Source":   source,
+		"Package":  pkg,
+		"Manifest": m,
+	})
+	if err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return code, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+var accessorTemplate = template.Must(template.New("accessors").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"unexport": func(s string) string {
+		// DBPort -> dbPort, JWTSecret -> jwtSecret.
+		i := 0
+		for i < len(s) && s[i] >= 'A' && s[i] <= 'Z' {
+			i++
+		}
+		if i > 1 && i < len(s) {
+			i--
+		}
+		name := strings.ToLower(s[:i]) + s[i:]
+		if token.IsKeyword(name) || name == "env" {
+			name += "Value"
+		}
+		return name
+	},
+	"reader": func(k ManifestKey) string {
+		if k.Type == "string" && k.Secret {
+			return "Secret"
+		}
+		return map[string]string{
+			"string": "String", "int": "Int", "bool": "Bool", "float": "Float",
+			"duration": "Duration", "url": "URL", "base64": "Bytes",
+		}[k.Type]
+	},
+	"enum": func(values []string) string {
+		var b strings.Builder
+		for _, v := range values {
+			b.WriteString(", " + strconv.Quote(v))
+		}
+		return b.String()
+	},
+}).Parse(`// Code generated by go-sops gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+{{with .Imports}}
+import (
+{{- range .}}
+	{{quote .}}
+{{- end}}
+)
+{{end}}
+{{- $m := .Manifest}}
+// {{$m.Type}} is a typed view of {{$m.File}}, checked against {{.Source}}
+// when it is loaded.
+type {{$m.Type}} struct {
+	env *EnvConfig
+{{- range $m.Keys}}
+	{{unexport .Field}} {{.GoType}}
+{{- end}}
+}
+
+// Load{{$m.Type}} decrypts filename, {{$m.File}} if empty, and reads every
+// key of the manifest, reporting all missing and invalid ones at once.
+func Load{{$m.Type}}(filename string, opts ...Option) (*{{$m.Type}}, error) {
+	if filename == "" {
+		filename = {{quote $m.File}}
+	}
+	env, err := LoadSOPSEnv(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return New{{$m.Type}}(env)
+}
+
+// New{{$m.Type}} reads the manifest's keys from an already loaded config.
+func New{{$m.Type}}(env *EnvConfig) (*{{$m.Type}}, error) {
+	r := &manifestReader{env: env}
+	c := &{{$m.Type}}{
+		env: env,
+{{- range $m.Keys}}
+		{{unexport .Field}}: r.{{reader .}}({{quote .Name}}, {{quote .Default}}, {{.Required}}{{enum .Enum}}),
+{{- end}}
+	}
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Env returns the config the accessors read from.
+func (c *{{$m.Type}}) Env() *EnvConfig {
+	return c.env
+}
+{{range $m.Keys}}
+// {{.Field}} returns {{.Name}}{{with .Default}}, {{.}} if unset{{end}}.{{with .Doc}} {{.}}{{end}}
+func (c *{{$m.Type}}) {{.Field}}() {{.GoType}} {
+	return c.{{unexport .Field}}
+}
+{{end}}`))
+
+var envConfigTemplate = template.Must(template.New("envconfig").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`// Code generated by go-sops gen -envconfig from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"io"
+	"os"
+)
+{{- $m := .Manifest}}
+
+// EnvConfig holds the keys {{.Source}} declares, as the strings
+// {{$m.File}} contains. Other keys of the file are available through Get.
+type EnvConfig struct {
+{{- range $i, $k := $m.Keys}}
+{{- if and $k.Group $i}}
+{{end}}
+	{{$k.Field}} string ` + "`" + `env:{{quote $k.Name}}{{with $k.Validate}} validate:{{quote .}}{{end}}` + "`" + `
+{{- end}}
+
+	envState
+}
+
+// envConfigKeys lists the keys of EnvConfig in manifest order.
+var envConfigKeys = []string{
+{{- range $m.Keys}}
+	{{quote .Name}},
+{{- end}}
+}
+
+// setFields copies the keys of EnvConfig from envMap.
+func (c *EnvConfig) setFields(envMap map[string]string) {
+{{- range $m.Keys}}
+	c.{{.Field}} = envMap[{{quote .Name}}]
+{{- end}}
+}
+
+// PrintConfig prints the keys of config to stdout with EmojiPrinter.
+func PrintConfig(config *EnvConfig, policy *MaskPolicy) {
+	FprintConfig(os.Stdout, EmojiPrinter, config, policy)
+}
+
+// FprintConfig writes the keys of config to w by group, masked by policy.
+// Keys that are neither set nor required are left out.
+func FprintConfig(w io.Writer, printer Printer, config *EnvConfig, policy *MaskPolicy) {
+	printer.Title(w, "🔓 Successfully loaded and decrypted environment configuration:")
+{{- range $m.Keys}}
+{{- if .Group}}
+
+	printer.Section(w, {{quote .Group}})
+{{- end}}
+{{- if .Required}}
+	printer.Value(w, {{quote .Name}}, policy.MaskValue({{quote .Name}}, config.{{.Field}}))
+{{- else}}
+	if config.{{.Field}} != "" {
+		printer.Value(w, {{quote .Name}}, policy.MaskValue({{quote .Name}}, config.{{.Field}}))
+	}
+{{- end}}
+{{- end}}
+}
+`))
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	for _, tt := range []struct {
+		file  string
+		flags []string
+	}{
+		{"appconfig_gen.go", nil},
+		{"envconfig_gen.go", []string{"-envconfig"}},
+	} {
+		out := filepath.Join(t.TempDir(), tt.file)
+		args := append([]string{"-manifest", "config.manifest.yaml", "-o", out}, tt.flags...)
+		var err error
+		captureStdout(t, func() { err = runGen(args) })
+		if err != nil {
+			t.Fatalf("gen %v: %v", tt.flags, err)
+		}
+		want, _ := os.ReadFile(tt.file)
+		got, _ := os.ReadFile(out)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is stale, run go generate", tt.file)
+		}
+	}
+}
+
+func TestLoadAppConfig(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{
+		"DB_HOST": "db.internal", "DB_NAME": "app", "DB_USER": "app", "DB_PASSWORD": "hunter22",
+		"JWT_SECRET": "jwt-signing-secret", "WEBHOOK_URL": "https://hooks.example.com/x", "DEBUG": "true",
+	})
+	fake.SetFile("bad.sops.env", map[string]string{
+		"DB_NAME": "app", "DB_USER": "app", "DB_PORT": "fifty", "LOG_LEVEL": "loud",
+	})
+
+	config, err := LoadAppConfig("", WithDecryptor(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DBHost() != "db.internal" || config.DBPort() != 5432 || config.DBMaxConnections() != 10 {
+		t.Errorf("database = %q:%d, %d connections", config.DBHost(), config.DBPort(), config.DBMaxConnections())
+	}
+	if config.DBPassword().Reveal() != "hunter22" || config.DBPassword().String() == "hunter22" {
+		t.Errorf("DBPassword() = %q, revealed %q", config.DBPassword(), config.DBPassword().Reveal())
+	}
+	if config.WebhookURL().Host != "hooks.example.com" || !config.Debug() || config.LogLevel() != "info" || config.Environment() != "development" {
+		t.Errorf("webhook %v, debug %v, log level %q, environment %q", config.WebhookURL(), config.Debug(), config.LogLevel(), config.Environment())
+	}
+
+	_, err = LoadAppConfig("bad.sops.env", WithDecryptor(fake))
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LoadAppConfig() = %v, want ErrKeyNotFound", err)
+	}
+	for _, key := range []string{"DB_HOST", "JWT_SECRET", "DB_PORT", "LOG_LEVEL"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not report %s: %v", key, err)
+		}
+	}
+}
+
+func TestGenerateAccessors(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "worker.manifest.yaml")
+	os.WriteFile(manifest, []byte(`type: WorkerConfig
+file: worker.sops.env
+keys:
+  - name: POLL_INTERVAL
+    type: duration
+    default: 5s
+  - name: QUEUE_TOKEN
+    secret: true
+    required: true
+`), 0o644)
+	m, err := LoadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := generateAccessors(m, "worker.manifest.yaml", "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package worker",
+		`"time"`,
+		"func LoadWorkerConfig(filename string, opts ...Option) (*WorkerConfig, error)",
+		"func (c *WorkerConfig) PollInterval() time.Duration",
+		"func (c *WorkerConfig) QueueToken() Secret",
+		`r.Duration("POLL_INTERVAL", "5s", false)`,
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generated code is missing %q:\n%s", want, code)
+		}
+	}
+}
+
+func TestEnvConfigFollowsManifest(t *testing.T) {
+	m, err := LoadManifest("config.manifest.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	var names []string
+	for _, k := range m.Keys {
+		names = append(names, k.Name)
+		values[k.Name] = "value-of-" + k.Name
+		unsetForTest(t, k.Name)
+	}
+	if !slices.Equal(names, envConfigKeys) {
+		t.Errorf("envConfigKeys = %q, want the manifest's %q", envConfigKeys, names)
+	}
+
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", values)
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := reflect.ValueOf(config).Elem()
+	for i := range fields.NumField() {
+		key := fields.Type().Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		if got := fields.Field(i).String(); got != values[key] {
+			t.Errorf("%s = %q, want %q", fields.Type().Field(i).Name, got, values[key])
+		}
+		delete(values, key)
+	}
+	if len(values) != 0 {
+		t.Errorf("manifest keys without an EnvConfig field: %v", values)
+	}
+
+	noMasking := &MaskPolicy{Allow: names}
+	var printed bytes.Buffer
+	FprintConfig(&printed, QuietPrinter, config, noMasking)
+	if err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake)); err != nil {
+		t.Fatal(err)
+	}
+	var system bytes.Buffer
+	FprintSystemEnvVars(&system, QuietPrinter, noMasking)
+	for _, key := range names {
+		line := key + "=value-of-" + key + "\n"
+		if !strings.Contains(printed.String(), line) {
+			t.Errorf("FprintConfig() leaves out %s", key)
+		}
+		if !strings.Contains(system.String(), line) {
+			t.Errorf("FprintSystemEnvVars() leaves out %s", key)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsDiff lists the keys of *.sops.* files that changed between two git
+// refs, for review. sops keeps the ciphertext of unchanged values on edit,
+// so the keys are compared by ciphertext and nothing is decrypted. No value
+// appears in the diff, not even masked.
+type SecretsDiff struct {
+	Base  string       `json:"base"`
+	Head  string       `json:"head"`
+	Files []FileChange `json:"files"`
+}
+
+type FileChange struct {
+	File string `json:"file"`
+	// Status is added, deleted, modified or renamed.
+	Status  string   `json:"status"`
+	OldFile string   `json:"old_file,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	// Reencrypted is set when every value got a new ciphertext, as after
+	// sops rotate, so Changed may include keys whose value is the same.
+	Reencrypted       bool     `json:"reencrypted,omitempty"`
+	RecipientsAdded   []string `json:"recipients_added,omitempty"`
+	RecipientsRemoved []string `json:"recipients_removed,omitempty"`
+	// Unencrypted is set when head has no sops metadata, which usually
+	// means the file was committed in plaintext.
+	Unencrypted bool `json:"unencrypted,omitempty"`
+}
+
+func runGitOpsDiff(args []string) error {
+	fs := flag.NewFlagSet("gitops-diff", flag.ContinueOnError)
+	base := fs.String("base", "", "ref to compare against, defaults to origin/$GITHUB_BASE_REF or origin/$CI_MERGE_REQUEST_TARGET_BRANCH_NAME")
+	head := fs.String("head", "HEAD", "ref with the changes")
+	webhook := fs.String("webhook", "", "POST the diff as JSON to this URL, with the markdown in text for Slack")
+	githubPR := fs.Int("github-pr", 0, "comment the diff on this pull request, using $GITHUB_TOKEN and $GITHUB_REPOSITORY")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *base == "" {
+		for _, name := range []string{"GITHUB_BASE_REF", "CI_MERGE_REQUEST_TARGET_BRANCH_NAME"} {
+			if branch := os.Getenv(name); branch != "" {
+				*base = "origin/" + branch
+				break
+			}
+		}
+		if *base == "" {
+			return errors.New("-base is required outside of a pull or merge request pipeline")
+		}
+	}
+
+	diff, err := secretsDiff(*base, *head, fs.Args())
+	if err != nil {
+		return err
+	}
+	text := diff.markdown()
+	if jsonOutput() {
+		if err := writeJSON(diff); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(text)
+	}
+	if len(diff.Files) == 0 {
+		return nil
+	}
+
+	if *webhook != "" {
+		payload := struct {
+			*SecretsDiff
+			Text string `json:"text"`
+		}{diff, text}
+		if err := postChangeReport(*webhook, payload, nil); err != nil {
+			return fmt.Errorf("failed to post to webhook %s: %w", DefaultRedactor.Redact(*webhook), err)
+		}
+	}
+	if *githubPR > 0 {
+		if err := commentOnGitHubPR(*githubPR, text); err != nil {
+			return fmt.Errorf("failed to comment on pull request #%d: %w", *githubPR, err)
+		}
+	}
+	return nil
+}
+
+// secretsDiff compares the *.sops.* files changed between base and head,
+// or only paths if given.
+func secretsDiff(base, head string, paths []string) (*SecretsDiff, error) {
+	cmdArgs := append([]string{"diff", "--name-status", "-M", base, head, "--"}, paths...)
+	out, err := exec.Command("git", cmdArgs...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s %s failed: %w", base, head, err)
+	}
+
+	diff := &SecretsDiff{Base: base, Head: head, Files: []FileChange{}}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		status, oldFile, file := fields[0], fields[1], fields[len(fields)-1]
+		if !sopsFileName(file) && !sopsFileName(oldFile) {
+			continue
+		}
+		change := FileChange{File: file}
+		var before, after []byte
+		switch status[0] {
+		case 'A':
+			change.Status = "added"
+		case 'D':
+			change.Status = "deleted"
+		case 'R':
+			change.Status, change.OldFile = "renamed", oldFile
+		case 'M':
+			change.Status = "modified"
+		default:
+			continue
+		}
+		if change.Status != "added" {
+			if before, err = gitShow(base, oldFile); err != nil {
+				return nil, err
+			}
+		}
+		if change.Status != "deleted" {
+			if after, err = gitShow(head, file); err != nil {
+				return nil, err
+			}
+		}
+		if err := compareSOPSFiles(&change, before, after); err != nil {
+			return nil, err
+		}
+		diff.Files = append(diff.Files, change)
+	}
+	return diff, nil
+}
+
+// sopsFileName reports whether file is encrypted by naming convention,
+// like config.sops.env, as opposed to the .sops.yaml creation rules.
+func sopsFileName(file string) bool {
+	base := filepath.Base(file)
+	return strings.Contains(base, ".sops.") && base != ".sops.yaml"
+}
+
+func gitShow(ref, file string) ([]byte, error) {
+	// git diff prints paths from the top of the repository.
+	data, err := exec.Command("git", "show", ref+":"+file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s:%s failed: %w", ref, file, err)
+	}
+	return data, nil
+}
+
+// compareSOPSFiles fills in the key and recipient changes. A nil revision
+// is a file that doesn't exist on that side.
+func compareSOPSFiles(change *FileChange, before, after []byte) error {
+	oldValues, err := sopsCiphertexts(change.File, before)
+	if err != nil {
+		return err
+	}
+	newValues, err := sopsCiphertexts(change.File, after)
+	if err != nil {
+		return err
+	}
+	// Encrypted values kept from base, and how many of them changed.
+	kept, reencrypted := 0, 0
+	for key, value := range newValues {
+		old, ok := oldValues[key]
+		if !ok {
+			change.Added = append(change.Added, key)
+			continue
+		}
+		encrypted := strings.HasPrefix(old, "ENC[") && strings.HasPrefix(value, "ENC[")
+		if encrypted {
+			kept++
+		}
+		if old != value {
+			change.Changed = append(change.Changed, key)
+			if encrypted {
+				reencrypted++
+			}
+		}
+	}
+	for key := range oldValues {
+		if _, ok := newValues[key]; !ok {
+			change.Removed = append(change.Removed, key)
+		}
+	}
+	slices.Sort(change.Added)
+	slices.Sort(change.Removed)
+	slices.Sort(change.Changed)
+	change.Reencrypted = kept > 1 && reencrypted == kept
+
+	// A base without metadata shows up as all recipients added.
+	oldRecipients, _ := sopsRecipients(change.File, before)
+	newRecipients, err := sopsRecipients(change.File, after)
+	if err != nil {
+		change.Unencrypted = true
+	}
+	for recipient := range newRecipients {
+		if !oldRecipients[recipient] {
+			change.RecipientsAdded = append(change.RecipientsAdded, recipient)
+		}
+	}
+	for recipient := range oldRecipients {
+		if !newRecipients[recipient] {
+			change.RecipientsRemoved = append(change.RecipientsRemoved, recipient)
+		}
+	}
+	slices.Sort(change.RecipientsAdded)
+	slices.Sort(change.RecipientsRemoved)
+	return nil
+}
+
+// sopsCiphertexts maps the key paths of an encrypted file to their
+// ENC[...] values, or plaintext for unencrypted keys.
+func sopsCiphertexts(file string, data []byte) (map[string]string, error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch filepath.Ext(file) {
+	case ".env", ".dotenv":
+		return envCiphertexts(data), nil
+	case ".ini":
+		return nil, fmt.Errorf("%s: comparing ini files is not supported", file)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	values := map[string]string{}
+	if len(doc.Content) > 0 {
+		walkKeyPaths(doc.Content[0], nil, func(path keyPath, leaf *yaml.Node) {
+			if leaf != nil {
+				values[path.dot()] = leaf.Value
+			}
+		})
+	}
+	return values, nil
+}
+
+// sopsRecipients lists the recipients of a file as backend:recipient.
+func sopsRecipients(file string, data []byte) (map[string]bool, error) {
+	if data == nil {
+		return nil, nil
+	}
+	parse := parseTreeMetadata
+	if ext := filepath.Ext(file); ext == ".env" || ext == ".dotenv" {
+		parse = parseEnvMetadata
+	}
+	meta, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	recipients := map[string]bool{}
+	for backend, names := range meta.Recipients {
+		for _, name := range names {
+			recipients[backend+":"+name] = true
+		}
+	}
+	return recipients, nil
+}
+
+// markdown formats the diff for a pull request comment or chat message.
+func (d *SecretsDiff) markdown() string {
+	var b strings.Builder
+	if len(d.Files) == 0 {
+		fmt.Fprintf(&b, "🔐 No encrypted files changed between `%s` and `%s`.\n", d.Base, d.Head)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "### 🔐 Secret changes between `%s` and `%s`\n", d.Base, d.Head)
+	for _, f := range d.Files {
+		fmt.Fprintf(&b, "\n**%s** (%s", f.File, f.Status)
+		if f.OldFile != "" {
+			fmt.Fprintf(&b, " from %s", f.OldFile)
+		}
+		b.WriteString(")\n\n")
+		if f.Unencrypted {
+			b.WriteString("- ⚠️ Not encrypted, there is no sops metadata\n")
+		}
+		writeMarkdownList(&b, "➕ Added", f.Added)
+		writeMarkdownList(&b, "➖ Removed", f.Removed)
+		writeMarkdownList(&b, "✏️ Changed", f.Changed)
+		if f.Reencrypted {
+			b.WriteString("- 🔄 Every value was re-encrypted, so some may be unchanged\n")
+		}
+		writeMarkdownList(&b, "👥 Recipients added", f.RecipientsAdded)
+		writeMarkdownList(&b, "🚫 Recipients removed", f.RecipientsRemoved)
+		if len(f.Added)+len(f.Removed)+len(f.Changed)+len(f.RecipientsAdded)+len(f.RecipientsRemoved) == 0 && !f.Unencrypted {
+			b.WriteString("- No key changes, only sops metadata\n")
+		}
+	}
+	return b.String()
+}
+
+func writeMarkdownList(b *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "`" + item + "`"
+	}
+	fmt.Fprintf(b, "- %s: %s\n", title, strings.Join(quoted, ", "))
+}
+
+func commentOnGitHubPR(number int, body string) error {
+	token, repo := os.Getenv("GITHUB_TOKEN"), os.Getenv("GITHUB_REPOSITORY")
+	if token == "" || repo == "" {
+		return errors.New("GITHUB_TOKEN and GITHUB_REPOSITORY must be set")
+	}
+	api := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", api, repo, number)
+	return postChangeReport(url, map[string]string{"body": body}, map[string]string{
+		"Authorization": "Bearer " + token,
+		"Accept":        "application/vnd.github+json",
+	})
+}
+
+func postChangeReport(url string, payload any, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitOpsDiff(t *testing.T) {
+	commit := gitRepo(t)
+	meta := "sops_mac=ENC[AES256_GCM,data:mac]\nsops_age__list_0__map_recipient=age1alice\n"
+	commit(time.Now().Add(-time.Hour), map[string]string{
+		"config.sops.env": "DB_PASSWORD=ENC[AES256_GCM,data:one]\nAPI_KEY=ENC[AES256_GCM,data:two]\n" + meta,
+		"app.sops.yaml":   "db:\n  password: ENC[AES256_GCM,data:x]\nsops:\n  mac: y\n",
+		"README.md":       "docs\n",
+	})
+	os.Remove("app.sops.yaml")
+	commit(time.Now(), map[string]string{
+		"config.sops.env": "DB_PASSWORD=ENC[AES256_GCM,data:rotated]\nAPI_KEY=ENC[AES256_GCM,data:two]\nSTRIPE_KEY=ENC[AES256_GCM,data:new]\n" +
+			meta + "sops_age__list_1__map_recipient=age1bob\n",
+		"leaked.sops.env": "DB_PASSWORD=hunter22\n",
+		"README.md":       "more docs\n",
+	})
+
+	var webhook struct {
+		SecretsDiff
+		Text string `json:"text"`
+	}
+	var comment map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hook":
+			json.NewDecoder(r.Body).Decode(&webhook)
+		case "/repos/acme/app/issues/7/comments":
+			auth = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&comment)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_API_URL", server.URL)
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("GITHUB_REPOSITORY", "acme/app")
+
+	var runErr error
+	out := string(captureStdout(t, func() {
+		runErr = runGitOpsDiff([]string{"-base", "HEAD~1", "-webhook", server.URL + "/hook", "-github-pr", "7"})
+	}))
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	if strings.Contains(out, "hunter22") || strings.Contains(out, "README") {
+		t.Errorf("markdown shows a value or a plaintext file:\n%s", out)
+	}
+
+	want := []FileChange{
+		{File: "app.sops.yaml", Status: "deleted", Removed: []string{"db.password"}},
+		{
+			File: "config.sops.env", Status: "modified",
+			Added: []string{"STRIPE_KEY"}, Changed: []string{"DB_PASSWORD"},
+			RecipientsAdded: []string{"age:age1bob"},
+		},
+		{File: "leaked.sops.env", Status: "added", Added: []string{"DB_PASSWORD"}, Unencrypted: true},
+	}
+	if !reflect.DeepEqual(webhook.Files, want) {
+		t.Errorf("webhook files = %+v\nwant %+v", webhook.Files, want)
+	}
+	if webhook.Text != out || comment["body"] != out {
+		t.Errorf("webhook text or PR comment differ from the printed markdown")
+	}
+	if auth != "Bearer gh-token" {
+		t.Errorf("PR comment Authorization = %q", auth)
+	}
+	for _, line := range []string{"**config.sops.env** (modified)", "- ✏️ Changed: `DB_PASSWORD`", "- ⚠️ Not encrypted"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("markdown is missing %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestGitOpsDiffNeedsBase(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME", "")
+	if err := runGitOpsDiff(nil); err == nil || !strings.Contains(err.Error(), "-base is required") {
+		t.Errorf("runGitOpsDiff() = %v", err)
+	}
+}
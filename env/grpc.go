@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The config service is declared by hand and speaks JSON (content type
+// application/grpc+gosops-json), so clients need no generated code:
+//
+//	service gosops.v1.ConfigService {
+//	  rpc GetConfig(GetConfigRequest) returns (GetConfigResponse);
+//	}
+const (
+	ConfigServiceName = "gosops.v1.ConfigService"
+	getConfigMethod   = "/" + ConfigServiceName + "/GetConfig"
+
+	// ConfigContentSubtype names the JSON codec. gRPC codecs are
+	// registered process-wide by name, so it is not plain "json", which
+	// would replace any other json codec linked into the program.
+	ConfigContentSubtype = "gosops-json"
+)
+
+type GetConfigRequest struct {
+	// Keys to return. Empty means every key the client may read.
+	Keys []string `json:"keys,omitempty"`
+}
+
+type GetConfigResponse struct {
+	Values     map[string]string `json:"values"`
+	Generation uint64            `json:"generation"`
+}
+
+// ConfigACL maps a client identity to the key patterns (exact names or
+// path.Match globs) it may read. Identities are taken from the verified
+// client certificate: URI SANs (e.g. SPIFFE IDs), DNS SANs and the CN.
+type ConfigACL map[string][]string
+
+type ConfigServer struct {
+	store *Store
+	acl   ConfigACL
+}
+
+func NewConfigServer(store *Store, acl ConfigACL) *ConfigServer {
+	return &ConfigServer{store: store, acl: acl}
+}
+
+func (s *ConfigServer) Register(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: ConfigServiceName,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "GetConfig",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := new(GetConfigRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return s.GetConfig(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: getConfigMethod}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return s.GetConfig(ctx, req.(*GetConfigRequest))
+				})
+			},
+		}},
+	}, s)
+}
+
+func (s *ConfigServer) GetConfig(ctx context.Context, req *GetConfigRequest) (*GetConfigResponse, error) {
+	client, patterns := s.authorize(ctx)
+	if len(patterns) == 0 {
+		return nil, status.Error(codes.PermissionDenied, "client is not in the config ACL")
+	}
+
+	// One read, so the generation is the one the values came from.
+	config, generation := s.store.current()
+	if config == nil {
+		return nil, status.Error(codes.Unavailable, "config not loaded")
+	}
+
+	keys := req.Keys
+	if len(keys) == 0 {
+		for key := range config.values {
+			if matchesAny(patterns, key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if !matchesAny(patterns, key) {
+			return nil, status.Errorf(codes.PermissionDenied, "%s may not read %s", client, key)
+		}
+		value, ok := config.values[key]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "key %s not found", key)
+		}
+		if DefaultMaskPolicy.IsSecretValue(key, value) {
+			auditRemoteAccess(key, config.provenance.owner(key), "grpc:"+client)
+		}
+		values[key] = value
+	}
+
+	return &GetConfigResponse{Values: values, Generation: generation}, nil
+}
+
+// authorize returns the first identity of the verified client certificate
+// and the key patterns granted to any of its identities.
+func (s *ConfigServer) authorize(ctx context.Context) (string, []string) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", nil
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	var identities []string
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	if len(identities) == 0 {
+		return "", nil
+	}
+
+	var patterns []string
+	for _, id := range identities {
+		patterns = append(patterns, s.acl[id]...)
+	}
+	return identities[0], patterns
+}
+
+// NewMTLSServerCredentials requires clients to present a certificate
+// signed by clientCAFile.
+func NewMTLSServerCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	pool, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}), nil
+}
+
+// NewMTLSClientCredentials presents the client certificate and verifies
+// the server against serverCAFile.
+func NewMTLSClientCredentials(certFile, keyFile, serverCAFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	pool, err := loadCertPool(serverCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}), nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// FetchConfig calls GetConfig on conn, returning all permitted keys if
+// none are given.
+func FetchConfig(ctx context.Context, conn grpc.ClientConnInterface, keys ...string) (*GetConfigResponse, error) {
+	resp := new(GetConfigResponse)
+	err := conn.Invoke(ctx, getConfigMethod, &GetConfigRequest{Keys: keys}, resp, grpc.CallContentSubtype(ConfigContentSubtype))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return ConfigContentSubtype }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"go-sops-env/sopstest"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// clientContext is the context of a call from a client whose verified
+// certificate has the common name cn.
+func clientContext(cn string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestGetConfigGenerationMatchesValues(t *testing.T) {
+	decryptor, _ := versionDecryptor(0)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server := NewConfigServer(store, ConfigACL{"billing": {"VERSION"}})
+	ctx := clientContext("billing")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if err := store.Reload(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for range 500 {
+		resp, err := server.GetConfig(ctx, &GetConfigRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The nth load decrypts VERSION=n, so a response that mixes two
+		// generations has a different VERSION.
+		if want := strconv.FormatUint(resp.Generation, 10); resp.Values["VERSION"] != want {
+			t.Fatalf("GetConfig() = VERSION %s with generation %d", resp.Values["VERSION"], resp.Generation)
+		}
+	}
+	wg.Wait()
+
+	if _, err := server.GetConfig(clientContext("unknown"), &GetConfigRequest{}); err == nil {
+		t.Error("GetConfig() for a client not in the ACL succeeded")
+	}
+}
+
+func TestConfigCodecOverGRPC(t *testing.T) {
+	if codec := encoding.GetCodec("json"); codec != nil {
+		if _, ok := codec.(jsonCodec); ok {
+			t.Error(`the config codec is registered as "json"`)
+		}
+	}
+
+	store := NewStore("config.sops.env", WithDecryptor(sopstest.NewFake()))
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	NewConfigServer(store, ConfigACL{}).Register(server)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Without the codec the call fails with Internal before reaching
+	// GetConfig; with it, an unauthenticated caller is turned away.
+	_, err = FetchConfig(context.Background(), conn, "DB_PASSWORD")
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("FetchConfig() error = %v, want PermissionDenied", err)
+	}
+}
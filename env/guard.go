@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("decryption circuit open")
+
+// DecryptGuard protects the decryption backend. Concurrent loads of a file
+// wait for a single sops run and share its result, loads within MinInterval
+// of a success reuse it, and after FailureThreshold consecutive failures the
+// circuit opens for Cooldown, serving the last good result marked as stale.
+type DecryptGuard struct {
+	MinInterval      time.Duration
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	mu    sync.Mutex
+	files map[string]*guardState
+}
+
+type guardState struct {
+	mu         sync.Mutex
+	lastGood   []byte
+	lastGoodAt time.Time
+	failures   int
+	openUntil  time.Time
+}
+
+func NewDecryptGuard() *DecryptGuard {
+	return &DecryptGuard{
+		MinInterval:      time.Second,
+		FailureThreshold: 3,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// WithGuard routes decryption through guard. Share one guard between all
+// loaders of a file for it to be effective.
+func WithGuard(guard *DecryptGuard) Option {
+	return func(o *loadOptions) {
+		o.guard = guard
+	}
+}
+
+func (g *DecryptGuard) state(filename string) *guardState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.files == nil {
+		g.files = make(map[string]*guardState)
+	}
+	st, ok := g.files[filename]
+	if !ok {
+		st = &guardState{}
+		g.files[filename] = st
+	}
+	return st
+}
+
+func (g *DecryptGuard) Do(filename string, decrypt func() ([]byte, error)) ([]byte, bool, error) {
+	st := g.state(filename)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	if st.lastGood != nil && now.Sub(st.lastGoodAt) < g.MinInterval {
+		return st.lastGood, false, nil
+	}
+
+	if now.Before(st.openUntil) {
+		if st.lastGood != nil {
+			return st.lastGood, true, nil
+		}
+		return nil, false, ErrCircuitOpen
+	}
+
+	data, err := decrypt()
+	if err != nil {
+		st.failures++
+		if g.FailureThreshold > 0 && st.failures >= g.FailureThreshold {
+			st.openUntil = now.Add(g.Cooldown)
+			slog.Warn("decryption circuit opened", "file", filename, "failures", st.failures, "cooldown", g.Cooldown)
+			if st.lastGood != nil {
+				return st.lastGood, true, nil
+			}
+		}
+		return nil, false, err
+	}
+
+	st.failures = 0
+	st.openUntil = time.Time{}
+	st.lastGood = data
+	st.lastGoodAt = time.Now()
+	return data, false, nil
+}
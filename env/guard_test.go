@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyDecryptor fails while failing is set and counts its calls.
+type flakyDecryptor struct {
+	calls   atomic.Int64
+	failing atomic.Bool
+}
+
+func (d *flakyDecryptor) Decrypt(_ context.Context, filename string) ([]byte, error) {
+	d.calls.Add(1)
+	if d.failing.Load() {
+		return nil, &DecryptError{File: filename, Kind: ErrNoMatchingKeys}
+	}
+	return []byte("DB_HOST=db\n"), nil
+}
+
+func TestGuardOpensCircuit(t *testing.T) {
+	decryptor := &flakyDecryptor{}
+	guard := &DecryptGuard{FailureThreshold: 2, Cooldown: time.Hour}
+	load := func() (*EnvConfig, error) {
+		return LoadSOPSEnv("config.sops.env", WithDecryptor(decryptor), WithGuard(guard))
+	}
+
+	if _, err := load(); err != nil {
+		t.Fatal(err)
+	}
+	decryptor.failing.Store(true)
+	if _, err := load(); !errors.Is(err, ErrNoMatchingKeys) {
+		t.Fatalf("first failure: error = %v", err)
+	}
+	// The failure that opens the circuit already serves the last good config.
+	for i := range 3 {
+		config, err := load()
+		if err != nil {
+			t.Fatalf("load %d with the circuit open: %v", i, err)
+		}
+		if !config.Stale() || config.Get("DB_HOST") != "db" {
+			t.Errorf("load %d: stale %v, DB_HOST %q, want the last good config marked stale", i, config.Stale(), config.Get("DB_HOST"))
+		}
+	}
+	if calls := decryptor.calls.Load(); calls != 3 {
+		t.Errorf("sops ran %d times, want 3: the open circuit must not call it", calls)
+	}
+}
+
+func TestGuardOpenWithoutLastGood(t *testing.T) {
+	decryptor := &flakyDecryptor{}
+	decryptor.failing.Store(true)
+	guard := &DecryptGuard{FailureThreshold: 1, Cooldown: time.Hour}
+
+	if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(decryptor), WithGuard(guard)); !errors.Is(err, ErrNoMatchingKeys) {
+		t.Fatalf("error = %v, want ErrNoMatchingKeys", err)
+	}
+	_, err := LoadSOPSEnv("config.sops.env", WithDecryptor(decryptor), WithGuard(guard))
+	if !errors.Is(err, ErrCircuitOpen) || ErrorCategory(err) != "circuit_open" {
+		t.Errorf("error = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestGuardSharesRecentResult(t *testing.T) {
+	decryptor := &flakyDecryptor{}
+	guard := NewDecryptGuard()
+	guard.MinInterval = time.Hour
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(decryptor), WithGuard(guard)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := decryptor.calls.Load(); calls != 1 {
+		t.Errorf("sops ran %d times for concurrent loads, want 1", calls)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type healthResponse struct {
+	StoreStatus
+	Healthy bool `json:"healthy"`
+}
+
+// HealthHandler reports 200 while the store has a config and either the last
+// reload succeeded or the last success is within maxStale. A zero maxStale
+// fails the probe as soon as a reload fails.
+func (s *Store) HealthHandler(maxStale time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := s.Status()
+
+		healthy := status.Generation > 0
+		if healthy && status.LastError != "" {
+			healthy = time.Since(status.LastSuccess) < maxStale
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(healthResponse{StoreStatus: status, Healthy: healthy})
+	})
+}
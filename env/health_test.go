@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	decryptor, _ := versionDecryptor(2)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+
+	probe := func(maxStale time.Duration) (int, healthResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		store.HealthHandler(maxStale).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body healthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("response is not JSON: %v\n%s", err, rec.Body)
+		}
+		return rec.Code, body
+	}
+
+	if code, _ := probe(time.Hour); code != http.StatusServiceUnavailable {
+		t.Errorf("before Load: status %d, want 503", code)
+	}
+
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if code, body := probe(0); code != http.StatusOK || !body.Healthy || body.Generation != 1 {
+		t.Errorf("after Load: status %d, body %+v", code, body)
+	}
+
+	if err := store.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() succeeded, want the second decryption to fail")
+	}
+	if code, body := probe(0); code != http.StatusServiceUnavailable || body.LastError == "" {
+		t.Errorf("failed reload, maxStale 0: status %d, body %+v", code, body)
+	}
+	if code, _ := probe(time.Hour); code != http.StatusOK {
+		t.Errorf("failed reload within maxStale: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const NotifyRolledBack = "config.rolled_back"
+
+var ErrGenerationNotFound = errors.New("generation not in history")
+
+// storeGeneration is one config the store has served.
+type storeGeneration struct {
+	generation uint64
+	config     *EnvConfig
+	loadedAt   time.Time
+	restored   uint64
+}
+
+// GenerationInfo describes a generation kept by WithHistory.
+type GenerationInfo struct {
+	Generation uint64    `json:"generation"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	Keys       int       `json:"keys"`
+	Current    bool      `json:"current,omitempty"`
+	// Restored is the generation this one is a copy of, after a rollback.
+	Restored uint64 `json:"restored,omitempty"`
+}
+
+// GenerationDiff compares two generations. Values are masked.
+type GenerationDiff struct {
+	From    uint64         `json:"from"`
+	To      uint64         `json:"to"`
+	Added   []string       `json:"added,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+	Changed []ConfigChange `json:"changed,omitempty"`
+}
+
+// ConfigChange holds masked values only.
+type ConfigChange struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// WithHistory makes a Store keep its last n decrypted generations, the
+// current one included, so Rollback can put an earlier one back. Every
+// kept generation holds its secrets in memory.
+func WithHistory(n int) Option {
+	return func(o *loadOptions) {
+		o.history = n
+	}
+}
+
+// record must be called with s.mu held.
+func (s *Store) record(config *EnvConfig, restored uint64, keep int) {
+	if keep <= 0 {
+		return
+	}
+	s.history = append(s.history, storeGeneration{
+		generation: s.generation,
+		config:     config,
+		loadedAt:   time.Now(),
+		restored:   restored,
+	})
+	if len(s.history) > keep {
+		s.history = slices.Delete(s.history, 0, len(s.history)-keep)
+	}
+}
+
+// History lists the kept generations, oldest first.
+func (s *Store) History() []GenerationInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]GenerationInfo, 0, len(s.history))
+	for _, g := range s.history {
+		infos = append(infos, GenerationInfo{
+			Generation: g.generation,
+			LoadedAt:   g.loadedAt,
+			Keys:       len(g.config.values),
+			Current:    g.generation == s.generation,
+			Restored:   g.restored,
+		})
+	}
+	return infos
+}
+
+// Rollback serves the config from n generations before the newest kept
+// one again, as a new generation. Rollback(1) reverts the last reload, and
+// calling it again undoes the rollback. The rolled back config stays until
+// the next reload, so revert the file too or the watcher will bring the bad
+// one back on its next change.
+func (s *Store) Rollback(n int) (uint64, error) {
+	s.mu.RLock()
+	kept := len(s.history)
+	var generation uint64
+	if i := kept - 1 - n; n > 0 && i >= 0 {
+		generation = s.history[i].generation
+	}
+	s.mu.RUnlock()
+	if generation == 0 {
+		return 0, fmt.Errorf("%w: %d back, %d kept", ErrGenerationNotFound, n, kept)
+	}
+	return s.RollbackTo(generation)
+}
+
+// RollbackTo serves the config of a kept generation again, as a new
+// generation, and returns the new generation number.
+func (s *Store) RollbackTo(generation uint64) (uint64, error) {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	options := newLoadOptions(s.opts)
+
+	var previous, current *EnvConfig
+	defer func() {
+		if current != nil {
+			s.changed(previous, current)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.find(generation)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrGenerationNotFound, generation)
+	}
+	if g.restored != 0 {
+		generation = g.restored
+	}
+	previous, current = s.config, g.config
+	s.config = g.config
+	s.generation++
+	s.lastError = nil
+	s.record(g.config, generation, options.history)
+
+	slog.Warn("config rolled back", "file", s.filename, "generation", s.generation, "restored", generation)
+	var changed []string
+	if previous != nil {
+		changed = diffGenerations(previous, g.config).changedKeys()
+	}
+	s.notify(options, g.config, NotifyEvent{Type: NotifyRolledBack, Keys: changed})
+	return s.generation, nil
+}
+
+// Diff compares two kept generations.
+func (s *Store) Diff(from, to uint64) (*GenerationDiff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.find(from)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrGenerationNotFound, from)
+	}
+	b, ok := s.find(to)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrGenerationNotFound, to)
+	}
+	diff := diffGenerations(a.config, b.config)
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// find must be called with s.mu held.
+func (s *Store) find(generation uint64) (storeGeneration, bool) {
+	for _, g := range s.history {
+		if g.generation == generation {
+			return g, true
+		}
+	}
+	return storeGeneration{}, false
+}
+
+func diffGenerations(from, to *EnvConfig) *GenerationDiff {
+	diff := &GenerationDiff{}
+	for key, value := range to.values {
+		old, ok := from.values[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case old != value:
+			diff.Changed = append(diff.Changed, ConfigChange{
+				Key:  key,
+				From: DefaultMaskPolicy.MaskValue(key, old),
+				To:   DefaultMaskPolicy.MaskValue(key, value),
+			})
+		}
+	}
+	for key := range from.values {
+		if _, ok := to.values[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b ConfigChange) int { return cmp.Compare(a.Key, b.Key) })
+	return diff
+}
+
+func (d *GenerationDiff) changedKeys() []string {
+	keys := slices.Concat(d.Added, d.Removed)
+	for _, c := range d.Changed {
+		keys = append(keys, c.Key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+type historyResponse struct {
+	Generation uint64           `json:"generation"`
+	History    []GenerationInfo `json:"history,omitempty"`
+	Diff       *GenerationDiff  `json:"diff,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// HistoryHandler lets an operator inspect and revert reloads, with the same
+// bearer token rules as ReloadHandler. GET lists the kept generations, or
+// with ?from=3&to=4 returns their diff. POST rolls back ?steps=1 by default,
+// or to ?generation=N, and returns the diff it made. Mount it at /-/history.
+func (s *Store) HistoryHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !checkBearer(w, r, token) {
+			return
+		}
+
+		query := r.URL.Query()
+		resp := historyResponse{}
+		status := http.StatusOK
+		var err error
+		switch {
+		case r.Method == http.MethodPost:
+			before := s.Generation()
+			var after uint64
+			if g := query.Get("generation"); g != "" {
+				var generation uint64
+				if generation, err = strconv.ParseUint(g, 10, 64); err == nil {
+					after, err = s.RollbackTo(generation)
+				}
+			} else {
+				steps := 1
+				if n := query.Get("steps"); n != "" {
+					steps, err = strconv.Atoi(n)
+				}
+				if err == nil {
+					after, err = s.Rollback(steps)
+				}
+			}
+			if err == nil {
+				// A stale generation served by the guard has no diff.
+				resp.Diff, _ = s.Diff(before, after)
+			}
+		case query.Has("from") || query.Has("to"):
+			var from, to uint64
+			from, err = strconv.ParseUint(query.Get("from"), 10, 64)
+			if err == nil {
+				to, err = strconv.ParseUint(query.Get("to"), 10, 64)
+			}
+			if err == nil {
+				resp.Diff, err = s.Diff(from, to)
+			}
+		default:
+			resp.History = s.History()
+		}
+		resp.Generation = s.Generation()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err != nil {
+			resp.Error = err.Error()
+			status = http.StatusBadRequest
+			if errors.Is(err, ErrGenerationNotFound) {
+				status = http.StatusNotFound
+			}
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
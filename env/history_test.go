@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStoreRollback(t *testing.T) {
+	decryptor, _ := versionDecryptor(0)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor), WithHistory(3))
+	for range 4 {
+		if err := store.Load(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := store.History()
+	if len(history) != 3 || history[0].Generation != 2 || !history[2].Current || history[2].Keys != 2 {
+		t.Fatalf("History() = %+v, want generations 2-4", history)
+	}
+
+	generation, err := store.Rollback(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generation != 5 || configVersion(t, store.Config()) != 3 {
+		t.Errorf("Rollback(1) = generation %d serving VERSION=%d, want 5 serving 3", generation, configVersion(t, store.Config()))
+	}
+	if last := store.History()[2]; last.Restored != 3 {
+		t.Errorf("newest generation = %+v, want restored from 3", last)
+	}
+
+	diff, err := store.Diff(4, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Key != "DB_PASSWORD" || strings.Contains(diff.Changed[0].From, "secret-4") {
+		t.Errorf("Diff(4, 5) = %+v, want masked changes", diff)
+	}
+	if diff.Changed[1] != (ConfigChange{Key: "VERSION", From: "4", To: "3"}) {
+		t.Errorf("VERSION change = %+v", diff.Changed[1])
+	}
+
+	// Rolling back the rollback brings the reload back.
+	if _, err := store.Rollback(1); err != nil || configVersion(t, store.Config()) != 4 {
+		t.Errorf("second Rollback(1): %v, VERSION=%d", err, configVersion(t, store.Config()))
+	}
+	if _, err := store.Rollback(3); !errors.Is(err, ErrGenerationNotFound) {
+		t.Errorf("Rollback(3) with 3 kept = %v, want ErrGenerationNotFound", err)
+	}
+	if _, err := store.RollbackTo(1); !errors.Is(err, ErrGenerationNotFound) {
+		t.Errorf("RollbackTo(1) = %v, want ErrGenerationNotFound", err)
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	decryptor, _ := versionDecryptor(0)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor), WithHistory(5))
+	for range 3 {
+		if err := store.Load(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := store.HistoryHandler("s3cret")
+	send := func(method, target, auth string) (int, historyResponse) {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", auth)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp historyResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	if code, _ := send(http.MethodGet, "/-/history", "Bearer nope"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", code)
+	}
+	if code, resp := send(http.MethodGet, "/-/history", "Bearer s3cret"); code != http.StatusOK || len(resp.History) != 3 || resp.Generation != 3 {
+		t.Errorf("GET = %d %+v", code, resp)
+	}
+	if code, resp := send(http.MethodGet, "/-/history?from=1&to=3", "Bearer s3cret"); code != http.StatusOK || resp.Diff == nil || len(resp.Diff.Changed) != 2 {
+		t.Errorf("GET diff = %d %+v", code, resp)
+	}
+	code, resp := send(http.MethodPost, "/-/history?generation=1", "Bearer s3cret")
+	if code != http.StatusOK || resp.Generation != 4 || resp.Diff == nil || resp.Diff.From != 3 || resp.Diff.To != 4 {
+		t.Errorf("POST generation=1 = %d %+v", code, resp)
+	}
+	if configVersion(t, store.Config()) != 1 {
+		t.Errorf("serving VERSION=%d after rollback to generation 1", configVersion(t, store.Config()))
+	}
+	if code, resp := send(http.MethodPost, "/-/history?steps=9", "Bearer s3cret"); code != http.StatusNotFound || resp.Error == "" {
+		t.Errorf("POST steps=9 = %d %+v", code, resp)
+	}
+	if code, _ := send(http.MethodPost, "/-/history?steps=x", "Bearer s3cret"); code != http.StatusBadRequest {
+		t.Errorf("POST steps=x = %d", code)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const starterEnv = `# Database Configuration
+DB_HOST=localhost
+DB_PORT=5432
+DB_NAME=app
+DB_USER=app
+DB_PASSWORD=change_me
+
+# API Keys and Secrets
+JWT_SECRET=change_me
+
+# Environment Settings
+ENVIRONMENT=development
+DEBUG=true
+LOG_LEVEL=debug
+`
+
+const starterYAML = `storage:
+  psql:
+    host: 127.0.0.1
+    port: 5432
+    database: app
+    username: app
+    password: change_me
+    pg_pool_max_conn: 10
+jwt:
+  auth: change_me
+`
+
+type recipients struct {
+	age    string
+	pgp    string
+	awsKMS string
+	gcpKMS string
+}
+
+func (r recipients) empty() bool {
+	return r.age == "" && r.pgp == "" && r.awsKMS == "" && r.gcpKMS == ""
+}
+
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to scaffold")
+	awsKMS := fs.String("aws-kms", "", "AWS KMS key ARN to add to the creation rules")
+	gcpKMS := fs.String("gcp-kms", "", "GCP KMS key resource ID to add to the creation rules")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Println("🔍 Detecting available keys...")
+	r := recipients{awsKMS: *awsKMS, gcpKMS: *gcpKMS}
+
+	if key, path := detectAgeRecipient(); key != "" {
+		fmt.Printf("  ✅ age key: %s (%s)\n", key, path)
+		r.age = key
+	}
+	if fp := detectPGPFingerprint(); fp != "" {
+		fmt.Printf("  ✅ GPG key: %s\n", fp)
+		r.pgp = fp
+	}
+	if detectAWSIdentity() {
+		if r.awsKMS == "" {
+			fmt.Println("  ℹ️  AWS credentials found, pass -aws-kms <arn> to use AWS KMS")
+		} else {
+			fmt.Printf("  ✅ AWS KMS: %s\n", r.awsKMS)
+		}
+	}
+	if detectGCloudIdentity() {
+		if r.gcpKMS == "" {
+			fmt.Println("  ℹ️  gcloud credentials found, pass -gcp-kms <resource-id> to use GCP KMS")
+		} else {
+			fmt.Printf("  ✅ GCP KMS: %s\n", r.gcpKMS)
+		}
+	}
+
+	if r.empty() {
+		return errors.New("no usable keys found, create one with `age-keygen -o ~/.config/sops/age/keys.txt` or pass -aws-kms/-gcp-kms")
+	}
+
+	sopsConfig := filepath.Join(*dir, ".sops.yaml")
+	if !*force {
+		for _, name := range []string{".sops.yaml", "config.sops.env", "config.sops.yaml"} {
+			if _, err := os.Stat(filepath.Join(*dir, name)); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite", name)
+			}
+		}
+	}
+
+	if err := writeScaffold(sopsConfig, renderSOPSConfig(r)); err != nil {
+		return err
+	}
+	fmt.Printf("\n📝 Wrote %s\n", sopsConfig)
+
+	starters := []struct {
+		name    string
+		content string
+	}{
+		{"config.sops.env", starterEnv},
+		{"config.sops.yaml", starterYAML},
+	}
+
+	for _, starter := range starters {
+		path := filepath.Join(*dir, starter.name)
+		if err := encryptToFile(path, "/dev/stdin", strings.NewReader(starter.content)); err != nil {
+			return err
+		}
+		fmt.Printf("🔒 Created %s\n", path)
+	}
+
+	fmt.Println("\n✅ Done! Edit your secrets with: sops config.sops.env")
+	return nil
+}
+
+func renderSOPSConfig(r recipients) string {
+	var b strings.Builder
+	b.WriteString("creation_rules:\n")
+
+	for _, pathRegex := range []string{`\.(env|dotenv)$`, `\.ya?ml$`} {
+		fmt.Fprintf(&b, "  - path_regex: %s\n", pathRegex)
+		if r.age != "" {
+			fmt.Fprintf(&b, "    age: >-\n      %s\n", r.age)
+		}
+		if r.pgp != "" {
+			fmt.Fprintf(&b, "    pgp: >-\n      %s\n", r.pgp)
+		}
+		if r.awsKMS != "" {
+			fmt.Fprintf(&b, "    kms: >-\n      %s\n", r.awsKMS)
+		}
+		if r.gcpKMS != "" {
+			fmt.Fprintf(&b, "    gcp_kms: >-\n      %s\n", r.gcpKMS)
+		}
+	}
+	return b.String()
+}
+
+func writeScaffold(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// encryptToFile encrypts source with sops into target. sops runs in
+// target's directory and treats the input as if it were named target, so
+// the creation rules for target apply; flags, such as --age, override
+// them. source may be /dev/stdin to encrypt plaintext from stdin without
+// writing it anywhere.
+//
+// The ciphertext goes to a temporary file next to target that is renamed
+// over it, so plaintext never sits under the encrypted name, and an
+// existing target is left untouched when sops fails.
+func encryptToFile(target, source string, stdin io.Reader, flags ...string) error {
+	dir, name := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+	if source != "/dev/stdin" {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		source = abs
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var stderr bytes.Buffer
+	args := append([]string{"-e", "--filename-override", name}, flags...)
+	cmd := exec.Command("sops", append(args, source)...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	cmd.Stdout = tmp
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func ageKeyFile() string {
+	if path := os.Getenv("SOPS_AGE_KEY_FILE"); path != "" {
+		return path
+	}
+
+	var candidates []string
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(configDir, "sops", "age", "keys.txt"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".config", "sops", "age", "keys.txt"))
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func detectAgeRecipient() (string, string) {
+	path := ageKeyFile()
+	if path == "" {
+		return "", ""
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if key, ok := strings.CutPrefix(line, "# public key:"); ok {
+			return strings.TrimSpace(key), path
+		}
+	}
+	return "", ""
+}
+
+func detectPGPFingerprint() string {
+	output, err := exec.Command("gpg", "--list-secret-keys", "--with-colons").Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) > 9 && fields[0] == "fpr" {
+			return fields[9]
+		}
+	}
+	return ""
+}
+
+func detectAWSIdentity() bool {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_PROFILE") != "" {
+		return true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(home, ".aws", "credentials"))
+	return err == nil
+}
+
+func detectGCloudIdentity() bool {
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		return true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(home, ".config", "gcloud", "application_default_credentials.json"))
+	return err == nil
+}
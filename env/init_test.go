@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitScaffolds(t *testing.T) {
+	installSOPSScript(t, encryptingSOPS)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	keyFile := filepath.Join(home, "keys.txt")
+	if err := os.WriteFile(keyFile, []byte("# created: 2026-01-01\n# public key: age1testrecipient\nAGE-SECRET-KEY-1...\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+
+	dir := t.TempDir()
+	if err := runInit([]string{"-dir", dir, "-aws-kms", "arn:aws:kms:eu-west-1:111:key/abc"}); err != nil {
+		t.Fatalf("runInit() error = %v", err)
+	}
+
+	rules, err := os.ReadFile(filepath.Join(dir, ".sops.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"age1testrecipient", "arn:aws:kms:eu-west-1:111:key/abc", `path_regex: \.(env|dotenv)$`, `path_regex: \.ya?ml$`} {
+		if !strings.Contains(string(rules), want) {
+			t.Errorf(".sops.yaml lacks %q:\n%s", want, rules)
+		}
+	}
+	for _, name := range []string{"config.sops.env", "config.sops.yaml"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The fake sops prefixes what it encrypts, so no plain line is left.
+		if !strings.HasPrefix(string(data), "# as "+name+"\nENC ") || strings.Contains(string(data), "\nDB_PASSWORD") {
+			t.Errorf("%s was not written through sops:\n%s", name, data)
+		}
+	}
+
+	if err := runInit([]string{"-dir", dir}); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("runInit() over existing files error = %v, want a hint to use -force", err)
+	}
+	if err := runInit([]string{"-dir", dir, "-force"}); err != nil {
+		t.Errorf("runInit() -force error = %v", err)
+	}
+}
+
+func TestInitWithoutKeys(t *testing.T) {
+	installSOPSScript(t, encryptingSOPS)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GNUPGHOME", t.TempDir())
+
+	dir := t.TempDir()
+	if err := runInit([]string{"-dir", dir}); err == nil || !strings.Contains(err.Error(), "no usable keys") {
+		t.Fatalf("runInit() error = %v, want no usable keys", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".sops.yaml")); err == nil {
+		t.Error("runInit() wrote .sops.yaml without any key")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runK8sInit decrypts every mounted *.sops.* file into dst and exits, so it
+// can run as an init container and the app container never needs sops or
+// keys. config.sops.env is written as config.env, and so on.
+func runK8sInit(args []string) error {
+	fset := flag.NewFlagSet("k8s-init", flag.ContinueOnError)
+	src := fset.String("src", "/sops", "directory with the mounted encrypted files")
+	dst := fset.String("dst", "/secrets", "emptyDir volume to write the plaintext files to")
+	modeFlag := fset.String("mode", "0400", "permissions of the written files; use 0440 with fsGroup to share them")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	mode, err := strconv.ParseUint(*modeFlag, 8, 32)
+	if err != nil || mode&^0o777 != 0 {
+		return fmt.Errorf("invalid -mode %q, expected octal permissions like 0400", *modeFlag)
+	}
+	if mode&0o007 != 0 {
+		return fmt.Errorf("-mode %s would make secrets world-accessible", *modeFlag)
+	}
+
+	files, err := filepath.Glob(filepath.Join(*src, "*.sops.*"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *.sops.* files found in %s", *src)
+	}
+
+	dirMode := fs.FileMode(0o700)
+	if mode&0o070 != 0 {
+		dirMode = 0o750
+	}
+	if err := os.MkdirAll(*dst, dirMode); err != nil {
+		return err
+	}
+	if err := os.Chmod(*dst, dirMode); err != nil {
+		return err
+	}
+
+	options := newLoadOptions(nil)
+	for _, file := range files {
+		target := filepath.Join(*dst, plaintextName(filepath.Base(file)))
+		if err := decryptToFile(context.Background(), file, target, fs.FileMode(mode), options); err != nil {
+			return err
+		}
+		fmt.Printf("🔓 %s -> %s\n", file, target)
+	}
+	fmt.Printf("✅ Decrypted %d file(s) into %s\n", len(files), *dst)
+	return nil
+}
+
+// plaintextName drops the ".sops" part of an encrypted file's name.
+func plaintextName(name string) string {
+	if i := strings.Index(name, ".sops."); i >= 0 {
+		return name[:i] + name[i+len(".sops"):]
+	}
+	return name
+}
+
+// decryptToFile writes the plaintext of filename to target through a temp
+// file, so the app never sees a partly written secret.
+func decryptToFile(ctx context.Context, filename, target string, mode fs.FileMode, options *loadOptions) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := decryptSOPSFile(ctx, filename, options, buf); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-"+filepath.Base(target)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Chmod before writing, so the plaintext is never readable with the
+	// default umask permissions.
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
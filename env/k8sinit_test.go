@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestK8sInitDecryptsMountedFiles(t *testing.T) {
+	installFakeSOPSBinary(t)
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "secrets")
+	files := map[string]string{
+		"config.sops.env":  "DB_PASSWORD=hunter22\n",
+		"app.sops.yaml":    "jwt:\n  auth: x\n",
+		"README.md":        "not a secret\n",
+		"other.enc.yaml":   "ignored\n",
+		"service.sops.ini": "[db]\npassword=x\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	captureStdout(t, func() {
+		if err := runK8sInit([]string{"-src", src, "-dst", dst, "-mode", "0440"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	entries, _ := os.ReadDir(dst)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "app.yaml,config.env,service.ini" {
+		t.Fatalf("dst holds %s", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(dst, "config.env"))
+	if string(data) != files["config.sops.env"] {
+		t.Errorf("config.env = %q", data)
+	}
+	info, _ := os.Stat(filepath.Join(dst, "config.env"))
+	if info.Mode().Perm() != 0o440 {
+		t.Errorf("config.env mode = %v, want 0440", info.Mode().Perm())
+	}
+	// Group-readable files need a group-traversable directory.
+	if info, _ := os.Stat(dst); info.Mode().Perm() != 0o750 {
+		t.Errorf("dst mode = %v, want 0750", info.Mode().Perm())
+	}
+}
+
+func TestK8sInitErrors(t *testing.T) {
+	installFakeSOPSBinary(t)
+	empty := t.TempDir()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-src", empty, "-dst", t.TempDir(), "-mode", "0644"}, "world-accessible"},
+		{[]string{"-src", empty, "-dst", t.TempDir(), "-mode", "rw"}, "invalid -mode"},
+		{[]string{"-src", empty, "-dst", t.TempDir()}, "no *.sops.* files"},
+	}
+	for _, tt := range tests {
+		if err := runK8sInit(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("runK8sInit(%q) error = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+
+	src, dst := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(src, "config.sops.env"), []byte("X=1\n"), 0o600)
+	installSOPSScript(t, "echo 'Failed to get the data key' >&2; exit 128\n")
+	if err := runK8sInit([]string{"-src", src, "-dst", dst}); err == nil {
+		t.Fatal("runK8sInit succeeded with a failing sops")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dst, "*")); len(matches) != 0 {
+		t.Errorf("a failed decryption left %q behind", matches)
+	}
+}
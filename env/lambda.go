@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-sops-env/lambdaconfig"
+)
+
+const (
+	// DefaultLambdaConfigFile is where a layer ships the encrypted file;
+	// layers are extracted to /opt.
+	DefaultLambdaConfigFile = "/opt/config.sops.env"
+	lambdaConfigFileEnv     = "SOPS_CONFIG_FILE"
+	lambdaExtensionAddr     = "127.0.0.1:2775"
+)
+
+// ErrKeyNotFound is lambdaconfig.ErrKeyNotFound, so a missing key wraps the
+// same error whether it came from an EnvConfig or a LambdaConfig.
+var ErrKeyNotFound = lambdaconfig.ErrKeyNotFound
+
+// LambdaConfig holds the decrypted values for the life of a Lambda
+// execution environment, so only the cold start pays for sops.
+type LambdaConfig = lambdaconfig.Config
+
+// LoadLambdaConfig decrypts $SOPS_CONFIG_FILE, or DefaultLambdaConfigFile,
+// on the first call for that file and returns the cached config afterwards.
+// Call it from init or the handler; a failed load is retried on the next
+// call.
+func LoadLambdaConfig(opts ...Option) (*LambdaConfig, error) {
+	filename := os.Getenv(lambdaConfigFileEnv)
+	if filename == "" {
+		filename = DefaultLambdaConfigFile
+	}
+	return loadLambdaFile(filename, opts)
+}
+
+func loadLambdaFile(filename string, opts []Option) (*LambdaConfig, error) {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	return lambdaconfig.Load("file:"+filename, func() (map[string]string, error) {
+		return readSOPSEnvMap(context.Background(), filename, newLoadOptions(opts))
+	})
+}
+
+// LoadLambdaConfigEmbedded is LoadLambdaConfig for a file compiled into the
+// binary with go:embed. name keeps the extension sops needs to tell the
+// format, e.g. "config.sops.env". Each name and content is cached
+// separately.
+func LoadLambdaConfigEmbedded(name string, data []byte, opts ...Option) (*LambdaConfig, error) {
+	sum := sha256.Sum256(data)
+	return lambdaconfig.Load("embedded:"+name+":"+hex.EncodeToString(sum[:]), func() (map[string]string, error) {
+		// /tmp is the only writable path in Lambda.
+		dir, err := os.MkdirTemp("", "go-sops-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, filepath.Base(name))
+		if err := os.WriteFile(filename, data, 0o600); err != nil {
+			return nil, err
+		}
+		return readSOPSEnvMap(context.Background(), filename, newLoadOptions(opts))
+	})
+}
+
+// invalidValue leaves the value out of the message, since the strconv and
+// time errors would quote the secret.
+func invalidValue(key, kind string) error {
+	return fmt.Errorf("%s is not a valid %s", key, kind)
+}
+
+// runLambdaExtension runs as an external Lambda extension: it decrypts the
+// file once during init and serves values on localhost to functions in
+// any runtime, until the environment shuts down.
+//
+//	GET http://127.0.0.1:2775/config?key=DB_PASSWORD
+//	X-Sops-Token: $AWS_SESSION_TOKEN
+func runLambdaExtension(args []string) error {
+	fs := flag.NewFlagSet("lambda-extension", flag.ContinueOnError)
+	filename := fs.String("f", DefaultLambdaConfigFile, "encrypted env file to serve")
+	addr := fs.String("addr", lambdaExtensionAddr, "local address to serve values on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if runtimeAPI == "" {
+		return errors.New("AWS_LAMBDA_RUNTIME_API is not set, run this as a Lambda extension from /opt/extensions")
+	}
+	ext := &lambdaExtension{base: "http://" + runtimeAPI + "/2020-01-01/extension", name: filepath.Base(os.Args[0])}
+	if err := ext.register(); err != nil {
+		return err
+	}
+
+	cfg, err := loadLambdaFile(*filename, nil)
+	if err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: cfg.Handler(os.Getenv("AWS_SESSION_TOKEN")), ReadHeaderTimeout: 5 * time.Second}
+	go server.Serve(lis)
+	defer server.Close()
+
+	for {
+		event, err := ext.next()
+		if err != nil {
+			return err
+		}
+		if event == "SHUTDOWN" {
+			return nil
+		}
+	}
+}
+
+type lambdaExtension struct {
+	base string
+	name string
+	id   string
+}
+
+func (e *lambdaExtension) register() error {
+	body, _ := json.Marshal(map[string][]string{"events": {"SHUTDOWN"}})
+	req, err := http.NewRequest(http.MethodPost, e.base+"/register", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Lambda-Extension-Name", e.name)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to register extension: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to register extension: %s", resp.Status)
+	}
+	e.id = resp.Header.Get("Lambda-Extension-Identifier")
+	return nil
+}
+
+// next blocks until the next lifecycle event and returns its type.
+func (e *lambdaExtension) next() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, e.base+"/event/next", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Lambda-Extension-Identifier", e.id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch next event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var event struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		return "", fmt.Errorf("failed to decode event: %w", err)
+	}
+	return event.EventType, nil
+}
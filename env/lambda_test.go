@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestLoadLambdaConfigCachesPerFile(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "a.sops.env"), filepath.Join(dir, "b.sops.env")
+	fake := sopstest.NewFake()
+	fake.SetFile(first, map[string]string{"NAME": "a"})
+	fake.SetFile(second, map[string]string{"NAME": "b"})
+
+	for range 2 {
+		for file, want := range map[string]string{first: "a", second: "b"} {
+			t.Setenv(lambdaConfigFileEnv, file)
+			cfg, err := LoadLambdaConfig(WithDecryptor(fake))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := cfg.Lookup("NAME"); got != want {
+				t.Errorf("LoadLambdaConfig() for %s NAME = %q, want %q", filepath.Base(file), got, want)
+			}
+		}
+	}
+	if fake.Loads(first) != 1 || fake.Loads(second) != 1 {
+		t.Errorf("loads = %d and %d, want each file decrypted once", fake.Loads(first), fake.Loads(second))
+	}
+}
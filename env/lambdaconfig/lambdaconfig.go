@@ -0,0 +1,160 @@
+// Package lambdaconfig keeps decrypted values for the life of an AWS Lambda
+// execution environment, so only the cold start pays for sops. Load caches
+// one Config per source, with typed getters and an HTTP handler for the
+// extension mode.
+//
+// The package does not run sops itself: Load takes the function that
+// decrypts. go-sops wires it up in LoadLambdaConfig and
+// LoadLambdaConfigEmbedded.
+package lambdaconfig
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenHeader carries the token Handler checks.
+const TokenHeader = "X-Sops-Token"
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// Config holds the decrypted values of one source.
+type Config struct {
+	values map[string]string
+}
+
+var (
+	mu      sync.Mutex
+	configs = make(map[string]*Config)
+)
+
+// Load returns the Config cached for source, calling load on the first call
+// for it. source names what load decrypts, such as "file:/opt/config.sops.env",
+// so loads of different files don't share a cache entry. A failed load is
+// not cached and the next call tries again.
+func Load(source string, load func() (map[string]string, error)) (*Config, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg, ok := configs[source]; ok {
+		return cfg, nil
+	}
+
+	start := time.Now()
+	values, err := load()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("decrypted Lambda config", "keys", len(values), "duration", time.Since(start))
+	cfg := &Config{values: values}
+	configs[source] = cfg
+	return cfg, nil
+}
+
+func (c *Config) Lookup(key string) (string, bool) {
+	value, ok := c.values[key]
+	return value, ok
+}
+
+func (c *Config) String(key string) (string, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	return value, nil
+}
+
+func (c *Config) Int(key string) (int, error) {
+	value, err := c.String(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, invalidValue(key, "integer")
+	}
+	return n, nil
+}
+
+func (c *Config) Bool(key string) (bool, error) {
+	value, err := c.String(key)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidValue(key, "boolean")
+	}
+	return b, nil
+}
+
+func (c *Config) Duration(key string) (time.Duration, error) {
+	value, err := c.String(key)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, invalidValue(key, "duration")
+	}
+	return d, nil
+}
+
+// Bytes base64-decodes the value of key, accepting the standard and
+// URL-safe alphabets with or without padding.
+func (c *Config) Bytes(key string) ([]byte, error) {
+	value, err := c.String(key)
+	if err != nil {
+		return nil, err
+	}
+	value = strings.TrimRight(strings.Join(strings.Fields(value), ""), "=")
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(value, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	data, err := encoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidValue(key, "base64 value")
+	}
+	return data, nil
+}
+
+// invalidValue leaves the value out of the message, since the strconv and
+// time errors would quote the secret.
+func invalidValue(key, kind string) error {
+	return fmt.Errorf("%s is not a valid %s", key, kind)
+}
+
+// Handler serves GET /config?key=NAME to callers presenting token in the
+// TokenHeader header.
+func (c *Config) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/config" {
+			http.NotFound(w, r)
+			return
+		}
+		given := r.Header.Get(TokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		key := r.URL.Query().Get("key")
+		value, ok := c.Lookup(key)
+		if !ok {
+			http.Error(w, "key not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]string{"key": key, "value": value})
+	})
+}
@@ -0,0 +1,101 @@
+package lambdaconfig
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCachesPerSource(t *testing.T) {
+	calls := map[string]int{}
+	loader := func(source, value string) func() (map[string]string, error) {
+		return func() (map[string]string, error) {
+			calls[source]++
+			return map[string]string{"NAME": value}, nil
+		}
+	}
+
+	for range 2 {
+		for _, source := range []string{"file:/a.sops.env", "file:/b.sops.env"} {
+			cfg, err := Load(t.Name()+source, loader(source, source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := cfg.Lookup("NAME"); got != source {
+				t.Errorf("Load(%q) NAME = %q, want %q", source, got, source)
+			}
+		}
+	}
+	for source, n := range calls {
+		if n != 1 {
+			t.Errorf("%s decrypted %d times, want 1", source, n)
+		}
+	}
+}
+
+func TestLoadRetriesFailures(t *testing.T) {
+	fail := errors.New("kms unavailable")
+	if _, err := Load(t.Name(), func() (map[string]string, error) { return nil, fail }); !errors.Is(err, fail) {
+		t.Fatalf("Load() error = %v, want %v", err, fail)
+	}
+	cfg, err := Load(t.Name(), func() (map[string]string, error) { return map[string]string{"OK": "1"}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Lookup("OK"); !ok {
+		t.Error("the load after a failure was not used")
+	}
+}
+
+func TestGetters(t *testing.T) {
+	cfg := &Config{values: map[string]string{
+		"PORT":    "5432",
+		"DEBUG":   "true",
+		"TIMEOUT": "3s",
+		"CERT":    "aGVs\nbG8=",
+		"BAD":     "s3cret",
+	}}
+	if n, err := cfg.Int("PORT"); err != nil || n != 5432 {
+		t.Errorf("Int() = %d, %v", n, err)
+	}
+	if b, err := cfg.Bool("DEBUG"); err != nil || !b {
+		t.Errorf("Bool() = %v, %v", b, err)
+	}
+	if d, err := cfg.Duration("TIMEOUT"); err != nil || d != 3*time.Second {
+		t.Errorf("Duration() = %v, %v", d, err)
+	}
+	if data, err := cfg.Bytes("CERT"); err != nil || string(data) != "hello" {
+		t.Errorf("Bytes() = %q, %v", data, err)
+	}
+	if _, err := cfg.String("MISSING"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("String() of a missing key error = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := cfg.Int("BAD"); err == nil || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("Int() of a bad value error = %v, want one without the value", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := (&Config{values: map[string]string{"DB_PASSWORD": "hunter2"}}).Handler("token")
+	tests := []struct {
+		token, key string
+		want       int
+	}{
+		{"token", "DB_PASSWORD", http.StatusOK},
+		{"token", "MISSING", http.StatusNotFound},
+		{"wrong", "DB_PASSWORD", http.StatusUnauthorized},
+		{"", "DB_PASSWORD", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/config?key="+tt.key, nil)
+		req.Header.Set(TokenHeader, tt.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("token %q, key %s: status = %d, want %d", tt.token, tt.key, rec.Code, tt.want)
+		}
+	}
+}
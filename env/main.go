@@ -1,243 +1,337 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"log"
+	"iter"
+	"log/slog"
 	"os"
-	"os/exec"
-	"sort"
+	"slices"
 	"strings"
-
-	"github.com/joho/godotenv"
+	"sync"
+	"time"
 )
 
-type EnvConfig struct {
-	DBHost           string
-	DBPort           string
-	DBName           string
-	DBUser           string
-	DBPassword       string
-	DBMaxConnections string
+// envState is what EnvConfig keeps besides its generated fields (see
+// envconfig_gen.go).
+type envState struct {
+	values     map[string]string
+	keys       []string
+	duplicates []DuplicateKey
+	stale      bool
+	schema     *SchemaReport
+	provenance Provenance
+
+	// Provenance for Snapshot.
+	file     string
+	source   string
+	lines    map[string]int
+	renamed  map[string]string
+	loadedAt time.Time
+
+	// recorder is the decryptor, when it wants to see the keys read.
+	recorder KeyRecorder
+}
 
-	RedisURL      string
-	RedisPassword string
+// Stale reports that the config is the last good one, served because the
+// decryption backend is failing (see WithGuard).
+func (c *EnvConfig) Stale() bool {
+	return c.stale
+}
 
-	JWTSecret       string
-	APIKey          string
-	StripeSecretKey string
-	SendGridAPIKey  string
+// Duplicates lists the keys the file assigns more than once. Which value
+// was kept depends on WithDuplicateKeys.
+func (c *EnvConfig) Duplicates() []DuplicateKey {
+	return c.duplicates
+}
 
-	GoogleClientID     string
-	GoogleClientSecret string
-	GitHubClientID     string
-	GitHubClientSecret string
+// Keys returns the keys of the file in the order they appear in it. Keys
+// added by WithDeprecations follow, sorted.
+func (c *EnvConfig) Keys() []string {
+	return slices.Clone(c.keys)
+}
 
-	WebhookURL             string
-	NotificationServiceURL string
+// All yields the key/value pairs in file order, like Keys. Reading a
+// secret this way is audited, as with Get.
+func (c *EnvConfig) All() iter.Seq2[string, string] {
+	return c.pairs(c.keys)
+}
 
-	Environment string
-	Debug       string
-	LogLevel    string
+// Sorted yields the key/value pairs sorted by key, for output that must
+// not change unless the values do.
+func (c *EnvConfig) Sorted() iter.Seq2[string, string] {
+	return c.pairs(slices.Sorted(slices.Values(c.keys)))
+}
 
-	EncryptionKey string
-	SigningKey    string
+func (c *EnvConfig) pairs(keys []string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, key := range keys {
+			value := c.values[key]
+			c.recordKey(key)
+			if DefaultMaskPolicy.IsSecretValue(key, value) {
+				auditAccess(key, c.provenance.owner(key), 1)
+			}
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
 }
 
-func LoadSOPSEnv(filename string) (*EnvConfig, error) {
+// orderedKeys keeps the file order of keys still in envMap and appends the
+// ones a loader added, sorted.
+func orderedKeys(keys []string, envMap map[string]string) []string {
+	ordered := make([]string, 0, len(envMap))
+	seen := make(map[string]bool, len(envMap))
+	for _, key := range keys {
+		if _, ok := envMap[key]; ok && !seen[key] {
+			ordered = append(ordered, key)
+			seen[key] = true
+		}
+	}
+	extra := len(ordered)
+	for key := range envMap {
+		if !seen[key] {
+			ordered = append(ordered, key)
+		}
+	}
+	slices.Sort(ordered[extra:])
+	return ordered
+}
+
+// decodedEnv is a decrypted and parsed file.
+type decodedEnv struct {
+	values     map[string]string
+	keys       []string       // in file order
+	lines      map[string]int // where each value was assigned
+	duplicates []DuplicateKey
+	source     string // the file read, a plaintext one under WithDevFallback
+	stale      bool   // a copy served by the guard
+}
 
-	cmd := exec.Command("sops", "-d", filename)
-	decryptedData, err := cmd.Output()
+func readSOPSEnvMap(ctx context.Context, filename string, options *loadOptions) (map[string]string, error) {
+	file, err := readSOPSEnvOrdered(ctx, filename, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt env file: %w", err)
+		return nil, err
 	}
+	return file.values, nil
+}
 
-	tmpFile, err := os.CreateTemp("", "decrypted-*.env")
+// readSOPSEnvOrdered decrypts and parses filename, keeping the keys in
+// file order.
+func readSOPSEnvOrdered(ctx context.Context, filename string, options *loadOptions) (*decodedEnv, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	source := filename
+	decryptedData, stale, err := decryptGuarded(ctx, filename, options, buf)
+	if err != nil && options.devFallback {
+		decryptedData, source, err = readDevFallback(filename, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, err
 	}
-	defer os.Remove(tmpFile.Name())
 
-	if _, err := tmpFile.Write(decryptedData); err != nil {
-		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	// parseDotenv copies the data into one string that all values share,
+	// so buf can be wiped and reused once it returns.
+	entries, err := options.dotenv.parse(decryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse env file: %w", err)
 	}
-	tmpFile.Close()
-
-	envMap, err := godotenv.Read(tmpFile.Name())
+	file, err := options.dotenv.collect(entries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse env file: %w", err)
 	}
-
-	config := &EnvConfig{
-		DBHost:                 envMap["DB_HOST"],
-		DBPort:                 envMap["DB_PORT"],
-		DBName:                 envMap["DB_NAME"],
-		DBUser:                 envMap["DB_USER"],
-		DBPassword:             envMap["DB_PASSWORD"],
-		DBMaxConnections:       envMap["DB_MAX_CONNECTIONS"],
-		RedisURL:               envMap["REDIS_URL"],
-		RedisPassword:          envMap["REDIS_PASSWORD"],
-		JWTSecret:              envMap["JWT_SECRET"],
-		APIKey:                 envMap["API_KEY"],
-		StripeSecretKey:        envMap["STRIPE_SECRET_KEY"],
-		SendGridAPIKey:         envMap["SENDGRID_API_KEY"],
-		GoogleClientID:         envMap["GOOGLE_CLIENT_ID"],
-		GoogleClientSecret:     envMap["GOOGLE_CLIENT_SECRET"],
-		GitHubClientID:         envMap["GITHUB_CLIENT_ID"],
-		GitHubClientSecret:     envMap["GITHUB_CLIENT_SECRET"],
-		WebhookURL:             envMap["WEBHOOK_URL"],
-		NotificationServiceURL: envMap["NOTIFICATION_SERVICE_URL"],
-		Environment:            envMap["ENVIRONMENT"],
-		Debug:                  envMap["DEBUG"],
-		LogLevel:               envMap["LOG_LEVEL"],
-		EncryptionKey:          envMap["ENCRYPTION_KEY"],
-		SigningKey:             envMap["SIGNING_KEY"],
+	if len(file.duplicates) > 0 {
+		slog.Warn("keys assigned more than once", "file", filename, "duplicates", formatDuplicates(file.duplicates))
 	}
+	DefaultRedactor.AddEnv(file.values)
 
-	return config, nil
+	file.source, file.stale = source, stale
+	traceResolved(ctx, filename, file, options)
+	return file, nil
 }
 
-func LoadSOPSEnvToSystem(filename string) error {
+func LoadSOPSEnv(filename string, opts ...Option) (*EnvConfig, error) {
+	return LoadSOPSEnvContext(context.Background(), filename, opts...)
+}
 
-	cmd := exec.Command("sops", "-d", filename)
-	decryptedData, err := cmd.Output()
+func LoadSOPSEnvContext(ctx context.Context, filename string, opts ...Option) (*EnvConfig, error) {
+	options := newLoadOptions(opts)
+
+	file, err := readSOPSEnvOrdered(ctx, filename, options)
+	observeDecrypt(filename, file, err, options)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt env file: %w", err)
+		return nil, err
 	}
-
-	scanner := bufio.NewScanner(strings.NewReader(string(decryptedData)))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
+	envMap := file.values
+	original := keySet(envMap)
+	if err := applyDeprecations(filename, envMap, options.deprecations); err != nil {
+		return nil, err
+	}
+	if err := applyExpiry(filename, envMap, options); err != nil {
+		return nil, err
+	}
+	if err := applyPolicy(ctx, filename, envMap, options); err != nil {
+		return nil, err
+	}
+	if err := applyRules(filename, envMap, options); err != nil {
+		return nil, err
+	}
+	if options.dryRun != nil {
+		fillDryRunReport(filename, original, envMap, options)
+		return &EnvConfig{}, nil
+	}
+	registerCanaries(envMap, options.canaries)
+	traceMapped(ctx, filename, envMap, options)
+	schema := applySchema(filename, envMap, options)
+
+	config := &EnvConfig{envState: envState{
+		values:     envMap,
+		keys:       orderedKeys(file.keys, envMap),
+		duplicates: file.duplicates,
+		stale:      file.stale,
+		schema:     schema,
+		provenance: loadProvenanceFor(filename),
+		file:       filename,
+		source:     file.source,
+		lines:      file.lines,
+		renamed:    renamedKeys(original, options.deprecations),
+		loadedAt:   time.Now(),
+		recorder:   keyRecorder(options),
+	}}
+	config.setFields(envMap)
+	if options.validation {
+		if err := validateEnvConfig(config); err != nil {
+			return nil, err
 		}
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+	return config, nil
+}
 
-		if err := os.Setenv(key, value); err != nil {
-			return fmt.Errorf("failed to set env var %s: %w", key, err)
-		}
+// observeDecrypt reports a decryption to the WithAlerter alerter. Serving
+// the last good config counts as a failure, since the backend is down.
+func observeDecrypt(filename string, file *decodedEnv, err error, options *loadOptions) {
+	if options.alerter == nil {
+		return
+	}
+	if err == nil && file.stale {
+		options.alerter.Observe(filename, ErrCircuitOpen)
+	} else {
+		options.alerter.Observe(filename, err)
 	}
-
-	return scanner.Err()
 }
 
-func PrintConfig(config *EnvConfig) {
-	fmt.Println("🔓 Successfully loaded and decrypted environment configuration:")
-	fmt.Println("================================================================")
-
-	fmt.Println("\n📊 Database Configuration:")
-	fmt.Printf("  DB_HOST: %s\n", config.DBHost)
-	fmt.Printf("  DB_PORT: %s\n", config.DBPort)
-	fmt.Printf("  DB_NAME: %s\n", config.DBName)
-	fmt.Printf("  DB_USER: %s\n", config.DBUser)
-	fmt.Printf("  DB_PASSWORD: %s\n", maskSecret(config.DBPassword))
-	fmt.Printf("  DB_MAX_CONNECTIONS: %s\n", config.DBMaxConnections)
-
-	fmt.Println("\n🔴 Redis Configuration:")
-	fmt.Printf("  REDIS_URL: %s\n", maskSecret(config.RedisURL))
-	fmt.Printf("  REDIS_PASSWORD: %s\n", maskSecret(config.RedisPassword))
-
-	fmt.Println("\n🔐 API Keys & Secrets:")
-	fmt.Printf("  JWT_SECRET: %s\n", maskSecret(config.JWTSecret))
-	fmt.Printf("  API_KEY: %s\n", maskSecret(config.APIKey))
-	fmt.Printf("  STRIPE_SECRET_KEY: %s\n", maskSecret(config.StripeSecretKey))
-	fmt.Printf("  SENDGRID_API_KEY: %s\n", maskSecret(config.SendGridAPIKey))
-
-	fmt.Println("\n🔑 OAuth Credentials:")
-	fmt.Printf("  GOOGLE_CLIENT_ID: %s\n", config.GoogleClientID)
-	fmt.Printf("  GOOGLE_CLIENT_SECRET: %s\n", maskSecret(config.GoogleClientSecret))
-	fmt.Printf("  GITHUB_CLIENT_ID: %s\n", config.GitHubClientID)
-	fmt.Printf("  GITHUB_CLIENT_SECRET: %s\n", maskSecret(config.GitHubClientSecret))
-
-	fmt.Println("\n🌐 External Services:")
-	fmt.Printf("  WEBHOOK_URL: %s\n", config.WebhookURL)
-	fmt.Printf("  NOTIFICATION_SERVICE_URL: %s\n", config.NotificationServiceURL)
-
-	fmt.Println("\n⚙️ Environment Settings:")
-	fmt.Printf("  ENVIRONMENT: %s\n", config.Environment)
-	fmt.Printf("  DEBUG: %s\n", config.Debug)
-	fmt.Printf("  LOG_LEVEL: %s\n", config.LogLevel)
-
-	fmt.Println("\n🔒 Encryption Keys:")
-	fmt.Printf("  ENCRYPTION_KEY: %s\n", maskSecret(config.EncryptionKey))
-	fmt.Printf("  SIGNING_KEY: %s\n", maskSecret(config.SigningKey))
+// systemEnvMu makes each LoadSOPSEnvToSystem call apply all of its keys
+// before another starts, so concurrent loads never leave the environment
+// with a mix of two files. Readers that need a consistent view, like
+// DriftReport, hold it for reading.
+var systemEnvMu sync.RWMutex
+
+func LoadSOPSEnvToSystem(filename string, opts ...Option) error {
+	return LoadSOPSEnvToSystemContext(context.Background(), filename, opts...)
 }
 
-func PrintSystemEnvVars() {
-	fmt.Println("\n🌍 Environment Variables (loaded into system):")
-	fmt.Println("================================================")
-
-	envVars := os.Environ()
-	sort.Strings(envVars)
-
-	ourVars := []string{
-		"DB_HOST", "DB_PORT", "DB_NAME", "DB_USER", "DB_PASSWORD", "DB_MAX_CONNECTIONS",
-		"REDIS_URL", "REDIS_PASSWORD",
-		"JWT_SECRET", "API_KEY", "STRIPE_SECRET_KEY", "SENDGRID_API_KEY",
-		"GOOGLE_CLIENT_ID", "GOOGLE_CLIENT_SECRET", "GITHUB_CLIENT_ID", "GITHUB_CLIENT_SECRET",
-		"WEBHOOK_URL", "NOTIFICATION_SERVICE_URL",
-		"ENVIRONMENT", "DEBUG", "LOG_LEVEL",
-		"ENCRYPTION_KEY", "SIGNING_KEY",
+func LoadSOPSEnvToSystemContext(ctx context.Context, filename string, opts ...Option) error {
+	options := newLoadOptions(opts)
+
+	file, err := readSOPSEnvOrdered(ctx, filename, options)
+	observeDecrypt(filename, file, err, options)
+	if err != nil {
+		return err
+	}
+	envMap, keys := file.values, file.keys
+	if file.stale {
+		slog.Warn("decryption backend unavailable, using last good config", "file", filename)
 	}
 
-	for _, varName := range ourVars {
-		if value := os.Getenv(varName); value != "" {
-			if isSecret(varName) {
-				fmt.Printf("  %s=%s\n", varName, maskSecret(value))
-			} else {
-				fmt.Printf("  %s=%s\n", varName, value)
-			}
+	original := keySet(envMap)
+	if err := applyDeprecations(filename, envMap, options.deprecations); err != nil {
+		return err
+	}
+	if err := applyExpiry(filename, envMap, options); err != nil {
+		return err
+	}
+	if err := applyPolicy(ctx, filename, envMap, options); err != nil {
+		return err
+	}
+	if err := applyRules(filename, envMap, options); err != nil {
+		return err
+	}
+	if options.dryRun != nil {
+		fillDryRunReport(filename, original, envMap, options)
+		return nil
+	}
+	registerCanaries(envMap, options.canaries)
+	applySchema(filename, envMap, options)
+	if options.validation {
+		// Validate before anything reaches the environment, as the
+		// EnvConfig that LoadSOPSEnv would return.
+		config := &EnvConfig{envState: envState{file: filename}}
+		config.setFields(envMap)
+		if err := validateEnvConfig(config); err != nil {
+			return err
 		}
 	}
-}
 
-func maskSecret(value string) string {
-	if len(value) <= 4 {
-		return strings.Repeat("*", len(value))
+	keys = orderedKeys(keys, envMap)
+
+	systemEnvMu.Lock()
+	defer systemEnvMu.Unlock()
+
+	var skipped []string
+	for _, key := range keys {
+		value, ok := envMap[key]
+		if !ok {
+			continue
+		}
+		if !options.systemKeyAllowed(key) {
+			skipped = append(skipped, key)
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set env var %s: %w", key, err)
+		}
+		rememberSystemKey(key)
 	}
-	return value[:2] + strings.Repeat("*", len(value)-4) + value[len(value)-2:]
+	warnSkippedKeys(filename, skipped)
+
+	return nil
 }
 
-func isSecret(varName string) bool {
-	secretVars := []string{
-		"PASSWORD", "SECRET", "KEY", "TOKEN", "CREDENTIAL", "PRIVATE",
+func main() {
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		exitOnError(err)
 	}
-
-	upper := strings.ToUpper(varName)
-	for _, secret := range secretVars {
-		if strings.Contains(upper, secret) {
-			return true
+	if len(args) > 0 {
+		if err := runCommand(args[0], args[1:]); err != nil {
+			exitOnError(err)
 		}
+		return
 	}
-	return false
+
+	runDemo()
 }
 
-func main() {
+func runDemo() {
 	fmt.Println("🔐 SOPS Environment Variable Manager")
 	fmt.Println("=====================================")
 
 	fmt.Println("\n📋 Method 1: Loading into structured configuration")
-	config, err := LoadSOPSEnv("config.sops.env")
-	if err != nil {
-		log.Fatalf("Error loading SOPS env config: %v", err)
-	}
-	PrintConfig(config)
+	config := Bootstrap("config.sops.env")
+	PrintConfig(config, DefaultMaskPolicy)
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 
 	fmt.Println("\n📋 Method 2: Loading into system environment variables")
 	if err := LoadSOPSEnvToSystem("config.sops.env"); err != nil {
-		log.Fatalf("Error loading SOPS env to system: %v", err)
+		writeStartupError(os.Stderr, "config.sops.env", err)
+		os.Exit(1)
 	}
-	PrintSystemEnvVars()
+	fmt.Println()
+	PrintSystemEnvVars(DefaultMaskPolicy)
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("🚀 Example Usage:")
@@ -245,7 +339,7 @@ func main() {
 	fmt.Println("\n1️⃣ Using Structured Config:")
 	fmt.Printf("   Database DSN: postgresql://%s:%s@%s:%s/%s\n",
 		config.DBUser,
-		maskSecret(config.DBPassword),
+		DefaultMaskPolicy.Mask(config.DBPassword),
 		config.DBHost,
 		config.DBPort,
 		config.DBName)
@@ -259,11 +353,16 @@ func main() {
 
 	fmt.Printf("   Database DSN: postgresql://%s:%s@%s:%s/%s\n",
 		dbUser,
-		maskSecret(dbPassword),
+		DefaultMaskPolicy.Mask(dbPassword),
 		dbHost,
 		dbPort,
 		dbName)
 
+	fmt.Println("\n3️⃣ Logging with automatic secret redaction:")
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(os.Stdout, nil), DefaultRedactor))
+	logger.Info("connecting to database", "user", config.DBUser, "password", config.DBPassword)
+	logger.Info("loaded config", "config", config)
+
 	fmt.Println("\n✅ SOPS environment variable integration complete!")
 	fmt.Println("Your environment secrets are now loaded and ready to use! 🎉")
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestLoadSOPSEnvWithFake(t *testing.T) {
+	t.Parallel()
+	fake := sopstest.NewFake()
+	fake.SetStruct("config.sops.env", EnvConfig{
+		DBHost:     "db.internal",
+		DBPassword: `p@ss "word" $HOME`,
+		Debug:      "true",
+	})
+
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake))
+	if err != nil {
+		t.Fatalf("LoadSOPSEnv() error = %v", err)
+	}
+	if config.DBHost != "db.internal" {
+		t.Errorf("DBHost = %q, want db.internal", config.DBHost)
+	}
+	if got := config.Get("DB_PASSWORD"); got != `p@ss "word" $HOME` {
+		t.Errorf(`Get("DB_PASSWORD") = %q`, got)
+	}
+	if n := fake.Loads("config.sops.env"); n != 1 {
+		t.Errorf("Loads() = %d, want 1", n)
+	}
+	if _, ok := config.Lookup("DB_HOST"); !ok {
+		t.Error(`Lookup("DB_HOST") found nothing`)
+	}
+	// Keys that aren't secrets are recorded too, without an audit sink.
+	fake.AssertRequested(t, "DB_PASSWORD", "DB_HOST")
+	fake.AssertNotRequested(t, "JWT_SECRET", "DEBUG")
+
+	for range config.All() {
+	}
+	fake.AssertRequested(t, "DEBUG")
+}
+
+func TestLoadSOPSEnvWithFakeError(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetError("config.sops.env", &DecryptError{File: "config.sops.env", Kind: ErrNoMatchingKeys})
+
+	if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake)); !errors.Is(err, ErrNoMatchingKeys) {
+		t.Errorf("LoadSOPSEnv() error = %v, want ErrNoMatchingKeys", err)
+	}
+	if _, err := LoadSOPSEnv("missing.sops.env", WithDecryptor(fake)); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("LoadSOPSEnv() error = %v, want ErrFileNotFound", err)
+	}
+}
+
+// installFakeSOPSBinary puts a sops on PATH that prints the file it is
+// given, so the exec path runs without keys.
+func installFakeSOPSBinary(tb testing.TB) {
+	installSOPSScript(tb, "for last; do :; done\nexec cat \"$last\"\n")
+}
+
+// installSOPSScript puts a sops on PATH that runs the shell script body.
+func installSOPSScript(tb testing.TB, body string) {
+	tb.Helper()
+	if runtime.GOOS == "windows" {
+		tb.Skip("the fake sops is a shell script")
+	}
+	dir := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sops"), []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// withStdin makes os.Stdin read content until the test ends.
+func withStdin(t *testing.T, content string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdin
+	os.Stdin = in
+	t.Cleanup(func() {
+		os.Stdin = previous
+		in.Close()
+	})
+}
+
+func BenchmarkLoadSOPSEnv(b *testing.B) {
+	installFakeSOPSBinary(b)
+	filename := filepath.Join(b.TempDir(), "config.sops.env")
+	if err := os.WriteFile(filename, benchmarkEnvFile(100), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := LoadSOPSEnv(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadSOPSEnvDecryptor(b *testing.B) {
+	fake := sopstest.NewFake()
+	entries, err := parseDotenv(benchmarkEnvFile(100))
+	if err != nil {
+		b.Fatal(err)
+	}
+	values, _ := dotenvMap(entries)
+	fake.SetFile("config.sops.env", values)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares the keys of a config file: their Go names, types,
+// defaults and rules. `go-sops gen` turns it into typed accessors, so the
+// struct, the variable names and the validation can't drift apart.
+//
+//	type: AppConfig
+//	file: config.sops.env
+//	keys:
+//	  - name: DB_PORT
+//	    type: int
+//	    default: "5432"
+//	  - name: JWT_SECRET
+//	    secret: true
+//	    required: true
+type Manifest struct {
+	Type string        `yaml:"type"`
+	File string        `yaml:"file"`
+	Keys []ManifestKey `yaml:"keys"`
+}
+
+// ManifestKey is one variable. Field defaults to the name in Go style,
+// DB_PORT becoming DBPort, and Type to string. A secret string is read as
+// Secret.
+type ManifestKey struct {
+	Name     string   `yaml:"name"`
+	Field    string   `yaml:"field"`
+	Type     string   `yaml:"type"`
+	Secret   bool     `yaml:"secret"`
+	Default  string   `yaml:"default"`
+	Required bool     `yaml:"required"`
+	Enum     []string `yaml:"enum"`
+	Doc      string   `yaml:"doc"`
+	// Validate is the key's validate tag on the generated EnvConfig, such
+	// as "url" or "port" (see validators.go).
+	Validate string `yaml:"validate"`
+	// Group starts a section of PrintConfig, like "🔴 Redis Configuration".
+	// The keys after it belong to it until the next group.
+	Group string `yaml:"group"`
+}
+
+// manifestTypes maps manifest types to the Go type of their accessor.
+var manifestTypes = map[string]string{
+	"string":   "string",
+	"int":      "int",
+	"bool":     "bool",
+	"float":    "float64",
+	"duration": "time.Duration",
+	"url":      "*url.URL",
+	"base64":   "[]byte",
+}
+
+// goInitialisms are spelled in capitals when a key becomes a field name.
+var goInitialisms = map[string]bool{
+	"API": true, "AWS": true, "CA": true, "DB": true, "DNS": true, "DSN": true,
+	"GCP": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"JWT": true, "KMS": true, "SMTP": true, "SQL": true, "SSH": true, "TLS": true,
+	"TTL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// LoadManifest reads and checks a manifest, filling in default fields and
+// types.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if m.Type == "" {
+		m.Type = "AppConfig"
+	}
+	if m.File == "" {
+		m.File = "config.sops.env"
+	}
+	if err := m.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *Manifest) check() error {
+	if !isGoIdentifier(m.Type) || !unicode.IsUpper(rune(m.Type[0])) {
+		return fmt.Errorf("type %q is not an exported Go identifier", m.Type)
+	}
+	if len(m.Keys) == 0 {
+		return errors.New("no keys")
+	}
+
+	names := make(map[string]bool)
+	fields := make(map[string]bool)
+	var errs []error
+	for i := range m.Keys {
+		k := &m.Keys[i]
+		if k.Field == "" {
+			k.Field = fieldName(k.Name)
+		}
+		if k.Type == "" {
+			k.Type = "string"
+		}
+
+		switch {
+		case !validEnvKey(k.Name):
+			errs = append(errs, fmt.Errorf("keys[%d]: invalid name %q", i, k.Name))
+			continue
+		case names[k.Name]:
+			errs = append(errs, fmt.Errorf("%s: listed twice", k.Name))
+		case !isGoIdentifier(k.Field) || !unicode.IsUpper(rune(k.Field[0])):
+			errs = append(errs, fmt.Errorf("%s: field %q is not an exported Go identifier", k.Name, k.Field))
+		case k.Field == "Env":
+			errs = append(errs, fmt.Errorf("%s: field Env is reserved, set another field", k.Name))
+		case fields[k.Field]:
+			errs = append(errs, fmt.Errorf("%s: field %s is used by another key", k.Name, k.Field))
+		case manifestTypes[k.Type] == "":
+			errs = append(errs, fmt.Errorf("%s: unknown type %q, expected one of %s", k.Name, k.Type, strings.Join(slices.Sorted(maps.Keys(manifestTypes)), ", ")))
+		case len(k.Enum) > 0 && k.Type != "string":
+			errs = append(errs, fmt.Errorf("%s: enum only applies to strings", k.Name))
+		case k.Default != "":
+			if err := checkManifestValue(*k, k.Default); err != nil {
+				errs = append(errs, fmt.Errorf("%s: default: %w", k.Name, err))
+			}
+		}
+		names[k.Name] = true
+		fields[k.Field] = true
+	}
+	for _, k := range m.Keys {
+		if err := checkManifestValidate(k.Validate, fields); err != nil {
+			errs = append(errs, fmt.Errorf("%s: validate: %w", k.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkManifestValidate checks that a validate tag only uses known rules,
+// and that cross-field rules name fields of the manifest.
+func checkManifestValidate(tag string, fields map[string]bool) error {
+	if tag == "" {
+		return nil
+	}
+	if strings.ContainsAny(tag, "`\"") {
+		return errors.New("must not contain quotes")
+	}
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if _, ok := validators[name]; ok {
+			continue
+		}
+		switch name {
+		case "required_with", "required_without", "excluded_with", "xor":
+		default:
+			return fmt.Errorf("unknown rule %q", name)
+		}
+		if param == "" {
+			return fmt.Errorf("%s needs field names", name)
+		}
+		for _, field := range strings.Fields(param) {
+			if !fields[field] {
+				return fmt.Errorf("%s names unknown field %s", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// GoType is the Go type of k's accessor.
+func (k ManifestKey) GoType() string {
+	if k.Type == "string" && k.Secret {
+		return "Secret"
+	}
+	return manifestTypes[k.Type]
+}
+
+// checkManifestValue reports whether value is valid for k. The error never
+// includes the value, which may be a secret.
+func checkManifestValue(k ManifestKey, value string) error {
+	var err error
+	switch k.Type {
+	case "int":
+		_, err = strconv.Atoi(value)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "float":
+		_, err = strconv.ParseFloat(value, 64)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "url":
+		var u *url.URL
+		if u, err = url.Parse(value); err == nil && (u.Scheme == "" || u.Host == "") {
+			err = errors.New("no scheme or host")
+		}
+	case "base64":
+		_, err = decodeBase64(k.Name, value)
+	case "string":
+		if len(k.Enum) > 0 && !slices.Contains(k.Enum, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(k.Enum, ", "))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("not a valid %s", k.Type)
+	}
+	return nil
+}
+
+// fieldName turns DB_MAX_CONNECTIONS into DBMaxConnections.
+func fieldName(key string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(key, func(r rune) bool { return r == '_' || r == '.' || r == '-' }) {
+		upper := strings.ToUpper(part)
+		if goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(upper[:1] + strings.ToLower(part[1:]))
+	}
+	return b.String()
+}
+
+func isGoIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// manifestReader reads typed values for generated code. It collects every
+// problem instead of stopping at the first, so one load reports them all.
+type manifestReader struct {
+	env  *EnvConfig
+	errs []error
+}
+
+func (r *manifestReader) lookup(key, def string, required bool) (string, bool) {
+	value, ok := r.env.values[key]
+	if !ok || value == "" {
+		if required {
+			r.errs = append(r.errs, fmt.Errorf("%s: %w", key, ErrKeyNotFound))
+		}
+		return def, def != ""
+	}
+	return value, true
+}
+
+func (r *manifestReader) fail(key, kind string) {
+	r.errs = append(r.errs, invalidValue(key, kind))
+}
+
+func (r *manifestReader) String(key, def string, required bool, enum ...string) string {
+	value, _ := r.lookup(key, def, required)
+	if len(enum) > 0 && value != "" && !slices.Contains(enum, value) {
+		r.errs = append(r.errs, fmt.Errorf("%s must be one of %s", key, strings.Join(enum, ", ")))
+	}
+	return value
+}
+
+func (r *manifestReader) Secret(key, def string, required bool) Secret {
+	value, _ := r.lookup(key, def, required)
+	return Secret(value)
+}
+
+func (r *manifestReader) Int(key, def string, required bool) int {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.fail(key, "integer")
+	}
+	return n
+}
+
+func (r *manifestReader) Bool(key, def string, required bool) bool {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.fail(key, "boolean")
+	}
+	return b
+}
+
+func (r *manifestReader) Float(key, def string, required bool) float64 {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.fail(key, "number")
+	}
+	return f
+}
+
+func (r *manifestReader) Duration(key, def string, required bool) time.Duration {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		r.fail(key, "duration")
+	}
+	return d
+}
+
+func (r *manifestReader) URL(key, def string, required bool) *url.URL {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		r.fail(key, "URL")
+		return nil
+	}
+	return u
+}
+
+func (r *manifestReader) Bytes(key, def string, required bool) []byte {
+	value, ok := r.lookup(key, def, required)
+	if !ok {
+		return nil
+	}
+	data, err := decodeBase64(key, value)
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+	return data
+}
+
+func (r *manifestReader) err() error {
+	return errors.Join(r.errs...)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefaultMaskPolicy(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{"DB_HOST", "db.internal", "db.internal"},
+		{"DB_PASSWORD", "hunter22", "hu****22"},
+		{"API_KEY", "abc", "***"},
+		// Deny marks keys secret that no pattern matches.
+		{"REDIS_URL", "redis://:pw@cache", "re*************he"},
+		// Known prefixes mark values secret under any name.
+		{"WEBHOOK", "ghp_0123456789", "gh**********89"},
+	}
+	for _, tt := range tests {
+		if got := DefaultMaskPolicy.MaskValue(tt.key, tt.value); got != tt.want {
+			t.Errorf("MaskValue(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFprintConfigUsesPolicy(t *testing.T) {
+	config := &EnvConfig{DBHost: "db.internal", DBPassword: "hunter22", JWTSecret: "jwt-signing-secret"}
+	policy := &MaskPolicy{
+		Patterns: []string{"PASSWORD", "SECRET"},
+		Allow:    []string{"JWT_SECRET"},
+		Deny:     []string{"DB_HOST"},
+		FullMask: true,
+	}
+
+	var out bytes.Buffer
+	FprintConfig(&out, QuietPrinter, config, policy)
+	for _, want := range []string{"DB_HOST=***********", "DB_PASSWORD=********", "JWT_SECRET=jwt-signing-secret"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var sopsBackends = []string{"pgp", "age", "kms", "gcp_kms", "azure_kv", "hc_vault"}
+
+// sopsMetadata is the part of the sops block that can be read without
+// decrypting anything.
+type sopsMetadata struct {
+	LastModified string
+	MAC          string
+	Version      string
+	Backends     []string
+	KeyGroups    int
+	// Ciphers lists the data ciphers named in the file's ENC[...] values.
+	Ciphers []string
+	// Recipients holds, per backend, what each key entry names: the age
+	// recipient, PGP fingerprint, KMS ARN or resource ID, or vault URL.
+	Recipients map[string][]string
+}
+
+// recipientFields is the metadata field that identifies a key, per backend.
+var recipientFields = map[string]string{
+	"pgp":      "fp",
+	"age":      "recipient",
+	"kms":      "arn",
+	"gcp_kms":  "resource_id",
+	"azure_kv": "vault_url",
+	"hc_vault": "vault_address",
+}
+
+var encCipherPattern = regexp.MustCompile(`ENC\[([A-Za-z0-9_]+),`)
+
+func readSOPSMetadata(filename string) (*sopsMetadata, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return parseSOPSMetadata(filename, data)
+}
+
+// parseSOPSMetadata reads the metadata of data, in the format filename's
+// extension names.
+func parseSOPSMetadata(filename string, data []byte) (*sopsMetadata, error) {
+	var meta *sopsMetadata
+	var err error
+	switch filepath.Ext(filename) {
+	case ".env", ".dotenv":
+		meta, err = parseEnvMetadata(data)
+	default:
+		meta, err = parseTreeMetadata(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ciphers := map[string]bool{}
+	for _, match := range encCipherPattern.FindAllSubmatch(data, -1) {
+		ciphers[string(match[1])] = true
+	}
+	meta.Ciphers = sortedKeys(ciphers)
+	return meta, nil
+}
+
+func parseEnvMetadata(data []byte) (*sopsMetadata, error) {
+	meta := &sopsMetadata{Recipients: map[string][]string{}}
+	backends := map[string]bool{}
+	groups := map[int]bool{}
+
+	for line := range bytes.Lines(data) {
+		key, value, ok := strings.Cut(strings.TrimRight(string(line), "\r\n"), "=")
+		if !ok || !strings.HasPrefix(key, "sops_") {
+			continue
+		}
+		key = strings.TrimPrefix(key, "sops_")
+
+		switch key {
+		case "lastmodified":
+			meta.LastModified = value
+		case "mac":
+			meta.MAC = value
+		case "version":
+			meta.Version = value
+		}
+
+		if rest, ok := strings.CutPrefix(key, "key_groups__list_"); ok {
+			index, rest, _ := strings.Cut(rest, "__map_")
+			if n, err := strconv.Atoi(index); err == nil {
+				groups[n] = true
+			}
+			key = rest
+		}
+		for _, backend := range sopsBackends {
+			if rest, ok := strings.CutPrefix(key, backend+"__list_"); ok {
+				backends[backend] = true
+				if _, field, _ := strings.Cut(rest, "__map_"); field == recipientFields[backend] {
+					meta.Recipients[backend] = append(meta.Recipients[backend], value)
+				}
+			}
+		}
+	}
+	meta.Backends = sortedKeys(backends)
+	meta.KeyGroups = len(groups)
+	if meta.KeyGroups == 0 && len(meta.Backends) > 0 {
+		meta.KeyGroups = 1
+	}
+	if meta.MAC == "" {
+		return nil, fmt.Errorf("no sops metadata found")
+	}
+	return meta, nil
+}
+
+func parseTreeMetadata(data []byte) (*sopsMetadata, error) {
+	var doc struct {
+		SOPS map[string]any `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sops metadata: %w", err)
+	}
+	if doc.SOPS == nil {
+		return nil, fmt.Errorf("no sops metadata found")
+	}
+
+	meta := &sopsMetadata{
+		LastModified: fmt.Sprint(doc.SOPS["lastmodified"]),
+		MAC:          fmt.Sprint(doc.SOPS["mac"]),
+		Version:      fmt.Sprint(doc.SOPS["version"]),
+		Recipients:   map[string][]string{},
+	}
+
+	backends := map[string]bool{}
+	addBackends := func(section map[string]any) {
+		for _, backend := range sopsBackends {
+			entries, ok := section[backend].([]any)
+			if !ok || len(entries) == 0 {
+				continue
+			}
+			backends[backend] = true
+			for _, entry := range entries {
+				if fields, ok := entry.(map[string]any); ok && fields[recipientFields[backend]] != nil {
+					meta.Recipients[backend] = append(meta.Recipients[backend], fmt.Sprint(fields[recipientFields[backend]]))
+				}
+			}
+		}
+	}
+
+	addBackends(doc.SOPS)
+	if groups, ok := doc.SOPS["key_groups"].([]any); ok && len(groups) > 0 {
+		meta.KeyGroups = len(groups)
+		for _, group := range groups {
+			if section, ok := group.(map[string]any); ok {
+				addBackends(section)
+			}
+		}
+	}
+
+	meta.Backends = sortedKeys(backends)
+	if meta.KeyGroups == 0 && len(meta.Backends) > 0 {
+		meta.KeyGroups = 1
+	}
+	return meta, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
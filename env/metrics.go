@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Metrics struct {
+	decryptDuration *prometheus.HistogramVec
+	decryptFailures *prometheus.CounterVec
+	reloads         *prometheus.CounterVec
+	startupDuration *prometheus.HistogramVec
+	configAge       *prometheus.Desc
+
+	mu          sync.Mutex
+	lastSuccess map[string]time.Time
+}
+
+// DefaultMetrics is updated by every loader; register it to export the
+// values: prometheus.MustRegister(DefaultMetrics).
+var DefaultMetrics = NewMetrics()
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		decryptDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sops_decrypt_duration_seconds",
+			Help:    "Time spent decrypting SOPS files.",
+			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"file"}),
+		decryptFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sops_decrypt_failures_total",
+			Help: "Number of failed SOPS decryptions.",
+		}, []string{"file"}),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sops_reload_total",
+			Help: "Number of config reloads triggered by file changes.",
+		}, []string{"file", "result"}),
+		startupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sops_startup_duration_seconds",
+			Help:    "Time LoadServerless took to produce a config, by source.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"file", "source"}),
+		configAge: prometheus.NewDesc(
+			"sops_config_age_seconds",
+			"Seconds since the file was last decrypted successfully.",
+			[]string{"file"}, nil,
+		),
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.decryptDuration.Describe(ch)
+	m.decryptFailures.Describe(ch)
+	m.reloads.Describe(ch)
+	m.startupDuration.Describe(ch)
+	ch <- m.configAge
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.decryptDuration.Collect(ch)
+	m.decryptFailures.Collect(ch)
+	m.reloads.Collect(ch)
+	m.startupDuration.Collect(ch)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for file, at := range m.lastSuccess {
+		ch <- prometheus.MustNewConstMetric(m.configAge, prometheus.GaugeValue, time.Since(at).Seconds(), file)
+	}
+}
+
+func (m *Metrics) observeDecrypt(file string, start time.Time, err error) {
+	m.decryptDuration.WithLabelValues(file).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.decryptFailures.WithLabelValues(file).Inc()
+		return
+	}
+
+	m.mu.Lock()
+	m.lastSuccess[file] = time.Now()
+	m.mu.Unlock()
+}
+
+func (m *Metrics) observeReload(file string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.reloads.WithLabelValues(file, result).Inc()
+}
+
+func (m *Metrics) observeStartup(file, source string, start time.Time) {
+	m.startupDuration.WithLabelValues(file, source).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"go-sops-env/sopstest"
+)
+
+func TestMetricsObserveLoads(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("metrics-ok.sops.env", map[string]string{"DB_HOST": "db"})
+	fake.SetError("metrics-bad.sops.env", errors.New("no key"))
+
+	for range 2 {
+		if _, err := LoadSOPSEnv("metrics-ok.sops.env", WithDecryptor(fake)); err != nil {
+			t.Fatalf("LoadSOPSEnv() error = %v", err)
+		}
+	}
+	if _, err := LoadSOPSEnv("metrics-bad.sops.env", WithDecryptor(fake)); err == nil {
+		t.Fatal("LoadSOPSEnv() succeeded with a failing decryptor")
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(DefaultMetrics)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	if got := sampleCount(families, "sops_decrypt_duration_seconds", "metrics-ok.sops.env"); got != 2 {
+		t.Errorf("decrypt duration samples = %v, want 2", got)
+	}
+	if got := sampleValue(families, "sops_decrypt_failures_total", "metrics-bad.sops.env"); got != 1 {
+		t.Errorf("decrypt failures = %v, want 1", got)
+	}
+	if got := sampleValue(families, "sops_decrypt_failures_total", "metrics-ok.sops.env"); got != 0 {
+		t.Errorf("failures for the good file = %v, want 0", got)
+	}
+	if metricFor(families, "sops_config_age_seconds", "metrics-ok.sops.env") == nil {
+		t.Error("no config age for the loaded file")
+	}
+	if metricFor(families, "sops_config_age_seconds", "metrics-bad.sops.env") != nil {
+		t.Error("config age reported for a file that never decrypted")
+	}
+}
+
+func metricFor(families []*dto.MetricFamily, name, file string) *dto.Metric {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "file" && label.GetValue() == file {
+					return metric
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func sampleCount(families []*dto.MetricFamily, name, file string) uint64 {
+	return metricFor(families, name, file).GetHistogram().GetSampleCount()
+}
+
+func sampleValue(families []*dto.MetricFamily, name, file string) float64 {
+	metric := metricFor(families, name, file)
+	if metric == nil {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
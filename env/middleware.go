@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type configContextKey struct{}
+
+// Middleware puts the store's current config into each request's context.
+// Reloads swap in a new EnvConfig rather than changing the old one, so a
+// request keeps seeing the same generation even if a reload lands while it
+// runs.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(ContextWithConfig(r.Context(), s.Config())))
+	})
+}
+
+func ContextWithConfig(ctx context.Context, config *EnvConfig) context.Context {
+	return context.WithValue(ctx, configContextKey{}, config)
+}
+
+// ConfigFromContext returns the config the middleware stored, or nil.
+func ConfigFromContext(ctx context.Context) *EnvConfig {
+	config, _ := ctx.Value(configContextKey{}).(*EnvConfig)
+	return config
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// GinConfigKey is the gin.Context key holding the request's config.
+const GinConfigKey = "go-sops.config"
+
+// GinMiddleware is Middleware for Gin. The config is available through
+// c.MustGet(GinConfigKey) and ConfigFromContext(c.Request.Context()).
+func (s *Store) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := s.Config()
+		c.Set(GinConfigKey, config)
+		c.Request = c.Request.WithContext(ContextWithConfig(c.Request.Context(), config))
+		c.Next()
+	}
+}
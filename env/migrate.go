@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// migrateFormats are the extensions sops can tell the format of.
+var migrateFormats = []string{".env", ".yaml", ".yml", ".json"}
+
+// runMigrate encrypts existing plaintext config files next to the
+// originals, keeps the originals out of git and shows how to load the
+// encrypted copies.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	ageRecipients := fs.String("recipients", "", "comma-separated age recipients, defaults to the .sops.yaml creation rules")
+	pgp := fs.String("pgp", "", "comma-separated PGP fingerprints to encrypt for")
+	force := fs.Bool("force", false, "overwrite existing encrypted files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("pass the plaintext files to migrate, e.g. go-sops migrate .env")
+	}
+
+	sopsFlags, err := recipientFlags(*ageRecipients, *pgp)
+	if err != nil {
+		return err
+	}
+
+	var migrated [][2]string
+	for _, file := range fs.Args() {
+		if err := checkRecipients(file, sopsFlags); err != nil {
+			return err
+		}
+		target, err := migrateFile(file, sopsFlags, *force)
+		if err != nil {
+			return err
+		}
+		migrated = append(migrated, [2]string{file, target})
+	}
+
+	fmt.Println("\n📝 Load the encrypted files instead of the plaintext ones:")
+	for _, m := range migrated {
+		printMigrationHint(m[0], m[1])
+	}
+	return nil
+}
+
+func migrateFile(file string, sopsFlags []string, force bool) (string, error) {
+	target, err := migratedName(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(file); err != nil {
+		return "", err
+	}
+	if _, err := readSOPSMetadata(file); err == nil {
+		return "", fmt.Errorf("%s is already encrypted", file)
+	}
+	if _, err := os.Stat(target); err == nil && !force {
+		return "", fmt.Errorf("%s already exists, use -force to overwrite", target)
+	}
+
+	if err := encryptToFile(target, file, nil, sopsFlags...); err != nil {
+		return "", err
+	}
+	fmt.Printf("🔒 %s → %s\n", file, target)
+
+	added, err := gitignoreFile(file)
+	if err != nil {
+		return "", err
+	}
+	if added != "" {
+		fmt.Printf("🙈 Added %s to %s\n", filepath.Base(file), added)
+	}
+	if exec.Command("git", "ls-files", "--error-unmatch", file).Run() == nil {
+		fmt.Printf("⚠️  %s is tracked by git, untrack it with `git rm --cached %s` and rotate its secrets, they are in the history\n", file, file)
+	}
+	return target, nil
+}
+
+// migratedName is config.sops.env for config.env and .sops.env for .env.
+func migratedName(file string) (string, error) {
+	ext := filepath.Ext(file)
+	if !slices.Contains(migrateFormats, ext) {
+		return "", fmt.Errorf("%s: sops can't tell the format, rename it to end in %s", file, strings.Join(migrateFormats, ", "))
+	}
+	stem := strings.TrimSuffix(file, ext)
+	if strings.HasSuffix(stem, ".sops") {
+		return "", fmt.Errorf("%s looks encrypted already", file)
+	}
+	return stem + ".sops" + ext, nil
+}
+
+// gitignoreFile adds file to the .gitignore next to it, returning the
+// .gitignore's path, or "" if it was already listed.
+func gitignoreFile(file string) (string, error) {
+	path := filepath.Join(filepath.Dir(file), ".gitignore")
+	entry := "/" + filepath.Base(file)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		line = strings.TrimSpace(line)
+		if line == entry || line == filepath.Base(file) {
+			return "", nil
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	prefix := ""
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		prefix = "\n"
+	}
+	if _, err := fmt.Fprintf(f, "%s%s\n", prefix, entry); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// recipientFlags turns -recipients and -pgp into sops flags, checking the
+// age recipients first so a typo fails before anything is written.
+func recipientFlags(ageRecipients, pgp string) ([]string, error) {
+	var flags []string
+	if ageRecipients != "" {
+		for _, r := range strings.Split(ageRecipients, ",") {
+			if _, err := age.ParseX25519Recipient(strings.TrimSpace(r)); err != nil {
+				return nil, fmt.Errorf("invalid age recipient %q: %w", r, err)
+			}
+		}
+		flags = append(flags, "--age", ageRecipients)
+	}
+	if pgp != "" {
+		flags = append(flags, "--pgp", pgp)
+	}
+	return flags, nil
+}
+
+// checkRecipients fails when sops would have nobody to encrypt target for.
+func checkRecipients(target string, sopsFlags []string) error {
+	if len(sopsFlags) == 0 && findSOPSConfig(filepath.Dir(target)) == "" {
+		return fmt.Errorf("%s: no .sops.yaml applies, pass -recipients age1... or run go-sops init", target)
+	}
+	return nil
+}
+
+// findSOPSConfig returns the .sops.yaml sops would use for files in dir.
+func findSOPSConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ".sops.yaml")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func printMigrationHint(file, target string) {
+	if filepath.Ext(file) == ".env" {
+		fmt.Printf(`
+  // %[1]s: instead of godotenv.Load(%[2]q)
+  if err := LoadSOPSEnvToSystem(%[3]q); err != nil {
+      log.Fatal(err)
+  }
+  // or, without touching the process environment:
+  config, err := LoadSOPSEnv(%[3]q)
+`, file, file, target)
+		return
+	}
+	fmt.Printf(`
+  // %[1]s: instead of os.ReadFile(%[2]q) and yaml.Unmarshal
+  config, err := LoadSOPSConfig(%[3]q) // go-sops-yaml
+`, file, file, target)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encryptingSOPS fails when FAKE_SOPS_FAIL is set and otherwise prints
+// the file it is given as ENC lines, after the --filename-override name.
+const encryptingSOPS = `[ -n "$FAKE_SOPS_FAIL" ] && { echo "no key could encrypt" >&2; exit 1; }
+while [ $# -gt 1 ]; do
+    [ "$1" = --filename-override ] && echo "# as $2"
+    shift
+done
+sed 's/^/ENC /' "$1"
+`
+
+func TestMigrateFile(t *testing.T) {
+	installSOPSScript(t, encryptingSOPS)
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(".env", []byte("DB_PASSWORD=hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := migrateFile(".env", nil, false)
+	if err != nil {
+		t.Fatalf("migrateFile() error = %v", err)
+	}
+	if target != ".sops.env" {
+		t.Errorf("target = %q, want .sops.env", target)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# as .sops.env\nENC DB_PASSWORD=hunter2\n"; string(data) != want {
+		t.Errorf("%s = %q, want %q", target, data, want)
+	}
+
+	// A failed re-migration must leave the encrypted file and nothing else.
+	t.Setenv("FAKE_SOPS_FAIL", "1")
+	if _, err := migrateFile(".env", nil, true); err == nil || !strings.Contains(err.Error(), "no key could encrypt") {
+		t.Fatalf("migrateFile() error = %v, want the sops error", err)
+	}
+	if after, err := os.ReadFile(target); err != nil || string(after) != string(data) {
+		t.Errorf("%s after a failed migration = %q, %v, want it unchanged", target, after, err)
+	}
+	leftovers, _ := filepath.Glob("*.tmp-*")
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %q", leftovers)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runNomad mirrors a Nomad template stanza for SOPS files: it renders the
+// plaintext into the allocation and applies change_mode to a task when the
+// encrypted file changes. Run it as a poststart sidecar task.
+func runNomad(args []string) error {
+	fset := flag.NewFlagSet("nomad", flag.ContinueOnError)
+	filename := fset.String("f", "config.sops.env", "encrypted file to render")
+	dest := fset.String("destination", "", "file to render to, defaults to $NOMAD_ALLOC_DIR/<plaintext name>")
+	permsFlag := fset.String("perms", "0400", "permissions of the rendered file")
+	changeMode := fset.String("change-mode", "restart", "what to do with -task on change: noop, signal or restart")
+	changeSignal := fset.String("change-signal", "SIGHUP", "signal to send with -change-mode signal")
+	task := fset.String("task", "", "task to signal or restart, as in the job file")
+	interval := fset.Duration("interval", 5*time.Second, "how often to check the file for changes")
+	once := fset.Bool("once", false, "render once and exit, e.g. as a prestart task")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	perms, err := strconv.ParseUint(*permsFlag, 8, 32)
+	if err != nil || perms&^0o777 != 0 {
+		return fmt.Errorf("invalid -perms %q, expected octal permissions like 0400", *permsFlag)
+	}
+	if perms&0o007 != 0 {
+		return fmt.Errorf("-perms %s would make secrets world-accessible", *permsFlag)
+	}
+	switch *changeMode {
+	case "noop", "signal", "restart":
+	default:
+		return fmt.Errorf("invalid -change-mode %q, expected noop, signal or restart", *changeMode)
+	}
+	if *changeMode != "noop" && !*once && *task == "" {
+		return fmt.Errorf("-change-mode %s needs -task", *changeMode)
+	}
+
+	if *dest == "" {
+		allocDir := os.Getenv("NOMAD_ALLOC_DIR")
+		if allocDir == "" {
+			return errors.New("$NOMAD_ALLOC_DIR is not set, pass -destination")
+		}
+		*dest = filepath.Join(allocDir, plaintextName(filepath.Base(*filename)))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	options := newLoadOptions(nil)
+	if err := decryptToFile(ctx, *filename, *dest, fs.FileMode(perms), options); err != nil {
+		return err
+	}
+	log.Printf("📝 Rendered %s to %s", *filename, *dest)
+	if *once {
+		return nil
+	}
+
+	client, err := newNomadClient()
+	if err != nil && *changeMode != "noop" {
+		return err
+	}
+
+	changes := watchFile(ctx, *filename, *interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+		}
+
+		if err := decryptToFile(ctx, *filename, *dest, fs.FileMode(perms), options); err != nil {
+			DefaultMetrics.observeReload(*filename, err)
+			log.Printf("⚠️ keeping the rendered file, decryption failed: %v", err)
+			continue
+		}
+		DefaultMetrics.observeReload(*filename, nil)
+		log.Printf("🔄 Re-rendered %s", *dest)
+
+		switch *changeMode {
+		case "signal":
+			err = client.signalTask(ctx, *task, *changeSignal)
+		case "restart":
+			err = client.restartTask(ctx, *task)
+		}
+		if err != nil {
+			log.Printf("⚠️ failed to %s task %s: %v", *changeMode, *task, err)
+		}
+	}
+}
+
+// nomadClient calls the allocation endpoints of the Nomad HTTP API. Inside
+// a task it prefers the Task API socket, which accepts the workload
+// identity token.
+type nomadClient struct {
+	addr    string
+	token   string
+	allocID string
+	http    *http.Client
+}
+
+func newNomadClient() (*nomadClient, error) {
+	allocID := os.Getenv("NOMAD_ALLOC_ID")
+	if allocID == "" {
+		return nil, errors.New("$NOMAD_ALLOC_ID is not set, run this as a Nomad task")
+	}
+	c := &nomadClient{
+		addr:    strings.TrimSuffix(os.Getenv("NOMAD_ADDR"), "/"),
+		token:   os.Getenv("NOMAD_TOKEN"),
+		allocID: allocID,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	socket := filepath.Join(os.Getenv("NOMAD_SECRETS_DIR"), "api.sock")
+	if _, err := os.Stat(socket); err == nil && os.Getenv("NOMAD_SECRETS_DIR") != "" {
+		c.addr = "http://localhost"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	if c.addr == "" {
+		c.addr = "http://127.0.0.1:4646"
+	}
+	return c, nil
+}
+
+func (c *nomadClient) signalTask(ctx context.Context, task, sig string) error {
+	return c.post(ctx, "/v1/client/allocation/"+c.allocID+"/signal", map[string]string{"Signal": sig, "Task": task})
+}
+
+func (c *nomadClient) restartTask(ctx context.Context, task string) error {
+	return c.post(ctx, "/v1/client/allocation/"+c.allocID+"/restart", map[string]string{"TaskName": task})
+}
+
+func (c *nomadClient) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("X-Nomad-Token", c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("%s: %s %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
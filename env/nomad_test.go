@@ -0,0 +1,15 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNomadRejectsWorldAccessiblePerms(t *testing.T) {
+	for _, perms := range []string{"0644", "0604", "0401", "0777"} {
+		err := runNomad([]string{"-perms", perms, "-destination", t.TempDir() + "/config.env", "-once"})
+		if err == nil || !strings.Contains(err.Error(), "world-accessible") {
+			t.Errorf("-perms %s error = %v, want it rejected as world-accessible", perms, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	NotifyReloaded      = "config.reloaded"
+	NotifyRotated       = "secret.rotated"
+	NotifyDecryptFailed = "decrypt.failed"
+
+	notifySignatureHeader = "X-Sops-Signature"
+	notifyEventHeader     = "X-Sops-Event"
+	notifyTimestampHeader = "X-Sops-Timestamp"
+
+	// NotifyTolerance is how far the signed timestamp of a notification may
+	// be from the receiver's clock before Verify rejects it as a replay.
+	NotifyTolerance = 5 * time.Minute
+)
+
+var (
+	ErrNotifySignature = errors.New("notification signature does not match")
+	ErrNotifyExpired   = errors.New("notification timestamp is outside the tolerance")
+)
+
+// NotifyEvent is the JSON body POSTed to webhooks. It carries key names and
+// an error category, never values.
+type NotifyEvent struct {
+	Type       string    `json:"type"`
+	File       string    `json:"file"`
+	Generation uint64    `json:"generation"`
+	Keys       []string  `json:"keys,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Notifier POSTs events to webhooks, signed with HMAC-SHA256 over
+// "<X-Sops-Timestamp>.<body>" in the X-Sops-Signature header as
+// sha256=<hex>. Receivers check both with Verify, so a captured request
+// can't be replayed later. Delivery happens in the background and is
+// retried a few times; failures are only logged.
+type Notifier struct {
+	secret []byte
+	urls   []string
+	http   *http.Client
+}
+
+// NewNotifier sends to urls, or when there are none, to the WEBHOOK_URL and
+// NOTIFICATION_SERVICE_URL of the config being loaded.
+func NewNotifier(secret []byte, urls ...string) *Notifier {
+	return &Notifier{secret: secret, urls: urls, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// WithNotifier makes a Store send reload, rotation and failure events.
+func WithNotifier(n *Notifier) Option {
+	return func(o *loadOptions) {
+		o.notifier = n
+	}
+}
+
+func (n *Notifier) targets(config *EnvConfig) []string {
+	if len(n.urls) > 0 || config == nil {
+		return n.urls
+	}
+	var urls []string
+	for _, url := range []string{config.WebhookURL, config.NotificationServiceURL} {
+		if url != "" && !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// Sign returns the signature header value for body sent with the
+// X-Sops-Timestamp timestamp, the Unix time in seconds.
+func (n *Notifier) Sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received notification: the signature must match the
+// timestamp and body, and the timestamp must be within NotifyTolerance of
+// now.
+func (n *Notifier) Verify(header http.Header, body []byte) error {
+	timestamp := header.Get(notifyTimestampHeader)
+	if !hmac.Equal([]byte(header.Get(notifySignatureHeader)), []byte(n.Sign(timestamp, body))) {
+		return ErrNotifySignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrNotifySignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > NotifyTolerance || age < -NotifyTolerance {
+		return ErrNotifyExpired
+	}
+	return nil
+}
+
+func (n *Notifier) notify(config *EnvConfig, event NotifyEvent) {
+	urls := n.targets(config)
+	if len(urls) == 0 {
+		return
+	}
+	event.Time = time.Now().UTC()
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, url := range urls {
+		go n.deliver(url, event.Type, body)
+	}
+}
+
+func (n *Notifier) deliver(url, eventType string, body []byte) {
+	backoff := time.Second
+	var err error
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err = n.post(url, eventType, body); err == nil {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	slog.Warn("failed to deliver config notification", "event", eventType, "url", DefaultRedactor.Redact(url), "error", err)
+}
+
+func (n *Notifier) post(url, eventType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(notifyEventHeader, eventType)
+	req.Header.Set(notifyTimestampHeader, timestamp)
+	req.Header.Set(notifySignatureHeader, n.Sign(timestamp, body))
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// rotatedKeys lists the secret keys whose value differs between two
+// generations, including secrets that were added or removed.
+func rotatedKeys(previous, current *EnvConfig) []string {
+	var keys []string
+	for key, value := range current.values {
+		old, ok := previous.values[key]
+		if (!ok || old != value) && (DefaultMaskPolicy.IsSecretValue(key, value) || DefaultMaskPolicy.IsSecretValue(key, old)) {
+			keys = append(keys, key)
+		}
+	}
+	for key, old := range previous.values {
+		if _, ok := current.values[key]; !ok && DefaultMaskPolicy.IsSecretValue(key, old) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
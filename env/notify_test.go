@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotifierSignsTimestamp(t *testing.T) {
+	n := NewNotifier([]byte("webhook-secret"))
+	received := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- n.Verify(r.Header, body)
+	}))
+	defer server.Close()
+
+	if err := n.post(server.URL, NotifyReloaded, []byte(`{"type":"config.reloaded"}`)); err != nil {
+		t.Fatalf("post() error = %v", err)
+	}
+	if err := <-received; err != nil {
+		t.Errorf("Verify() of a delivered notification = %v", err)
+	}
+}
+
+func TestNotifierVerify(t *testing.T) {
+	n := NewNotifier([]byte("webhook-secret"))
+	body := []byte(`{"type":"secret.rotated"}`)
+	signed := func(timestamp string) http.Header {
+		header := http.Header{}
+		header.Set(notifyTimestampHeader, timestamp)
+		header.Set(notifySignatureHeader, n.Sign(timestamp, body))
+		return header
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-NotifyTolerance-time.Minute).Unix(), 10)
+
+	replayed := signed(now)
+	replayed.Set(notifyTimestampHeader, strconv.FormatInt(time.Now().Unix()+1, 10))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{"valid", signed(now), body, nil},
+		{"other body", signed(now), []byte(`{"type":"decrypt.failed"}`), ErrNotifySignature},
+		{"timestamp changed", replayed, body, ErrNotifySignature},
+		{"expired", signed(old), body, ErrNotifyExpired},
+		{"not a timestamp", signed("soon"), body, ErrNotifySignature},
+		{"unsigned", http.Header{}, body, ErrNotifySignature},
+		{"other secret", func() http.Header {
+			header := http.Header{}
+			header.Set(notifyTimestampHeader, now)
+			header.Set(notifySignatureHeader, NewNotifier([]byte("other")).Sign(now, body))
+			return header
+		}(), body, ErrNotifySignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := n.Verify(tt.header, tt.body); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
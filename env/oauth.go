@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// OAuth2Option adjusts a config built by GoogleOAuth2 or GitHubOAuth2.
+type OAuth2Option func(*oauth2.Config)
+
+// WithRedirectURL sets the callback URL registered with the provider.
+func WithRedirectURL(url string) OAuth2Option {
+	return func(c *oauth2.Config) {
+		c.RedirectURL = url
+	}
+}
+
+// WithScopes replaces the default scopes.
+func WithScopes(scopes ...string) OAuth2Option {
+	return func(c *oauth2.Config) {
+		c.Scopes = scopes
+	}
+}
+
+// GoogleOAuth2 returns a config for Google sign-in from GOOGLE_CLIENT_ID and
+// GOOGLE_CLIENT_SECRET, asking for the OpenID email and profile scopes
+// unless WithScopes says otherwise.
+func (c *EnvConfig) GoogleOAuth2(opts ...OAuth2Option) (*oauth2.Config, error) {
+	return newOAuth2Config("google", "GOOGLE_CLIENT_ID", c.GoogleClientID, c.GoogleClientSecret,
+		endpoints.Google, []string{"openid", "email", "profile"}, opts)
+}
+
+// GitHubOAuth2 returns a config for GitHub sign-in from GITHUB_CLIENT_ID and
+// GITHUB_CLIENT_SECRET, asking for the profile and email addresses unless
+// WithScopes says otherwise.
+func (c *EnvConfig) GitHubOAuth2(opts ...OAuth2Option) (*oauth2.Config, error) {
+	return newOAuth2Config("github", "GITHUB_CLIENT_ID", c.GitHubClientID, c.GitHubClientSecret,
+		endpoints.GitHub, []string{"read:user", "user:email"}, opts)
+}
+
+func newOAuth2Config(provider, idKey, id, secret string, endpoint oauth2.Endpoint, scopes []string, opts []OAuth2Option) (*oauth2.Config, error) {
+	if id == "" || secret == "" {
+		return nil, fmt.Errorf("%s: %s or its secret is not set: %w", provider, idKey, ErrClientNotConfigured)
+	}
+	cfg := &oauth2.Config{
+		ClientID:     id,
+		ClientSecret: secret,
+		Endpoint:     endpoint,
+		Scopes:       scopes,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"golang.org/x/oauth2/endpoints"
+
+	"go-sops-env/sopstest"
+)
+
+func TestOAuth2Configs(t *testing.T) {
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{
+		"GOOGLE_CLIENT_ID": "google-id", "GOOGLE_CLIENT_SECRET": "google-secret",
+		"GITHUB_CLIENT_ID": "github-id",
+	})
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	google, err := config.GoogleOAuth2(WithRedirectURL("https://app.example.com/callback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if google.ClientID != "google-id" || google.ClientSecret != "google-secret" || google.Endpoint != endpoints.Google {
+		t.Errorf("Google config = %+v", google)
+	}
+	authURL, _ := url.Parse(google.AuthCodeURL("state"))
+	query := authURL.Query()
+	if query.Get("client_id") != "google-id" || query.Get("redirect_uri") != "https://app.example.com/callback" || query.Get("scope") != "openid email profile" {
+		t.Errorf("AuthCodeURL() = %s", authURL)
+	}
+	if query.Has("client_secret") {
+		t.Errorf("AuthCodeURL() carries the client secret: %s", authURL)
+	}
+
+	if _, err := config.GitHubOAuth2(); !errors.Is(err, ErrClientNotConfigured) {
+		t.Errorf("GitHubOAuth2() without a secret: %v, want ErrClientNotConfigured", err)
+	}
+	config.GitHubClientSecret = "github-secret"
+	github, err := config.GitHubOAuth2(WithScopes("repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if github.Endpoint != endpoints.GitHub || !slices.Equal(github.Scopes, []string{"repo"}) {
+		t.Errorf("GitHub config = %+v", github)
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	sopsSecretAPIVersion = "gosops.io/v1alpha1"
+	sourceHashAnnotation = "gosops.io/source-hash"
+	managedByLabel       = "app.kubernetes.io/managed-by"
+	operatorName         = "go-sops"
+)
+
+//go:embed sopssecret.crd.yaml
+var sopsSecretCRD string
+
+// SopsSecret asks the operator to decrypt a SOPS file and keep a Secret in
+// sync with it.
+type SopsSecret struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   objectMeta       `json:"metadata"`
+	Spec       SopsSecretSpec   `json:"spec"`
+	Status     SopsSecretStatus `json:"status"`
+}
+
+type SopsSecretSpec struct {
+	SecretName string     `json:"secretName,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Source     SopsSource `json:"source"`
+}
+
+// SopsSource names where the encrypted file lives. Exactly one field is set.
+type SopsSource struct {
+	ConfigMap *ConfigMapSource `json:"configMap,omitempty"`
+	Git       *GitSource       `json:"git,omitempty"`
+}
+
+type ConfigMapSource struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type GitSource struct {
+	URL  string `json:"url"`
+	Ref  string `json:"ref,omitempty"`
+	Path string `json:"path"`
+}
+
+type SopsSecretStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	SourceHash         string      `json:"sourceHash,omitempty"`
+	LastSyncTime       string      `json:"lastSyncTime,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+func (s *SopsSecret) secretName() string {
+	if s.Spec.SecretName != "" {
+		return s.Spec.SecretName
+	}
+	return s.Metadata.Name
+}
+
+func (s *SopsSecret) readyCondition() *Condition {
+	for i := range s.Status.Conditions {
+		if s.Status.Conditions[i].Type == "Ready" {
+			return &s.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// reconcileError carries the condition reason for a failed sync.
+type reconcileError struct {
+	Reason string
+	Err    error
+}
+
+func (e *reconcileError) Error() string { return e.Err.Error() }
+func (e *reconcileError) Unwrap() error { return e.Err }
+
+func reconcileFailed(reason string, err error) error {
+	return &reconcileError{Reason: reason, Err: err}
+}
+
+type operator struct {
+	client    *kubeClient
+	namespace string
+	options   *loadOptions
+	gitAllow  gitAllowlist
+	keyAllow  keyAllowlist
+}
+
+func runOperator(args []string) error {
+	fs := flag.NewFlagSet("operator", flag.ContinueOnError)
+	apiServer := fs.String("api-server", "", "API server URL, defaults to the in-cluster API")
+	namespace := fs.String("namespace", "", "only watch this namespace")
+	interval := fs.Duration("interval", time.Minute, "how often to re-check every SopsSecret")
+	printCRD := fs.Bool("print-crd", false, "print the SopsSecret CRD and exit")
+	allowGit := fs.String("allow-git", "", "comma-separated namespace=repo-prefix entries, * for any namespace, that git sources may clone")
+	allowKeys := fs.String("allow-keys", "", "comma-separated namespace=key entries, * for any namespace, that ConfigMap sources must be encrypted for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *printCRD {
+		fmt.Print(sopsSecretCRD)
+		return nil
+	}
+
+	gitAllow, err := parseGitAllowlist(*allowGit)
+	if err != nil {
+		return err
+	}
+	keyAllow, err := parseKeyAllowlist(*allowKeys)
+	if err != nil {
+		return err
+	}
+	client, err := newKubeClient(*apiServer)
+	if err != nil {
+		return err
+	}
+	op := &operator{client: client, namespace: *namespace, options: newLoadOptions(nil), gitAllow: gitAllow, keyAllow: keyAllow}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("operator started", "namespace", *namespace, "interval", *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := op.syncAll(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to list SopsSecrets", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (op *operator) syncAll(ctx context.Context) error {
+	listPath := "/apis/" + sopsSecretAPIVersion + "/sopssecrets"
+	if op.namespace != "" {
+		listPath = "/apis/" + sopsSecretAPIVersion + "/namespaces/" + url.PathEscape(op.namespace) + "/sopssecrets"
+	}
+	var list struct {
+		Items []SopsSecret `json:"items"`
+	}
+	if err := op.client.do(ctx, http.MethodGet, listPath, nil, &list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		op.sync(ctx, &list.Items[i])
+	}
+	return nil
+}
+
+// sync reconciles one SopsSecret and records the outcome in its status.
+func (op *operator) sync(ctx context.Context, ss *SopsSecret) {
+	hash, err := op.reconcile(ctx, ss)
+	if errors.Is(err, errUpToDate) {
+		return
+	}
+
+	cond := Condition{Type: "Ready", Status: "True", Reason: "Synced", Message: "Secret " + ss.secretName() + " is up to date"}
+	status := ss.Status
+	if err != nil {
+		reason := "SyncFailed"
+		var rerr *reconcileError
+		if errors.As(err, &rerr) {
+			reason = rerr.Reason
+		}
+		cond = Condition{Type: "Ready", Status: "False", Reason: reason, Message: DefaultRedactor.Redact(err.Error())}
+		slog.Warn("SopsSecret sync failed", "namespace", ss.Metadata.Namespace, "name", ss.Metadata.Name, "reason", reason, "error", err)
+	} else {
+		status.SourceHash = hash
+		status.LastSyncTime = time.Now().UTC().Format(time.RFC3339)
+		slog.Info("SopsSecret synced", "namespace", ss.Metadata.Namespace, "name", ss.Metadata.Name, "secret", ss.secretName())
+	}
+
+	cond.ObservedGeneration = ss.Metadata.Generation
+	cond.LastTransitionTime = time.Now().UTC().Format(time.RFC3339)
+	if prev := ss.readyCondition(); prev != nil && prev.Status == cond.Status {
+		cond.LastTransitionTime = prev.LastTransitionTime
+	}
+	status.ObservedGeneration = ss.Metadata.Generation
+	status.Conditions = []Condition{cond}
+
+	statusPath := op.resourcePath(ss) + "/status"
+	patch := map[string]any{"status": status}
+	if err := op.client.do(ctx, http.MethodPatch, statusPath, patch, nil); err != nil && ctx.Err() == nil {
+		slog.Error("failed to update SopsSecret status", "namespace", ss.Metadata.Namespace, "name", ss.Metadata.Name, "error", err)
+	}
+}
+
+var errUpToDate = errors.New("up to date")
+
+// reconcile writes the decrypted Secret, returning errUpToDate when the
+// encrypted file, the spec and the Secret are all unchanged. Key rotation
+// rewrites the encrypted file, which changes its hash and forces a resync.
+func (op *operator) reconcile(ctx context.Context, ss *SopsSecret) (string, error) {
+	name, encrypted, err := op.fetchSource(ctx, ss)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encrypted)
+	hash := hex.EncodeToString(sum[:])
+
+	secretPath := "/api/v1/namespaces/" + url.PathEscape(ss.Metadata.Namespace) + "/secrets/" + url.PathEscape(ss.secretName())
+	var existing kubeSecret
+	err = op.client.do(ctx, http.MethodGet, secretPath, nil, &existing)
+	found := err == nil
+	if err != nil && !isNotFound(err) {
+		return "", reconcileFailed("SecretReadFailed", err)
+	}
+	if found && !ownedBy(existing.Metadata, ss) {
+		return "", reconcileFailed("SecretConflict", fmt.Errorf("secret %s exists and is not managed by this SopsSecret", ss.secretName()))
+	}
+
+	ready := ss.readyCondition()
+	if found && existing.Metadata.Annotations[sourceHashAnnotation] == hash &&
+		ss.Status.SourceHash == hash && ss.Status.ObservedGeneration == ss.Metadata.Generation &&
+		ready != nil && ready.Status == "True" {
+		return hash, errUpToDate
+	}
+
+	data, err := op.decrypt(ctx, name, encrypted)
+	if err != nil {
+		return "", reconcileFailed("DecryptFailed", err)
+	}
+
+	secretType := ss.Spec.Type
+	if secretType == "" {
+		secretType = "Opaque"
+	}
+	secret := kubeSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: objectMeta{
+			Name:        ss.secretName(),
+			Namespace:   ss.Metadata.Namespace,
+			Labels:      map[string]string{managedByLabel: operatorName},
+			Annotations: map[string]string{sourceHashAnnotation: hash},
+			OwnerReferences: []ownerReference{{
+				APIVersion: sopsSecretAPIVersion,
+				Kind:       "SopsSecret",
+				Name:       ss.Metadata.Name,
+				UID:        ss.Metadata.UID,
+				Controller: true,
+			}},
+		},
+		Type: secretType,
+		Data: data,
+	}
+
+	if found {
+		secret.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+		err = op.client.do(ctx, http.MethodPut, secretPath, secret, nil)
+	} else {
+		createPath := "/api/v1/namespaces/" + url.PathEscape(ss.Metadata.Namespace) + "/secrets"
+		err = op.client.do(ctx, http.MethodPost, createPath, secret, nil)
+	}
+	if err != nil {
+		return "", reconcileFailed("SecretWriteFailed", err)
+	}
+	return hash, nil
+}
+
+func ownedBy(meta objectMeta, ss *SopsSecret) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.Kind == "SopsSecret" && ref.UID == ss.Metadata.UID {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *operator) resourcePath(ss *SopsSecret) string {
+	return "/apis/" + sopsSecretAPIVersion + "/namespaces/" + url.PathEscape(ss.Metadata.Namespace) +
+		"/sopssecrets/" + url.PathEscape(ss.Metadata.Name)
+}
+
+// fetchSource returns the encrypted file's base name, which sops needs to
+// tell the format, and its contents.
+func (op *operator) fetchSource(ctx context.Context, ss *SopsSecret) (string, []byte, error) {
+	src := ss.Spec.Source
+	switch {
+	case src.ConfigMap != nil && src.Git != nil:
+		return "", nil, reconcileFailed("InvalidSpec", errors.New("source must set only one of configMap and git"))
+
+	case src.ConfigMap != nil:
+		cmPath := "/api/v1/namespaces/" + url.PathEscape(ss.Metadata.Namespace) + "/configmaps/" + url.PathEscape(src.ConfigMap.Name)
+		var cm kubeConfigMap
+		if err := op.client.do(ctx, http.MethodGet, cmPath, nil, &cm); err != nil {
+			return "", nil, reconcileFailed("SourceNotFound", err)
+		}
+		name := path.Base(src.ConfigMap.Key)
+		data, ok := cm.BinaryData[src.ConfigMap.Key]
+		if value, found := cm.Data[src.ConfigMap.Key]; found {
+			data, ok = []byte(value), true
+		}
+		if !ok {
+			return "", nil, reconcileFailed("SourceNotFound", fmt.Errorf("key %s not found in ConfigMap %s", src.ConfigMap.Key, src.ConfigMap.Name))
+		}
+		if err := op.keyAllow.check(ss.Metadata.Namespace, name, data); err != nil {
+			return "", nil, reconcileFailed("SourceNotAllowed", err)
+		}
+		return name, data, nil
+
+	case src.Git != nil:
+		if err := checkGitURL(src.Git.URL); err != nil {
+			return "", nil, reconcileFailed("InvalidSpec", err)
+		}
+		if !op.gitAllow.allows(ss.Metadata.Namespace, src.Git.URL) {
+			return "", nil, reconcileFailed("SourceNotAllowed", fmt.Errorf("repository %s is not allowed for namespace %s, see -allow-git", src.Git.URL, ss.Metadata.Namespace))
+		}
+		data, err := fetchGitFile(ctx, src.Git)
+		if err != nil {
+			return "", nil, reconcileFailed("SourceNotFound", err)
+		}
+		return path.Base(src.Git.Path), data, nil
+
+	default:
+		return "", nil, reconcileFailed("InvalidSpec", errors.New("source must set configMap or git"))
+	}
+}
+
+// gitAllowlist maps a namespace, or * for every namespace, to the
+// repository URL prefixes its SopsSecrets may clone. Without entries git
+// sources are refused, so a namespace can't make the operator clone from
+// anywhere with its credentials.
+type gitAllowlist map[string][]string
+
+// parseGitAllowlist reads the -allow-git flag, like
+// "team-a=https://github.com/org/team-a/,*=git@github.com:org/shared".
+func parseGitAllowlist(s string) (gitAllowlist, error) {
+	allow := gitAllowlist{}
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		namespace, prefix, ok := strings.Cut(entry, "=")
+		if !ok || namespace == "" || prefix == "" {
+			return nil, fmt.Errorf("invalid -allow-git entry %q, want namespace=repo-prefix", entry)
+		}
+		if err := checkGitURL(prefix); err != nil {
+			return nil, fmt.Errorf("invalid -allow-git entry %q: %w", entry, err)
+		}
+		allow[namespace] = append(allow[namespace], prefix)
+	}
+	return allow, nil
+}
+
+func (a gitAllowlist) allows(namespace, repo string) bool {
+	for _, prefix := range slices.Concat(a[namespace], a["*"]) {
+		// A prefix ends at a path boundary: .../org doesn't allow .../org-other.
+		if repo == prefix || strings.HasPrefix(repo, prefix) && (strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ":") || repo[len(prefix)] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+// keyAllowlist maps a namespace, or * for every namespace, to the sops
+// keys its ConfigMap sources must be encrypted for: age recipients, PGP
+// fingerprints, KMS ARNs or resource IDs, or vault URLs. The operator can
+// decrypt whatever its own keys open, so without this check a namespace
+// could copy another team's file into a ConfigMap and get its plaintext
+// back as a Secret. Without entries ConfigMap sources are refused.
+type keyAllowlist map[string][]string
+
+// parseKeyAllowlist reads the -allow-keys flag, like
+// "team-a=age1...,team-b=arn:aws:kms:eu-west-1:111122223333:key/...".
+func parseKeyAllowlist(s string) (keyAllowlist, error) {
+	allow := keyAllowlist{}
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		namespace, key, ok := strings.Cut(entry, "=")
+		if !ok || namespace == "" || key == "" {
+			return nil, fmt.Errorf("invalid -allow-keys entry %q, want namespace=key", entry)
+		}
+		allow[namespace] = append(allow[namespace], key)
+	}
+	return allow, nil
+}
+
+// check reports an error unless the encrypted file is encrypted for at
+// least one key allowed for namespace, going by its sops metadata.
+func (a keyAllowlist) check(namespace, name string, data []byte) error {
+	allowed := slices.Concat(a[namespace], a["*"])
+	if len(allowed) == 0 {
+		return fmt.Errorf("no keys are allowed for namespace %s, see -allow-keys", namespace)
+	}
+	meta, err := parseSOPSMetadata(name, data)
+	if err != nil {
+		return fmt.Errorf("%s is not sops-encrypted: %w", name, err)
+	}
+	for _, recipients := range meta.Recipients {
+		for _, recipient := range recipients {
+			if slices.Contains(allowed, recipient) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not encrypted for any key allowed for namespace %s, see -allow-keys", name, namespace)
+}
+
+// checkGitURL accepts https and ssh repositories, including the scp-like
+// user@host:path form. Local paths, file:// and transports like ext:: are
+// refused, since they would read the operator's own filesystem or run
+// commands.
+func checkGitURL(repo string) error {
+	if scheme, _, ok := strings.Cut(repo, "://"); ok {
+		if scheme == "https" || scheme == "ssh" {
+			return nil
+		}
+		return fmt.Errorf("git URL scheme %s is not allowed, use https or ssh", scheme)
+	}
+	host, _, ok := strings.Cut(repo, ":")
+	if ok && len(host) > 1 && !strings.ContainsAny(host, "/\\") && !strings.Contains(repo, "::") && !strings.HasPrefix(repo, "-") {
+		return nil
+	}
+	return fmt.Errorf("git URL %s is not an https or ssh repository", repo)
+}
+
+// fetchGitFile shallow-clones the repository and reads one file from it.
+// Credentials come from the pod's git configuration.
+func fetchGitFile(ctx context.Context, src *GitSource) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "go-sops-git-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if src.Ref != "" {
+		args = append(args, "--branch", src.Ref)
+	}
+	args = append(args, "--", src.URL, dir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL=https:ssh")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone %s failed: %w: %s", src.URL, err, strings.TrimSpace(string(out)))
+	}
+	return readCloneFile(dir, src.Path)
+}
+
+// readCloneFile reads name from the clone in dir. The repository controls
+// its symlinks, so name and its parents must not be one.
+func readCloneFile(dir, name string) ([]byte, error) {
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if rel == "" {
+		return nil, fmt.Errorf("git path %q names no file", name)
+	}
+	file := dir
+	for part := range strings.SplitSeq(rel, "/") {
+		file = filepath.Join(file, part)
+		info, err := os.Lstat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from the repository: %w", rel, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("refusing to read %s from the repository: %s is a symlink", rel, part)
+		}
+	}
+	return os.ReadFile(file)
+}
+
+// decrypt turns the encrypted file into Secret data: one key per variable
+// for dotenv files, otherwise a single key holding the whole plaintext.
+func (op *operator) decrypt(ctx context.Context, name string, encrypted []byte) (map[string][]byte, error) {
+	dir, err := os.MkdirTemp("", "go-sops-operator-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, encrypted, 0o600); err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := decryptSOPSFile(ctx, file, op.options, buf); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(name, ".env") {
+		entries, err := parseDotenv(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse env file: %w", err)
+		}
+		envMap, _ := dotenvMap(entries)
+		DefaultRedactor.AddEnv(envMap)
+		data := make(map[string][]byte, len(envMap))
+		for key, value := range envMap {
+			data[key] = []byte(value)
+		}
+		return data, nil
+	}
+	return map[string][]byte{plaintextName(name): bytes.Clone(buf.Bytes())}, nil
+}
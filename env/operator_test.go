@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckGitURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://github.com/org/repo.git", true},
+		{"ssh://git@github.com/org/repo.git", true},
+		{"git@github.com:org/repo.git", true},
+		{"github.com:org/repo", true},
+		{"http://github.com/org/repo.git", false},
+		{"file:///etc", false},
+		{"/var/run/secrets", false},
+		{"./repo", false},
+		{"../repo", false},
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"C:\\repo", false},
+		{"-uhello:x", false},
+		{"repo", false},
+	}
+	for _, tt := range tests {
+		if err := checkGitURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("checkGitURL(%q) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestGitAllowlist(t *testing.T) {
+	allow, err := parseGitAllowlist("team-a=https://github.com/org/team-a, *=git@github.com:org/shared/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		namespace, url string
+		want           bool
+	}{
+		{"team-a", "https://github.com/org/team-a", true},
+		{"team-a", "https://github.com/org/team-a/sub.git", true},
+		{"team-a", "https://github.com/org/team-a-other", false},
+		{"team-b", "https://github.com/org/team-a", false},
+		{"team-b", "git@github.com:org/shared/config.git", true},
+		{"team-a", "git@github.com:org/shared/config.git", true},
+		{"team-b", "git@github.com:org/other.git", false},
+	}
+	for _, tt := range tests {
+		if got := allow.allows(tt.namespace, tt.url); got != tt.want {
+			t.Errorf("allows(%q, %q) = %v, want %v", tt.namespace, tt.url, got, tt.want)
+		}
+	}
+
+	if empty, _ := parseGitAllowlist(""); empty.allows("default", "https://github.com/org/repo") {
+		t.Error("an empty allowlist allows git sources")
+	}
+	for _, invalid := range []string{"team-a", "=https://x/", "team-a=file:///srv/git"} {
+		if _, err := parseGitAllowlist(invalid); err == nil {
+			t.Errorf("parseGitAllowlist(%q) error = nil", invalid)
+		}
+	}
+}
+
+func TestReadCloneFile(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(outside, []byte("service-account-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "deploy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "deploy", "config.sops.env"), []byte("A=ENC[...]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "deploy", "link.sops.env")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readCloneFile(dir, "/deploy/../deploy/config.sops.env")
+	if err != nil || string(data) != "A=ENC[...]\n" {
+		t.Errorf("readCloneFile() = %q, %v", data, err)
+	}
+	for _, name := range []string{"deploy/link.sops.env", "linkdir/token", "../../" + filepath.Base(outside), ""} {
+		data, err := readCloneFile(dir, name)
+		if err == nil || strings.Contains(string(data), "token") {
+			t.Errorf("readCloneFile(%q) = %q, %v, want an error", name, data, err)
+		}
+	}
+}
+
+func TestKeyAllowlist(t *testing.T) {
+	allow, err := parseKeyAllowlist("team-a=age1teama, *=arn:aws:kms:eu-west-1:111122223333:key/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	envFile := func(recipient string) []byte {
+		return []byte("DB_PASSWORD=ENC[AES256_GCM,data:x]\nsops_age__list_0__map_recipient=" + recipient + "\nsops_mac=ENC[AES256_GCM,data:y]\n")
+	}
+	yamlFile := []byte("password: ENC[AES256_GCM,data:x]\nsops:\n  kms:\n  - arn: arn:aws:kms:eu-west-1:111122223333:key/shared\n  mac: ENC[AES256_GCM,data:y]\n")
+	tests := []struct {
+		name, namespace, file string
+		data                  []byte
+		ok                    bool
+	}{
+		{"own key", "team-a", "config.sops.env", envFile("age1teama"), true},
+		{"other team's file", "team-a", "config.sops.env", envFile("age1teamb"), false},
+		{"other namespace", "team-b", "config.sops.env", envFile("age1teama"), false},
+		{"shared key for every namespace", "team-b", "config.sops.yaml", yamlFile, true},
+		{"plaintext", "team-a", "config.env", []byte("DB_PASSWORD=hunter2\n"), false},
+	}
+	for _, tt := range tests {
+		if err := allow.check(tt.namespace, tt.file, tt.data); (err == nil) != tt.ok {
+			t.Errorf("%s: check() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+
+	empty, _ := parseKeyAllowlist("")
+	if err := empty.check("team-a", "config.sops.env", envFile("age1teama")); err == nil {
+		t.Error("an empty allowlist allows ConfigMap sources")
+	}
+	if _, err := parseKeyAllowlist("team-a"); err == nil {
+		t.Error("parseKeyAllowlist() accepted an entry without a key")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+type Option func(*loadOptions)
+
+type loadOptions struct {
+	deprecations   []Deprecation
+	canaries       []string
+	guard          *DecryptGuard
+	expiryWindow   time.Duration
+	onExpiry       func(SecretExpiry)
+	fips           bool
+	tamperAlerts   bool
+	onTamper       func(TamperEvent)
+	maintenance    []MaintenanceWindow
+	signature      *signatureVerifier
+	allowedKeys    []string
+	deniedKeys     []string
+	dryRun         *DryRunReport
+	parallelUnwrap bool
+	warmCache      *warmCache
+	sopsEnv        []string
+	notifier       *Notifier
+	alerter        *Alerter
+	devFallback    bool
+	decryptor      Decryptor
+	dotenv         dotenvOptions
+	timeout        time.Duration
+	killGrace      time.Duration
+	logger         *slog.Logger
+	permissions    PermissionPolicy
+	schema         *Schema
+	history        int
+	policy         *Policy
+	rules          *Rules
+	validation     bool
+}
+
+func newLoadOptions(opts []Option) *loadOptions {
+	o := &loadOptions{
+		timeout:      DefaultDecryptTimeout,
+		killGrace:    DefaultKillGrace,
+		expiryWindow: DefaultExpiryWindow,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTimeout bounds how long sops may run. Zero disables the limit and
+// leaves cancellation to the caller's context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *loadOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithKillGrace sets how long sops gets to exit after SIGTERM before it and
+// its children are killed.
+func WithKillGrace(grace time.Duration) Option {
+	return func(o *loadOptions) {
+		o.killGrace = grace
+	}
+}
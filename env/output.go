@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// outputFormat is set by the global --output flag. With json, check, keys,
+// doctor, view, age-report and gitops-diff print one JSON document to
+// stdout instead of text. Errors still go to stderr and set the exit
+// status.
+var outputFormat = "text"
+
+// parseGlobalFlags consumes the flags before the command name:
+//
+//	go-sops --output json check -f config.sops.env
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		if name != "output" {
+			// Not a global flag, so leave it to runCommand, which handles -h.
+			return args, nil
+		}
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, errors.New("--output needs a value, text or json")
+			}
+			value, args = args[0], args[1:]
+		}
+		if value != "text" && value != "json" {
+			return nil, fmt.Errorf("--output must be text or json, not %q", value)
+		}
+		outputFormat = value
+	}
+	return args, nil
+}
+
+func jsonOutput() bool {
+	return outputFormat == "json"
+}
+
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGlobalFlags(t *testing.T) {
+	defer func(previous string) { outputFormat = previous }(outputFormat)
+	tests := []struct {
+		args    []string
+		rest    []string
+		format  string
+		wantErr string
+	}{
+		{[]string{"check", "-f", "x"}, []string{"check", "-f", "x"}, "text", ""},
+		{[]string{"--output", "json", "check"}, []string{"check"}, "json", ""},
+		{[]string{"-output=json", "keys"}, []string{"keys"}, "json", ""},
+		{[]string{"-h"}, []string{"-h"}, "text", ""},
+		{[]string{"--output"}, nil, "text", "--output needs a value"},
+		{[]string{"--output", "yaml", "check"}, nil, "text", `--output must be text or json, not "yaml"`},
+	}
+	for _, tt := range tests {
+		outputFormat = "text"
+		rest, err := parseGlobalFlags(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("parseGlobalFlags(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(rest, tt.rest) || outputFormat != tt.format {
+			t.Errorf("parseGlobalFlags(%q) = %q, %v with format %s", tt.args, rest, err, outputFormat)
+		}
+	}
+}
+
+func TestCommandsJSONOutput(t *testing.T) {
+	installFakeSOPSBinary(t)
+	defer func(previous string) { outputFormat = previous }(outputFormat)
+	outputFormat = "json"
+
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "config.sops.env")
+	expires := time.Now().AddDate(0, 0, 10).Format(time.DateOnly)
+	os.WriteFile(envFile, []byte("DB_HOST=db\nDB_PASSWORD=hunter22\nDB_PASSWORD"+ExpiresSuffix+"="+expires+"\n"), 0o600)
+	yamlFile := filepath.Join(dir, "config.sops.yaml")
+	os.WriteFile(yamlFile, []byte("storage:\n  psql:\n    host: db\nsops:\n  mac: x\n"), 0o600)
+
+	decode := func(name string, run func() error, v any) {
+		t.Helper()
+		var err error
+		out := captureStdout(t, func() { err = run() })
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := json.Unmarshal(out, v); err != nil {
+			t.Fatalf("%s: stdout is not JSON: %v\n%s", name, err, out)
+		}
+		if strings.Contains(string(out), "hunter22") {
+			t.Errorf("%s leaks a secret:\n%s", name, out)
+		}
+	}
+
+	var check checkReport
+	decode("check", func() error { return runCheck([]string{"-f", envFile, "-within", "720h"}) }, &check)
+	if check.Keys != 2 || len(check.Expiries) != 1 || check.Expiries[0].Status != "expiring" || check.Expiries[0].Key != "DB_PASSWORD" {
+		t.Errorf("check = %+v", check)
+	}
+
+	var keys []struct{ Path, Extract string }
+	decode("keys", func() error { return runKeys([]string{"-f", yamlFile}) }, &keys)
+	if len(keys) != 1 || keys[0].Path != "storage.psql.host" || keys[0].Extract != `["storage"]["psql"]["host"]` {
+		t.Errorf("keys = %+v", keys)
+	}
+
+	var view viewReport
+	decode("view", func() error { return runView([]string{"-f", envFile}) }, &view)
+	if len(view.Keys) != 3 || view.Keys[1].Key != "DB_PASSWORD" || !view.Keys[1].Secret {
+		t.Errorf("view = %+v", view)
+	}
+}
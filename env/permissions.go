@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// PermissionPolicy decides what a load does when the encrypted file or the
+// age key file has unsafe permissions.
+type PermissionPolicy int
+
+const (
+	// PermissionIgnore skips the check. It is the default.
+	PermissionIgnore PermissionPolicy = iota
+	// PermissionWarn logs the problems and loads anyway.
+	PermissionWarn
+	// PermissionFail fails the load with ErrInsecurePermissions.
+	PermissionFail
+)
+
+var ErrInsecurePermissions = errors.New("insecure file permissions")
+
+// WithPermissionCheck checks, before decrypting, that the encrypted file is
+// not world-writable, and that the age key file (SOPS_AGE_KEY_FILE or
+// ~/.config/sops/age/keys.txt) is neither readable nor writable by others
+// and belongs to the current user or root. On shared hosts these are easy
+// to get wrong with a careless chmod or a copied home directory. The check
+// only runs on Unix.
+func WithPermissionCheck(policy PermissionPolicy) Option {
+	return func(o *loadOptions) {
+		o.permissions = policy
+	}
+}
+
+func checkPermissions(filename string, options *loadOptions) error {
+	if options.permissions == PermissionIgnore {
+		return nil
+	}
+
+	problems := filePermissionProblems(filename, false)
+	if keyFile := ageKeyFile(); keyFile != "" {
+		problems = append(problems, filePermissionProblems(keyFile, true)...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if options.permissions == PermissionWarn {
+		slog.Warn("insecure file permissions", "file", filename, "problems", problems)
+		return nil
+	}
+	return fmt.Errorf("%s: %w: %s", filename, ErrInsecurePermissions, strings.Join(problems, "; "))
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// filePermissionProblems reports nothing: outside Unix, file modes don't
+// describe who can read a file.
+func filePermissionProblems(path string, private bool) []string {
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// filePermissionProblems describes what is unsafe about path. A private
+// file, like a key, must also not be readable by others and must belong
+// to the current user or root.
+func filePermissionProblems(path string, private bool) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	var problems []string
+	mode := info.Mode().Perm()
+	if mode&0o002 != 0 {
+		problems = append(problems, fmt.Sprintf("%s is world-writable (%#o)", path, mode))
+	}
+	if !private {
+		return problems
+	}
+	if mode&0o004 != 0 {
+		problems = append(problems, fmt.Sprintf("%s is world-readable (%#o), run `chmod 600 %s`", path, mode, path))
+	}
+	if uid, ok := fileOwner(info); ok && uid != 0 && uid != os.Getuid() {
+		problems = append(problems, fmt.Sprintf("%s belongs to uid %d, not the current user", path, uid))
+	}
+	return problems
+}
+
+func fileOwner(info fs.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
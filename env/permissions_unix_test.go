@@ -0,0 +1,64 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithPermissionCheck(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	keyFile := filepath.Join(dir, "keys.txt")
+	for _, path := range []string{filename, keyFile} {
+		if err := os.WriteFile(path, []byte("x\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+	decryptor := WithDecryptor(plaintextDecryptor("DB_PASSWORD=hunter22\n"))
+
+	if _, err := LoadSOPSEnv(filename, decryptor, WithPermissionCheck(PermissionFail)); err != nil {
+		t.Fatalf("private files: %v", err)
+	}
+
+	os.Chmod(filename, 0o666)
+	os.Chmod(keyFile, 0o644)
+	if _, err := LoadSOPSEnv(filename, decryptor); err != nil {
+		t.Errorf("PermissionIgnore: %v", err)
+	}
+	_, err := LoadSOPSEnv(filename, decryptor, WithPermissionCheck(PermissionFail))
+	if !errors.Is(err, ErrInsecurePermissions) {
+		t.Fatalf("PermissionFail: %v, want ErrInsecurePermissions", err)
+	}
+	for _, want := range []string{filename + " is world-writable (0666)", keyFile + " is world-readable (0644)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q: %v", want, err)
+		}
+	}
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	config, err := LoadSOPSEnv(filename, decryptor, WithPermissionCheck(PermissionWarn))
+	if err != nil || config.DBPassword != "hunter22" {
+		t.Errorf("PermissionWarn: %v", err)
+	}
+	if !strings.Contains(logs.String(), "insecure file permissions") {
+		t.Errorf("PermissionWarn logged nothing: %s", logs.String())
+	}
+
+	// A world-readable encrypted file is fine: it is encrypted.
+	os.Chmod(filename, 0o644)
+	os.Chmod(keyFile, 0o600)
+	if _, err := LoadSOPSEnv(filename, decryptor, WithPermissionCheck(PermissionFail)); err != nil {
+		t.Errorf("world-readable encrypted file: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxBeforeConnect returns a pgxpool BeforeConnect hook that sets the user
+// and password of every new connection from creds.
+func PgxBeforeConnect(creds CredentialProvider) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, cc *pgx.ConnConfig) error {
+		c, err := creds.Credentials(ctx)
+		if err != nil {
+			return err
+		}
+		if c.Username != "" {
+			cc.User = c.Username
+		}
+		cc.Password = c.Password
+		return nil
+	}
+}
+
+// ConfigurePgxPool installs the hook on cfg with the store's DB
+// credentials, keeping any BeforeConnect already set. With Watch running,
+// connections opened after a rotation use the new password.
+func (s *Store) ConfigurePgxPool(cfg *pgxpool.Config) {
+	hook := PgxBeforeConnect(s.DBCredentials())
+	previous := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if previous != nil {
+			if err := previous(ctx, cc); err != nil {
+				return err
+			}
+		}
+		return hook(ctx, cc)
+	}
+}
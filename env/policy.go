@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+var ErrPolicyViolation = errors.New("config violates policy")
+
+// Policy is a set of Rego rules in package gosops that a decrypted config
+// must pass before it is accepted. Rules see the file and its values:
+//
+//	package gosops
+//
+//	deny contains msg if {
+//		input.values.ENVIRONMENT == "production"
+//		input.values.DEBUG != "false"
+//		msg := "DEBUG must be false in production"
+//	}
+//
+// deny rules fail the load, warn rules are logged. A rule may yield a
+// message or an object like {"msg": "...", "key": "DB_HOST"}. A boolean
+// rule, like deny if { ... }, counts as one violation when it is true.
+type Policy struct {
+	query rego.PreparedEvalQuery
+}
+
+// PolicyViolation is one message of a deny or warn rule. Config values in
+// the message are masked.
+type PolicyViolation struct {
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists the deny messages of a rejected config.
+type PolicyError struct {
+	File       string
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Key != "" {
+			messages = append(messages, v.Key+": "+v.Message)
+		} else {
+			messages = append(messages, v.Message)
+		}
+	}
+	return fmt.Sprintf("%s violates policy: %s", e.File, strings.Join(messages, "; "))
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// LoadPolicy reads .rego files and data documents from files or
+// directories, or an OPA bundle from a .tar.gz.
+func LoadPolicy(ctx context.Context, paths ...string) (*Policy, error) {
+	var options []func(*rego.Rego)
+	var files []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".tar.gz") {
+			options = append(options, rego.LoadBundle(path))
+		} else {
+			files = append(files, path)
+		}
+	}
+	if len(files) > 0 {
+		options = append(options, rego.Load(files, nil))
+	}
+	return preparePolicy(ctx, options...)
+}
+
+// NewPolicy compiles one Rego module given as source.
+func NewPolicy(ctx context.Context, name, source string) (*Policy, error) {
+	return preparePolicy(ctx, rego.Module(name, source))
+}
+
+func preparePolicy(ctx context.Context, options ...func(*rego.Rego)) (*Policy, error) {
+	options = append(options, rego.Query("data.gosops"))
+	query, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile policy: %w", err)
+	}
+	return &Policy{query: query}, nil
+}
+
+// WithPolicy rejects configs that policy denies, with a *PolicyError.
+func WithPolicy(policy *Policy) Option {
+	return func(o *loadOptions) {
+		o.policy = policy
+	}
+}
+
+// Evaluate runs the policy against envMap and returns the deny and warn
+// messages.
+func (p *Policy) Evaluate(ctx context.Context, filename string, envMap map[string]string) (deny, warn []PolicyViolation, err error) {
+	values := make(map[string]any, len(envMap))
+	for key, value := range envMap {
+		values[key] = value
+	}
+	results, err := p.query.Eval(ctx, rego.EvalInput(map[string]any{"file": filename, "values": values}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to evaluate policy: %w", err)
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return nil, nil, nil
+	}
+	document, _ := results[0].Expressions[0].Value.(map[string]any)
+	return policyViolations("deny", document["deny"], envMap), policyViolations("warn", document["warn"], envMap), nil
+}
+
+func applyPolicy(ctx context.Context, filename string, envMap map[string]string, options *loadOptions) error {
+	if options.policy == nil {
+		return nil
+	}
+	deny, warn, err := options.policy.Evaluate(ctx, filename, envMap)
+	if err != nil {
+		return err
+	}
+	for _, v := range warn {
+		slog.Warn("config policy warning", "file", filename, "variable", v.Key, "message", v.Message)
+	}
+	if len(deny) > 0 {
+		return &PolicyError{File: filename, Violations: deny}
+	}
+	return nil
+}
+
+// policyViolations reads the set of a deny or warn rule, or its value if
+// it is a boolean rule.
+func policyViolations(name string, rule any, envMap map[string]string) []PolicyViolation {
+	if matched, ok := rule.(bool); ok {
+		if !matched {
+			return nil
+		}
+		return []PolicyViolation{{Message: "policy rule " + name + " is true"}}
+	}
+	items, _ := rule.([]any)
+	var violations []PolicyViolation
+	for _, item := range items {
+		var v PolicyViolation
+		switch item := item.(type) {
+		case string:
+			v.Message = item
+		case map[string]any:
+			v.Message, _ = item["msg"].(string)
+			v.Key, _ = item["key"].(string)
+		default:
+			v.Message = fmt.Sprint(item)
+		}
+		v.Message = maskValues(v.Message, envMap)
+		violations = append(violations, v)
+	}
+	slices.SortFunc(violations, func(a, b PolicyViolation) int {
+		return strings.Compare(a.Key+"\x00"+a.Message, b.Key+"\x00"+b.Message)
+	})
+	return violations
+}
+
+// maskValues masks the secret values of envMap that a message quotes, in
+// case a rule interpolates one.
+func maskValues(message string, envMap map[string]string) string {
+	for key, value := range envMap {
+		if len(value) >= 4 && DefaultMaskPolicy.IsSecretValue(key, value) && strings.Contains(message, value) {
+			message = strings.ReplaceAll(message, value, DefaultMaskPolicy.MaskValue(key, value))
+		}
+	}
+	return message
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	production := map[string]string{"ENVIRONMENT": "production", "DEBUG": "true", "DB_PASSWORD": "hunter2-secret"}
+	tests := []struct {
+		name     string
+		source   string
+		values   map[string]string
+		wantDeny []PolicyViolation
+		wantWarn []PolicyViolation
+	}{
+		{
+			name: "set rules",
+			source: `package gosops
+deny contains {"msg": "DEBUG must be false in production", "key": "DEBUG"} if {
+	input.values.ENVIRONMENT == "production"
+	input.values.DEBUG != "false"
+}
+warn contains msg if {
+	msg := sprintf("password %s is short", [input.values.DB_PASSWORD])
+}`,
+			values:   production,
+			wantDeny: []PolicyViolation{{Key: "DEBUG", Message: "DEBUG must be false in production"}},
+			wantWarn: []PolicyViolation{{Message: "password " + DefaultMaskPolicy.MaskValue("DB_PASSWORD", "hunter2-secret") + " is short"}},
+		},
+		{
+			name: "boolean deny",
+			source: `package gosops
+deny if {
+	input.values.DEBUG == "true"
+}`,
+			values:   production,
+			wantDeny: []PolicyViolation{{Message: "policy rule deny is true"}},
+		},
+		{
+			name: "boolean warn not matched",
+			source: `package gosops
+default warn := false
+warn if {
+	input.values.DEBUG == "false"
+}`,
+			values: production,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(context.Background(), "policy.rego", tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deny, warn, err := policy.Evaluate(context.Background(), "config.sops.env", tt.values)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if !reflect.DeepEqual(deny, tt.wantDeny) {
+				t.Errorf("deny = %v, want %v", deny, tt.wantDeny)
+			}
+			if !reflect.DeepEqual(warn, tt.wantWarn) {
+				t.Errorf("warn = %v, want %v", warn, tt.wantWarn)
+			}
+		})
+	}
+}
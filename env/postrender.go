@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholderPattern matches ${sops:KEY} and ${sops:KEY|base64}, the latter
+// for the data field of a Secret.
+var placeholderPattern = regexp.MustCompile(`\$\{sops:([A-Za-z_][A-Za-z0-9_.]*)(\|base64)?\}`)
+
+// runHelmPostRender is a Helm post-renderer: it reads rendered manifests
+// on stdin, fills the placeholders from the encrypted file and writes them
+// to stdout. Pass flags with --post-renderer-args.
+func runHelmPostRender(args []string) error {
+	fs := flag.NewFlagSet("helm-postrender", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file with the values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envMap, err := readSOPSEnvMap(context.Background(), *filename, newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+	return postRender(os.Stdin, os.Stdout, envMap)
+}
+
+// postRender substitutes placeholders in string scalars only, so a value
+// containing quotes, colons or newlines can't change the manifest's
+// structure.
+func postRender(r io.Reader, w io.Writer, envMap map[string]string) error {
+	dec := yaml.NewDecoder(r)
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+
+	missing := make(map[string]struct{})
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse manifests: %w", err)
+		}
+		substitutePlaceholders(&doc, envMap, missing)
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		keys := make([]string, 0, len(missing))
+		for key := range missing {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("placeholders reference keys missing from the encrypted file: %v", keys)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func substitutePlaceholders(node *yaml.Node, envMap map[string]string, missing map[string]struct{}) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		replaced := placeholderPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			groups := placeholderPattern.FindStringSubmatch(match)
+			value, ok := envMap[groups[1]]
+			if !ok {
+				missing[groups[1]] = struct{}{}
+				return match
+			}
+			if groups[2] != "" {
+				return base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			return value
+		})
+		if replaced != node.Value {
+			node.Value = replaced
+			// Keep the result a string even if the value looks like a
+			// number or boolean.
+			node.Style &^= yaml.TaggedStyle
+			if node.Style == 0 {
+				node.Style = yaml.DoubleQuotedStyle
+			}
+		}
+	}
+	for _, child := range node.Content {
+		substitutePlaceholders(child, envMap, missing)
+	}
+}
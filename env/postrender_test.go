@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const renderedManifests = `apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+data:
+  password: ${sops:DB_PASSWORD|base64}
+---
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - env:
+            - name: PORT
+              value: ${sops:PORT}
+            - name: DSN
+              value: postgres://app:${sops:DB_PASSWORD}@db
+`
+
+func TestHelmPostRender(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	// A value that would break the YAML if it were pasted in as text.
+	os.WriteFile(filename, []byte("DB_PASSWORD='p: \"x\"\\nkind: Evil'\nPORT=8080\n"), 0o600)
+	withStdin(t, renderedManifests)
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = runHelmPostRender([]string{"-f", filename}) })
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	var secret struct {
+		Data map[string]string `yaml:"data"`
+	}
+	var deployment struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Env []struct {
+							Name  string `yaml:"name"`
+							Value any    `yaml:"value"`
+						} `yaml:"env"`
+					} `yaml:"containers"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	}
+	if err := dec.Decode(&secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&deployment); err != nil {
+		t.Fatalf("second manifest: %v\n%s", err, out)
+	}
+
+	if got := secret.Data["password"]; got != "cDogIngiXG5raW5kOiBFdmls" {
+		t.Errorf("data.password = %q, want the base64 of the value", got)
+	}
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	if env[0].Value != "8080" {
+		t.Errorf("PORT = %#v, want the string \"8080\"", env[0].Value)
+	}
+	if env[1].Value != `postgres://app:p: "x"\nkind: Evil@db` {
+		t.Errorf("DSN = %#v", env[1].Value)
+	}
+}
+
+func TestPostRenderMissingKeys(t *testing.T) {
+	var out bytes.Buffer
+	err := postRender(strings.NewReader(renderedManifests), &out, map[string]string{"PORT": "1"})
+	if err == nil || !strings.Contains(err.Error(), "DB_PASSWORD") {
+		t.Errorf("error = %v, want DB_PASSWORD reported missing", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("partial output written:\n%s", out.String())
+	}
+}
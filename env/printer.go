@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Printer lays out the output of FprintConfig and FprintSystemEnvVars.
+// Titles and sections arrive with their emoji, like "🔴 Redis
+// Configuration", and values already masked.
+type Printer interface {
+	Title(w io.Writer, title string)
+	Section(w io.Writer, title string)
+	Value(w io.Writer, key, value string)
+}
+
+var (
+	// EmojiPrinter is the default, the output of the demo.
+	EmojiPrinter Printer = emojiPrinter{}
+	// PlainPrinter drops the emoji, for logs and terminals without them.
+	PlainPrinter Printer = plainPrinter{}
+	// ColorPrinter is PlainPrinter with ANSI colors.
+	ColorPrinter Printer = colorPrinter{}
+	// QuietPrinter prints KEY=value lines only, for scripts.
+	QuietPrinter Printer = quietPrinter{}
+)
+
+type emojiPrinter struct{}
+
+func (emojiPrinter) Title(w io.Writer, title string) {
+	// The emoji is two columns wide.
+	fmt.Fprintf(w, "%s\n%s\n", title, strings.Repeat("=", utf8.RuneCountInString(title)+1))
+}
+
+func (emojiPrinter) Section(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+}
+
+func (emojiPrinter) Value(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "  %s: %s\n", key, value)
+}
+
+type plainPrinter struct{}
+
+func (plainPrinter) Title(w io.Writer, title string) {
+	title = stripEmoji(title)
+	fmt.Fprintf(w, "%s\n%s\n", title, strings.Repeat("=", utf8.RuneCountInString(title)))
+}
+
+func (plainPrinter) Section(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n%s:\n", stripEmoji(title))
+}
+
+func (plainPrinter) Value(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "  %s: %s\n", key, value)
+}
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiBlue  = "\x1b[34m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+type colorPrinter struct{}
+
+func (colorPrinter) Title(w io.Writer, title string) {
+	fmt.Fprintf(w, "%s%s%s\n", ansiBold, stripEmoji(title), ansiReset)
+}
+
+func (colorPrinter) Section(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n%s%s%s:%s\n", ansiBold, ansiBlue, stripEmoji(title), ansiReset)
+}
+
+func (colorPrinter) Value(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "  %s%s%s: %s\n", ansiCyan, key, ansiReset, value)
+}
+
+type quietPrinter struct{}
+
+func (quietPrinter) Title(io.Writer, string)   {}
+func (quietPrinter) Section(io.Writer, string) {}
+
+func (quietPrinter) Value(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "%s=%s\n", key, value)
+}
+
+// stripEmoji drops the symbols and spaces before the first letter.
+func stripEmoji(title string) string {
+	return strings.TrimLeftFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// PrintSystemEnvVars prints the EnvConfig keys set in the process
+// environment to stdout with EmojiPrinter.
+func PrintSystemEnvVars(policy *MaskPolicy) {
+	FprintSystemEnvVars(os.Stdout, EmojiPrinter, policy)
+}
+
+// FprintSystemEnvVars writes the EnvConfig keys set in the process
+// environment to w, masked by policy.
+func FprintSystemEnvVars(w io.Writer, printer Printer, policy *MaskPolicy) {
+	printer.Title(w, "🌍 Environment Variables (loaded into system):")
+	for _, key := range envConfigKeys {
+		if value := os.Getenv(key); value != "" {
+			printer.Value(w, key, policy.MaskValue(key, value))
+		}
+	}
+}
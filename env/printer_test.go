@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrinters(t *testing.T) {
+	config := &EnvConfig{DBHost: "db.internal", DBName: "app", DBUser: "app", JWTSecret: "jwt-signing-secret"}
+	tests := []struct {
+		name    string
+		printer Printer
+		want    string
+	}{
+		{"emoji", EmojiPrinter, "🔓 Successfully loaded and decrypted environment configuration:\n" +
+			strings.Repeat("=", 63) + "\n\n" +
+			"📊 Database Configuration:\n  DB_HOST: db.internal\n"},
+		{"plain", PlainPrinter, "Successfully loaded and decrypted environment configuration:\n" +
+			strings.Repeat("=", 60) + "\n\n" +
+			"Database Configuration:\n  DB_HOST: db.internal\n"},
+		{"color", ColorPrinter, "\x1b[1mSuccessfully loaded and decrypted environment configuration:\x1b[0m\n\n" +
+			"\x1b[1m\x1b[34mDatabase Configuration:\x1b[0m\n  \x1b[36mDB_HOST\x1b[0m: db.internal\n"},
+		{"quiet", QuietPrinter, "DB_HOST=db.internal\nDB_NAME=app\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			FprintConfig(&out, tt.printer, config, nil)
+			if !bytes.HasPrefix(out.Bytes(), []byte(tt.want)) {
+				t.Errorf("output starts %q, want %q", out.String()[:min(out.Len(), len(tt.want)+20)], tt.want)
+			}
+			if bytes.Contains(out.Bytes(), []byte("jwt-signing-secret")) {
+				t.Errorf("output is not masked:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestFprintSystemEnvVars(t *testing.T) {
+	unsetForTest(t, envConfigKeys...)
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_PASSWORD", "hunter22")
+	t.Setenv("UNRELATED", "x")
+
+	var out bytes.Buffer
+	FprintSystemEnvVars(&out, QuietPrinter, nil)
+	if want := "DB_HOST=db.internal\nDB_PASSWORD=hu****22\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+var signalsByName = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"KILL": os.Kill,
+}
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,102 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sleepingSOPS is a sops that starts a helper, as gpg-agent would be, and
+// hangs. With ignoreTerm both ignore SIGTERM.
+func sleepingSOPS(t *testing.T, ignoreTerm bool) (filename, pidFile string) {
+	t.Helper()
+	script := "sleep 30 &\necho $! > \"$FAKE_SOPS_PIDFILE\"\nwait\n"
+	if ignoreTerm {
+		script = "trap '' TERM\n" + script
+	}
+	installSOPSScript(t, script)
+
+	dir := t.TempDir()
+	pidFile = filepath.Join(dir, "helper.pid")
+	t.Setenv("FAKE_SOPS_PIDFILE", pidFile)
+	filename = filepath.Join(dir, "config.sops.env")
+	if err := os.WriteFile(filename, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename, pidFile
+}
+
+// assertHelperGone checks that the process in pidFile has exited. A zombie
+// left for an init that doesn't reap counts as gone.
+func assertHelperGone(t *testing.T, pidFile string) {
+	t.Helper()
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("helper never started: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if syscall.Kill(pid, 0) != nil {
+			return
+		}
+		if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+			if fields := strings.Fields(string(stat)); len(fields) > 2 && fields[2] == "Z" {
+				return
+			}
+		}
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	t.Errorf("helper process %d outlived sops", pid)
+}
+
+func TestTimeoutStopsProcessGroup(t *testing.T) {
+	filename, pidFile := sleepingSOPS(t, false)
+
+	start := time.Now()
+	_, err := LoadSOPSEnv(filename, WithTimeout(200*time.Millisecond), WithKillGrace(5*time.Second))
+	if !errors.Is(err, ErrDecryptTimeout) {
+		t.Fatalf("LoadSOPSEnv() error = %v, want ErrDecryptTimeout", err)
+	}
+	// SIGTERM is enough here, so the grace period is not waited out.
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("load took %v after a 200ms timeout", elapsed)
+	}
+	assertHelperGone(t, pidFile)
+}
+
+func TestKillAfterGrace(t *testing.T) {
+	filename, pidFile := sleepingSOPS(t, true)
+
+	start := time.Now()
+	_, err := LoadSOPSEnv(filename, WithTimeout(100*time.Millisecond), WithKillGrace(300*time.Millisecond))
+	if !errors.Is(err, ErrDecryptTimeout) {
+		t.Fatalf("LoadSOPSEnv() error = %v, want ErrDecryptTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("load took %v, want the timeout plus the grace period", elapsed)
+	}
+	assertHelperGone(t, pidFile)
+}
+
+func TestCancelStopsSOPS(t *testing.T) {
+	filename, pidFile := sleepingSOPS(t, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err := LoadSOPSEnvContext(ctx, filename)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrDecryptTimeout) {
+		t.Fatalf("LoadSOPSEnvContext() error = %v, want context.Canceled", err)
+	}
+	assertHelperGone(t, pidFile)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyProvenance is who owns a key and when it was last rotated, kept in
+// a plaintext file next to the encrypted one (see ProvenanceFile):
+//
+//	STRIPE_SECRET_KEY:
+//	  owner: payments-team
+//	  contact: "#payments-oncall"
+//	  rotated: 2025-09-01
+//	  runbook: https://wiki.example.com/runbooks/stripe-keys
+//
+// It must not hold anything secret, since it is not encrypted.
+type KeyProvenance struct {
+	Owner   string `yaml:"owner" json:"owner,omitempty"`
+	Contact string `yaml:"contact" json:"contact,omitempty"`
+	Rotated string `yaml:"rotated" json:"rotated,omitempty"`
+	Runbook string `yaml:"runbook" json:"runbook,omitempty"`
+}
+
+// RotatedAt parses Rotated, a date like 2025-09-01 or an RFC 3339 time.
+func (p KeyProvenance) RotatedAt() (time.Time, bool) {
+	if t, err := parseExpiry(p.Rotated); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Provenance maps keys to their KeyProvenance.
+type Provenance map[string]KeyProvenance
+
+// ProvenanceFile is where the provenance of filename lives: config.sops.env
+// becomes config.meta.yaml. The name leaves out .sops, since tools pick
+// encrypted files by the *.sops.* convention and this one is plaintext.
+func ProvenanceFile(filename string) string {
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+	return strings.TrimSuffix(stem, ".sops") + ".meta.yaml"
+}
+
+// LoadProvenance reads a provenance file.
+func LoadProvenance(path string) (Provenance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var provenance Provenance
+	if err := yaml.Unmarshal(data, &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for key, p := range provenance {
+		if p.Rotated != "" {
+			if _, ok := p.RotatedAt(); !ok {
+				return nil, fmt.Errorf("%s: %s: rotated %q is not a date like 2025-09-01", path, key, p.Rotated)
+			}
+		}
+	}
+	return provenance, nil
+}
+
+// loadProvenanceFor reads the provenance next to filename if there is one.
+// The metadata is informational, so a broken file is logged rather than
+// failing the load.
+func loadProvenanceFor(filename string) Provenance {
+	path := ProvenanceFile(filename)
+	provenance, err := LoadProvenance(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		slog.Warn("ignoring key provenance", "file", path, "error", err)
+		return nil
+	}
+	return provenance
+}
+
+func (p Provenance) owner(key string) string {
+	return p[key].Owner
+}
+
+// Provenance returns the owner and rotation metadata of key, from the
+// ProvenanceFile next to the loaded file.
+func (c *EnvConfig) Provenance(key string) (KeyProvenance, bool) {
+	p, ok := c.provenance[key]
+	return p, ok
+}
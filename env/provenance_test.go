@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProvenanceFile(t *testing.T) {
+	tests := []struct {
+		filename, want string
+	}{
+		{"config.sops.env", "config.meta.yaml"},
+		{"deploy/prod.sops.yaml", "deploy/prod.meta.yaml"},
+		{"secrets.env", "secrets.meta.yaml"},
+	}
+	for _, tt := range tests {
+		got := ProvenanceFile(tt.filename)
+		if got != tt.want {
+			t.Errorf("ProvenanceFile(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+		// The provenance is plaintext, so nothing that looks for encrypted
+		// files may pick it up.
+		if sopsFileName(got) {
+			t.Errorf("sopsFileName(%q) = true for a provenance file", got)
+		}
+		if ok, _ := filepath.Match("*.sops.*", filepath.Base(got)); ok {
+			t.Errorf("%q matches *.sops.*", got)
+		}
+	}
+}
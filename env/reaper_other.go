@@ -0,0 +1,31 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// reaper is only needed on unix, where the wrapper may run as PID 1.
+type reaper struct{}
+
+func newReaper() *reaper { return nil }
+
+func (r *reaper) start(cmd *exec.Cmd, child *childProcess) error {
+	return cmd.Start()
+}
+
+func startWaited(cmd *exec.Cmd) (done func(), err error) {
+	return func() {}, cmd.Start()
+}
+
+var ignoredSignals = map[os.Signal]bool{}
+
+var terminatingSignals = map[os.Signal]bool{
+	os.Interrupt: true,
+}
+
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
@@ -0,0 +1,138 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// reaper collects every exited process when we run as PID 1, where orphans
+// of the child are re-parented to us and would otherwise linger as
+// zombies. It also collects our own child, so cmd.Wait must not be used,
+// except for commands started with startWaited, such as sops on reload.
+type reaper struct {
+	mu       sync.Mutex
+	children map[int]*childProcess
+	// waited counts the commands whose cmd.Wait is pending.
+	waited int
+}
+
+// activeReaper is the reaper of this process, if it runs as PID 1.
+var activeReaper atomic.Pointer[reaper]
+
+// newReaper returns nil unless this process is PID 1.
+func newReaper() *reaper {
+	if os.Getpid() != 1 {
+		return nil
+	}
+	r := &reaper{children: make(map[int]*childProcess)}
+	activeReaper.Store(r)
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+	go func() {
+		for range sigchld {
+			r.reap()
+		}
+	}()
+	return r
+}
+
+// start registers the child under the same lock reap takes, so a child
+// that exits immediately is never reaped before it is known.
+func (r *reaper) start(cmd *exec.Cmd, child *childProcess) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	r.children[cmd.Process.Pid] = child
+	return nil
+}
+
+func (r *reaper) reap() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waited > 0 {
+		// Waiting for any pid could take a process whose cmd.Wait is
+		// pending and fail it with ECHILD, so collect only our children by
+		// pid. Orphans are collected once the last waited command exits.
+		for pid, child := range r.children {
+			var status syscall.WaitStatus
+			if waited, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && waited == pid {
+				delete(r.children, pid)
+				child.exited(waitStatusCode(status), nil)
+			}
+		}
+		return
+	}
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return
+		}
+		if child, ok := r.children[pid]; ok {
+			delete(r.children, pid)
+			child.exited(waitStatusCode(status), nil)
+		}
+	}
+}
+
+// startWaited starts a command the caller collects with cmd.Wait. While it
+// runs, the reaper of a PID 1 process leaves it alone; done must be called
+// after cmd.Wait returns.
+func startWaited(cmd *exec.Cmd) (done func(), err error) {
+	r := activeReaper.Load()
+	if r == nil {
+		return func() {}, cmd.Start()
+	}
+	// Under r.mu, so reap can't run between the start and the count.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	r.waited++
+	return func() {
+		r.mu.Lock()
+		r.waited--
+		r.mu.Unlock()
+		r.reap()
+	}, nil
+}
+
+var ignoredSignals = map[os.Signal]bool{
+	syscall.SIGCHLD: true,
+	syscall.SIGURG:  true, // used by the Go runtime for preemption
+	syscall.SIGPIPE: true,
+}
+
+var terminatingSignals = map[os.Signal]bool{
+	syscall.SIGTERM: true,
+	syscall.SIGINT:  true,
+	syscall.SIGQUIT: true,
+}
+
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok {
+		return waitStatusCode(status)
+	}
+	return state.ExitCode()
+}
+
+// waitStatusCode follows the shell convention of 128+n for a child killed
+// by signal n.
+func waitStatusCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
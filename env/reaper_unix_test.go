@@ -0,0 +1,33 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestReaperLeavesWaitedCommands(t *testing.T) {
+	r := &reaper{children: make(map[int]*childProcess)}
+	activeReaper.Store(r)
+	defer activeReaper.Store(nil)
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	done, err := startWaited(cmd)
+	if err != nil {
+		t.Skipf("sh: %v", err)
+	}
+	// Reap as a SIGCHLD would once the command has exited; without the
+	// waited count this takes the process and cmd.Wait fails with ECHILD.
+	time.Sleep(100 * time.Millisecond)
+	r.reap()
+	err = cmd.Wait()
+	done()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("cmd.Wait() error = %v, want exit status 3", err)
+	}
+	if r.waited != 0 {
+		t.Errorf("waited = %d after done, want 0", r.waited)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Values shorter than this are not redacted by value, otherwise a secret like
+// "1" would wipe every digit from the logs. Such keys are still caught by name.
+const minRedactLength = 4
+
+type Redactor struct {
+	policy   *MaskPolicy
+	mu       sync.RWMutex
+	secrets  map[string]struct{}
+	replacer *strings.Replacer
+	canaries map[string]string
+	onCanary func(CanaryEvent)
+}
+
+var DefaultRedactor = NewRedactor(DefaultMaskPolicy)
+
+func NewRedactor(policy *MaskPolicy) *Redactor {
+	return &Redactor{policy: policy, secrets: make(map[string]struct{})}
+}
+
+func (r *Redactor) isSecretKey(key string) bool {
+	return r.policy.IsSecret(key)
+}
+
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for _, value := range values {
+		if len(value) < minRedactLength {
+			continue
+		}
+		if _, ok := r.secrets[value]; !ok {
+			r.secrets[value] = struct{}{}
+			changed = true
+		}
+	}
+	if changed {
+		r.rebuild()
+	}
+}
+
+func (r *Redactor) AddEnv(envMap map[string]string) {
+	var values []string
+	for key, value := range envMap {
+		if r.policy.IsSecretValue(key, value) {
+			values = append(values, value)
+		}
+	}
+	r.Add(values...)
+}
+
+func (r *Redactor) rebuild() {
+	values := make([]string, 0, len(r.secrets))
+	for value := range r.secrets {
+		values = append(values, value)
+	}
+	// Longest first, so a secret containing another secret is replaced whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		pairs = append(pairs, value, redactedValue)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	replacer := r.replacer
+	hasCanaries := len(r.canaries) > 0
+	r.mu.RUnlock()
+
+	if replacer == nil {
+		return s
+	}
+	if hasCanaries {
+		r.checkCanaries(s)
+	}
+	return replacer.Replace(s)
+}
+
+// redactAny redacts strings nested in maps and slices as produced by log
+// encoders, falling back to the formatted value for anything else.
+func (r *Redactor) redactAny(v any) (any, bool) {
+	switch v := v.(type) {
+	case nil:
+		return v, false
+	case string:
+		redacted := r.Redact(v)
+		return redacted, redacted != v
+	case error:
+		redacted := r.Redact(v.Error())
+		return redacted, redacted != v.Error()
+	case map[string]any:
+		changed := false
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if r.isSecretKey(key) {
+				out[key] = redactedValue
+				changed = true
+				continue
+			}
+			redacted, c := r.redactAny(value)
+			out[key] = redacted
+			changed = changed || c
+		}
+		return out, changed
+	case []any:
+		changed := false
+		out := make([]any, len(v))
+		for i, value := range v {
+			redacted, c := r.redactAny(value)
+			out[i] = redacted
+			changed = changed || c
+		}
+		return out, changed
+	default:
+		formatted := fmt.Sprintf("%+v", v)
+		redacted := r.Redact(formatted)
+		if redacted != formatted {
+			return redacted, true
+		}
+		return v, false
+	}
+}
+
+func (r *Redactor) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if r.isSecretKey(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, redactedValue)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Redact(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = r.redactAttr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		if redacted, changed := r.redactAny(a.Value.Any()); changed {
+			return slog.Any(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+type RedactingHandler struct {
+	inner    slog.Handler
+	redactor *Redactor
+}
+
+func NewRedactingHandler(inner slog.Handler, redactor *Redactor) *RedactingHandler {
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
+	return &RedactingHandler{inner: inner, redactor: redactor}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, redacted)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactor.redactAttr(attr)
+	}
+	return &RedactingHandler{inner: h.inner.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{inner: h.inner.WithGroup(name), redactor: h.redactor}
+}
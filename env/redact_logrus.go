@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+type RedactingLogrusHook struct {
+	redactor *Redactor
+}
+
+func NewRedactingLogrusHook(redactor *Redactor) *RedactingLogrusHook {
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
+	return &RedactingLogrusHook{redactor: redactor}
+}
+
+func (h *RedactingLogrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *RedactingLogrusHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.redactor.Redact(entry.Message)
+
+	for key, value := range entry.Data {
+		if h.redactor.isSecretKey(key) {
+			entry.Data[key] = redactedValue
+			continue
+		}
+		if redacted, changed := h.redactor.redactAny(value); changed {
+			entry.Data[key] = redacted
+		}
+	}
+	return nil
+}
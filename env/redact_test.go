@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	redactor := NewRedactor(DefaultMaskPolicy)
+	redactor.AddEnv(map[string]string{"DB_PASSWORD": "hunter22", "DB_HOST": "db.internal", "PIN": "1"})
+
+	var out bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&out, nil), redactor)).With("dsn", "postgres://app:hunter22@db")
+	logger.Info("connecting with hunter22",
+		"host", "db.internal",
+		"api_token", "whatever",
+		"err", errors.New("auth failed for hunter22"),
+		slog.Group("db", "password", "x", "attempt", 2),
+		"extra", map[string]any{"nested": []any{"hunter22"}},
+	)
+
+	line := out.String()
+	if strings.Contains(line, "hunter22") {
+		t.Errorf("log line leaks the password: %s", line)
+	}
+	for _, want := range []string{"host=db.internal", "api_token=[REDACTED]", "db.password=[REDACTED]", "db.attempt=2", "connecting with [REDACTED]"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line lacks %q: %s", want, line)
+		}
+	}
+}
+
+func TestRedactorSkipsShortValues(t *testing.T) {
+	redactor := NewRedactor(DefaultMaskPolicy)
+	redactor.Add("1", "abc", "longer-secret")
+	if got := redactor.Redact("port 1 abc longer-secret"); got != "port 1 abc [REDACTED]" {
+		t.Errorf("Redact() = %q", got)
+	}
+	// A secret containing another is replaced whole.
+	redactor.Add("longer-secret-2")
+	if got := redactor.Redact("longer-secret-2"); got != "[REDACTED]" {
+		t.Errorf("Redact() = %q, want one replacement", got)
+	}
+}
+
+func TestRedactingZapCore(t *testing.T) {
+	redactor := NewRedactor(DefaultMaskPolicy)
+	redactor.Add("hunter22")
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewRedactingZapCore(core, redactor)).With(zap.String("dsn", "app:hunter22@db"))
+	logger.Info("login hunter22",
+		zap.String("user", "app"),
+		zap.String("client_secret", "other"),
+		zap.Error(errors.New("bad password hunter22")),
+		zap.Reflect("cfg", map[string]string{"pw": "hunter22"}),
+	)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.Message != "login [REDACTED]" {
+		t.Errorf("message = %q", entry.Message)
+	}
+	fields := entry.ContextMap()
+	for key, want := range map[string]string{
+		"dsn":           "app:[REDACTED]@db",
+		"user":          "app",
+		"client_secret": "[REDACTED]",
+		"error":         "bad password [REDACTED]",
+	} {
+		if fields[key] != want {
+			t.Errorf("field %s = %v, want %q", key, fields[key], want)
+		}
+	}
+	if cfg, _ := fields["cfg"].(string); strings.Contains(cfg, "hunter22") || cfg == "" {
+		t.Errorf("cfg field = %v, want the redacted form", fields["cfg"])
+	}
+}
+
+func TestRedactingLogrusHook(t *testing.T) {
+	redactor := NewRedactor(DefaultMaskPolicy)
+	redactor.Add("hunter22")
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableQuote: true})
+	logger.AddHook(NewRedactingLogrusHook(redactor))
+	logger.WithFields(logrus.Fields{
+		"user":     "app",
+		"password": "other",
+		"dsn":      "app:hunter22@db",
+		"extra":    []any{"hunter22"},
+	}).Info("login hunter22")
+
+	line := out.String()
+	if strings.Contains(line, "hunter22") || strings.Contains(line, "other") {
+		t.Errorf("log line leaks a secret: %s", line)
+	}
+	for _, want := range []string{"msg=login [REDACTED]", "user=app", "password=[REDACTED]", "dsn=app:[REDACTED]@db"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line lacks %q: %s", want, line)
+		}
+	}
+}
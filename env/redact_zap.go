@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type redactingCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func NewRedactingZapCore(core zapcore.Core, redactor *Redactor) zapcore.Core {
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
+	return &redactingCore{Core: core, redactor: redactor}
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.Redact(entry.Message)
+	entry.Stack = c.redactor.Redact(entry.Stack)
+	return c.Core.Write(entry, c.redactFields(fields))
+}
+
+func (c *redactingCore) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = c.redactField(field)
+	}
+	return redacted
+}
+
+func (c *redactingCore) redactField(field zapcore.Field) zapcore.Field {
+	if field.Type == zapcore.NamespaceType || field.Type == zapcore.SkipType {
+		return field
+	}
+	if c.redactor.isSecretKey(field.Key) {
+		return zap.String(field.Key, redactedValue)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = c.redactor.Redact(field.String)
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			if msg := c.redactor.Redact(err.Error()); msg != err.Error() {
+				return zap.String(field.Key, msg)
+			}
+		}
+	case zapcore.StringerType:
+		if s, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, c.redactor.Redact(s.String()))
+		}
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+		if m, ok := field.Interface.(zapcore.ObjectMarshaler); ok {
+			enc := zapcore.NewMapObjectEncoder()
+			if err := m.MarshalLogObject(enc); err == nil {
+				if value, changed := c.redactor.redactAny(enc.Fields); changed {
+					return zap.Any(field.Key, value)
+				}
+			}
+		}
+	case zapcore.ReflectType:
+		formatted := fmt.Sprintf("%+v", field.Interface)
+		if redacted := c.redactor.Redact(formatted); redacted != formatted {
+			return zap.String(field.Key, redacted)
+		}
+	}
+	return field
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCredentialsProvider adapts creds to go-redis's
+// CredentialsProviderContext, which is called on every new connection.
+func RedisCredentialsProvider(creds CredentialProvider) func(context.Context) (string, string, error) {
+	return func(ctx context.Context) (string, string, error) {
+		c, err := creds.Credentials(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		return c.Username, c.Password, nil
+	}
+}
+
+// RedisOptions parses REDIS_URL and wires the store in as the credentials
+// provider, so reconnects after a rotation use the new password.
+func (s *Store) RedisOptions() (*redis.Options, error) {
+	config := s.Config()
+	if config == nil {
+		return nil, ErrNoConfig
+	}
+	opts, err := redis.ParseURL(config.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	opts.Username, opts.Password = "", ""
+	opts.CredentialsProviderContext = RedisCredentialsProvider(s.RedisCredentials())
+	return opts, nil
+}
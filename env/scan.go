@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type scanFinding struct {
+	file   string
+	line   int
+	key    string
+	reason string
+}
+
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{".env", "config.env"}
+	}
+
+	var findings []scanFinding
+	for _, filename := range files {
+		found, err := scanEnvFile(filename, DefaultMaskPolicy)
+		if os.IsNotExist(err) && len(fs.Args()) == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("✅ No plaintext secrets found")
+		return nil
+	}
+
+	fmt.Println("🚨 Plaintext secrets found:")
+	for _, f := range findings {
+		fmt.Printf("  %s:%d: %s (%s)\n", f.file, f.line, f.key, f.reason)
+	}
+	return fmt.Errorf("found %d plaintext secret(s), encrypt them with sops", len(findings))
+}
+
+func scanEnvFile(filename string, policy *MaskPolicy) ([]scanFinding, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseDotenv(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var findings []scanFinding
+	for _, e := range entries {
+		if e.Value == "" || strings.HasPrefix(e.Value, "ENC[") || strings.HasPrefix(e.Key, "sops_") {
+			continue
+		}
+		if secret, reason := policy.Classify(e.Key, e.Value); secret {
+			findings = append(findings, scanFinding{file: filename, line: e.Line, key: e.Key, reason: reason})
+		}
+	}
+	return findings, nil
+}
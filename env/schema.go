@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Schema is the full set of keys a program reads, declared in one place so
+// a load can report keys in the file that nothing consumes and declared
+// keys the file lacks. Build it in Go or from a manifest:
+//
+//	var schema = NewSchema("DB_HOST", "DB_PASSWORD").Optional("SENTRY_DSN")
+//	var schema = manifest.Schema()
+type Schema struct {
+	required map[string]bool
+}
+
+// NewSchema declares keys the file must set.
+func NewSchema(keys ...string) *Schema {
+	s := &Schema{required: make(map[string]bool)}
+	for _, key := range keys {
+		s.required[key] = true
+	}
+	return s
+}
+
+// Optional declares keys the program reads but the file may leave out.
+func (s *Schema) Optional(keys ...string) *Schema {
+	for _, key := range keys {
+		if !s.required[key] {
+			s.required[key] = false
+		}
+	}
+	return s
+}
+
+// Schema declares the manifest's keys. Keys with a default, or that are not
+// required, are optional.
+func (m *Manifest) Schema() *Schema {
+	s := NewSchema()
+	for _, k := range m.Keys {
+		if k.Required && k.Default == "" {
+			s.required[k.Name] = true
+		} else {
+			s.Optional(k.Name)
+		}
+	}
+	return s
+}
+
+// EnvConfigSchema declares the EnvConfig fields, all optional.
+func EnvConfigSchema() *Schema {
+	s := NewSchema()
+	for key := range envConfigFields {
+		s.Optional(key)
+	}
+	return s
+}
+
+// SchemaReport compares a file with a Schema.
+type SchemaReport struct {
+	File string `json:"file"`
+	// Unused keys are in the file but not in the schema.
+	Unused []string `json:"unused,omitempty"`
+	// Missing keys are required by the schema but not in the file.
+	Missing []string `json:"missing,omitempty"`
+}
+
+func (r *SchemaReport) OK() bool {
+	return len(r.Unused) == 0 && len(r.Missing) == 0
+}
+
+// WithSchema compares the file with schema on every load and logs unused
+// and missing keys. The load itself doesn't fail, EnvConfig.SchemaReport
+// returns the result; LoadSOPSEnvToSystem only logs it. Expiry metadata of
+// a declared key counts as used.
+func WithSchema(schema *Schema) Option {
+	return func(o *loadOptions) {
+		o.schema = schema
+	}
+}
+
+// Check compares the decrypted keys of filename with the schema.
+func (s *Schema) Check(filename string, envMap map[string]string) *SchemaReport {
+	report := &SchemaReport{File: filename}
+	for key := range envMap {
+		if _, declared := s.required[key]; declared {
+			continue
+		}
+		if secretKey, ok := strings.CutSuffix(key, ExpiresSuffix); ok {
+			if _, declared := s.required[secretKey]; declared {
+				continue
+			}
+		}
+		report.Unused = append(report.Unused, key)
+	}
+	for key, required := range s.required {
+		if _, ok := envMap[key]; required && !ok {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+	slices.Sort(report.Unused)
+	slices.Sort(report.Missing)
+	return report
+}
+
+func applySchema(filename string, envMap map[string]string, options *loadOptions) *SchemaReport {
+	if options.schema == nil {
+		return nil
+	}
+	report := options.schema.Check(filename, envMap)
+	if len(report.Unused) > 0 {
+		slog.Warn("config keys not in schema, remove them if nothing reads them", "file", filename, "keys", report.Unused)
+	}
+	if len(report.Missing) > 0 {
+		slog.Warn("declared config keys missing from file", "file", filename, "keys", report.Missing)
+	}
+	return report
+}
+
+// SchemaReport is the comparison made by WithSchema, or nil without one.
+func (c *EnvConfig) SchemaReport() *SchemaReport {
+	return c.schema
+}
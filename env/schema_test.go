@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestWithSchemaInBothLoaders(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	t.Setenv("GO_SOPS_SCHEMA_TEST", "")
+
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"GO_SOPS_SCHEMA_TEST": "1", "UNUSED_KEY": "x"})
+	schema := NewSchema("GO_SOPS_SCHEMA_TEST", "MISSING_KEY")
+
+	config, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake), WithSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := config.SchemaReport()
+	if report == nil || strings.Join(report.Unused, ",") != "UNUSED_KEY" || strings.Join(report.Missing, ",") != "MISSING_KEY" {
+		t.Errorf("SchemaReport() = %+v, want UNUSED_KEY unused and MISSING_KEY missing", report)
+	}
+
+	logs.Reset()
+	if err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake), WithSchema(schema), WithAllowedKeys("GO_SOPS_SCHEMA_TEST")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"config keys not in schema", "UNUSED_KEY", "declared config keys missing from file", "MISSING_KEY"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("LoadSOPSEnvToSystem logs = %q, want %q", logs.String(), want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// Secret is a string that doesn't print. fmt, slog and encoding/json all
+// see [REDACTED] instead of the value, so logging a whole config struct
+// can't leak it. Call Reveal where the value is actually needed.
+type Secret string
+
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
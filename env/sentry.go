@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// InitSentry initializes the Sentry SDK from SENTRY_DSN, with events tagged
+// by ENVIRONMENT and the release from SENTRY_RELEASE or, failing that, the
+// VCS revision the binary was built from. Messages and exception values
+// pass through DefaultRedactor before they leave the process. customize
+// runs last and may override any of it.
+func (c *EnvConfig) InitSentry(customize ...func(*sentry.ClientOptions)) error {
+	if c.SentryDSN == "" {
+		return fmt.Errorf("sentry: SENTRY_DSN is not set: %w", ErrClientNotConfigured)
+	}
+	DefaultRedactor.AddEnv(c.values)
+
+	options := sentry.ClientOptions{
+		Dsn:         c.SentryDSN,
+		Environment: c.Environment,
+		Release:     c.values["SENTRY_RELEASE"],
+		Debug:       isTrue(c.Debug),
+		BeforeSend:  redactSentryEvent,
+	}
+	if options.Release == "" {
+		options.Release = buildRelease()
+	}
+	for _, fn := range customize {
+		fn(&options)
+	}
+
+	if err := sentry.Init(options); err != nil {
+		return fmt.Errorf("failed to initialize Sentry: %w", err)
+	}
+	return nil
+}
+
+func redactSentryEvent(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	event.Message = DefaultRedactor.Redact(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = DefaultRedactor.Redact(event.Exception[i].Value)
+	}
+	for i := range event.Breadcrumbs {
+		event.Breadcrumbs[i].Message = DefaultRedactor.Redact(event.Breadcrumbs[i].Message)
+	}
+	return event
+}
+
+// buildRelease returns the main module version, or the short VCS revision
+// for development builds.
+func buildRelease() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return info.Main.Path + "@" + v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return s.Value[:12]
+		}
+	}
+	return ""
+}
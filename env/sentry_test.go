@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// recordingTransport keeps the events Sentry would have sent.
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Flush(time.Duration) bool              { return true }
+func (t *recordingTransport) FlushWithContext(context.Context) bool { return true }
+func (t *recordingTransport) Configure(sentry.ClientOptions)        {}
+func (t *recordingTransport) Close()                                {}
+
+func (t *recordingTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestInitSentryRedactsEvents(t *testing.T) {
+	config := &EnvConfig{envState: envState{values: map[string]string{
+		"SENTRY_RELEASE":    "api@1.2.3",
+		"STRIPE_SECRET_KEY": "sk_live_sentry_secret",
+	}}}
+	config.SentryDSN = "https://public@sentry.example.com/1"
+	config.Environment = "staging"
+
+	transport := &recordingTransport{}
+	var options sentry.ClientOptions
+	err := config.InitSentry(func(o *sentry.ClientOptions) {
+		o.Transport = transport
+		options = *o
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sentry.CurrentHub().BindClient(nil) })
+
+	if options.Environment != "staging" || options.Release != "api@1.2.3" {
+		t.Errorf("options = environment %q, release %q", options.Environment, options.Release)
+	}
+
+	sentry.AddBreadcrumb(&sentry.Breadcrumb{Message: "charging with sk_live_sentry_secret"})
+	sentry.CaptureException(errors.New("stripe rejected sk_live_sentry_secret"))
+	sentry.CaptureMessage("key is sk_live_sentry_secret")
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.events) != 2 {
+		t.Fatalf("sent %d events, want 2", len(transport.events))
+	}
+	for _, event := range transport.events {
+		text := event.Message
+		for _, exception := range event.Exception {
+			text += exception.Value
+		}
+		for _, breadcrumb := range event.Breadcrumbs {
+			text += breadcrumb.Message
+		}
+		if strings.Contains(text, "sk_live_sentry_secret") || !strings.Contains(text, redactedValue) {
+			t.Errorf("event text %q was not redacted", text)
+		}
+	}
+}
+
+func TestInitSentryWithoutDSN(t *testing.T) {
+	config := &EnvConfig{}
+	if err := config.InitSentry(); !errors.Is(err, ErrClientNotConfigured) {
+		t.Errorf("InitSentry() = %v, want ErrClientNotConfigured", err)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Serverless cold starts are billed and user-visible, so LoadServerless
+// gives up on a stuck backend much sooner than the defaults.
+const (
+	ServerlessDecryptTimeout = 5 * time.Second
+	ServerlessKillGrace      = 200 * time.Millisecond
+	warmCacheKeyEnv          = "SOPS_WARM_CACHE_KEY"
+)
+
+// LoadServerless is LoadSOPSEnv tuned for cold starts: short timeouts,
+// every key type tried at once, and the startup latency recorded in
+// DefaultMetrics. Options passed in override the defaults.
+func LoadServerless(ctx context.Context, filename string, opts ...Option) (*EnvConfig, error) {
+	start := time.Now()
+	defaults := []Option{
+		WithTimeout(ServerlessDecryptTimeout),
+		WithKillGrace(ServerlessKillGrace),
+		WithParallelUnwrap(),
+	}
+	options := newLoadOptions(append(defaults, opts...))
+	if options.warmCache != nil {
+		options.warmCache.hit.Store(false)
+	}
+
+	config, err := LoadSOPSEnvContext(ctx, filename, append(defaults, opts...)...)
+	source := "sops"
+	if options.warmCache != nil && options.warmCache.hit.Load() {
+		source = "cache"
+	}
+	if err != nil {
+		source = "failed"
+	}
+	DefaultMetrics.observeStartup(filename, source, start)
+	return config, err
+}
+
+// WithParallelUnwrap starts one sops per key type in the file's metadata,
+// each preferring a different one, and keeps the first to succeed. A slow
+// or unreachable KMS then no longer delays a file that age or another
+// region could open.
+func WithParallelUnwrap() Option {
+	return func(o *loadOptions) {
+		o.parallelUnwrap = true
+	}
+}
+
+// WithWarmCache keeps an AES-256-GCM encrypted copy of the plaintext at
+// path, bound to the hash of the encrypted file, and reads it instead of
+// running sops while the file is unchanged. Generate it at build time with
+// `go-sops warm` so even the first start skips sops. key must be 32 bytes
+// and should come from the platform's secret manager, never the image.
+func WithWarmCache(path string, key []byte) Option {
+	cache := &warmCache{path: path, key: key}
+	return func(o *loadOptions) {
+		o.warmCache = cache
+	}
+}
+
+type warmCache struct {
+	path string
+	key  []byte
+	hit  atomic.Bool
+}
+
+func (c *warmCache) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid warm cache key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// read writes the cached plaintext to out if it was made from encrypted.
+func (c *warmCache) read(encrypted []byte, out *bytes.Buffer) bool {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return false
+	}
+	aead, err := c.aead()
+	if err != nil || len(data) < aead.NonceSize() {
+		return false
+	}
+	sum := sha256.Sum256(encrypted)
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, sum[:])
+	if err != nil {
+		return false
+	}
+	out.Write(plaintext)
+	clear(plaintext)
+	c.hit.Store(true)
+	return true
+}
+
+func (c *warmCache) write(encrypted, plaintext []byte) error {
+	aead, err := c.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(encrypted)
+	sealed := aead.Seal(nonce, nonce, plaintext, sum[:])
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".warm-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(sealed)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// decryptWithCache serves filename from the warm cache when possible and
+// refreshes the cache after a real decryption.
+func decryptWithCache(ctx context.Context, filename string, options *loadOptions, out *bytes.Buffer, decrypt func() error) error {
+	encrypted, err := os.ReadFile(filename)
+	if err != nil {
+		options.trace(ctx, "warm cache skipped", "file", filename, "error", err)
+		return decrypt()
+	}
+	if options.warmCache.read(encrypted, out) {
+		options.trace(ctx, "warm cache hit", "file", filename, "cache", options.warmCache.path)
+		return nil
+	}
+	options.trace(ctx, "warm cache miss", "file", filename, "cache", options.warmCache.path)
+	if err := decrypt(); err != nil {
+		return err
+	}
+	if err := options.warmCache.write(encrypted, out.Bytes()); err != nil {
+		slog.Warn("failed to update warm cache", "file", filename, "cache", options.warmCache.path, "error", err)
+	}
+	return nil
+}
+
+// runSOPSParallel races one sops per key type. The losers are cancelled
+// and cleaned up in the background.
+func runSOPSParallel(ctx context.Context, filename string, options *loadOptions, out *bytes.Buffer) error {
+	meta, err := readSOPSMetadata(filename)
+	if err != nil || len(meta.Backends) < 2 {
+		return runSOPS(ctx, options, out, "-d", filename)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	type result struct {
+		buf *bytes.Buffer
+		err error
+	}
+	results := make(chan result, len(meta.Backends))
+	for _, backend := range meta.Backends {
+		attempt := *options
+		attempt.sopsEnv = append(append([]string{}, options.sopsEnv...), "SOPS_DECRYPTION_ORDER="+backend)
+		go func() {
+			buf := getBuffer()
+			err := runSOPS(ctx, &attempt, buf, "-d", filename)
+			results <- result{buf: buf, err: err}
+		}()
+	}
+
+	var errs []error
+	for remaining := len(meta.Backends); remaining > 0; remaining-- {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			putBuffer(r.buf)
+			continue
+		}
+		out.Write(r.buf.Bytes())
+		putBuffer(r.buf)
+		cancel()
+		go func() {
+			for range remaining - 1 {
+				putBuffer((<-results).buf)
+			}
+		}()
+		return nil
+	}
+	cancel()
+	return errs[0]
+}
+
+// runWarm writes the warm cache for a file, e.g. during an image build.
+func runWarm(args []string) error {
+	fs := flag.NewFlagSet("warm", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted file to cache")
+	out := fs.String("o", "config.warm", "cache file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(warmCacheKeyEnv))
+	if err != nil || len(key) != 32 {
+		return errors.New("$" + warmCacheKeyEnv + " must hold a base64-encoded 32-byte key, e.g. from `openssl rand -base64 32`")
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := decryptSOPSFile(context.Background(), *filename, newLoadOptions(nil), buf); err != nil {
+		return err
+	}
+	encrypted, err := os.ReadFile(*filename)
+	if err != nil {
+		return err
+	}
+	cache := &warmCache{path: *out, key: key}
+	if err := cache.write(encrypted, buf.Bytes()); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote warm cache %s for %s\n", *out, *filename)
+	return nil
+}
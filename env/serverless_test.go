@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingSOPS is a fake sops that logs each run to $FAKE_SOPS_LOG. With
+// $FAKE_SOPS_AGE_ONLY set it hangs, like an unreachable KMS, unless
+// SOPS_DECRYPTION_ORDER puts age first.
+func countingSOPS(t *testing.T) (runs func() int) {
+	t.Helper()
+	installSOPSScript(t, `echo "${SOPS_DECRYPTION_ORDER:-default}" >> "$FAKE_SOPS_LOG"
+[ -n "$FAKE_SOPS_AGE_ONLY" ] && [ "$SOPS_DECRYPTION_ORDER" != age ] && exec sleep 30
+for last; do :; done
+sed -n '/^sops_/!p' "$last"
+`)
+	log := filepath.Join(t.TempDir(), "sops.log")
+	t.Setenv("FAKE_SOPS_LOG", log)
+	return func() int {
+		data, _ := os.ReadFile(log)
+		return bytes.Count(data, []byte("\n"))
+	}
+}
+
+const twoBackendFile = "DB_PASSWORD=hunter22\n" +
+	"sops_kms__list_0__map_arn=arn:aws:kms:eu-west-1:1:key/k\n" +
+	"sops_age__list_0__map_recipient=age1x\n" +
+	"sops_mac=ENC[AES256_GCM,data:y]\n"
+
+func TestLoadServerlessWarmCache(t *testing.T) {
+	runs := countingSOPS(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	cache := filepath.Join(dir, "config.warm")
+	os.WriteFile(filename, []byte("DB_PASSWORD=hunter22\n"), 0o600)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	for i := range 2 {
+		config, err := LoadServerless(context.Background(), filename, WithWarmCache(cache, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Get("DB_PASSWORD") != "hunter22" {
+			t.Errorf("load %d: DB_PASSWORD = %q", i, config.Get("DB_PASSWORD"))
+		}
+	}
+	if runs() != 1 {
+		t.Errorf("sops ran %d times, want once before the cache was warm", runs())
+	}
+	if data, _ := os.ReadFile(cache); bytes.Contains(data, []byte("hunter22")) {
+		t.Error("the warm cache holds the plaintext")
+	}
+
+	// A cache made from another version of the file is not used.
+	os.WriteFile(filename, []byte("DB_PASSWORD=rotated\n"), 0o600)
+	config, err := LoadServerless(context.Background(), filename, WithWarmCache(cache, key))
+	if err != nil || config.Get("DB_PASSWORD") != "rotated" || runs() != 2 {
+		t.Errorf("after the file changed: %v, DB_PASSWORD %q, %d runs", err, config.Get("DB_PASSWORD"), runs())
+	}
+	// Nor is one read with the wrong key.
+	if _, err := LoadServerless(context.Background(), filename, WithWarmCache(cache, bytes.Repeat([]byte{8}, 32))); err != nil || runs() != 3 {
+		t.Errorf("wrong key: %v, %d runs", err, runs())
+	}
+}
+
+func TestRunWarm(t *testing.T) {
+	runs := countingSOPS(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	cache := filepath.Join(dir, "config.warm")
+	os.WriteFile(filename, []byte("DB_PASSWORD=hunter22\n"), 0o600)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	t.Setenv(warmCacheKeyEnv, "short")
+	if err := runWarm([]string{"-f", filename, "-o", cache}); err == nil || !strings.Contains(err.Error(), warmCacheKeyEnv) {
+		t.Errorf("runWarm() with a bad key: %v", err)
+	}
+	t.Setenv(warmCacheKeyEnv, base64.StdEncoding.EncodeToString(key))
+	captureStdout(t, func() {
+		if err := runWarm([]string{"-f", filename, "-o", cache}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if _, err := LoadServerless(context.Background(), filename, WithWarmCache(cache, key)); err != nil {
+		t.Fatal(err)
+	}
+	if runs() != 1 {
+		t.Errorf("sops ran %d times, want only for runWarm", runs())
+	}
+}
+
+func TestParallelUnwrapSkipsHungBackend(t *testing.T) {
+	countingSOPS(t)
+	t.Setenv("FAKE_SOPS_AGE_ONLY", "1")
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	os.WriteFile(filename, []byte(twoBackendFile), 0o600)
+
+	start := time.Now()
+	config, err := LoadServerless(context.Background(), filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Get("DB_PASSWORD") != "hunter22" {
+		t.Errorf("DB_PASSWORD = %q", config.Get("DB_PASSWORD"))
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("load took %v, waiting for the hung KMS attempt", elapsed)
+	}
+}
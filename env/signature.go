@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var ErrSignatureInvalid = errors.New("signature verification failed")
+
+type SignatureError struct {
+	File      string
+	Signature string
+	Reason    string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("%s: signature %s: %s", e.File, e.Signature, e.Reason)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return ErrSignatureInvalid
+}
+
+// signatureVerifier checks a detached signature over the encrypted file
+// with an external tool, the same way decryption is delegated to sops.
+type signatureVerifier struct {
+	suffix string
+	// stdin feeds the file to the tool instead of passing its path.
+	stdin bool
+	args  func(filename, signature string) []string
+}
+
+// WithMinisign requires filename.minisig, made with `minisign -Sm file`, to
+// verify against publicKeyFile before the file is decrypted.
+func WithMinisign(publicKeyFile string) Option {
+	return func(o *loadOptions) {
+		o.signature = &signatureVerifier{
+			suffix: ".minisig",
+			args: func(filename, signature string) []string {
+				return []string{"minisign", "-V", "-q", "-p", publicKeyFile, "-m", filename, "-x", signature}
+			},
+		}
+	}
+}
+
+// WithSSHSignature requires filename.sig, made with
+// `ssh-keygen -Y sign -n namespace -f key file`, to verify for identity
+// against an allowed_signers file before the file is decrypted.
+func WithSSHSignature(allowedSigners, identity, namespace string) Option {
+	return func(o *loadOptions) {
+		o.signature = &signatureVerifier{
+			suffix: ".sig",
+			stdin:  true,
+			args: func(filename, signature string) []string {
+				return []string{"ssh-keygen", "-Y", "verify", "-f", allowedSigners, "-I", identity, "-n", namespace, "-s", signature}
+			},
+		}
+	}
+}
+
+// verifiedCopy reads filename once into a private directory, checks the
+// signature against that copy and returns its path for decryption. The
+// copy keeps the base name, which sops needs to tell the format.
+func (v *signatureVerifier) verifiedCopy(ctx context.Context, filename string) (string, func(), error) {
+	signature := filename + v.suffix
+	if _, err := os.Stat(signature); err != nil {
+		return "", nil, &SignatureError{File: filename, Signature: signature, Reason: "missing"}
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	dir, err := os.MkdirTemp("", "go-sops-verified-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	verified := filepath.Join(dir, filepath.Base(filename))
+	if err := os.WriteFile(verified, data, 0o600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err := v.verify(ctx, filename, verified, signature, data); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return verified, cleanup, nil
+}
+
+// verify runs the tool on verified, the copy of filename holding data.
+func (v *signatureVerifier) verify(ctx context.Context, filename, verified, signature string, data []byte) error {
+	args := v.args(verified, signature)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if v.stdin {
+		cmd.Stdin = bytes.NewReader(data)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	done, err := startWaited(cmd)
+	if err == nil {
+		err = cmd.Wait()
+		done()
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+		reason := firstLine(output.String())
+		if reason == "" {
+			reason = "does not match"
+		}
+		return &SignatureError{File: filename, Signature: signature, Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+// cmpSignature "verifies" a file whose signature is a copy of it, with
+// cmp standing in for minisign or ssh-keygen.
+func cmpSignature(t *testing.T, stdin bool) Option {
+	t.Helper()
+	if _, err := exec.LookPath("cmp"); err != nil {
+		t.Skip("cmp is not installed")
+	}
+	return func(o *loadOptions) {
+		o.signature = &signatureVerifier{
+			suffix: ".sig",
+			stdin:  stdin,
+			args: func(filename, signature string) []string {
+				if stdin {
+					return []string{"cmp", "-s", "-", signature}
+				}
+				return []string{"cmp", "-s", filename, signature}
+			},
+		}
+	}
+}
+
+func TestSignatureDecryptsVerifiedBytes(t *testing.T) {
+	for _, stdin := range []bool{false, true} {
+		name := "path"
+		if stdin {
+			name = "stdin"
+		}
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "config.sops.env")
+			signed := []byte("DB_PASSWORD=signed\n")
+			if err := os.WriteFile(filename, signed, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filename+".sig", signed, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var decrypted string
+			decryptor := DecryptorFunc(func(_ context.Context, path string) ([]byte, error) {
+				// Swap the file after verification, as an attacker racing
+				// the load would.
+				if err := os.WriteFile(filename, []byte("DB_PASSWORD=swapped\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(path)
+				decrypted = string(data)
+				return data, err
+			})
+
+			config, err := LoadSOPSEnv(filename, WithDecryptor(decryptor), cmpSignature(t, stdin))
+			if err != nil {
+				t.Fatalf("LoadSOPSEnv() error = %v", err)
+			}
+			if got := config.Get("DB_PASSWORD"); got != "signed" || decrypted != string(signed) {
+				t.Errorf("decrypted %q, DB_PASSWORD = %q, want the signed bytes", decrypted, got)
+			}
+
+			// The swapped file no longer matches its signature.
+			if _, err := LoadSOPSEnv(filename, WithDecryptor(decryptor), cmpSignature(t, stdin)); !errors.Is(err, ErrSignatureInvalid) {
+				t.Errorf("LoadSOPSEnv() of the swapped file error = %v, want ErrSignatureInvalid", err)
+			}
+		})
+	}
+}
+
+func TestSignatureMissing(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(filename, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadSOPSEnv(filename, WithDecryptor(sopstest.NewFake()), cmpSignature(t, false))
+	var sigErr *SignatureError
+	if !errors.As(err, &sigErr) || sigErr.Reason != "missing" {
+		t.Errorf("LoadSOPSEnv() error = %v, want a missing signature", err)
+	}
+}
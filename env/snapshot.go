@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Snapshot is the effective config with secrets masked, safe to log or
+// serve from a debug endpoint. It answers "what is this process running,
+// and where did each value come from?".
+type Snapshot struct {
+	File       string          `json:"file"`
+	Source     string          `json:"source"`
+	Generation uint64          `json:"generation,omitempty"`
+	LoadedAt   time.Time       `json:"loaded_at,omitzero"`
+	Stale      bool            `json:"stale,omitempty"`
+	Keys       []SnapshotEntry `json:"keys"`
+	Duplicates []DuplicateKey  `json:"duplicates,omitempty"`
+}
+
+// SnapshotEntry is one key of a Snapshot. Source is the file and line the
+// value was assigned on; RenamedFrom is set when WithDeprecations moved it
+// from an old key. EnvOverride reports that the process environment holds
+// a different value, which Getenv callers see instead.
+type SnapshotEntry struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Secret      bool   `json:"secret,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Source      string `json:"source"`
+	RenamedFrom string `json:"renamed_from,omitempty"`
+	EnvOverride bool   `json:"env_override,omitempty"`
+}
+
+// Snapshot returns the config masked by policy, DefaultMaskPolicy if nil,
+// with keys in file order. Source differs from File when the values came
+// from a plaintext file under WithDevFallback.
+func (c *EnvConfig) Snapshot(policy *MaskPolicy) *Snapshot {
+	policy = policy.OrDefault()
+	snap := &Snapshot{
+		File:       c.file,
+		Source:     c.source,
+		LoadedAt:   c.loadedAt,
+		Stale:      c.stale,
+		Duplicates: c.duplicates,
+		Keys:       make([]SnapshotEntry, 0, len(c.keys)),
+	}
+
+	systemEnvMu.RLock()
+	defer systemEnvMu.RUnlock()
+	for _, key := range c.keys {
+		value := c.values[key]
+		entry := SnapshotEntry{
+			Key:         key,
+			Value:       policy.MaskValue(key, value),
+			Secret:      policy.IsSecretValue(key, value),
+			Field:       envConfigFields[key],
+			Source:      c.source,
+			RenamedFrom: c.renamed[key],
+		}
+		line := c.lines[key]
+		if entry.RenamedFrom != "" {
+			line = c.lines[entry.RenamedFrom]
+		}
+		if line > 0 {
+			entry.Source = fmt.Sprintf("%s:%d", c.source, line)
+		}
+		if current, ok := os.LookupEnv(key); ok && current != value {
+			entry.EnvOverride = true
+		}
+		snap.Keys = append(snap.Keys, entry)
+	}
+	return snap
+}
+
+// Snapshot is EnvConfig.Snapshot for the current config, with the store's
+// generation. It returns nil before the first successful load.
+func (s *Store) Snapshot(policy *MaskPolicy) *Snapshot {
+	config, generation := s.current()
+
+	if config == nil {
+		return nil
+	}
+	snap := config.Snapshot(policy)
+	snap.Generation = generation
+	return snap
+}
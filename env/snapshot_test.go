@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	t.Setenv("DB_HOST", "override.internal")
+
+	content := "DB_HOST=db.internal\n# comment\nDB_PASSWORD=snapshot-secret\nREDIS_ADDR=redis:6379\n"
+	config, err := LoadSOPSEnv("config.sops.env",
+		WithDecryptor(plaintextDecryptor(content)),
+		WithDeprecations(Deprecation{Old: "REDIS_ADDR", New: "REDIS_URL"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := config.Snapshot(nil)
+	if snap.File != "config.sops.env" || snap.Source != "config.sops.env" || snap.LoadedAt.IsZero() {
+		t.Errorf("snapshot header = %q, %q, %v", snap.File, snap.Source, snap.LoadedAt)
+	}
+
+	entries := make(map[string]SnapshotEntry)
+	for _, entry := range snap.Keys {
+		entries[entry.Key] = entry
+	}
+	if host := entries["DB_HOST"]; host.Source != "config.sops.env:1" || host.Field != "DBHost" || !host.EnvOverride {
+		t.Errorf("DB_HOST = %+v", host)
+	}
+	if password := entries["DB_PASSWORD"]; password.Source != "config.sops.env:3" || !password.Secret || password.Value == "snapshot-secret" {
+		t.Errorf("DB_PASSWORD = %+v", password)
+	}
+	if redis := entries["REDIS_URL"]; redis.RenamedFrom != "REDIS_ADDR" || redis.Source != "config.sops.env:4" {
+		t.Errorf("REDIS_URL = %+v", redis)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "snapshot-secret") {
+		t.Errorf("snapshot JSON leaks the password: %s", data)
+	}
+}
+
+func TestStoreSnapshot(t *testing.T) {
+	decryptor, _ := versionDecryptor(0)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if snap := store.Snapshot(nil); snap != nil {
+		t.Errorf("Snapshot() before the first load = %+v", snap)
+	}
+	for range 2 {
+		if err := store.Load(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if snap := store.Snapshot(nil); snap == nil || snap.Generation != store.Generation() || snap.Generation == 0 {
+		t.Errorf("Snapshot() = %+v, want generation %d", snap, store.Generation())
+	}
+}
@@ -0,0 +1,165 @@
+package sopstest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// AgeFixture is a throwaway age key and directory for integration tests
+// that go through the real sops binary. Create one in TestMain, encrypt the
+// fixtures with it and Close it after m.Run:
+//
+//	func TestMain(m *testing.M) {
+//		fixture, err := sopstest.NewAgeFixture()
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		fixtureFile, err = fixture.Encrypt("config.sops.env", []byte("DB_PASSWORD=test\n"))
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		fixture.Close()
+//		os.Exit(code)
+//	}
+//
+// The key only exists in the temporary directory, so nothing secret is ever
+// committed.
+type AgeFixture struct {
+	Dir       string
+	KeyFile   string
+	Recipient string
+
+	restoreEnv func()
+}
+
+// NewAgeFixture generates an age identity, writes it to a key file in a new
+// temporary directory and points SOPS_AGE_KEY_FILE at it, so sops run by
+// the loaders can decrypt files encrypted with Encrypt.
+func NewAgeFixture() (*AgeFixture, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "go-sops-fixture-*")
+	if err != nil {
+		return nil, err
+	}
+
+	keyFile := filepath.Join(dir, "keys.txt")
+	key := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)
+	if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	f := &AgeFixture{Dir: dir, KeyFile: keyFile, Recipient: identity.Recipient().String()}
+	f.restoreEnv = setenvTemporarily(map[string]string{
+		"SOPS_AGE_KEY_FILE": keyFile,
+		"SOPS_AGE_KEY":      "",
+	})
+	return f, nil
+}
+
+// Encrypt writes plaintext to name in the fixture directory and encrypts it
+// in place for the fixture's key. The format follows the extension, as
+// with sops itself. It returns the file's path.
+func (f *AgeFixture) Encrypt(name string, plaintext []byte) (string, error) {
+	path := filepath.Join(f.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, plaintext, 0o600); err != nil {
+		return "", err
+	}
+
+	if err := encryptForAge(path, f.Recipient); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// encryptForAge encrypts path in place for recipient only. An empty config
+// keeps a .sops.yaml in a parent directory from adding recipients the test
+// can't decrypt for.
+func encryptForAge(path, recipient string) error {
+	cmd := exec.Command("sops", "--config", os.DevNull, "-e", "-i", "--age", recipient, path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to encrypt fixture %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+var fixtureExtensions = map[string]string{
+	"dotenv": ".env",
+	"env":    ".env",
+	"yaml":   ".yaml",
+	"json":   ".json",
+	"ini":    ".ini",
+	"binary": ".bin",
+}
+
+// WriteEncryptedTemp encrypts content for the age recipient into a file in
+// t.TempDir() and returns its path, failing the test on error. format is
+// dotenv, yaml, json, ini or binary. Pair it with an AgeFixture, whose
+// key file sops will use to decrypt:
+//
+//	path := sopstest.WriteEncryptedTemp(t, "dotenv", "DB_PORT=5432\n", fixture.Recipient)
+func WriteEncryptedTemp(t testing.TB, format, content, recipient string) string {
+	t.Helper()
+	ext, ok := fixtureExtensions[format]
+	if !ok {
+		t.Fatalf("WriteEncryptedTemp: unknown format %q", format)
+		return ""
+	}
+	path := filepath.Join(t.TempDir(), "fixture.sops"+ext)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteEncryptedTemp: %v", err)
+		return ""
+	}
+	if err := encryptForAge(path, recipient); err != nil {
+		t.Fatalf("WriteEncryptedTemp: %v", err)
+		return ""
+	}
+	return path
+}
+
+// Close restores SOPS_AGE_KEY_FILE and deletes the key and every fixture.
+func (f *AgeFixture) Close() error {
+	f.restoreEnv()
+	return os.RemoveAll(f.Dir)
+}
+
+// setenvTemporarily sets or, for empty values, unsets the variables and
+// returns a function that puts the previous values back.
+func setenvTemporarily(vars map[string]string) func() {
+	previous := make(map[string]*string, len(vars))
+	for key, value := range vars {
+		if old, ok := os.LookupEnv(key); ok {
+			previous[key] = &old
+		} else {
+			previous[key] = nil
+		}
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+	}
+	return func() {
+		for key, old := range previous {
+			if old == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *old)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package sopstest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAgeFixture(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY_FILE", "/previous/keys.txt")
+	t.Setenv("SOPS_AGE_KEY", "AGE-SECRET-KEY-PREVIOUS")
+
+	fixture, err := NewAgeFixture()
+	if err != nil {
+		t.Fatalf("NewAgeFixture() error = %v", err)
+	}
+	if !strings.HasPrefix(fixture.Recipient, "age1") {
+		t.Errorf("Recipient = %q, want an age1 recipient", fixture.Recipient)
+	}
+	if got := os.Getenv("SOPS_AGE_KEY_FILE"); got != fixture.KeyFile {
+		t.Errorf("SOPS_AGE_KEY_FILE = %q, want %q", got, fixture.KeyFile)
+	}
+	if _, ok := os.LookupEnv("SOPS_AGE_KEY"); ok {
+		t.Error("SOPS_AGE_KEY is still set")
+	}
+	info, err := os.Stat(fixture.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("key file mode = %#o, want 0600", mode)
+	}
+
+	if err := fixture.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(fixture.Dir); !os.IsNotExist(err) {
+		t.Errorf("fixture directory still exists: %v", err)
+	}
+	if got := os.Getenv("SOPS_AGE_KEY_FILE"); got != "/previous/keys.txt" {
+		t.Errorf("SOPS_AGE_KEY_FILE = %q after Close, want it restored", got)
+	}
+	if got := os.Getenv("SOPS_AGE_KEY"); got != "AGE-SECRET-KEY-PREVIOUS" {
+		t.Errorf("SOPS_AGE_KEY = %q after Close, want it restored", got)
+	}
+}
+
+// fatalTB records Fatalf instead of stopping the test, to check how
+// WriteEncryptedTemp fails.
+type fatalTB struct {
+	*testing.T
+	failed string
+}
+
+func (f *fatalTB) Fatalf(format string, args ...any) {
+	f.failed = fmt.Sprintf(format, args...)
+}
+
+func TestWriteEncryptedTempUnknownFormat(t *testing.T) {
+	tb := &fatalTB{T: t}
+	if path := WriteEncryptedTemp(tb, "toml", "A=1\n", "age1unused"); path != "" {
+		t.Errorf("WriteEncryptedTemp() = %q, want no path", path)
+	}
+	if want := `WriteEncryptedTemp: unknown format "toml"`; tb.failed != want {
+		t.Errorf("Fatalf(%q), want %q", tb.failed, want)
+	}
+}
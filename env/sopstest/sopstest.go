@@ -0,0 +1,171 @@
+// Package sopstest stands in for sops in unit tests. Its Fake is a
+// go-sops Decryptor, so a loader given WithDecryptor(fake) gets the canned
+// values set for a file instead of running the sops binary, and tests need
+// no keys.
+//
+// The fake also records the keys read from the configs it produced,
+// through EnvConfig.Get, Lookup, All and Sorted. It doesn't go through the
+// audit sink, so tests using their own fakes can run in parallel.
+//
+// For integration tests through the real sops binary, AgeFixture and
+// WriteEncryptedTemp encrypt fixtures for a throwaway age key.
+package sopstest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Fake is an in-memory Decryptor. Its zero value is not usable; create it
+// with NewFake.
+type Fake struct {
+	mu        sync.Mutex
+	files     map[string]map[string]string
+	errs      map[string]error
+	loads     map[string]int
+	requested map[string]int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		files:     make(map[string]map[string]string),
+		errs:      make(map[string]error),
+		loads:     make(map[string]int),
+		requested: make(map[string]int),
+	}
+}
+
+// SetFile makes filename decrypt to values.
+func (f *Fake) SetFile(filename string, values map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[filename] = maps.Clone(values)
+	delete(f.errs, filename)
+}
+
+// SetStruct makes filename decrypt to the non-empty fields of v, a struct
+// or struct pointer with env tags such as an EnvConfig.
+func (f *Fake) SetStruct(filename string, v any) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sopstest: SetStruct needs a struct, got %T", v))
+	}
+	values := make(map[string]string)
+	for i := 0; i < rv.NumField(); i++ {
+		key := rv.Type().Field(i).Tag.Get("env")
+		if key == "" || !rv.Type().Field(i).IsExported() {
+			continue
+		}
+		if value := fmt.Sprint(rv.Field(i).Interface()); value != "" {
+			values[key] = value
+		}
+	}
+	f.SetFile(filename, values)
+}
+
+// SetError makes decrypting filename fail with err, e.g. a *DecryptError
+// with Kind ErrNoMatchingKeys.
+func (f *Fake) SetError(filename string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[filename] = err
+}
+
+// Loads returns how many times filename was decrypted.
+func (f *Fake) Loads(filename string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loads[filename]
+}
+
+// Requested returns the keys read from the fake's configs, sorted.
+func (f *Fake) Requested() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(maps.Keys(f.requested))
+}
+
+// AssertRequested fails the test unless every key was read.
+func (f *Fake) AssertRequested(t testing.TB, keys ...string) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		if f.requested[key] == 0 {
+			t.Errorf("key %s was never requested", key)
+		}
+	}
+}
+
+// AssertNotRequested fails the test if any key was read, e.g. to check a
+// code path doesn't touch a secret it shouldn't need.
+func (f *Fake) AssertNotRequested(t testing.TB, keys ...string) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		if n := f.requested[key]; n > 0 {
+			t.Errorf("key %s was requested %d times", key, n)
+		}
+	}
+}
+
+// RecordKey is called by the configs the fake produced for every key read
+// from them.
+func (f *Fake) RecordKey(_, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requested[key]++
+}
+
+// Decrypt renders the values set for filename as an env file. A file
+// without values fails with an error matching fs.ErrNotExist, which the
+// loaders report as ErrFileNotFound.
+func (f *Fake) Decrypt(_ context.Context, filename string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loads[filename]++
+	if err := f.errs[filename]; err != nil {
+		return nil, err
+	}
+	values, ok := f.files[filename]
+	if !ok {
+		return nil, fmt.Errorf("sopstest: no values set for %s: %w", filename, fs.ErrNotExist)
+	}
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		fmt.Fprintf(&b, "%s=%s\n", key, quote(values[key]))
+	}
+	return []byte(b.String()), nil
+}
+
+// quote double-quotes value with the escapes the go-sops dotenv parser
+// reads back.
+func quote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$', '`':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
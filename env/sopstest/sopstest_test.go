@@ -0,0 +1,35 @@
+package sopstest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestFake(t *testing.T) {
+	fake := NewFake()
+	fake.SetStruct("config.sops.env", struct {
+		Host     string `env:"DB_HOST"`
+		Password string `env:"DB_PASSWORD"`
+		Unset    string `env:"API_KEY"`
+	}{Host: "db.internal", Password: "p\"a$s\ns"})
+
+	data, err := fake.Decrypt(context.Background(), "config.sops.env")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if want := "DB_HOST=\"db.internal\"\nDB_PASSWORD=\"p\\\"a\\$s\\ns\"\n"; string(data) != want {
+		t.Errorf("Decrypt() = %q, want %q", data, want)
+	}
+	if _, err := fake.Decrypt(context.Background(), "missing.sops.env"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Decrypt() of an unknown file error = %v, want fs.ErrNotExist", err)
+	}
+	if got := fake.Loads("config.sops.env"); got != 1 {
+		t.Errorf("Loads() = %d, want 1", got)
+	}
+
+	fake.RecordKey("config.sops.env", "DB_HOST")
+	fake.AssertRequested(t, "DB_HOST")
+	fake.AssertNotRequested(t, "DB_PASSWORD")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connectorRefreshInterval is the least time between two reloads started
+// by failed connections, so a pool reconnecting in a loop can't turn a
+// wrong password into a stream of decryptions.
+const connectorRefreshInterval = 30 * time.Second
+
+// authErrorMessages identify refused credentials from drivers whose errors
+// carry no SQLSTATE, such as MySQL and SQL Server.
+var authErrorMessages = []string{
+	"password authentication failed",
+	"access denied for user",
+	"login failed for user",
+}
+
+// credentialConnector opens every new connection with the credentials the
+// provider returns at that moment, so connections opened after a rotation
+// use the new password without restarting the pool.
+type credentialConnector struct {
+	driver   driver.Driver
+	dsn      func(Credentials) string
+	creds    CredentialProvider
+	interval time.Duration
+
+	mu         sync.Mutex
+	refreshing chan struct{}
+	refreshed  time.Time
+}
+
+// NewConnector wraps d for sql.OpenDB. dsn builds the data source name for
+// a set of credentials. If the database refuses the credentials and creds
+// can refresh, the source is reloaded and the connection retried once with
+// the new credentials. Connections failing together share one reload, and
+// there is at most one every 30 seconds; other failures, such as the
+// database being down, never reload. Pair it with
+// db.SetConnMaxLifetime so connections using the old password are retired.
+func NewConnector(d driver.Driver, creds CredentialProvider, dsn func(Credentials) string) driver.Connector {
+	return &credentialConnector{driver: d, dsn: dsn, creds: creds, interval: connectorRefreshInterval}
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	creds, err := c.creds.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.connect(ctx, creds)
+	if err == nil {
+		return conn, nil
+	}
+
+	refresher, ok := c.creds.(CredentialRefresher)
+	if !ok || !isAuthError(err) {
+		return nil, err
+	}
+	c.refresh(ctx, refresher)
+	// Only retry if the credentials changed, by this reload or a recent
+	// one; otherwise the failure has nothing to do with rotation.
+	fresh, freshErr := c.creds.Credentials(ctx)
+	if freshErr != nil || fresh == creds {
+		return nil, err
+	}
+	return c.connect(ctx, fresh)
+}
+
+// refresh reloads the credentials unless a reload is running, in which case
+// it waits for that one, or one finished less than c.interval ago.
+func (c *credentialConnector) refresh(ctx context.Context, refresher CredentialRefresher) {
+	c.mu.Lock()
+	if running := c.refreshing; running != nil {
+		c.mu.Unlock()
+		select {
+		case <-running:
+		case <-ctx.Done():
+		}
+		return
+	}
+	if !c.refreshed.IsZero() && time.Since(c.refreshed) < c.interval {
+		c.mu.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	c.mu.Unlock()
+
+	err := refresher.Refresh(ctx)
+
+	c.mu.Lock()
+	c.refreshing = nil
+	c.refreshed = time.Now()
+	c.mu.Unlock()
+	close(done)
+	if err != nil {
+		slog.Warn("failed to refresh credentials after a refused connection", "error", err)
+	}
+}
+
+// isAuthError reports whether err says the database refused the
+// credentials, as opposed to being unreachable or overloaded. PostgreSQL
+// drivers report it as SQLSTATE class 28.
+func isAuthError(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return strings.HasPrefix(state.SQLState(), "28")
+	}
+	message := strings.ToLower(err.Error())
+	for _, s := range authErrorMessages {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *credentialConnector) connect(ctx context.Context, creds Credentials) (driver.Conn, error) {
+	dsn := c.dsn(creds)
+
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// passwordDriver accepts connections whose DSN is password and fails the
+// others with refuse.
+type passwordDriver struct {
+	mu       sync.Mutex
+	password string
+	refuse   error
+}
+
+func (d *passwordDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dsn != d.password {
+		return nil, d.refuse
+	}
+	return nil, nil
+}
+
+// rotatingCredentials returns current, and on Refresh, next.
+type rotatingCredentials struct {
+	mu        sync.Mutex
+	current   string
+	next      string
+	refreshes atomic.Int32
+	release   chan struct{}
+}
+
+func (c *rotatingCredentials) Credentials(context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Credentials{Password: c.current}, nil
+}
+
+func (c *rotatingCredentials) Refresh(context.Context) error {
+	c.refreshes.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.next
+	return nil
+}
+
+func newTestConnector(d driver.Driver, creds CredentialProvider) *credentialConnector {
+	return NewConnector(d, creds, func(c Credentials) string { return c.Password }).(*credentialConnector)
+}
+
+func TestConnectorRefreshesOnAuthErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		refuse      error
+		wantRefresh bool
+	}{
+		{"postgres invalid password", sqlStateError("28P01"), true},
+		{"mysql access denied", errors.New("Error 1045 (28000): Access denied for user 'app'@'10.0.0.1'"), true},
+		{"postgres too many connections", sqlStateError("53300"), false},
+		{"unreachable", errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := &rotatingCredentials{current: "old", next: "new"}
+			connector := newTestConnector(&passwordDriver{password: "new", refuse: tt.refuse}, creds)
+
+			_, err := connector.Connect(context.Background())
+			if got := creds.refreshes.Load() == 1; got != tt.wantRefresh {
+				t.Fatalf("refreshed = %v, want %v", got, tt.wantRefresh)
+			}
+			if tt.wantRefresh && err != nil {
+				t.Errorf("Connect() after the refresh error = %v", err)
+			}
+			if !tt.wantRefresh && !errors.Is(err, tt.refuse) {
+				t.Errorf("Connect() error = %v, want %v", err, tt.refuse)
+			}
+		})
+	}
+}
+
+func TestConnectorCoalescesRefreshes(t *testing.T) {
+	creds := &rotatingCredentials{current: "old", next: "new", release: make(chan struct{})}
+	connector := newTestConnector(&passwordDriver{password: "new", refuse: sqlStateError("28P01")}, creds)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := connector.Connect(context.Background())
+			errs <- err
+		}()
+	}
+	for creds.refreshes.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give the other connections time to join the running refresh.
+	time.Sleep(20 * time.Millisecond)
+	close(creds.release)
+	wg.Wait()
+	close(errs)
+
+	if n := creds.refreshes.Load(); n != 1 {
+		t.Errorf("%d refreshes for 10 concurrent failures, want 1", n)
+	}
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Connect() error = %v", err)
+		}
+	}
+}
+
+func TestConnectorRateLimitsRefreshes(t *testing.T) {
+	// The refresh doesn't fix the password, so every connection fails.
+	creds := &rotatingCredentials{current: "old", next: "still wrong"}
+	connector := newTestConnector(&passwordDriver{password: "new", refuse: sqlStateError("28P01")}, creds)
+
+	for range 5 {
+		if _, err := connector.Connect(context.Background()); err == nil {
+			t.Fatal("Connect() with a wrong password succeeded")
+		}
+	}
+	if n := creds.refreshes.Load(); n != 1 {
+		t.Errorf("%d refreshes within the interval, want 1", n)
+	}
+
+	connector.interval = 0
+	connector.Connect(context.Background())
+	if n := creds.refreshes.Load(); n != 2 {
+		t.Errorf("%d refreshes after the interval, want 2", n)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store holds the current config for a file and swaps it atomically on
+// reload, so readers always see a complete generation.
+type Store struct {
+	filename string
+	opts     []Option
+
+	// loadMu serializes loads, so a slow decryption can't overwrite the
+	// result of a newer one.
+	loadMu sync.Mutex
+
+	mu          sync.RWMutex
+	config      *EnvConfig
+	generation  uint64
+	lastSuccess time.Time
+	lastError   error
+	metadata    *sopsMetadata
+	history     []storeGeneration
+
+	clientsOnce sync.Once
+	clients     *Clients
+
+	subscribersMu sync.Mutex
+	subscribers   []func(previous, current *EnvConfig)
+}
+
+type StoreStatus struct {
+	File        string    `json:"file"`
+	Generation  uint64    `json:"generation"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	Stale       bool      `json:"stale,omitempty"`
+}
+
+func NewStore(filename string, opts ...Option) *Store {
+	return &Store{filename: filename, opts: opts}
+}
+
+func (s *Store) Load(ctx context.Context) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	config, err := LoadSOPSEnvContext(ctx, s.filename, s.opts...)
+	options := newLoadOptions(s.opts)
+
+	var metadata *sopsMetadata
+	if err == nil {
+		s.mu.RLock()
+		previous := s.metadata
+		s.mu.RUnlock()
+		metadata = checkMetadataTamper(s.filename, previous, options)
+	}
+
+	var previous *EnvConfig
+	defer func() {
+		if err == nil {
+			s.changed(previous, config)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError = err
+	if err != nil {
+		s.notify(options, s.config, NotifyEvent{Type: NotifyDecryptFailed, Error: ErrorCategory(err)})
+		return err
+	}
+	previous = s.config
+	s.config = config
+	s.metadata = metadata
+	s.generation++
+	// A stale config came from the guard, not from sops, so it does not
+	// count as a success for the readiness probe.
+	if config.Stale() {
+		s.lastError = ErrCircuitOpen
+		s.notify(options, config, NotifyEvent{Type: NotifyDecryptFailed, Error: ErrorCategory(ErrCircuitOpen)})
+		return nil
+	}
+	s.lastSuccess = time.Now()
+	s.record(config, 0, options.history)
+	if previous != nil {
+		s.notify(options, config, NotifyEvent{Type: NotifyReloaded})
+		if keys := rotatedKeys(previous, config); len(keys) > 0 {
+			s.notify(options, config, NotifyEvent{Type: NotifyRotated, Keys: keys})
+		}
+	}
+	return nil
+}
+
+// notify must be called with s.mu held.
+func (s *Store) notify(options *loadOptions, config *EnvConfig, event NotifyEvent) {
+	if options.notifier == nil {
+		return
+	}
+	event.File = s.filename
+	event.Generation = s.generation
+	options.notifier.notify(config, event)
+}
+
+// subscribe calls fn after every new generation, outside of s.mu but while
+// loads are serialized, so fn sees generations in order. fn must not load
+// the store itself.
+func (s *Store) subscribe(fn func(previous, current *EnvConfig)) (cancel func()) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+	i := len(s.subscribers) - 1
+	return func() {
+		s.subscribersMu.Lock()
+		defer s.subscribersMu.Unlock()
+		s.subscribers[i] = nil
+	}
+}
+
+func (s *Store) changed(previous, current *EnvConfig) {
+	s.subscribersMu.Lock()
+	subscribers := slices.Clone(s.subscribers)
+	s.subscribersMu.Unlock()
+	for _, fn := range subscribers {
+		if fn != nil {
+			fn(previous, current)
+		}
+	}
+}
+
+func (s *Store) Reload(ctx context.Context) error {
+	err := s.Load(ctx)
+	DefaultMetrics.observeReload(s.filename, err)
+	return err
+}
+
+// Watch reloads the store whenever the file changes until ctx is done.
+// Failed reloads keep the previous config.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	changes := watchFile(ctx, s.filename, interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("⚠️ keeping generation %d, reload of %s failed: %v", s.Generation(), s.filename, err)
+			}
+		}
+	}
+}
+
+func (s *Store) Config() *EnvConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+func (s *Store) Generation() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.generation
+}
+
+// current returns the config and its generation together, for callers
+// that report both while a reload may replace them.
+func (s *Store) current() (*EnvConfig, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config, s.generation
+}
+
+func (s *Store) Status() StoreStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := StoreStatus{
+		File:        s.filename,
+		Generation:  s.generation,
+		LastSuccess: s.lastSuccess,
+	}
+	if s.config != nil {
+		status.Stale = s.config.Stale()
+	}
+	if s.lastError != nil {
+		status.LastError = s.lastError.Error()
+	}
+	return status
+}
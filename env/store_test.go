@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// versionDecryptor returns VERSION=1, VERSION=2, ... on successive
+// decryptions, so each generation of a Store is distinguishable.
+func versionDecryptor(failEvery int64) (Decryptor, *atomic.Int64) {
+	var calls atomic.Int64
+	return DecryptorFunc(func(_ context.Context, filename string) ([]byte, error) {
+		n := calls.Add(1)
+		if failEvery > 0 && n%failEvery == 0 {
+			return nil, &DecryptError{File: filename, Kind: ErrNoMatchingKeys}
+		}
+		return fmt.Appendf(nil, "VERSION=%d\nDB_PASSWORD=secret-%d\n", n, n), nil
+	}), &calls
+}
+
+func configVersion(t *testing.T, config *EnvConfig) int {
+	t.Helper()
+	version, err := strconv.Atoi(config.Get("VERSION"))
+	if err != nil {
+		t.Errorf("VERSION = %q: %v", config.Get("VERSION"), err)
+	}
+	if want := "secret-" + config.Get("VERSION"); config.Get("DB_PASSWORD") != want {
+		t.Errorf("DB_PASSWORD = %q in generation with VERSION=%s, want a complete generation", config.Get("DB_PASSWORD"), config.Get("VERSION"))
+	}
+	return version
+}
+
+func TestStoreConcurrentReload(t *testing.T) {
+	decryptor, calls := versionDecryptor(5)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Subscribers run while loads are serialized, so they must see every
+	// generation in order, each previous being the last current.
+	var mu sync.Mutex
+	var seen []int
+	var last *EnvConfig
+	cancel := store.subscribe(func(previous, current *EnvConfig) {
+		mu.Lock()
+		defer mu.Unlock()
+		if previous != last && last != nil {
+			t.Errorf("subscriber got a previous config that is not the last current one")
+		}
+		last = current
+		seen = append(seen, configVersion(t, current))
+	})
+	defer cancel()
+
+	const reloaders, readers, reloads = 4, 8, 50
+	var failed atomic.Int64
+	ctx, stop := context.WithCancel(context.Background())
+	var readersWG, reloadersWG sync.WaitGroup
+	for range readers {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			lastVersion, lastGeneration := 0, uint64(0)
+			for ctx.Err() == nil {
+				generation := store.Generation()
+				config := store.Config()
+				if generation < lastGeneration {
+					t.Errorf("Generation() went back from %d to %d", lastGeneration, generation)
+				}
+				version := configVersion(t, config)
+				if version < lastVersion {
+					t.Errorf("Config() went back from VERSION=%d to %d", lastVersion, version)
+				}
+				lastVersion, lastGeneration = version, generation
+				store.Status()
+				if snap := store.Snapshot(nil); snap == nil {
+					t.Error("Snapshot() = nil after the first load")
+				}
+			}
+		}()
+	}
+	for range reloaders {
+		reloadersWG.Add(1)
+		go func() {
+			defer reloadersWG.Done()
+			for range reloads {
+				if err := store.Reload(ctx); err != nil {
+					if !errors.Is(err, ErrNoMatchingKeys) {
+						t.Errorf("Reload() error = %v", err)
+					}
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	reloadersWG.Wait()
+	stop()
+	readersWG.Wait()
+
+	total := calls.Load()
+	succeeded := total - failed.Load()
+	// The first Load succeeded before the subscriber was added.
+	if got := store.Generation(); got != uint64(succeeded) {
+		t.Errorf("Generation() = %d, want %d successful loads", got, succeeded)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != int(succeeded-1) {
+		t.Errorf("subscriber saw %d generations, want %d", len(seen), succeeded-1)
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i] <= seen[i-1] {
+			t.Fatalf("subscriber saw VERSION=%d after %d", seen[i], seen[i-1])
+		}
+	}
+	if version := configVersion(t, store.Config()); len(seen) > 0 && version != seen[len(seen)-1] {
+		t.Errorf("Config() has VERSION=%d, want the last generation %d", version, seen[len(seen)-1])
+	}
+}
+
+func TestStoreFailedReloadKeepsConfig(t *testing.T) {
+	decryptor, _ := versionDecryptor(2)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	first := store.Config()
+
+	if err := store.Reload(context.Background()); !errors.Is(err, ErrNoMatchingKeys) {
+		t.Fatalf("Reload() error = %v, want ErrNoMatchingKeys", err)
+	}
+	if store.Config() != first || store.Generation() != 1 {
+		t.Errorf("failed reload replaced generation 1")
+	}
+	if status := store.Status(); status.LastError == "" {
+		t.Error("Status().LastError is empty after a failed reload")
+	}
+
+	if err := store.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := configVersion(t, store.Config()); got != 3 || store.Generation() != 2 {
+		t.Errorf("after reload VERSION=%d generation %d, want 3 and 2", got, store.Generation())
+	}
+}
+
+func TestStoreSubscribeCancel(t *testing.T) {
+	decryptor, _ := versionDecryptor(0)
+	store := NewStore("config.sops.env", WithDecryptor(decryptor))
+
+	var calls atomic.Int64
+	var wg sync.WaitGroup
+	cancels := make([]func(), 8)
+	for i := range cancels {
+		cancels[i] = store.subscribe(func(_, _ *EnvConfig) { calls.Add(1) })
+	}
+	// Cancelling while loads run must neither race nor call a cancelled
+	// subscriber afterwards.
+	for i := range cancels {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cancels[i]()
+		}()
+		go func() {
+			defer wg.Done()
+			if err := store.Reload(context.Background()); err != nil {
+				t.Errorf("Reload() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	before := calls.Load()
+	if err := store.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if after := calls.Load(); after != before {
+		t.Errorf("cancelled subscribers were called %d times", after-before)
+	}
+}
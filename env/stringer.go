@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// String and GoString mask secret fields with DefaultMaskPolicy, so that
+// printing a config with %v, %+v or %#v is safe. They use value receivers
+// to cover both EnvConfig and *EnvConfig.
+func (c EnvConfig) String() string {
+	return formatRedacted(reflect.ValueOf(c), false)
+}
+
+func (c EnvConfig) GoString() string {
+	return formatRedacted(reflect.ValueOf(c), true)
+}
+
+func formatRedacted(v reflect.Value, goSyntax bool) string {
+	t := v.Type()
+
+	var b strings.Builder
+	sep := " "
+	if goSyntax {
+		b.WriteString(t.String())
+		sep = ", "
+	}
+	b.WriteString("{")
+
+	first := true
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		value := v.Field(i).String()
+		if key := field.Tag.Get("env"); key != "" {
+			value = DefaultMaskPolicy.MaskValue(key, value)
+		}
+
+		if !first {
+			b.WriteString(sep)
+		}
+		first = false
+		if goSyntax {
+			fmt.Fprintf(&b, "%s:%q", field.Name, value)
+		} else {
+			fmt.Fprintf(&b, "%s:%s", field.Name, value)
+		}
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
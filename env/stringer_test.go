@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnvConfigFormatMasksSecrets(t *testing.T) {
+	config := &EnvConfig{DBHost: "db.internal", DBPassword: "hunter22", JWTSecret: "jwt-signing-secret"}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		for _, v := range []any{config, *config} {
+			out := fmt.Sprintf(format, v)
+			if strings.Contains(out, "hunter22") || strings.Contains(out, "jwt-signing-secret") {
+				t.Errorf("Sprintf(%q, %T) shows a secret: %s", format, v, out)
+			}
+			if !strings.Contains(out, "db.internal") {
+				t.Errorf("Sprintf(%q, %T) = %s, want DBHost shown", format, v, out)
+			}
+		}
+	}
+	if out := fmt.Sprintf("%#v", config); !strings.HasPrefix(out, "main.EnvConfig{") || !strings.Contains(out, `DBPassword:"hu****22"`) {
+		t.Errorf("GoString() = %s", out)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SyncReport is the outcome of one reconciliation between the file and
+// the remote store. It names keys only.
+type SyncReport struct {
+	Time          time.Time `json:"time"`
+	File          string    `json:"file"`
+	Remote        string    `json:"remote"`
+	RemoteVersion string    `json:"remote_version,omitempty"`
+	InSync        int       `json:"in_sync"`
+	// Pushed keys were changed in the file and written to the remote.
+	Pushed []string `json:"pushed,omitempty"`
+	// Pulled keys were changed remotely and written to the file.
+	Pulled []string `json:"pulled,omitempty"`
+	// Conflicts changed on both sides since the last sync, to different
+	// values. Neither side is touched until they agree or -prefer is set.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Drifted keys changed remotely but were not pulled, because -pull is
+	// off.
+	Drifted []string `json:"drifted,omitempty"`
+}
+
+// syncState is what the last sync agreed on. Values are kept as
+// HMAC-SHA256 hashes, so the state file tells which side changed without
+// holding the secrets. The HMAC key lives in its own file, so a leaked
+// state file can't be used to test guesses of a value.
+type syncState struct {
+	// Salt is only set in state files from before the HMAC key. Their
+	// hashes are ignored.
+	Salt          string            `json:"salt,omitempty"`
+	RemoteVersion string            `json:"remote_version"`
+	Keys          map[string]string `json:"keys"`
+
+	key []byte
+}
+
+type syncer struct {
+	file      string
+	state     string
+	keyFile   string
+	remote    RemoteStore
+	pull      bool
+	prefer    string
+	options   *loadOptions
+	reportOut string
+}
+
+// runSyncd keeps a sops file and a Vault, AWS Secrets Manager or Google
+// Secret Manager secret in agreement: changes to the file are pushed, and
+// with -pull remote changes are written back into the file.
+func runSyncd(args []string) error {
+	fs := flag.NewFlagSet("syncd", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to sync")
+	remoteSpec := fs.String("remote", "", "vault:secret/app, asm:prod/app or gsm:projects/p/secrets/app")
+	interval := fs.Duration("interval", 30*time.Second, "how often to check the remote and the file")
+	pull := fs.Bool("pull", false, "write remote changes back into the file")
+	prefer := fs.String("prefer", "", "resolve conflicts with the file or remote value instead of reporting them")
+	statePath := fs.String("state", "", "where to keep the last synced state, defaults to a file under the user config directory")
+	keyFile := fs.String("state-key", "", "file with the key the state's hashes are made with, created if missing, defaults to syncd.key under the user config directory")
+	reportOut := fs.String("report", "", "write each reconciliation report to this file as JSON")
+	once := fs.Bool("once", false, "reconcile once and exit, non-zero on conflicts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *remoteSpec == "" {
+		return errors.New("-remote is required")
+	}
+	if *prefer != "" && *prefer != "file" && *prefer != "remote" {
+		return fmt.Errorf("-prefer must be file or remote, not %q", *prefer)
+	}
+	if *statePath != "" && filepath.Dir(*statePath) == filepath.Dir(*filename) {
+		// Next to the file is usually inside the repository.
+		added, err := gitignoreFile(*statePath)
+		if err != nil {
+			return err
+		}
+		if added != "" {
+			fmt.Printf("📝 added %s to %s\n", filepath.Base(*statePath), added)
+		}
+	}
+	if *statePath == "" || *keyFile == "" {
+		dir, err := syncdConfigDir()
+		if err != nil {
+			return err
+		}
+		if *statePath == "" {
+			*statePath = defaultSyncStatePath(dir, *filename)
+		}
+		if *keyFile == "" {
+			*keyFile = filepath.Join(dir, "syncd.key")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	remote, err := openRemote(ctx, *remoteSpec)
+	if err != nil {
+		return err
+	}
+	s := &syncer{
+		file:      *filename,
+		state:     *statePath,
+		keyFile:   *keyFile,
+		remote:    remote,
+		pull:      *pull,
+		prefer:    *prefer,
+		options:   newLoadOptions(nil),
+		reportOut: *reportOut,
+	}
+
+	if *once {
+		report, err := s.reconcile(ctx)
+		if err != nil {
+			return err
+		}
+		if len(report.Conflicts) > 0 {
+			return fmt.Errorf("%d conflicting key(s)", len(report.Conflicts))
+		}
+		return nil
+	}
+
+	slog.Info("syncd started", "file", s.file, "remote", s.remote.String(), "pull", s.pull, "interval", *interval)
+	changes := watchFile(ctx, s.file, time.Second)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.reconcile(ctx); err != nil {
+			slog.Error("sync failed", "file", s.file, "remote", s.remote.String(), "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile compares both sides with the last synced state, key by key:
+// a key changed on one side is copied to the other, a key changed on both
+// is a conflict.
+func (s *syncer) reconcile(ctx context.Context) (*SyncReport, error) {
+	key, err := loadSyncKey(s.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadSyncState(s.state, key)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := decryptSOPSFile(ctx, s.file, s.options, buf); err != nil {
+		return nil, err
+	}
+	entries, err := s.options.dotenv.parse(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.file, err)
+	}
+	local, err := s.options.dotenv.collect(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.file, err)
+	}
+
+	remote, version, err := s.remote.Read(ctx)
+	if errors.Is(err, ErrRemoteNotFound) {
+		remote, version = map[string]string{}, ""
+	} else if err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{Time: time.Now().UTC(), File: s.file, Remote: s.remote.String(), RemoteVersion: version}
+	push := maps.Clone(remote)
+	changes := map[string]*string{}
+	base := map[string]string{}
+
+	keys := slices.Sorted(maps.Keys(mergeKeySets(local.values, remote)))
+	for _, key := range keys {
+		l, inFile := local.values[key]
+		r, inRemote := remote[key]
+		lHash, rHash := state.hash(key, l, inFile), state.hash(key, r, inRemote)
+		if lHash == rHash {
+			report.InSync++
+			base[key] = lHash
+			continue
+		}
+
+		last, known := state.Keys[key]
+		fileChanged := !known || lHash != last
+		remoteChanged := !known || rHash != last
+		if !known {
+			// Nothing to compare with: a key on one side only is new there.
+			fileChanged, remoteChanged = inFile, inRemote
+		}
+		if fileChanged && remoteChanged {
+			switch s.prefer {
+			case "file":
+				remoteChanged = false
+			case "remote":
+				fileChanged = false
+			}
+		}
+
+		switch {
+		case fileChanged && remoteChanged:
+			report.Conflicts = append(report.Conflicts, key)
+			if known {
+				base[key] = last
+			}
+		case fileChanged:
+			if inFile {
+				push[key] = l
+			} else {
+				delete(push, key)
+			}
+			report.Pushed = append(report.Pushed, key)
+			base[key] = lHash
+		case s.pull || s.prefer == "remote":
+			if inRemote {
+				changes[key] = &r
+			} else {
+				changes[key] = nil
+			}
+			report.Pulled = append(report.Pulled, key)
+			base[key] = rHash
+		default:
+			report.Drifted = append(report.Drifted, key)
+			if known {
+				base[key] = last
+			}
+		}
+	}
+
+	if len(report.Pushed) > 0 {
+		if report.RemoteVersion, err = s.remote.Write(ctx, push, version); err != nil {
+			return nil, err
+		}
+	}
+	if len(report.Pulled) > 0 {
+		plaintext := rewriteEnvLines(buf.Bytes(), entries, local.lines, changes)
+		if err := writeSOPSPlaintext(ctx, s.file, plaintext, s.options); err != nil {
+			return nil, err
+		}
+	}
+
+	state.RemoteVersion, state.Keys = report.RemoteVersion, base
+	if err := state.save(s.state); err != nil {
+		return nil, err
+	}
+	s.print(report)
+	return report, nil
+}
+
+func (s *syncer) print(report *SyncReport) {
+	switch {
+	case len(report.Pushed) > 0:
+		fmt.Printf("⬆️  pushed %s to %s (version %s)\n", strings.Join(report.Pushed, ", "), report.Remote, report.RemoteVersion)
+	case len(report.Pulled) == 0 && len(report.Conflicts) == 0 && len(report.Drifted) == 0:
+		fmt.Printf("✅ %s and %s in sync (%d keys)\n", report.File, report.Remote, report.InSync)
+	}
+	if len(report.Pulled) > 0 {
+		fmt.Printf("⬇️  pulled %s into %s\n", strings.Join(report.Pulled, ", "), report.File)
+	}
+	if len(report.Conflicts) > 0 {
+		fmt.Printf("⚠️  conflict: %s changed in both, make them agree or pass -prefer file|remote\n", strings.Join(report.Conflicts, ", "))
+	}
+	if len(report.Drifted) > 0 {
+		fmt.Printf("ℹ️  %s changed in %s, run with -pull to take the change\n", strings.Join(report.Drifted, ", "), report.Remote)
+	}
+
+	if s.reportOut == "" {
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.reportOut, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		slog.Warn("failed to write sync report", "report", s.reportOut, "error", err)
+	}
+}
+
+func mergeKeySets(a, b map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// syncdConfigDir is where syncd keeps its state and key by default, out of
+// the repository the encrypted file is in.
+func syncdConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no default for -state and -state-key: %w", err)
+	}
+	return filepath.Join(configDir, "go-sops", "syncd"), nil
+}
+
+// defaultSyncStatePath names the state after the file's absolute path, so
+// checkouts of the same file in two places don't share a state.
+func defaultSyncStatePath(dir, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, filepath.Base(file)+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadSyncKey reads the HMAC key from path, creating it with a random key
+// on first use.
+func loadSyncKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 16 {
+			return nil, fmt.Errorf("invalid sync state key in %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		// Another syncd created it first.
+		return loadSyncKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(key)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+func loadSyncState(path string, key []byte) (*syncState, error) {
+	state := &syncState{Keys: map[string]string{}, key: key}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to read sync state %s: %w", path, err)
+	}
+	if state.Salt != "" {
+		slog.Warn("ignoring sync state from an older version, the next sync is like a first one", "state", path)
+		state.Salt, state.RemoteVersion, state.Keys = "", "", nil
+	}
+	if state.Keys == nil {
+		state.Keys = map[string]string{}
+	}
+	return state, nil
+}
+
+func (st *syncState) save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// hash is "" for a key that isn't set, so a deletion is a change too.
+func (st *syncState) hash(key, value string, present bool) string {
+	if !present {
+		return ""
+	}
+	mac := hmac.New(sha256.New, st.key)
+	mac.Write([]byte(key + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// rewriteEnvLines replaces the assignment of each changed key, all of its
+// lines for a quoted value spanning several, deleting it for a nil value
+// and appending keys that are new. Comments and every other line are kept
+// as they are. lines holds the line of the assignment that is in effect,
+// and entries are the parsed assignments with their spans.
+func rewriteEnvLines(plaintext []byte, entries []envEntry, lines map[string]int, changes map[string]*string) []byte {
+	byLine := make(map[int]envEntry, len(changes))
+	for _, e := range entries {
+		if _, changed := changes[e.Key]; changed && lines[e.Key] == e.Line {
+			byLine[e.Line] = e
+		}
+	}
+
+	var out bytes.Buffer
+	n, skipTo := 0, 0
+	for line := range bytes.Lines(plaintext) {
+		n++
+		if n <= skipTo {
+			continue
+		}
+		e, ok := byLine[n]
+		if !ok {
+			out.Write(line)
+			continue
+		}
+		if value := changes[e.Key]; value != nil {
+			fmt.Fprintf(&out, "%s=%s\n", e.Key, quoteDotenvValue(*value))
+		}
+		skipTo = e.End
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	for _, key := range slices.Sorted(maps.Keys(changes)) {
+		if _, ok := lines[key]; !ok && changes[key] != nil {
+			fmt.Fprintf(&out, "%s=%s\n", key, quoteDotenvValue(*changes[key]))
+		}
+	}
+	return out.Bytes()
+}
+
+// writeSOPSPlaintext replaces the contents of an encrypted file through
+// sops' editor, so the file keeps its recipients, key groups and data
+// key, and values that didn't change keep their ciphertext.
+func writeSOPSPlaintext(ctx context.Context, filename string, plaintext []byte, options *loadOptions) error {
+	tmp, err := os.CreateTemp("", "go-sops-syncd-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(plaintext)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	editor := fmt.Sprintf("cp '%s'", tmp.Name())
+	attempt := *options
+	attempt.sopsEnv = append(slices.Clone(options.sopsEnv), "SOPS_EDITOR="+editor, "EDITOR="+editor)
+	var out bytes.Buffer
+	if err := runSOPS(ctx, &attempt, &out, filename); err != nil {
+		return fmt.Errorf("failed to update %s: %w", filename, newDecryptError(filename, err))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+// memoryRemote is a RemoteStore whose version counts the writes.
+type memoryRemote struct {
+	values  map[string]string
+	version int
+}
+
+func (m *memoryRemote) Read(context.Context) (map[string]string, string, error) {
+	if m.values == nil {
+		return nil, "", ErrRemoteNotFound
+	}
+	return maps.Clone(m.values), strconv.Itoa(m.version), nil
+}
+
+func (m *memoryRemote) Write(_ context.Context, values map[string]string, _ string) (string, error) {
+	m.values = maps.Clone(values)
+	m.version++
+	return strconv.Itoa(m.version), nil
+}
+
+func (m *memoryRemote) String() string { return "memory" }
+
+func TestRewriteEnvLines(t *testing.T) {
+	plaintext := "# database\nDB_HOST=db.internal\nTLS_KEY=\"-----BEGIN KEY-----\nold\n-----END KEY-----\"\nPORT=5432 # default\nOLD=1\n"
+	changed := "new\nkey"
+	tests := []struct {
+		name    string
+		changes map[string]*string
+		want    string
+	}{
+		{
+			name:    "multi-line value replaced",
+			changes: map[string]*string{"TLS_KEY": &changed},
+			want:    "# database\nDB_HOST=db.internal\nTLS_KEY=\"new\\nkey\"\nPORT=5432 # default\nOLD=1\n",
+		},
+		{
+			name:    "multi-line value deleted",
+			changes: map[string]*string{"TLS_KEY": nil},
+			want:    "# database\nDB_HOST=db.internal\nPORT=5432 # default\nOLD=1\n",
+		},
+		{
+			name:    "single lines and new keys",
+			changes: map[string]*string{"DB_HOST": &changed, "OLD": nil, "NEW": &changed},
+			want:    "# database\nDB_HOST=\"new\\nkey\"\nTLS_KEY=\"-----BEGIN KEY-----\nold\n-----END KEY-----\"\nPORT=5432 # default\nNEW=\"new\\nkey\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseDotenv([]byte(plaintext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			local, err := dotenvOptions{}.collect(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(rewriteEnvLines([]byte(plaintext), entries, local.lines, tt.changes))
+			if got != tt.want {
+				t.Errorf("rewriteEnvLines() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// The result must still parse, with the other keys untouched.
+			if _, err := parseDotenv([]byte(got)); err != nil {
+				t.Errorf("rewritten file doesn't parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestRewriteEnvLinesDuplicates(t *testing.T) {
+	plaintext := "A=\"first\nvalue\"\nB=2\nA=\"last\nvalue\"\n"
+	entries, err := parseDotenv([]byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, _ := dotenvOptions{}.collect(entries)
+	value := "new"
+	// Only the assignment in effect, the last one, is replaced.
+	got := string(rewriteEnvLines([]byte(plaintext), entries, local.lines, map[string]*string{"A": &value}))
+	if want := "A=\"first\nvalue\"\nB=2\nA=new\n"; got != want {
+		t.Errorf("rewriteEnvLines() = %q, want %q", got, want)
+	}
+}
+
+func TestSyncStateIsKeyed(t *testing.T) {
+	dir := t.TempDir()
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DB_PASSWORD": "hunter2"})
+	remote := &memoryRemote{}
+	s := &syncer{
+		file:    "config.sops.env",
+		state:   filepath.Join(dir, "state.json"),
+		keyFile: filepath.Join(dir, "keys", "syncd.key"),
+		remote:  remote,
+		options: newLoadOptions([]Option{WithDecryptor(fake)}),
+	}
+
+	report, err := s.reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Pushed) != 1 || remote.values["DB_PASSWORD"] != "hunter2" {
+		t.Fatalf("first sync pushed %q, remote = %q", report.Pushed, remote.values)
+	}
+	if info, err := os.Stat(s.keyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file = %v, %v, want a 0600 file", info, err)
+	}
+
+	data, err := os.ReadFile(s.state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "salt") {
+		t.Errorf("state holds a salt:\n%s", data)
+	}
+	var state syncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	// Without the key file, the value can't be checked against the hash.
+	unkeyed := sha256.Sum256([]byte("DB_PASSWORD\x00hunter2"))
+	if hash := state.Keys["DB_PASSWORD"]; len(hash) != 64 || hash == hex.EncodeToString(unkeyed[:]) {
+		t.Errorf("state hash = %q, want a full HMAC", hash)
+	}
+
+	if report, err = s.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if report.InSync != 1 || len(report.Pushed) != 0 {
+		t.Errorf("second sync = %+v, want the key in sync", report)
+	}
+}
+
+func TestSyncStateIgnoresSaltedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{"salt": "00ff", "remote_version": "3", "keys": {"DB_PASSWORD": "0123456789abcdef0123456789abcdef"}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadSyncState(path, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Keys) != 0 || state.RemoteVersion != "" {
+		t.Errorf("salted state = %+v, want it ignored", state)
+	}
+}
+
+func TestLoadSyncKeyIsStable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syncd.key")
+	first, err := loadSyncKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadSyncKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 32 || string(first) != string(second) {
+		t.Errorf("keys = %x and %x, want the same 32 bytes", first, second)
+	}
+}
+
+func TestDefaultSyncStatePath(t *testing.T) {
+	dir := t.TempDir()
+	a := defaultSyncStatePath(dir, "/srv/a/config.sops.env")
+	b := defaultSyncStatePath(dir, "/srv/b/config.sops.env")
+	if a == b || filepath.Dir(a) != dir {
+		t.Errorf("state paths = %s and %s, want distinct files in %s", a, b, dir)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	vault "github.com/hashicorp/vault/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrRemoteNotFound = errors.New("remote secret not found")
+	// ErrRemoteConflict is returned by Write when the secret changed since
+	// the Read that returned version. Sync again to merge the change.
+	ErrRemoteConflict = errors.New("remote secret changed since it was read")
+)
+
+// RemoteStore is the other side of `go-sops syncd`: one secret holding the
+// same keys as the file.
+type RemoteStore interface {
+	// Read returns the keys and a version that changes with every write.
+	// A secret that doesn't exist yet is ErrRemoteNotFound.
+	Read(ctx context.Context) (values map[string]string, version string, err error)
+	// Write replaces the keys if the secret is still at version, the one
+	// Read returned or "" when it was not found, and returns the new
+	// version. A secret someone else wrote in between is ErrRemoteConflict.
+	Write(ctx context.Context, values map[string]string, version string) (string, error)
+	String() string
+}
+
+// openRemote parses vault:secret/app, asm:prod/app or
+// gsm:projects/my-project/secrets/app.
+func openRemote(ctx context.Context, spec string) (RemoteStore, error) {
+	kind, path, ok := strings.Cut(spec, ":")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("remote %q should look like vault:secret/app, asm:prod/app or gsm:projects/p/secrets/app", spec)
+	}
+	switch kind {
+	case "vault":
+		client, err := newVaultClient()
+		if err != nil {
+			return nil, err
+		}
+		path = strings.Trim(path, "/")
+		mount, version := vaultMount(ctx, client, path)
+		return &vaultStore{client: client, mount: mount, path: strings.TrimPrefix(path, mount+"/"), v1: version == "1"}, nil
+	case "asm":
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		return &asmStore{client: secretsmanager.NewFromConfig(cfg), id: path}, nil
+	case "gsm":
+		if !strings.HasPrefix(path, "projects/") || !strings.Contains(path, "/secrets/") {
+			return nil, fmt.Errorf("gsm remote %q should be projects/<project>/secrets/<name>", path)
+		}
+		client, err := secretmanager.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+		}
+		return &gsmStore{client: client, name: path}, nil
+	}
+	return nil, fmt.Errorf("unknown remote %q, expected vault, asm or gsm", kind)
+}
+
+// remoteValue turns one value of a JSON secret into an env value. Values
+// that aren't strings, like numbers or nested objects, become JSON.
+func remoteValue(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	default:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	}
+}
+
+func remoteValues(data map[string]any) (map[string]string, error) {
+	values := make(map[string]string, len(data))
+	for key, raw := range data {
+		value, err := remoteValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// contentVersion stands in for a version on stores that have none.
+func contentVersion(values map[string]string) string {
+	h := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		fmt.Fprintf(h, "%s\x00%s\x00", key, values[key])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+type vaultStore struct {
+	client *vault.Client
+	mount  string
+	path   string
+	v1     bool
+}
+
+func (s *vaultStore) String() string {
+	return "vault:" + s.mount + "/" + s.path
+}
+
+func (s *vaultStore) Read(ctx context.Context) (map[string]string, string, error) {
+	var secret *vault.KVSecret
+	var err error
+	if s.v1 {
+		secret, err = s.client.KVv1(s.mount).Get(ctx, s.path)
+	} else {
+		secret, err = s.client.KVv2(s.mount).Get(ctx, s.path)
+	}
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return nil, "", fmt.Errorf("%s: %w", s, ErrRemoteNotFound)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", s, err)
+	}
+	values, err := remoteValues(secret.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	if secret.VersionMetadata == nil {
+		return values, contentVersion(values), nil
+	}
+	return values, strconv.Itoa(secret.VersionMetadata.Version), nil
+}
+
+func (s *vaultStore) Write(ctx context.Context, values map[string]string, version string) (string, error) {
+	data := make(map[string]any, len(values))
+	for key, value := range values {
+		data[key] = value
+	}
+	if s.v1 {
+		// KV v1 has no versions, so compare the content instead. That
+		// leaves a short window between the check and the write.
+		if err := checkRemoteVersion(ctx, s, version); err != nil {
+			return "", err
+		}
+		if err := s.client.KVv1(s.mount).Put(ctx, s.path, data); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", s, err)
+		}
+		return contentVersion(values), nil
+	}
+
+	// Check-and-set makes Vault refuse the write if it changed since Read;
+	// 0 only creates the secret.
+	cas, err := strconv.Atoi(version)
+	if err != nil {
+		cas = 0
+	}
+	secret, err := s.client.KVv2(s.mount).Put(ctx, s.path, data, vault.WithCheckAndSet(cas))
+	var respErr *vault.ResponseError
+	if errors.As(err, &respErr) && strings.Contains(strings.Join(respErr.Errors, " "), "check-and-set") {
+		return "", fmt.Errorf("%s: %w", s, ErrRemoteConflict)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", s, err)
+	}
+	return strconv.Itoa(secret.VersionMetadata.Version), nil
+}
+
+// checkRemoteVersion reads the store again and fails with
+// ErrRemoteConflict unless it is still at version.
+func checkRemoteVersion(ctx context.Context, store RemoteStore, version string) error {
+	_, current, err := store.Read(ctx)
+	if errors.Is(err, ErrRemoteNotFound) {
+		current, err = "", nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return fmt.Errorf("%s: %w", store, ErrRemoteConflict)
+	}
+	return nil
+}
+
+// asmStore is an AWS Secrets Manager secret whose string is a JSON object,
+// as the console creates for key/value secrets.
+type asmStore struct {
+	client *secretsmanager.Client
+	id     string
+}
+
+func (s *asmStore) String() string {
+	return "asm:" + s.id
+}
+
+func (s *asmStore) Read(ctx context.Context) (map[string]string, string, error) {
+	out, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.id)})
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, "", fmt.Errorf("%s: %w", s, ErrRemoteNotFound)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", s, err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(aws.ToString(out.SecretString)), &data); err != nil {
+		return nil, "", fmt.Errorf("%s is not a JSON key/value secret", s)
+	}
+	values, err := remoteValues(data)
+	return values, aws.ToString(out.VersionId), err
+}
+
+// asmPendingStage labels a version written by Write until it becomes
+// AWSCURRENT.
+const asmPendingStage = "GO_SOPS_PENDING"
+
+// Write adds the version without moving AWSCURRENT, then moves the label
+// from version to it. Secrets Manager refuses the move when AWSCURRENT is
+// on another version by then, which makes it a compare-and-swap.
+func (s *asmStore) Write(ctx context.Context, values map[string]string, version string) (string, error) {
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	input := &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(s.id),
+		SecretString: aws.String(string(encoded)),
+	}
+	if version != "" {
+		input.VersionStages = []string{asmPendingStage}
+	}
+	out, err := s.client.PutSecretValue(ctx, input)
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", fmt.Errorf("%s: %w, create it with `aws secretsmanager create-secret --name %s`", s, ErrRemoteNotFound, s.id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", s, err)
+	}
+	if version == "" {
+		return aws.ToString(out.VersionId), nil
+	}
+
+	_, err = s.client.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(s.id),
+		VersionStage:        aws.String("AWSCURRENT"),
+		MoveToVersionId:     out.VersionId,
+		RemoveFromVersionId: aws.String(version),
+	})
+	var invalidParam *smtypes.InvalidParameterException
+	var invalidRequest *smtypes.InvalidRequestException
+	if errors.As(err, &invalidParam) || errors.As(err, &invalidRequest) {
+		return "", fmt.Errorf("%s: %w", s, ErrRemoteConflict)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", s, err)
+	}
+	return aws.ToString(out.VersionId), nil
+}
+
+// gsmStore is a Google Secret Manager secret whose payload is a JSON
+// object. Every write adds a version.
+type gsmStore struct {
+	client *secretmanager.Client
+	name   string
+}
+
+func (s *gsmStore) String() string {
+	return "gsm:" + s.name
+}
+
+func (s *gsmStore) Read(ctx context.Context) (map[string]string, string, error) {
+	resp, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: s.name + "/versions/latest"})
+	if status.Code(err) == codes.NotFound {
+		return nil, "", fmt.Errorf("%s: %w", s, ErrRemoteNotFound)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", s, err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(resp.GetPayload().GetData(), &data); err != nil {
+		return nil, "", fmt.Errorf("%s is not a JSON key/value secret", s)
+	}
+	values, err := remoteValues(data)
+	return values, resp.GetName(), err
+}
+
+// Write compares the latest version with version first. AddSecretVersion
+// has no precondition, so a write between the two calls still wins.
+func (s *gsmStore) Write(ctx context.Context, values map[string]string, version string) (string, error) {
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	latest, err := s.client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{Name: s.name + "/versions/latest"})
+	switch {
+	case status.Code(err) == codes.NotFound:
+		if version != "" {
+			return "", fmt.Errorf("%s: %w", s, ErrRemoteConflict)
+		}
+	case err != nil:
+		return "", fmt.Errorf("failed to read %s: %w", s, err)
+	case latest.GetName() != version:
+		return "", fmt.Errorf("%s: %w", s, ErrRemoteConflict)
+	}
+	resp, err := s.client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  s.name,
+		Payload: &secretmanagerpb.SecretPayload{Data: encoded},
+	})
+	if status.Code(err) == codes.NotFound {
+		return "", fmt.Errorf("%s: %w, create it with `gcloud secrets create`", s, ErrRemoteNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", s, err)
+	}
+	return resp.GetName(), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// fakeVault serves one KV secret at secret/app, as v2 with check-and-set
+// or as v1 without versions.
+type fakeVault struct {
+	v1      bool
+	mu      sync.Mutex
+	data    map[string]any
+	version int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	respond := func(code int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	}
+	if !strings.HasSuffix(r.URL.Path, "/app") {
+		respond(http.StatusNotFound, map[string]any{"errors": []string{}})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if f.data == nil {
+			respond(http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		if f.v1 {
+			respond(http.StatusOK, map[string]any{"data": f.data})
+			return
+		}
+		respond(http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     f.data,
+			"metadata": map[string]any{"version": f.version, "created_time": "2025-01-01T00:00:00Z"},
+		}})
+	case http.MethodPut, http.MethodPost:
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if f.v1 {
+			f.data = body
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if cas, _ := body["options"].(map[string]any)["cas"].(float64); int(cas) != f.version {
+			respond(http.StatusBadRequest, map[string]any{"errors": []string{"check-and-set parameter did not match the current version"}})
+			return
+		}
+		f.data, _ = body["data"].(map[string]any)
+		f.version++
+		respond(http.StatusOK, map[string]any{"data": map[string]any{"version": f.version, "created_time": "2025-01-01T00:00:00Z"}})
+	}
+}
+
+func newFakeVaultStore(t *testing.T, fake *fakeVault) *vaultStore {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := vault.NewClient(&vault.Config{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test")
+	return &vaultStore{client: client, mount: "secret", path: "app", v1: fake.v1}
+}
+
+func TestVaultWriteConflict(t *testing.T) {
+	for _, v1 := range []bool{false, true} {
+		name := "kv2"
+		if v1 {
+			name = "kv1"
+		}
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeVault{v1: v1}
+			store := newFakeVaultStore(t, fake)
+			ctx := context.Background()
+
+			if _, _, err := store.Read(ctx); !errors.Is(err, ErrRemoteNotFound) {
+				t.Fatalf("Read() error = %v, want ErrRemoteNotFound", err)
+			}
+			version, err := store.Write(ctx, map[string]string{"A": "1"}, "")
+			if err != nil {
+				t.Fatalf("Write() creating the secret error = %v", err)
+			}
+			_, read, err := store.Read(ctx)
+			if err != nil || read != version {
+				t.Fatalf("Read() = version %q, %v, want %q", read, err, version)
+			}
+
+			// Someone else writes after our Read.
+			if _, err := store.Write(ctx, map[string]string{"A": "2"}, read); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if _, err := store.Write(ctx, map[string]string{"A": "3"}, read); !errors.Is(err, ErrRemoteConflict) {
+				t.Errorf("Write() with a stale version error = %v, want ErrRemoteConflict", err)
+			}
+			if _, err := store.Write(ctx, map[string]string{"A": "3"}, ""); !errors.Is(err, ErrRemoteConflict) {
+				t.Errorf("Write() creating an existing secret error = %v, want ErrRemoteConflict", err)
+			}
+			values, _, _ := store.Read(ctx)
+			if values["A"] != "2" {
+				t.Errorf("A = %q after the refused writes, want 2", values["A"])
+			}
+		})
+	}
+}
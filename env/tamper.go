@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	TamperMACMismatch   = "mac_mismatch"
+	TamperOutsideWindow = "outside_window"
+	TamperRollback      = "rollback"
+)
+
+// TamperEvent reports a change to an encrypted file that security
+// monitoring should look at.
+type TamperEvent struct {
+	File                 string
+	Reason               string
+	LastModified         string
+	PreviousLastModified string
+	Time                 time.Time
+}
+
+// MaintenanceWindow is a daily time range in which config changes are
+// expected. Start and End are offsets from midnight in Location (UTC if
+// nil); End before Start wraps past midnight. Empty Days means every day.
+type MaintenanceWindow struct {
+	Days     []time.Weekday
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	if len(w.Days) > 0 && !slices.Contains(w.Days, t.Weekday()) {
+		return false
+	}
+
+	offset := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// WithTamperAlerts calls handler (or logs an error if nil) when decryption
+// fails MAC verification. A Store additionally tracks the file's sops
+// metadata across reloads and alerts when its lastmodified goes backwards,
+// or when it changed outside every window (if windows are given).
+func WithTamperAlerts(handler func(TamperEvent), windows ...MaintenanceWindow) Option {
+	return func(o *loadOptions) {
+		o.tamperAlerts = true
+		o.onTamper = handler
+		o.maintenance = windows
+	}
+}
+
+func (o *loadOptions) alertTamper(event TamperEvent) {
+	event.Time = time.Now().UTC()
+	if o.onTamper != nil {
+		o.onTamper(event)
+		return
+	}
+	slog.Error("possible config tampering", "file", event.File, "reason", event.Reason,
+		"lastmodified", event.LastModified, "previous_lastmodified", event.PreviousLastModified)
+}
+
+func checkMACTamper(filename string, err error, options *loadOptions) {
+	if options.tamperAlerts && errors.Is(err, ErrMACMismatch) {
+		options.alertTamper(TamperEvent{File: filename, Reason: TamperMACMismatch})
+	}
+}
+
+// checkMetadataTamper compares the file's current metadata with what the
+// previous load saw and returns the current metadata for the next call.
+func checkMetadataTamper(filename string, previous *sopsMetadata, options *loadOptions) *sopsMetadata {
+	if !options.tamperAlerts {
+		return nil
+	}
+
+	current, err := readSOPSMetadata(filename)
+	if err != nil || previous == nil {
+		return current
+	}
+	if current.MAC == previous.MAC && current.LastModified == previous.LastModified {
+		return current
+	}
+
+	event := TamperEvent{
+		File:                 filename,
+		LastModified:         current.LastModified,
+		PreviousLastModified: previous.LastModified,
+	}
+
+	modified, err := time.Parse(time.RFC3339, current.LastModified)
+	if err != nil {
+		modified = time.Now()
+	}
+	if prev, err := time.Parse(time.RFC3339, previous.LastModified); err == nil && modified.Before(prev) {
+		event.Reason = TamperRollback
+		options.alertTamper(event)
+		return current
+	}
+
+	if len(options.maintenance) > 0 && !slices.ContainsFunc(options.maintenance, func(w MaintenanceWindow) bool {
+		return w.Contains(modified)
+	}) {
+		event.Reason = TamperOutsideWindow
+		options.alertTamper(event)
+	}
+	return current
+}
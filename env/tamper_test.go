@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTamperAlertOnMACMismatch(t *testing.T) {
+	decryptor := DecryptorFunc(func(_ context.Context, filename string) ([]byte, error) {
+		return nil, &DecryptError{File: filename, Kind: ErrMACMismatch}
+	})
+	var events []TamperEvent
+	LoadSOPSEnv("config.sops.env", WithDecryptor(decryptor), WithTamperAlerts(func(e TamperEvent) { events = append(events, e) }))
+	if len(events) != 1 || events[0].Reason != TamperMACMismatch || events[0].File != "config.sops.env" {
+		t.Errorf("events = %+v, want one mac_mismatch", events)
+	}
+}
+
+func TestStoreTamperAlerts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	write := func(lastModified, mac string) {
+		t.Helper()
+		content := "DB_HOST=ENC[AES256_GCM,data:x]\nsops_lastmodified=" + lastModified + "\nsops_mac=" + mac + "\n"
+		if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decryptor := DecryptorFunc(func(context.Context, string) ([]byte, error) {
+		return []byte("DB_HOST=db\n"), nil
+	})
+
+	var events []TamperEvent
+	// A window from 01:00 to 03:00 UTC.
+	window := MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour}
+	store := NewStore(filename, WithDecryptor(decryptor),
+		WithTamperAlerts(func(e TamperEvent) { events = append(events, e) }, window))
+
+	write("2030-01-02T02:00:00Z", "ENC[AES256_GCM,data:a]")
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	write("2030-01-03T02:30:00Z", "ENC[AES256_GCM,data:b]")
+	if err := store.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("alert for a change inside the window: %+v", events)
+	}
+
+	write("2030-01-04T12:00:00Z", "ENC[AES256_GCM,data:c]")
+	store.Reload(context.Background())
+	write("2030-01-01T02:00:00Z", "ENC[AES256_GCM,data:d]")
+	store.Reload(context.Background())
+
+	if len(events) != 2 || events[0].Reason != TamperOutsideWindow || events[1].Reason != TamperRollback {
+		t.Fatalf("events = %+v, want outside_window then rollback", events)
+	}
+	if events[1].PreviousLastModified != "2030-01-04T12:00:00Z" || events[1].LastModified != "2030-01-01T02:00:00Z" {
+		t.Errorf("rollback event = %+v", events[1])
+	}
+}
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	overnight := MaintenanceWindow{Days: []time.Weekday{time.Saturday}, Start: 22 * time.Hour, End: 2 * time.Hour}
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2030-01-05T23:00:00Z", true}, // Saturday
+		{"2030-01-05T01:00:00Z", true},
+		{"2030-01-05T12:00:00Z", false},
+		{"2030-01-06T23:00:00Z", false}, // Sunday
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		if got := overnight.Contains(at); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runTFExternal implements Terraform's external data source protocol: the
+// query arrives on stdin as a JSON object of strings and the result must be
+// one too. Errors go to stderr with a non-zero exit, which Terraform shows
+// as the data source's error.
+//
+// Query keys:
+//
+//	file  encrypted env file, defaults to config.sops.env
+//	keys  comma-separated keys to return, defaults to all
+func runTFExternal(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("tf-external takes its arguments as a JSON query on stdin")
+	}
+
+	var query map[string]string
+	if err := json.NewDecoder(os.Stdin).Decode(&query); err != nil {
+		return fmt.Errorf("invalid query, expected a JSON object of strings: %w", err)
+	}
+	for key := range query {
+		if key != "file" && key != "keys" {
+			return fmt.Errorf("unknown query key %q, expected file or keys", key)
+		}
+	}
+
+	filename := query["file"]
+	if filename == "" {
+		filename = "config.sops.env"
+	}
+	envMap, err := readSOPSEnvMap(context.Background(), filename, newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+
+	result := envMap
+	if query["keys"] != "" {
+		result = make(map[string]string)
+		for _, key := range strings.Split(query["keys"], ",") {
+			key = strings.TrimSpace(key)
+			value, ok := envMap[key]
+			if !ok {
+				return fmt.Errorf("key %s not found in %s", key, filename)
+			}
+			result[key] = value
+		}
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
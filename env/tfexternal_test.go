@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTFExternal(t *testing.T) {
+	installFakeSOPSBinary(t)
+	filename := filepath.Join(t.TempDir(), "config.sops.env")
+	os.WriteFile(filename, []byte("DB_HOST=db\nDB_PASSWORD=hunter22\nPORT=5432\n"), 0o600)
+	query := func(q map[string]string) string {
+		data, _ := json.Marshal(q)
+		return string(data)
+	}
+
+	tests := []struct {
+		stdin string
+		want  map[string]string
+		err   string
+	}{
+		{stdin: query(map[string]string{"file": filename}), want: map[string]string{"DB_HOST": "db", "DB_PASSWORD": "hunter22", "PORT": "5432"}},
+		{stdin: query(map[string]string{"file": filename, "keys": "DB_HOST, PORT"}), want: map[string]string{"DB_HOST": "db", "PORT": "5432"}},
+		{stdin: query(map[string]string{"file": filename, "keys": "API_KEY"}), err: "key API_KEY not found"},
+		{stdin: query(map[string]string{"file": filename, "path": "x"}), err: `unknown query key "path"`},
+		// Terraform sends only strings; anything else is an error.
+		{stdin: `{"file": 1}`, err: "invalid query"},
+	}
+	for _, tt := range tests {
+		withStdin(t, tt.stdin)
+		var err error
+		out := captureStdout(t, func() { err = runTFExternal(nil) })
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.stdin, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.stdin, err)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("output is not a JSON object of strings: %v\n%s", err, out)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: result %v, want %v", tt.stdin, got, tt.want)
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("%s: %s = %q, want %q", tt.stdin, key, got[key], value)
+			}
+		}
+	}
+
+	if err := runTFExternal([]string{"-f", filename}); err == nil {
+		t.Error("runTFExternal accepted command-line arguments")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+)
+
+// WithLogger sends debug events about loading to logger: which file was
+// read, the decryption backend, warm cache hits and misses, which keys are
+// redacted and which ones landed in a field. Events carry key names, never
+// values, and go through a RedactingHandler in case a value ends up in an
+// error. Enable the debug level on logger's handler to see them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *loadOptions) {
+		o.logger = slog.New(NewRedactingHandler(logger.Handler(), DefaultRedactor))
+	}
+}
+
+func (o *loadOptions) trace(ctx context.Context, msg string, args ...any) {
+	if o.logger != nil {
+		o.logger.DebugContext(ctx, msg, args...)
+	}
+}
+
+func (o *loadOptions) tracing(ctx context.Context) bool {
+	return o.logger != nil && o.logger.Enabled(ctx, slog.LevelDebug)
+}
+
+// traceBackend logs how filename is about to be decrypted.
+func traceBackend(ctx context.Context, filename string, options *loadOptions) {
+	if !options.tracing(ctx) {
+		return
+	}
+	backend := decryptorName(options.decryptor)
+	if options.decryptor == nil && options.parallelUnwrap {
+		backend = "exec-parallel"
+	}
+	args := []any{"file", filename, "decryptor", backend}
+	if meta, err := readSOPSMetadata(filename); err == nil {
+		args = append(args, "backends", meta.Backends)
+	}
+	options.trace(ctx, "decryption backend chosen", args...)
+}
+
+// traceResolved logs the file a load read, after any dev fallback.
+func traceResolved(ctx context.Context, filename string, file *decodedEnv, options *loadOptions) {
+	if !options.tracing(ctx) {
+		return
+	}
+	path, err := filepath.Abs(file.source)
+	if err != nil {
+		path = file.source
+	}
+	options.trace(ctx, "config file resolved",
+		"file", filename, "path", path, "dev_fallback", file.source != filename, "stale", file.stale, "variables", len(file.keys))
+
+	var secret []string
+	for _, key := range file.keys {
+		if DefaultRedactor.policy.IsSecretValue(key, file.values[key]) {
+			secret = append(secret, key)
+		}
+	}
+	options.trace(ctx, "values redacted", "file", filename, "variables", secret)
+}
+
+// traceMapped logs which keys of envMap filled an EnvConfig field, and
+// which fields the file left empty.
+func traceMapped(ctx context.Context, filename string, envMap map[string]string, options *loadOptions) {
+	if !options.tracing(ctx) {
+		return
+	}
+	var mapped, unmapped, unset []string
+	for key := range envMap {
+		if _, ok := envConfigFields[key]; ok {
+			mapped = append(mapped, key)
+		} else {
+			unmapped = append(unmapped, key)
+		}
+	}
+	for key := range envConfigFields {
+		if _, ok := envMap[key]; !ok {
+			unset = append(unset, key)
+		}
+	}
+	slices.Sort(mapped)
+	slices.Sort(unmapped)
+	slices.Sort(unset)
+	options.trace(ctx, "variables mapped", "file", filename, "fields", mapped, "no_field", unmapped, "unset_fields", unset)
+}
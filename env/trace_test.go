@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// traceEvents returns the messages logged to logs, one JSON object per line.
+func traceEvents(t *testing.T, logs *bytes.Buffer) map[string]map[string]any {
+	t.Helper()
+	events := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		events[event["msg"].(string)] = event
+	}
+	return events
+}
+
+func TestWithLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	content := "DB_HOST=db\nDB_PASSWORD=trace-secret-value\nFEATURE_X=on\n"
+
+	_, err := LoadSOPSEnv("trace.sops.env", WithDecryptor(plaintextDecryptor(content)), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "trace-secret-value") {
+		t.Errorf("trace logs a value:\n%s", logs.String())
+	}
+	events := traceEvents(t, &logs)
+	for _, msg := range []string{"decryption backend chosen", "config file resolved", "values redacted", "variables mapped"} {
+		if events[msg] == nil {
+			t.Errorf("no %q event:\n%s", msg, logs.String())
+		}
+	}
+	if got := events["decryption backend chosen"]["decryptor"]; got != "custom" {
+		t.Errorf("decryptor = %v, want custom", got)
+	}
+	if got := events["values redacted"]["variables"]; !slices.Equal(toStrings(got), []string{"DB_PASSWORD"}) {
+		t.Errorf("redacted variables = %v", got)
+	}
+	mapped := events["variables mapped"]
+	if !slices.Contains(toStrings(mapped["fields"]), "DB_HOST") || !slices.Equal(toStrings(mapped["no_field"]), []string{"FEATURE_X"}) {
+		t.Errorf("variables mapped = %v", mapped)
+	}
+
+	logs.Reset()
+	var spec struct {
+		Host string `envconfig:"DB_HOST"`
+		Port int    `envconfig:"DB_PORT" default:"5432"`
+	}
+	if err := ProcessSOPSEnv("trace.sops.env", "", &spec, WithDecryptor(plaintextDecryptor(content)), WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	var from []string
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var event map[string]any
+		json.Unmarshal([]byte(line), &event)
+		if event["msg"] == "variable mapped" {
+			from = append(from, fmt.Sprint(event["variable"], "=", event["from"]))
+		}
+	}
+	if !slices.Equal(from, []string{"DB_HOST=file", "DB_PORT=default"}) {
+		t.Errorf("variable mapped events = %q", from)
+	}
+
+	logs.Reset()
+	quiet := slog.New(slog.NewJSONHandler(&logs, nil))
+	if _, err := LoadSOPSEnv("trace.sops.env", WithDecryptor(plaintextDecryptor(content)), WithLogger(quiet)); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged below the handler's level:\n%s", logs.String())
+	}
+}
+
+func TestWithLoggerWarmCache(t *testing.T) {
+	countingSOPS(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.env")
+	os.WriteFile(filename, []byte("DB_PASSWORD=hunter22\n"), 0o600)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cache := WithWarmCache(filepath.Join(dir, "config.warm"), bytes.Repeat([]byte{7}, 32))
+
+	for _, want := range []string{"warm cache miss", "warm cache hit"} {
+		logs.Reset()
+		if _, err := LoadServerless(context.Background(), filename, cache, WithLogger(logger)); err != nil {
+			t.Fatal(err)
+		}
+		if traceEvents(t, &logs)[want] == nil {
+			t.Errorf("no %q event:\n%s", want, logs.String())
+		}
+	}
+}
+
+func toStrings(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		s, _ := item.(string)
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// TypedKey describes one variable and the Go type it is read as, for
+// typed access to single values without a struct:
+//
+//	var DBPort = Key[int]("DB_PORT", Default(5432))
+//
+//	port := DBPort.Get(cfg)
+//
+// T may be any type ProcessSOPSEnv can fill: strings (including Secret),
+// numbers, bools, time.Duration, slices, maps and encoding.TextUnmarshaler.
+type TypedKey[T any] struct {
+	name     string
+	def      T
+	hasDef   bool
+	required bool
+	parse    func(string) (T, error)
+}
+
+type KeyOption[T any] func(*TypedKey[T])
+
+// Key declares a variable read as T.
+func Key[T any](name string, opts ...KeyOption[T]) *TypedKey[T] {
+	k := &TypedKey[T]{name: name, parse: parseTypedValue[T]}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+// Default is the value of a key the file doesn't set.
+func Default[T any](value T) KeyOption[T] {
+	return func(k *TypedKey[T]) {
+		k.def, k.hasDef = value, true
+	}
+}
+
+// Required makes Lookup fail with ErrKeyNotFound when the file doesn't set
+// the key and there is no default. KEY= counts as set.
+func Required[T any]() KeyOption[T] {
+	return func(k *TypedKey[T]) {
+		k.required = true
+	}
+}
+
+// ParseWith reads the value with parse instead of the built-in conversion.
+func ParseWith[T any](parse func(string) (T, error)) KeyOption[T] {
+	return func(k *TypedKey[T]) {
+		k.parse = parse
+	}
+}
+
+func (k *TypedKey[T]) Name() string {
+	return k.name
+}
+
+// Lookup returns the key's value in cfg, or its default when the file
+// doesn't set the key. A key set to the empty string, KEY=, is set: a
+// string key is "" rather than its default, and other types fail to
+// convert. It fails when the value doesn't convert to T, or when a
+// required key is missing. The error leaves the value out.
+func (k *TypedKey[T]) Lookup(cfg *EnvConfig) (T, error) {
+	raw, ok := cfg.Lookup(k.name)
+	if !ok {
+		if k.required && !k.hasDef {
+			return k.def, fmt.Errorf("%s: %w", k.name, ErrKeyNotFound)
+		}
+		return k.def, nil
+	}
+	value, err := k.parse(raw)
+	if err != nil {
+		return k.def, invalidValue(k.name, reflect.TypeFor[T]().String())
+	}
+	return value, nil
+}
+
+// Get is Lookup for callers that are fine with the default: a missing or
+// invalid value yields it, and an invalid one is logged.
+func (k *TypedKey[T]) Get(cfg *EnvConfig) T {
+	value, err := k.Lookup(cfg)
+	if err != nil {
+		slog.Warn("using default for config key", "key", k.name, "error", err)
+	}
+	return value
+}
+
+func parseTypedValue[T any](raw string) (T, error) {
+	var value T
+	err := setEnvconfigField(raw, reflect.ValueOf(&value).Elem())
+	return value, err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTypedKeyEmptyIsNotMissing(t *testing.T) {
+	cfg := &EnvConfig{envState: envState{values: map[string]string{
+		"GREETING": "",
+		"DB_PORT":  "",
+		"TIMEOUT":  "2s",
+	}}}
+
+	greeting := Key[string]("GREETING", Default("hello"))
+	if got, err := greeting.Lookup(cfg); err != nil || got != "" {
+		t.Errorf("GREETING= Lookup() = %q, %v, want the empty value", got, err)
+	}
+	unset := Key[string]("FAREWELL", Default("bye"))
+	if got, err := unset.Lookup(cfg); err != nil || got != "bye" {
+		t.Errorf("unset Lookup() = %q, %v, want the default", got, err)
+	}
+
+	port := Key[int]("DB_PORT", Default(5432))
+	if _, err := port.Lookup(cfg); err == nil {
+		t.Error("DB_PORT= Lookup() succeeded, want an invalid int")
+	}
+	if got := port.Get(cfg); got != 5432 {
+		t.Errorf("DB_PORT= Get() = %d, want the default", got)
+	}
+
+	if _, err := Key[string]("GREETING", Required[string]()).Lookup(cfg); err != nil {
+		t.Errorf("required GREETING= Lookup() error = %v", err)
+	}
+	if _, err := Key[string]("FAREWELL", Required[string]()).Lookup(cfg); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("required unset Lookup() error = %v, want ErrKeyNotFound", err)
+	}
+	if got := Key[time.Duration]("TIMEOUT").Get(cfg); got != 2*time.Second {
+		t.Errorf("TIMEOUT Get() = %v, want 2s", got)
+	}
+}
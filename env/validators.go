@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validators are the formats a validate tag can name. They only check
+// fields that are set; pair them with required:"true" to demand a value.
+var validators = map[string]struct {
+	check   func(value string) bool
+	message string
+}{
+	"url":      {validURL, "must be a URL with a scheme and host"},
+	"hostname": {validHostname, "must be a hostname or IP address"},
+	"port":     {validPort, "must be a port between 1 and 65535"},
+	"duration": {validDuration, "must be a duration like 30s or 5m"},
+	"email":    {validEmail, "must be an email address"},
+	"base64":   {validBase64, "must be base64"},
+	"pem":      {validPEM, "must be PEM, like -----BEGIN CERTIFICATE-----"},
+}
+
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+func validHostname(value string) bool {
+	return net.ParseIP(value) != nil || (len(value) <= 253 && hostnameRegexp.MatchString(value))
+}
+
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+func validDuration(value string) bool {
+	_, err := time.ParseDuration(value)
+	return err == nil
+}
+
+func validEmail(value string) bool {
+	addr, err := mail.ParseAddress(value)
+	return err == nil && addr.Address == value
+}
+
+func validBase64(value string) bool {
+	_, err := decodeBase64("", value)
+	return err == nil
+}
+
+// validPEM accepts one or more PEM blocks and nothing else, such as a
+// certificate chain.
+func validPEM(value string) bool {
+	rest := []byte(strings.TrimSpace(value))
+	if len(rest) == 0 {
+		return false
+	}
+	for len(rest) > 0 {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return false
+		}
+		rest = []byte(strings.TrimSpace(string(rest)))
+	}
+	return true
+}
+
+// checkFieldValidators applies the validate tags of fields, a
+// comma-separated list of formats and cross-field checks that name other
+// fields of the same struct:
+//
+//	Host     string `validate:"hostname,required_with=Port"`
+//	Port     int    `validate:"port"`
+//	RedisURL string `validate:"url,xor=Host Port"`
+//
+// required_with and required_without make the field required when any of
+// the others is set or unset, excluded_with forbids it when any is set,
+// and xor wants either the field or all of the others, not both.
+func checkFieldValidators(fields []envconfigField) ([]RuleFailure, error) {
+	var failures []RuleFailure
+	for _, info := range fields {
+		tag := info.tags.Get("validate")
+		if tag == "" {
+			continue
+		}
+		set := !info.field.IsZero()
+		for rule := range strings.SplitSeq(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			var message string
+			var failed bool
+			if v, ok := validators[name]; ok {
+				failed, message = set && !v.check(fieldString(info.field)), v.message
+			} else {
+				others, err := siblingFields(info, name, param)
+				if err != nil {
+					return nil, err
+				}
+				failed, message = crossFieldFails(name, set, others)
+				message += " " + strings.Join(strings.Fields(param), ", ")
+			}
+			if failed {
+				failures = append(failures, RuleFailure{Rule: name, Key: info.key, Message: message})
+			}
+		}
+	}
+	return failures, nil
+}
+
+func siblingFields(info envconfigField, rule, param string) ([]bool, error) {
+	switch rule {
+	case "required_with", "required_without", "excluded_with", "xor":
+	default:
+		return nil, fmt.Errorf("%s: unknown validate rule %q", info.name, rule)
+	}
+	names := strings.Fields(param)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: validate rule %s needs field names", info.name, rule)
+	}
+	set := make([]bool, 0, len(names))
+	for _, name := range names {
+		other := info.owner.FieldByName(name)
+		if !other.IsValid() {
+			return nil, fmt.Errorf("%s: validate rule %s names unknown field %s", info.name, rule, name)
+		}
+		set = append(set, !other.IsZero())
+	}
+	return set, nil
+}
+
+func crossFieldFails(rule string, set bool, others []bool) (bool, string) {
+	anySet, allSet := false, true
+	for _, s := range others {
+		anySet = anySet || s
+		allSet = allSet && s
+	}
+	switch rule {
+	case "required_with":
+		return !set && anySet, "is required with"
+	case "required_without":
+		return !set && !allSet, "is required without"
+	case "excluded_with":
+		return set && anySet, "must be empty with"
+	}
+	// xor: the field alone, or the complete group alone.
+	if set {
+		return anySet, "must be empty with"
+	}
+	return !allSet, "is required without"
+}
+
+func fieldString(field reflect.Value) string {
+	if d, ok := field.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if field.Kind() == reflect.String {
+		return field.String()
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// WithValidation applies the validate tags of EnvConfig, such as url on
+// the URL fields and port on DB_PORT, and rejects a config that fails them
+// with a *RuleError. LoadSOPSEnvToSystem checks them before setting any
+// variable. ProcessSOPSEnv always applies the tags of its spec.
+func WithValidation() Option {
+	return func(o *loadOptions) {
+		o.validation = true
+	}
+}
+
+func validateEnvConfig(config *EnvConfig) error {
+	s := reflect.ValueOf(config).Elem()
+	var fields []envconfigField
+	for i := range s.NumField() {
+		ftype := s.Type().Field(i)
+		key := ftype.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		fields = append(fields, envconfigField{name: ftype.Name, key: key, field: s.Field(i), tags: ftype.Tag, owner: s})
+	}
+	failures, err := checkFieldValidators(fields)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return &RuleError{File: config.file, Failures: failures}
+	}
+	return nil
+}
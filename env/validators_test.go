@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"go-sops-env/sopstest"
+)
+
+func TestWithValidationInBothLoaders(t *testing.T) {
+	t.Setenv("DB_HOST", "")
+	t.Setenv("DB_PORT", "")
+	os.Unsetenv("DB_HOST")
+	os.Unsetenv("DB_PORT")
+
+	fake := sopstest.NewFake()
+	fake.SetFile("config.sops.env", map[string]string{"DB_HOST": "db.internal", "DB_PORT": "99999"})
+
+	var ruleErr *RuleError
+	if _, err := LoadSOPSEnv("config.sops.env", WithDecryptor(fake), WithValidation()); !errors.As(err, &ruleErr) {
+		t.Errorf("LoadSOPSEnv() error = %v, want a *RuleError", err)
+	}
+	err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake), WithValidation())
+	if !errors.As(err, &ruleErr) || len(ruleErr.Failures) != 1 || ruleErr.Failures[0].Rule != "port" {
+		t.Fatalf("LoadSOPSEnvToSystem() error = %v, want the port rule to fail", err)
+	}
+	if _, set := os.LookupEnv("DB_HOST"); set {
+		t.Error("LoadSOPSEnvToSystem set DB_HOST although validation failed")
+	}
+
+	fake.SetFile("config.sops.env", map[string]string{"DB_HOST": "db.internal", "DB_PORT": "5432"})
+	if err := LoadSOPSEnvToSystem("config.sops.env", WithDecryptor(fake), WithValidation(), WithAllowedKeys("DB_HOST", "DB_PORT")); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("DB_PORT"); got != "5432" {
+		t.Errorf("DB_PORT = %q, want 5432", got)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+func runImport(args []string) error {
+	if len(args) == 0 || args[0] != "vault" {
+		return errors.New("usage: import vault -path secret/app -o config.sops.env, vault is the only source so far")
+	}
+	return runImportVault(args[1:])
+}
+
+// runImportVault reads one Vault KV secret and writes it as an encrypted
+// env file. The plaintext is piped to sops and never written to disk.
+func runImportVault(args []string) error {
+	fs := flag.NewFlagSet("import vault", flag.ContinueOnError)
+	path := fs.String("path", "", "KV secret to import, e.g. secret/app")
+	out := fs.String("o", "config.sops.env", "encrypted env file to write")
+	ageRecipients := fs.String("recipients", "", "comma-separated age recipients, defaults to the .sops.yaml creation rules")
+	pgp := fs.String("pgp", "", "comma-separated PGP fingerprints to encrypt for")
+	upper := fs.Bool("upper", false, "write keys in UPPER_SNAKE_CASE, api-key becoming API_KEY")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for Vault")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-path is required")
+	}
+	if filepath.Ext(*out) != ".env" {
+		return fmt.Errorf("%s: the imported file is in env format, name it *.sops.env", *out)
+	}
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite", *out)
+	}
+	sopsFlags, err := recipientFlags(*ageRecipients, *pgp)
+	if err != nil {
+		return err
+	}
+	if err := checkRecipients(*out, sopsFlags); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	data, err := readVaultKV(ctx, *path)
+	if err != nil {
+		return err
+	}
+	content, keys, err := vaultDotenv(data, *upper)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *path, err)
+	}
+
+	if err := encryptToFile(*out, "/dev/stdin", strings.NewReader(content), sopsFlags...); err != nil {
+		return err
+	}
+	fmt.Printf("🔒 Imported %d keys from vault:%s into %s\n", keys, *path, *out)
+	return nil
+}
+
+// readVaultKV reads a KV secret using the usual VAULT_ADDR, VAULT_TOKEN,
+// VAULT_NAMESPACE and VAULT_CACERT variables, or ~/.vault-token. It asks
+// Vault which mount path belongs to and whether it is KV v1 or v2, as the
+// vault CLI does.
+func readVaultKV(ctx context.Context, path string) (map[string]any, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.Trim(path, "/")
+	mount, version := vaultMount(ctx, client, path)
+	secretPath := strings.TrimPrefix(path, mount+"/")
+
+	var secret *vault.KVSecret
+	if version == "1" {
+		secret, err = client.KVv1(mount).Get(ctx, secretPath)
+	} else {
+		secret, err = client.KVv2(mount).Get(ctx, secretPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault:%s: %w", path, err)
+	}
+	if len(secret.Data) == 0 {
+		return nil, fmt.Errorf("vault:%s has no keys", path)
+	}
+	return secret.Data, nil
+}
+
+func newVaultClient() (*vault.Client, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the Vault client: %w", err)
+	}
+	if client.Token() == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if token, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				client.SetToken(strings.TrimSpace(string(token)))
+			}
+		}
+	}
+	if client.Token() == "" {
+		return nil, errors.New("no Vault token, set VAULT_TOKEN or run `vault login`")
+	}
+	return client, nil
+}
+
+// vaultMount returns the mount of path and its KV version. Without
+// permission to look it up, it assumes a KV v2 mount named after the first
+// path segment, like secret/.
+func vaultMount(ctx context.Context, client *vault.Client, path string) (string, string) {
+	fallback, _, _ := strings.Cut(path, "/")
+	info, err := client.Logical().ReadWithContext(ctx, "sys/internal/ui/mounts/"+path)
+	if err != nil || info == nil {
+		return fallback, "2"
+	}
+	mount, _ := info.Data["path"].(string)
+	if mount == "" {
+		return fallback, "2"
+	}
+	version := "1"
+	if options, ok := info.Data["options"].(map[string]any); ok {
+		if v, ok := options["version"].(string); ok && v != "" {
+			version = v
+		}
+	}
+	return strings.TrimSuffix(mount, "/"), version
+}
+
+// vaultDotenv renders a secret's data as env lines sorted by key. Values
+// that aren't strings, like numbers or nested objects, are written as JSON.
+func vaultDotenv(data map[string]any, upper bool) (string, int, error) {
+	values := make(map[string]string, len(data))
+	for key, raw := range data {
+		if upper {
+			key = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+		}
+		if !validEnvKey(key) {
+			return "", 0, fmt.Errorf("key %q is not a valid env variable name, try -upper", key)
+		}
+		if _, ok := values[key]; ok {
+			return "", 0, fmt.Errorf("two keys become %s", key)
+		}
+
+		value, err := remoteValue(raw)
+		if err != nil {
+			return "", 0, fmt.Errorf("key %s: %w", key, err)
+		}
+		values[key] = value
+	}
+
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		fmt.Fprintf(&b, "%s=%s\n", key, quoteDotenvValue(values[key]))
+	}
+	return b.String(), len(values), nil
+}
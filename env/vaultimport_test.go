@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestImportVault(t *testing.T) {
+	installSOPSScript(t, encryptingSOPS)
+	server := httptest.NewServer(&fakeVault{data: map[string]any{"DB_PASSWORD": "hunter2"}, version: 1})
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test")
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(".sops.yaml", []byte("creation_rules: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const existing = "DB_PASSWORD=ENC[AES256_GCM,data:old]\n"
+	if err := os.WriteFile("config.sops.env", []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-path", "secret/app", "-force"}
+
+	t.Setenv("FAKE_SOPS_FAIL", "1")
+	if err := runImportVault(args); err == nil || !strings.Contains(err.Error(), "no key could encrypt") {
+		t.Fatalf("runImportVault() error = %v, want the sops error", err)
+	}
+	if data, err := os.ReadFile("config.sops.env"); err != nil || string(data) != existing {
+		t.Errorf("config.sops.env after a failed import = %q, %v, want it unchanged", data, err)
+	}
+
+	t.Setenv("FAKE_SOPS_FAIL", "")
+	if err := runImportVault(args); err != nil {
+		t.Fatalf("runImportVault() error = %v", err)
+	}
+	data, err := os.ReadFile("config.sops.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# as config.sops.env\nENC DB_PASSWORD=hunter2\n"; string(data) != want {
+		t.Errorf("config.sops.env = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runView prints the keys of an encrypted file with masked values and, from
+// its provenance file, who owns each one and when it was last rotated.
+func runView(args []string) error {
+	fs := flag.NewFlagSet("view", flag.ContinueOnError)
+	filename := fs.String("f", "config.sops.env", "encrypted env file to view")
+	metaFile := fs.String("meta", "", "provenance file, defaults to config.meta.yaml next to the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	explicit := *metaFile != ""
+	if !explicit {
+		*metaFile = ProvenanceFile(*filename)
+	}
+
+	file, err := readSOPSEnvOrdered(context.Background(), *filename, newLoadOptions(nil))
+	if err != nil {
+		return err
+	}
+	provenance, err := LoadProvenance(*metaFile)
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return err
+	}
+
+	report := &viewReport{File: *filename, Keys: make([]viewEntry, 0, len(file.keys))}
+	for _, key := range file.keys {
+		value := file.values[key]
+		entry := viewEntry{Key: key, Value: DefaultMaskPolicy.MaskValue(key, value), Secret: DefaultMaskPolicy.IsSecretValue(key, value)}
+		if p, ok := provenance[key]; ok {
+			entry.Provenance = &p
+		}
+		report.Keys = append(report.Keys, entry)
+	}
+	for key := range provenance {
+		if _, ok := file.values[key]; !ok {
+			report.UnknownProvenance = append(report.UnknownProvenance, key)
+		}
+	}
+	slices.Sort(report.UnknownProvenance)
+
+	if jsonOutput() {
+		return writeJSON(report)
+	}
+	report.print(*metaFile)
+	return nil
+}
+
+// viewReport is what view prints, with values masked.
+type viewReport struct {
+	File string      `json:"file"`
+	Keys []viewEntry `json:"keys"`
+	// UnknownProvenance lists keys of the provenance file the file lacks.
+	UnknownProvenance []string `json:"unknown_provenance,omitempty"`
+}
+
+type viewEntry struct {
+	Key        string         `json:"key"`
+	Value      string         `json:"value"`
+	Secret     bool           `json:"secret,omitempty"`
+	Provenance *KeyProvenance `json:"provenance,omitempty"`
+}
+
+func (r *viewReport) print(metaFile string) {
+	fmt.Printf("🔓 %s (%d keys)\n", r.File, len(r.Keys))
+	width := 0
+	for _, entry := range r.Keys {
+		width = max(width, len(entry.Key))
+	}
+	for _, entry := range r.Keys {
+		line := fmt.Sprintf("  %-*s  %s", width, entry.Key, entry.Value)
+		if entry.Provenance != nil {
+			line += "  " + describeProvenance(*entry.Provenance)
+		}
+		fmt.Println(line)
+	}
+	if len(r.UnknownProvenance) > 0 {
+		fmt.Printf("ℹ️  %s lists keys the file doesn't have: %s\n", metaFile, strings.Join(r.UnknownProvenance, ", "))
+	}
+}
+
+func describeProvenance(p KeyProvenance) string {
+	var parts []string
+	if p.Owner != "" {
+		owner := "👤 " + p.Owner
+		if p.Contact != "" {
+			owner += " (" + p.Contact + ")"
+		}
+		parts = append(parts, owner)
+	}
+	if rotated, ok := p.RotatedAt(); ok {
+		parts = append(parts, fmt.Sprintf("🔄 rotated %s, %d days ago", p.Rotated, int(time.Since(rotated).Hours()/24)))
+	}
+	if p.Runbook != "" {
+		parts = append(parts, "📖 "+p.Runbook)
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+func runWatch(args []string) error {
+	cfg, err := parseSupervisorFlags("watch", args, true)
+	if err != nil {
+		return err
+	}
+	return supervise(cfg)
+}
+
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	sig, ok := signalsByName[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signal %q", name)
+	}
+	return sig, nil
+}
+
+type childProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	code int
+}
+
+func startChild(args []string, env map[string]string, r *reaper) (*childProcess, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = mergeEnv(os.Environ(), env)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	child := &childProcess{cmd: cmd, done: make(chan struct{})}
+	if r != nil {
+		if err := r.start(cmd, child); err != nil {
+			return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+		}
+		return child, nil
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	go func() {
+		err := cmd.Wait()
+		child.exited(exitCode(cmd.ProcessState), err)
+	}()
+
+	return child, nil
+}
+
+func (c *childProcess) exited(code int, err error) {
+	name := c.cmd.Args[0]
+	switch {
+	case err != nil:
+		log.Printf("⚠️ %s exited: %v", name, err)
+	case code != 0:
+		log.Printf("⚠️ %s exited with code %d", name, code)
+	default:
+		log.Printf("%s exited", name)
+	}
+	c.code = code
+	close(c.done)
+}
+
+func (c *childProcess) signal(sig os.Signal) error {
+	select {
+	case <-c.done:
+		return errors.New("child is not running")
+	default:
+	}
+	return c.cmd.Process.Signal(sig)
+}
+
+func (c *childProcess) stop(sig os.Signal, grace time.Duration) {
+	if err := c.signal(sig); err != nil {
+		return
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(grace):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+func mergeEnv(base []string, env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	merged := append([]string{}, base...)
+	for _, key := range keys {
+		merged = append(merged, key+"="+env[key])
+	}
+	return merged
+}
+
+func watchFile(ctx context.Context, filename string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		last, _ := fileDigest(filename)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			digest, err := fileDigest(filename)
+			if err != nil || digest == last {
+				continue
+			}
+			last = digest
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
+
+func fileDigest(filename string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return digest, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
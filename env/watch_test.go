@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchRestartsChildOnChange(t *testing.T) {
+	installFakeSOPSBinary(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.sops.env")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(file, []byte("WATCH_KEY=v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first child waits to be restarted; the one started with v2 exits.
+	script := `echo "$WATCH_KEY" >> "$0"; [ "$WATCH_KEY" = v2 ] && exit 3; exec sleep 30`
+	done := make(chan error, 1)
+	go func() {
+		done <- runWatch([]string{"-f", file, "-interval", "10ms", "-grace", "5s", "--", "sh", "-c", script, out})
+	}()
+
+	waitForFile(t, out, "v1\n")
+	if err := os.WriteFile(file, []byte("WATCH_KEY=v2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		var exit *ExitCodeError
+		if !errors.As(err, &exit) || exit.Code != 3 {
+			t.Errorf("runWatch() error = %v, want the child's exit status 3", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the child was not restarted after the file changed")
+	}
+	if data, _ := os.ReadFile(out); string(data) != "v1\nv2\n" {
+		t.Errorf("children saw %q, want v1 then v2", data)
+	}
+}
+
+func waitForFile(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %q, want %q", path, data, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchFileReportsChanges(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.sops.env")
+	if err := os.WriteFile(file, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changes := watchFile(t.Context(), file, 5*time.Millisecond)
+
+	select {
+	case <-changes:
+		t.Fatal("change reported for an unchanged file")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := os.WriteFile(file, []byte("A=2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported after the file was rewritten")
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	got := mergeEnv([]string{"PATH=/bin", "KEY=old"}, map[string]string{"KEY": "new", "A": "1"})
+	// The file's values come last, so they win over the inherited ones.
+	if want := []string{"PATH=/bin", "KEY=old", "A=1", "KEY=new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"INT", "sigint", "SIGINT"} {
+		if sig, err := parseSignal(name); err != nil || sig != os.Interrupt {
+			t.Errorf("parseSignal(%q) = %v, %v, want SIGINT", name, sig, err)
+		}
+	}
+	if _, err := parseSignal("NOPE"); err == nil || !strings.Contains(err.Error(), "NOPE") {
+		t.Errorf("parseSignal(NOPE) error = %v", err)
+	}
+}
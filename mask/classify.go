@@ -0,0 +1,100 @@
+package mask
+
+import (
+	"math"
+	"strings"
+)
+
+// KnownSecretPrefixes start tokens issued by common providers, such as
+// Stripe, GitHub, AWS, Slack and SendGrid, and PEM blocks.
+var KnownSecretPrefixes = []string{
+	"sk_live_", "sk_test_", "rk_live_", "pk_live_",
+	"ghp_", "gho_", "ghs_", "ghu_", "github_pat_", "glpat-",
+	"AKIA", "ASIA", "AIza",
+	"xoxb-", "xoxp-", "xapp-",
+	"SG.", "-----BEGIN",
+}
+
+func (p *Policy) IsSecretValue(key, value string) bool {
+	secret, _ := p.Classify(key, value)
+	return secret
+}
+
+// Classify reports whether a key/value pair should be treated as secret and
+// why: "allow", "deny", "name", "prefix", "entropy", or "" when it isn't.
+func (p *Policy) Classify(key, value string) (bool, string) {
+	p = p.OrDefault()
+
+	for _, allowed := range p.Allow {
+		if strings.EqualFold(allowed, key) {
+			return false, "allow"
+		}
+	}
+	for _, denied := range p.Deny {
+		if strings.EqualFold(denied, key) {
+			return true, "deny"
+		}
+	}
+
+	upper := strings.ToUpper(key)
+	for _, pattern := range p.Patterns {
+		if strings.Contains(upper, strings.ToUpper(pattern)) {
+			return true, "name"
+		}
+	}
+
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true, "prefix"
+		}
+	}
+
+	if p.Entropy && len(value) >= p.MinEntropyLength && looksRandom(value) {
+		return true, "entropy"
+	}
+
+	return false, ""
+}
+
+// looksRandom applies the thresholds commonly used by secret scanners: hex
+// strings carry at most 4 bits per character, base64-like tokens up to 6.
+// Values containing other characters (URLs, hostnames, prose) are skipped.
+func looksRandom(value string) bool {
+	switch {
+	case isCharset(value, "0123456789abcdefABCDEF"):
+		return shannonEntropy(value) >= 3.0
+	case isCharset(value, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=_-"):
+		return shannonEntropy(value) >= 4.5
+	default:
+		return false
+	}
+}
+
+func isCharset(value, charset string) bool {
+	for _, r := range value {
+		if !strings.ContainsRune(charset, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		freq := float64(count) / float64(total)
+		entropy -= freq * math.Log2(freq)
+	}
+	return entropy
+}
@@ -0,0 +1,3 @@
+module go-sops-mask
+
+go 1.24.3
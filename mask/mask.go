@@ -0,0 +1,71 @@
+// Package mask decides which config values are secret and how to hide
+// them. It is shared by go-sops-env and go-sops-yaml, which each set their
+// own Default.
+package mask
+
+import (
+	"strings"
+)
+
+type Policy struct {
+	// Patterns are matched case-insensitively against key names, or key
+	// paths such as storage.psql.password.
+	Patterns []string
+	// Allow and Deny list exact keys that are never or always secret,
+	// taking precedence over Patterns.
+	Allow []string
+	Deny  []string
+	// Prefixes mark values as secret regardless of their key name.
+	Prefixes []string
+	// Entropy enables a Shannon-entropy check on values of at least
+	// MinEntropyLength characters, catching random tokens under dull names.
+	Entropy          bool
+	MinEntropyLength int
+	// FullMask hides the whole value instead of revealing its ends.
+	FullMask bool
+	// Reveal is how many characters are shown at each end of a value.
+	Reveal int
+	// MinRevealLength is the shortest value that is partially revealed;
+	// anything shorter is masked entirely.
+	MinRevealLength int
+}
+
+// Default is the policy a nil *Policy stands for. Programs replace it with
+// their own from an init function.
+var Default = &Policy{
+	Patterns:         []string{"PASSWORD", "SECRET", "KEY", "TOKEN", "CREDENTIAL", "PRIVATE"},
+	Prefixes:         KnownSecretPrefixes,
+	Entropy:          true,
+	MinEntropyLength: 20,
+	Reveal:           2,
+	MinRevealLength:  5,
+}
+
+// OrDefault returns p, or Default if p is nil.
+func (p *Policy) OrDefault() *Policy {
+	if p == nil {
+		return Default
+	}
+	return p
+}
+
+func (p *Policy) IsSecret(key string) bool {
+	secret, _ := p.Classify(key, "")
+	return secret
+}
+
+func (p *Policy) Mask(value string) string {
+	p = p.OrDefault()
+
+	if p.FullMask || len(value) < p.MinRevealLength || len(value) <= 2*p.Reveal {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:p.Reveal] + strings.Repeat("*", len(value)-2*p.Reveal) + value[len(value)-p.Reveal:]
+}
+
+func (p *Policy) MaskValue(key, value string) string {
+	if p.IsSecretValue(key, value) {
+		return p.Mask(value)
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package mask
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	policy := &Policy{
+		Patterns:         []string{"PASSWORD"},
+		Allow:            []string{"PUBLIC_PASSWORD_HINT"},
+		Deny:             []string{"storage.redis.url"},
+		Prefixes:         KnownSecretPrefixes,
+		Entropy:          true,
+		MinEntropyLength: 20,
+	}
+	tests := []struct {
+		key, value string
+		secret     bool
+		reason     string
+	}{
+		{"PUBLIC_PASSWORD_HINT", "x", false, "allow"},
+		{"STORAGE.REDIS.URL", "redis://cache", true, "deny"},
+		{"db_password", "x", true, "name"},
+		{"STRIPE", "sk_live_abc", true, "prefix"},
+		{"SESSION", "q8Zr2LxV9mNpT4wKc7YbH1sJ", true, "entropy"},
+		{"HOST", "db.internal.example.com", false, ""},
+	}
+	for _, tt := range tests {
+		secret, reason := policy.Classify(tt.key, tt.value)
+		if secret != tt.secret || reason != tt.reason {
+			t.Errorf("Classify(%q) = %v, %q, want %v, %q", tt.key, secret, reason, tt.secret, tt.reason)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	policy := &Policy{Reveal: 2, MinRevealLength: 5}
+	for value, want := range map[string]string{
+		"hunter2": "hu***r2",
+		"abcd":    "****",
+		"":        "",
+	} {
+		if got := policy.Mask(value); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", value, got, want)
+		}
+	}
+	if got := (&Policy{FullMask: true}).Mask("hunter2"); got != "*******" {
+		t.Errorf("FullMask Mask() = %q", got)
+	}
+}
+
+func TestNilPolicyUsesDefault(t *testing.T) {
+	previous := Default
+	defer func() { Default = previous }()
+	Default = &Policy{Deny: []string{"REDIS_URL"}, FullMask: true}
+
+	var policy *Policy
+	if got := policy.MaskValue("REDIS_URL", "redis://cache"); got != "*************" {
+		t.Errorf("nil policy MaskValue() = %q, want Default to mask it", got)
+	}
+}
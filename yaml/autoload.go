@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultConfigFile = "config.sops.yaml"
+	appEnvVar         = "APP_ENV"
+	podInfoDirVar     = "PODINFO_DIR"
+	defaultPodInfoDir = "/etc/podinfo"
+	metadataTimeout   = 300 * time.Millisecond
+)
+
+var (
+	environmentPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	environmentLabels  = []string{"app.kubernetes.io/environment", "environment", "env"}
+)
+
+// LoadAuto loads config.<env>.sops.yaml for the detected environment, or
+// config.sops.yaml when none is detected. See DetectEnvironment.
+func LoadAuto(opts ...Option) (*Config, error) {
+	filename, err := AutoConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadSOPSConfig(filename, opts...)
+}
+
+// AutoConfigFile returns the file LoadAuto would load. Once an environment
+// is detected its file must exist; falling back to the default file could
+// start production with development credentials.
+func AutoConfigFile() (string, error) {
+	if raw := os.Getenv(appEnvVar); raw != "" && normalizeEnvironment(raw) == "" {
+		return "", fmt.Errorf("$%s=%q is not a valid environment name", appEnvVar, raw)
+	}
+	env, source := DetectEnvironment(context.Background())
+	if env == "" {
+		return DefaultConfigFile, nil
+	}
+	filename := "config." + env + ".sops.yaml"
+	if _, err := os.Stat(filename); err != nil {
+		return "", fmt.Errorf("environment %q (from %s) has no config file: %w", env, source, err)
+	}
+	return filename, nil
+}
+
+// DetectEnvironment returns the environment name and where it came from,
+// checking in order:
+//
+//   - $APP_ENV
+//   - a Kubernetes downward API labels file ($PODINFO_DIR/labels, default
+//     /etc/podinfo/labels) with an app.kubernetes.io/environment,
+//     environment or env label
+//   - the Environment instance tag on EC2 (with tags in instance metadata
+//     enabled) or the environment instance attribute on GCE
+//
+// The metadata servers are only asked when the machine's DMI data says it
+// runs on that cloud. It returns "" when nothing is found.
+func DetectEnvironment(ctx context.Context) (env, source string) {
+	if env := normalizeEnvironment(os.Getenv(appEnvVar)); env != "" {
+		return env, "$" + appEnvVar
+	}
+
+	dir := os.Getenv(podInfoDirVar)
+	if dir == "" {
+		dir = defaultPodInfoDir
+	}
+	if env := podLabelEnvironment(filepath.Join(dir, "labels")); env != "" {
+		return env, "pod labels"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	switch vendor := dmi("sys_vendor") + " " + dmi("product_name"); {
+	case strings.Contains(vendor, "Amazon EC2"):
+		if env := normalizeEnvironment(ec2Tag(ctx, "Environment")); env != "" {
+			return env, "EC2 instance tags"
+		}
+	case strings.Contains(vendor, "Google"):
+		if env := normalizeEnvironment(gceAttribute(ctx, "environment")); env != "" {
+			return env, "GCE instance attributes"
+		}
+	}
+	return "", ""
+}
+
+// normalizeEnvironment lowercases env and rejects anything that isn't a
+// plain name, since it becomes part of a file path.
+func normalizeEnvironment(env string) string {
+	env = strings.ToLower(strings.TrimSpace(env))
+	if !environmentPattern.MatchString(env) {
+		return ""
+	}
+	return env
+}
+
+// podLabelEnvironment reads the downward API format, one key="value" per
+// line.
+func podLabelEnvironment(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	labels := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		labels[key] = value
+	}
+	for _, label := range environmentLabels {
+		if env := normalizeEnvironment(labels[label]); env != "" {
+			return env
+		}
+	}
+	return ""
+}
+
+func dmi(name string) string {
+	data, _ := os.ReadFile("/sys/class/dmi/id/" + name)
+	return strings.TrimSpace(string(data))
+}
+
+// ec2Tag reads an instance tag through IMDSv2.
+func ec2Tag(ctx context.Context, tag string) string {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, "http://169.254.169.254/latest/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token := metadataGet(req)
+	if token == "" {
+		return ""
+	}
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://169.254.169.254/latest/meta-data/tags/instance/"+tag, nil)
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return metadataGet(req)
+}
+
+func gceAttribute(ctx context.Context, name string) string {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/attributes/"+name, nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	return metadataGet(req)
+}
+
+func metadataGet(req *http.Request) string {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
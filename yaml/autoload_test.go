@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePodLabels(t *testing.T, labels string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "labels"), []byte(labels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(podInfoDirVar, dir)
+}
+
+func TestDetectEnvironment(t *testing.T) {
+	writePodLabels(t, "app=\"api\"\napp.kubernetes.io/environment=\"Staging\"\n")
+
+	t.Setenv(appEnvVar, " Production ")
+	if env, source := DetectEnvironment(context.Background()); env != "production" || source != "$APP_ENV" {
+		t.Errorf("with $APP_ENV: got %q from %q", env, source)
+	}
+
+	t.Setenv(appEnvVar, "")
+	if env, source := DetectEnvironment(context.Background()); env != "staging" || source != "pod labels" {
+		t.Errorf("with pod labels: got %q from %q", env, source)
+	}
+
+	writePodLabels(t, "environment=\"../prod\"\nenv=\"qa\"\n")
+	if env, _ := DetectEnvironment(context.Background()); env != "qa" {
+		t.Errorf("with a path in the first label: got %q, want qa", env)
+	}
+}
+
+func TestAutoConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(podInfoDirVar, t.TempDir())
+
+	t.Setenv(appEnvVar, "../production")
+	if _, err := AutoConfigFile(); err == nil {
+		t.Error("AutoConfigFile() accepted a path as the environment")
+	}
+
+	t.Setenv(appEnvVar, "staging")
+	if _, err := AutoConfigFile(); err == nil || !strings.Contains(err.Error(), `"staging"`) {
+		t.Errorf("AutoConfigFile() without config.staging.sops.yaml = %v", err)
+	}
+
+	if err := os.WriteFile("config.staging.sops.yaml", []byte(validConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if filename, err := AutoConfigFile(); err != nil || filename != "config.staging.sops.yaml" {
+		t.Fatalf("AutoConfigFile() = %q, %v", filename, err)
+	}
+
+	installFakeSOPS(t)
+	config, err := LoadAuto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Storage.PSQL.Password != "hunter22" {
+		t.Errorf("LoadAuto() loaded password %q", config.Storage.PSQL.Password)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// MustLoadSOPSConfig is LoadSOPSConfig for package-level variables and
+// tests: it panics if the file doesn't load.
+func MustLoadSOPSConfig(filename string, opts ...Option) *Config {
+	config, err := LoadSOPSConfig(filename, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("go-sops: load %s: %v", filename, err))
+	}
+	return config
+}
+
+// Bootstrap loads the config the way LoadAuto does, for main. If loading
+// or validation fails, it prints every problem and a hint to stderr and
+// exits with status 1, so mains don't each format their own log.Fatalf.
+func Bootstrap(opts ...Option) *Config {
+	var config *Config
+	filename, err := AutoConfigFile()
+	if err == nil {
+		config, err = LoadSOPSConfig(filename, opts...)
+	}
+	if err == nil {
+		return config
+	}
+	if filename == "" {
+		filename = "config"
+	}
+
+	writeStartupError(os.Stderr, filename, err)
+	os.Exit(1)
+	return nil
+}
+
+func writeStartupError(w io.Writer, filename string, err error) {
+	fmt.Fprintf(w, "❌ Failed to load %s\n", filename)
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(w, "   %s\n", strings.TrimRight(line, " \t"))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		fmt.Fprintln(w, "   sops said:")
+		for _, line := range strings.Split(strings.TrimSpace(string(exitErr.Stderr)), "\n") {
+			fmt.Fprintf(w, "     %s\n", line)
+		}
+	}
+	if hint := startupHint(filename, err); hint != "" {
+		fmt.Fprintf(w, "💡 %s\n", hint)
+	}
+}
+
+func startupHint(filename string, err error) string {
+	var validationErr *ValidationError
+	var strictErr *StrictError
+	var report *LoadReport
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "install sops: https://github.com/getsops/sops/releases"
+	case errors.Is(err, os.ErrNotExist):
+		return "set APP_ENV to pick another environment, or create the file with `sops " + filename + "`"
+	case errors.As(err, &validationErr), errors.As(err, &strictErr), errors.As(err, &report):
+		return "fix the keys above with `sops " + filename + "`"
+	case errors.As(err, new(*exec.ExitError)):
+		return "check that one of the file's keys is available, e.g. `gpg --list-secret-keys`"
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMustLoadSOPSConfig(t *testing.T) {
+	installFakeSOPS(t)
+	if config := MustLoadSOPSConfig(writeConfig(t, validConfig)); config.JWT.Auth != "jwt-signing-secret" {
+		t.Errorf("JWT.Auth = %q", config.JWT.Auth)
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "go-sops: load missing.sops.yaml: ") {
+			t.Errorf("panic = %q", msg)
+		}
+	}()
+	MustLoadSOPSConfig("missing.sops.yaml")
+	t.Error("MustLoadSOPSConfig() returned for a missing file")
+}
+
+func TestWriteStartupError(t *testing.T) {
+	installFakeSOPS(t)
+	_, invalid := LoadSOPSConfig(writeConfig(t, strings.Replace(validConfig, "auth: jwt-signing-secret", "auth: \"\"", 1)))
+	t.Chdir(t.TempDir())
+	t.Setenv(appEnvVar, "staging")
+	_, missing := AutoConfigFile()
+	failed := exec.Command("sh", "-c", "echo 'Failed to get the data key' >&2; exit 128").Run()
+	if exitErr, ok := failed.(*exec.ExitError); ok {
+		// Run doesn't capture stderr; Output, which the loader uses, does.
+		exitErr.Stderr = []byte("Failed to get the data key\n")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"invalid", invalid, []string{"   invalid config:\n", "💡 fix the keys above with `sops config.sops.yaml`"}},
+		{"missing", missing, []string{"💡 set APP_ENV to pick another environment"}},
+		{"not installed", &exec.Error{Name: "sops", Err: exec.ErrNotFound}, []string{"💡 install sops"}},
+		{"sops failed", failed, []string{"   sops said:\n     Failed to get the data key\n", "💡 check that one of the file's keys is available"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("no error to report")
+			}
+			var out bytes.Buffer
+			writeStartupError(&out, "config.sops.yaml", tt.err)
+			if !strings.HasPrefix(out.String(), "❌ Failed to load config.sops.yaml\n") {
+				t.Errorf("output = %q", out.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output is missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestBootstrapExits(t *testing.T) {
+	if os.Getenv("GO_SOPS_TEST_BOOTSTRAP") != "" {
+		Bootstrap()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBootstrapExits$")
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), "GO_SOPS_TEST_BOOTSTRAP=1", appEnvVar+"=staging")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Bootstrap() exited with %v, want status 1", err)
+	}
+	if !strings.Contains(stderr.String(), `environment "staging" (from $APP_ENV) has no config file`) ||
+		!strings.Contains(stderr.String(), "💡 set APP_ENV to pick another environment") {
+		t.Errorf("stderr = %s", stderr.String())
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func decryptSOPSFile(filename string) ([]byte, error) {
+	decryptedData, err := exec.Command("sops", "-d", filename).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config file: %w", err)
+	}
+	return decryptedData, nil
+}
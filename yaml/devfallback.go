@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// WithDevFallback reads the plaintext file next to the encrypted one,
+// config.yaml for config.sops.yaml, when the encrypted file is missing or
+// sops isn't installed. It is for local development: it refuses when the
+// detected environment (see DetectEnvironment) is production, and logs a
+// warning every time it is used.
+func WithDevFallback() Option {
+	return func(o *loadOptions) {
+		o.devFallback = true
+	}
+}
+
+// readDevFallback returns the plaintext to use instead of filename, or
+// cause if the fallback doesn't apply.
+func readDevFallback(filename string, cause error) ([]byte, error) {
+	_, statErr := os.Stat(filename)
+	if !errors.Is(statErr, os.ErrNotExist) && !errors.Is(cause, exec.ErrNotFound) {
+		return nil, cause
+	}
+	if env, source := DetectEnvironment(context.Background()); env == "production" || env == "prod" {
+		return nil, fmt.Errorf("%w; not falling back to plaintext, environment is %s (from %s)", cause, env, source)
+	}
+
+	path := filepath.Join(filepath.Dir(filename), plaintextName(filepath.Base(filename)))
+	if path == filename {
+		return nil, cause
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, cause
+	}
+	log.Printf("⚠️⚠️⚠️ DEV FALLBACK: %v", cause)
+	log.Printf("⚠️⚠️⚠️ DEV FALLBACK: loading PLAINTEXT secrets from %s instead of %s. Never use this outside local development.", path, filename)
+	return data, nil
+}
+
+// plaintextName maps config.sops.yaml to config.yaml.
+func plaintextName(name string) string {
+	if i := strings.Index(name, ".sops."); i >= 0 {
+		return name[:i] + name[i+len(".sops"):]
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDevFallback(t *testing.T) {
+	t.Setenv(appEnvVar, "")
+	t.Setenv(podInfoDirVar, t.TempDir())
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.yaml")
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(validConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if _, err := LoadSOPSConfig(filename); err == nil {
+		t.Fatal("LoadSOPSConfig() without WithDevFallback read the plaintext file")
+	}
+
+	config, err := LoadSOPSConfig(filename, WithDevFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Storage.PSQL.Password != "hunter22" {
+		t.Errorf("password = %q", config.Storage.PSQL.Password)
+	}
+	if !strings.Contains(logs.String(), "DEV FALLBACK") {
+		t.Errorf("no warning logged: %s", logs.String())
+	}
+
+	t.Setenv(appEnvVar, "prod")
+	if _, err := LoadSOPSConfig(filename, WithDevFallback()); err == nil || !strings.Contains(err.Error(), "not falling back") {
+		t.Errorf("with APP_ENV=prod: %v, want a refusal", err)
+	}
+}
+
+func TestDevFallbackWithoutSOPS(t *testing.T) {
+	t.Setenv(appEnvVar, "development")
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.sops.yaml")
+	for _, name := range []string{"config.sops.yaml", "config.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(validConfig), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", t.TempDir())
+	log.SetOutput(new(bytes.Buffer))
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if _, err := LoadSOPSConfig(filename, WithDevFallback()); err != nil {
+		t.Errorf("LoadSOPSConfig() without sops = %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/IBM/sarama"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	franzscram "github.com/twmb/franz-go/pkg/sasl/scram"
+	"github.com/xdg-go/scram"
+)
+
+type Kafka struct {
+	Brokers       []string `yaml:"brokers" validate:"required,min=1"`
+	SASLMechanism string   `yaml:"sasl_mechanism,omitempty" validate:"omitempty,oneof=PLAIN SCRAM-SHA-256 SCRAM-SHA-512"`
+	Username      string   `yaml:"username,omitempty" validate:"required_with=SASLMechanism"`
+	Password      string   `yaml:"password,omitempty" validate:"required_with=SASLMechanism"`
+	TLS           bool     `yaml:"tls,omitempty"`
+	// CA, Cert and Key hold PEM data, so the client certificate is
+	// encrypted along with the rest of the file.
+	CA   string `yaml:"ca,omitempty"`
+	Cert string `yaml:"cert,omitempty" validate:"required_with=Key"`
+	Key  string `yaml:"key,omitempty" validate:"required_with=Cert"`
+}
+
+var ErrNoKafkaConfig = errors.New("config has no storage.kafka section")
+
+// TLSConfig returns nil when TLS is off. Setting CA or Cert implies TLS.
+func (k *Kafka) TLSConfig() (*tls.Config, error) {
+	if !k.TLS && k.CA == "" && k.Cert == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if k.CA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(k.CA)) {
+			return nil, errors.New("storage.kafka.ca contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	if k.Cert != "" {
+		cert, err := tls.X509KeyPair([]byte(k.Cert), []byte(k.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage.kafka client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// FranzOptions returns the seed brokers, SASL and TLS options for
+// kgo.NewClient; append topic and group options to them.
+func (c *Config) FranzOptions() ([]kgo.Opt, error) {
+	k := c.Storage.Kafka
+	if k == nil {
+		return nil, ErrNoKafkaConfig
+	}
+	opts := []kgo.Opt{kgo.SeedBrokers(k.Brokers...)}
+
+	tlsConfig, err := k.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	var mechanism sasl.Mechanism
+	switch k.SASLMechanism {
+	case "":
+	case "PLAIN":
+		mechanism = plain.Auth{User: k.Username, Pass: k.Password}.AsMechanism()
+	case "SCRAM-SHA-256":
+		mechanism = franzscram.Auth{User: k.Username, Pass: k.Password}.AsSha256Mechanism()
+	case "SCRAM-SHA-512":
+		mechanism = franzscram.Auth{User: k.Username, Pass: k.Password}.AsSha512Mechanism()
+	default:
+		return nil, fmt.Errorf("unsupported storage.kafka.sasl_mechanism %q", k.SASLMechanism)
+	}
+	if mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
+
+// SaramaConfig returns a sarama.NewConfig with the network settings
+// filled in. The brokers are in Storage.Kafka.Brokers.
+func (c *Config) SaramaConfig() (*sarama.Config, error) {
+	k := c.Storage.Kafka
+	if k == nil {
+		return nil, ErrNoKafkaConfig
+	}
+	cfg := sarama.NewConfig()
+
+	tlsConfig, err := k.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsConfig
+	}
+
+	if k.SASLMechanism != "" {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = k.Username
+		cfg.Net.SASL.Password = k.Password
+		switch k.SASLMechanism {
+		case "PLAIN":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case "SCRAM-SHA-256":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha256.New} }
+		case "SCRAM-SHA-512":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha512.New} }
+		default:
+			return nil, fmt.Errorf("unsupported storage.kafka.sasl_mechanism %q", k.SASLMechanism)
+		}
+	}
+	return cfg, nil
+}
+
+// scramClient is the sarama.SCRAMClient sarama leaves to the caller.
+type scramClient struct {
+	hash         func() hash.Hash
+	conversation *scram.ClientConversation
+}
+
+func (s *scramClient) Begin(userName, password, authzID string) error {
+	client, err := scram.HashGeneratorFcn(s.hash).NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	s.conversation = client.NewConversation()
+	return nil
+}
+
+func (s *scramClient) Step(challenge string) (string, error) {
+	return s.conversation.Step(challenge)
+}
+
+func (s *scramClient) Done() bool {
+	return s.conversation.Done()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// testCertPEM returns a self-signed certificate and its key as PEM.
+func testCertPEM(t *testing.T) (cert, key string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kafka-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestSaramaConfigSCRAM(t *testing.T) {
+	cert, key := testCertPEM(t)
+	config := &Config{Storage: Storage{Kafka: &Kafka{
+		Brokers:       []string{"kafka:9093"},
+		SASLMechanism: "SCRAM-SHA-512",
+		Username:      "app",
+		Password:      "hunter22",
+		CA:            cert,
+		Cert:          cert,
+		Key:           key,
+	}}}
+	cfg, err := config.SaramaConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("sarama rejects the config: %v", err)
+	}
+	if !cfg.Net.TLS.Enable || len(cfg.Net.TLS.Config.Certificates) != 1 || cfg.Net.TLS.Config.RootCAs == nil {
+		t.Errorf("TLS not configured: %+v", cfg.Net.TLS)
+	}
+	if cfg.Net.SASL.Mechanism != sarama.SASLTypeSCRAMSHA512 {
+		t.Errorf("mechanism = %s", cfg.Net.SASL.Mechanism)
+	}
+
+	// Run the exchange against a SCRAM server that knows the password.
+	stored := func() scram.StoredCredentials {
+		client, _ := scram.SHA512.NewClient("app", "hunter22", "")
+		return client.GetStoredCredentials(scram.KeyFactors{Salt: "salt", Iters: 4096})
+	}()
+	server, err := scram.SHA512.NewServer(func(string) (scram.StoredCredentials, error) { return stored, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConv := server.NewConversation()
+	client := cfg.Net.SASL.SCRAMClientGeneratorFunc()
+	if err := client.Begin(cfg.Net.SASL.User, cfg.Net.SASL.Password, ""); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := client.Step("")
+	for err == nil && !client.Done() {
+		var challenge string
+		if challenge, err = serverConv.Step(msg); err == nil {
+			msg, err = client.Step(challenge)
+		}
+	}
+	if err != nil || !serverConv.Valid() {
+		t.Errorf("SCRAM exchange failed: %v (server valid %v)", err, serverConv.Valid())
+	}
+}
+
+func TestFranzOptions(t *testing.T) {
+	config := &Config{Storage: Storage{Kafka: &Kafka{Brokers: []string{"a:9092", "b:9092"}, SASLMechanism: "PLAIN", Username: "u", Password: "p", TLS: true}}}
+	opts, err := config.FranzOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seed brokers, TLS dialer and SASL.
+	if len(opts) != 3 {
+		t.Errorf("got %d options, want 3", len(opts))
+	}
+}
+
+func TestKafkaConfigErrors(t *testing.T) {
+	if _, err := (&Config{}).SaramaConfig(); !errors.Is(err, ErrNoKafkaConfig) {
+		t.Errorf("SaramaConfig() error = %v, want ErrNoKafkaConfig", err)
+	}
+	if _, err := (&Config{}).FranzOptions(); !errors.Is(err, ErrNoKafkaConfig) {
+		t.Errorf("FranzOptions() error = %v, want ErrNoKafkaConfig", err)
+	}
+	bad := &Config{Storage: Storage{Kafka: &Kafka{Brokers: []string{"a:9092"}, CA: "not pem"}}}
+	if _, err := bad.FranzOptions(); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("FranzOptions() with a bad CA: %v", err)
+	}
+	bad.Storage.Kafka.CA, bad.Storage.Kafka.SASLMechanism = "", "GSSAPI"
+	if _, err := bad.SaramaConfig(); err == nil {
+		t.Error("SaramaConfig() accepted an unsupported mechanism")
+	}
+}
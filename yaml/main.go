@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
-	"log"
-	"os/exec"
+	"io"
+	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,87 +14,154 @@ import (
 type Config struct {
 	Storage Storage `yaml:"storage"`
 	JWT     JWT     `yaml:"jwt"`
+	SMTP    *SMTP   `yaml:"smtp,omitempty" validate:"omitempty"`
 }
 
 type Storage struct {
-	PSQL  PSQL  `yaml:"psql"`
-	Redis Redis `yaml:"redis"`
+	PSQL  PSQL   `yaml:"psql"`
+	Redis Redis  `yaml:"redis"`
+	Mongo *Mongo `yaml:"mongo,omitempty" validate:"omitempty"`
+	Kafka *Kafka `yaml:"kafka,omitempty" validate:"omitempty"`
 }
 
 type PSQL struct {
-	Host          string `yaml:"host"`
-	Port          int    `yaml:"port"`
-	Database      string `yaml:"database"`
-	Username      string `yaml:"username"`
-	Password      string `yaml:"password"`
-	PGPoolMaxConn int    `yaml:"pg_pool_max_conn"`
+	Host          string `yaml:"host" validate:"required,hostname_rfc1123|ip"`
+	Port          int    `yaml:"port" validate:"min=1,max=65535"`
+	Database      string `yaml:"database" validate:"required"`
+	Username      string `yaml:"username" validate:"required"`
+	Password      string `yaml:"password" validate:"required"`
+	PGPoolMaxConn int    `yaml:"pg_pool_max_conn" validate:"min=1"`
+	// Options are the other URL parameters, like sslmode, kept as given.
+	Options map[string]string `yaml:"options,omitempty"`
 }
 
 type Redis struct {
-	Addr     string `yaml:"addr"`
-	Port     int    `yaml:"port"`
+	Addr     string `yaml:"addr" validate:"required,hostname_rfc1123|ip"`
+	Port     int    `yaml:"port" validate:"min=1,max=65535"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
-	DB       int    `yaml:"db"`
+	DB       int    `yaml:"db" validate:"min=0"`
+	TLS      bool   `yaml:"tls,omitempty"`
 }
 
 type JWT struct {
-	Auth string `yaml:"auth"`
+	Auth string `yaml:"auth" validate:"required"`
 }
 
-func LoadSOPSConfig(filename string) (*Config, error) {
-	cmd := exec.Command("sops", "-d", filename)
-	decryptedData, err := cmd.Output()
+func LoadSOPSConfig(filename string, opts ...Option) (*Config, error) {
+	options := newLoadOptions(opts)
+
+	decryptedData, err := decryptSOPSFile(filename)
+	if err != nil && options.devFallback {
+		decryptedData, err = readDevFallback(filename, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	decryptedData, err = normalizeText(decryptedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt config file: %w", err)
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if options.softFail {
+		return loadWithReport(filename, decryptedData, options)
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(decryptedData, &config); err != nil {
+	if options.strict {
+		if err := checkStrict(decryptedData, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(decryptedData))
+	decoder.KnownFields(options.strict)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	if err := validateConfig(&config); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
-func main() {
-	config, err := LoadSOPSConfig("config.sops.yaml")
-	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+func PrintConfig(config *Config, policy *MaskPolicy) {
+	show := func(key string, value any) string {
+		return policy.MaskValue(key, fmt.Sprint(value))
 	}
 
 	fmt.Println("🔓 Successfully loaded and decrypted configuration:")
 	fmt.Println("====================================================")
 
 	fmt.Println("📊 Database Configuration:")
-	fmt.Printf("  Host: %s\n", config.Storage.PSQL.Host)
-	fmt.Printf("  Port: %d\n", config.Storage.PSQL.Port)
-	fmt.Printf("  Database: %s\n", config.Storage.PSQL.Database)
-	fmt.Printf("  Username: %s\n", config.Storage.PSQL.Username)
-	fmt.Printf("  Password: %s\n", config.Storage.PSQL.Password)
-	fmt.Printf("  Max Connections: %d\n", config.Storage.PSQL.PGPoolMaxConn)
+	fmt.Printf("  Host: %s\n", show("storage.psql.host", config.Storage.PSQL.Host))
+	fmt.Printf("  Port: %s\n", show("storage.psql.port", config.Storage.PSQL.Port))
+	fmt.Printf("  Database: %s\n", show("storage.psql.database", config.Storage.PSQL.Database))
+	fmt.Printf("  Username: %s\n", show("storage.psql.username", config.Storage.PSQL.Username))
+	fmt.Printf("  Password: %s\n", show("storage.psql.password", config.Storage.PSQL.Password))
+	fmt.Printf("  Max Connections: %s\n", show("storage.psql.pg_pool_max_conn", config.Storage.PSQL.PGPoolMaxConn))
 
 	fmt.Println("\n🔴 Redis Configuration:")
-	fmt.Printf("  Address: %s\n", config.Storage.Redis.Addr)
-	fmt.Printf("  Port: %d\n", config.Storage.Redis.Port)
-	fmt.Printf("  Username: %s\n", config.Storage.Redis.Username)
-	fmt.Printf("  Password: %s\n", config.Storage.Redis.Password)
-	fmt.Printf("  Database: %d\n", config.Storage.Redis.DB)
+	fmt.Printf("  Address: %s\n", show("storage.redis.addr", config.Storage.Redis.Addr))
+	fmt.Printf("  Port: %s\n", show("storage.redis.port", config.Storage.Redis.Port))
+	fmt.Printf("  Username: %s\n", show("storage.redis.username", config.Storage.Redis.Username))
+	fmt.Printf("  Password: %s\n", show("storage.redis.password", config.Storage.Redis.Password))
+	fmt.Printf("  Database: %s\n", show("storage.redis.db", config.Storage.Redis.DB))
+
+	if m := config.Storage.Mongo; m != nil {
+		fmt.Println("\n🍃 MongoDB Configuration:")
+		fmt.Printf("  Hosts: %s\n", show("storage.mongo.hosts", strings.Join(m.Hosts, ",")))
+		fmt.Printf("  Database: %s\n", show("storage.mongo.database", m.Database))
+		fmt.Printf("  Username: %s\n", show("storage.mongo.username", m.Username))
+		fmt.Printf("  Password: %s\n", show("storage.mongo.password", m.Password))
+	}
+
+	if k := config.Storage.Kafka; k != nil {
+		fmt.Println("\n📨 Kafka Configuration:")
+		fmt.Printf("  Brokers: %s\n", show("storage.kafka.brokers", strings.Join(k.Brokers, ",")))
+		fmt.Printf("  SASL Mechanism: %s\n", show("storage.kafka.sasl_mechanism", k.SASLMechanism))
+		fmt.Printf("  Username: %s\n", show("storage.kafka.username", k.Username))
+		fmt.Printf("  Password: %s\n", show("storage.kafka.password", k.Password))
+	}
+
+	if m := config.SMTP; m != nil {
+		fmt.Println("\n✉️ SMTP Configuration:")
+		fmt.Printf("  Host: %s\n", show("smtp.host", m.Host))
+		fmt.Printf("  Port: %s\n", show("smtp.port", m.Port))
+		fmt.Printf("  Username: %s\n", show("smtp.username", m.Username))
+		fmt.Printf("  Password: %s\n", show("smtp.password", m.Password))
+	}
 
 	fmt.Println("\n🔐 JWT Configuration:")
-	fmt.Printf("  Auth Key: %s\n", config.JWT.Auth)
+	fmt.Printf("  Auth Key: %s\n", show("jwt.auth", config.JWT.Auth))
+}
+
+func main() {
+	unsafeShow := flag.Bool("unsafe-show", false, "print secrets in plaintext")
+	flag.Parse()
+
+	policy := DefaultMaskPolicy
+	if *unsafeShow {
+		fmt.Fprintln(os.Stderr, "⚠️  -unsafe-show: secrets will be printed in plaintext")
+		policy = ShowAllPolicy
+	}
+
+	config := Bootstrap(WithStrict())
+
+	PrintConfig(config, policy)
 
 	fmt.Println("\n======================================================")
 	fmt.Println("🚀 Example Usage:")
 	fmt.Printf("PostgreSQL DSN: postgresql://%s:%s@%s:%d/%s\n",
 		config.Storage.PSQL.Username,
-		config.Storage.PSQL.Password,
+		policy.MaskValue("storage.psql.password", config.Storage.PSQL.Password),
 		config.Storage.PSQL.Host,
 		config.Storage.PSQL.Port,
 		config.Storage.PSQL.Database)
 	fmt.Printf("Redis URL: redis://%s:%s@%s:%d/%d\n",
 		config.Storage.Redis.Username,
-		config.Storage.Redis.Password,
+		policy.MaskValue("storage.redis.password", config.Storage.Redis.Password),
 		config.Storage.Redis.Addr,
 		config.Storage.Redis.Port,
 		config.Storage.Redis.DB)
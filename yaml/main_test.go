@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// validConfig is config.yaml: a file that passes validation.
+const validConfig = `storage:
+  psql:
+    host: 127.0.0.1
+    port: 5432
+    database: app
+    username: postgres
+    password: hunter22
+    pg_pool_max_conn: 4
+  redis:
+    addr: localhost
+    port: 6379
+    username: user
+    password: redis-pass
+    db: 0
+jwt:
+  auth: jwt-signing-secret
+`
+
+// installFakeSOPS puts a sops on PATH that prints its last argument, so
+// tests load plaintext files through the real exec path.
+func installFakeSOPS(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sops is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\nexec cat \"$last\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.sops.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// captureStdout returns what run writes to os.Stdout.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	run()
+	w.Close()
+	return string(<-done)
+}
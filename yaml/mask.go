@@ -0,0 +1,25 @@
+package main
+
+import "go-sops-mask"
+
+// MaskPolicy decides which keys are secret and how their values are
+// masked. It lives in go-sops-mask, shared with go-sops-env.
+type MaskPolicy = mask.Policy
+
+var DefaultMaskPolicy = &MaskPolicy{
+	Patterns:         []string{"PASSWORD", "SECRET", "KEY", "TOKEN", "CREDENTIAL", "PRIVATE"},
+	Deny:             []string{"jwt.auth"},
+	Prefixes:         mask.KnownSecretPrefixes,
+	Entropy:          true,
+	MinEntropyLength: 20,
+	Reveal:           2,
+	MinRevealLength:  5,
+}
+
+// ShowAllPolicy treats nothing as secret. It backs the -unsafe-show flag.
+var ShowAllPolicy = &MaskPolicy{}
+
+// A nil *MaskPolicy stands for DefaultMaskPolicy.
+func init() {
+	mask.Default = DefaultMaskPolicy
+}
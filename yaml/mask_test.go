@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintConfigMasksSecrets(t *testing.T) {
+	installFakeSOPS(t)
+	config, err := LoadSOPSConfig(writeConfig(t, validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() { PrintConfig(config, DefaultMaskPolicy) })
+	for _, secret := range []string{"hunter22", "redis-pass", "jwt-signing-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("PrintConfig shows %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"Host: 127.0.0.1", "Password: hu****22", "Username: postgres"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PrintConfig output lacks %q:\n%s", want, out)
+		}
+	}
+
+	if out := captureStdout(t, func() { PrintConfig(config, ShowAllPolicy) }); !strings.Contains(out, "Password: hunter22") {
+		t.Errorf("ShowAllPolicy masked the password:\n%s", out)
+	}
+}
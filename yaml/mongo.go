@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type Mongo struct {
+	// Hosts are host:port pairs, or a single host name with SRV.
+	Hosts         []string `yaml:"hosts" validate:"required,min=1"`
+	SRV           bool     `yaml:"srv,omitempty"`
+	Database      string   `yaml:"database" validate:"required"`
+	Username      string   `yaml:"username,omitempty"`
+	Password      string   `yaml:"password,omitempty"`
+	AuthSource    string   `yaml:"auth_source,omitempty"`
+	AuthMechanism string   `yaml:"auth_mechanism,omitempty"`
+	ReplicaSet    string   `yaml:"replica_set,omitempty"`
+	TLS           bool     `yaml:"tls,omitempty"`
+	TLSCAFile     string   `yaml:"tls_ca_file,omitempty"`
+}
+
+var ErrNoMongoConfig = errors.New("config has no storage.mongo section")
+
+// URI builds the connection string with the credentials percent-encoded,
+// so passwords containing characters like @, : or / work unchanged.
+func (m *Mongo) URI() (string, error) {
+	if len(m.Hosts) == 0 {
+		return "", errors.New("storage.mongo.hosts is empty")
+	}
+	scheme := "mongodb"
+	if m.SRV {
+		if len(m.Hosts) != 1 || strings.Contains(m.Hosts[0], ":") {
+			return "", errors.New("storage.mongo.srv needs exactly one host name without a port")
+		}
+		scheme = "mongodb+srv"
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme + "://")
+	if m.Username != "" {
+		b.WriteString(escapeUserinfo(m.Username))
+		if m.Password != "" {
+			b.WriteString(":" + escapeUserinfo(m.Password))
+		}
+		b.WriteString("@")
+	}
+	b.WriteString(strings.Join(m.Hosts, ","))
+	b.WriteString("/" + url.PathEscape(m.Database))
+
+	query := url.Values{}
+	if m.AuthSource != "" {
+		query.Set("authSource", m.AuthSource)
+	}
+	if m.AuthMechanism != "" {
+		query.Set("authMechanism", m.AuthMechanism)
+	}
+	if m.ReplicaSet != "" {
+		query.Set("replicaSet", m.ReplicaSet)
+	}
+	if m.TLS || m.TLSCAFile != "" {
+		query.Set("tls", "true")
+	}
+	if m.TLSCAFile != "" {
+		query.Set("tlsCAFile", m.TLSCAFile)
+	}
+	if len(query) > 0 {
+		b.WriteString("?" + query.Encode())
+	}
+	return b.String(), nil
+}
+
+// escapeUserinfo percent-encodes everything but unreserved characters,
+// which covers the $ : / ? # [ ] @ the MongoDB URI spec requires escaping.
+func escapeUserinfo(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// OpenMongo connects to storage.mongo and pings it, so a bad password or
+// unreachable cluster fails at startup rather than on the first query.
+func (c *Config) OpenMongo(ctx context.Context) (*mongo.Client, error) {
+	m := c.Storage.Mongo
+	if m == nil {
+		return nil, ErrNoMongoConfig
+	}
+	uri, err := m.URI()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	return client, nil
+}
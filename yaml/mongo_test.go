@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestMongoURI(t *testing.T) {
+	m := &Mongo{
+		Hosts:      []string{"mongo-0:27017", "mongo-1:27017"},
+		Database:   "app",
+		Username:   "app@svc",
+		Password:   "p@ss:w/rd?#",
+		AuthSource: "admin",
+		ReplicaSet: "rs0",
+		TLS:        true,
+	}
+	uri, err := m.URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The driver must read back exactly the credentials of the config.
+	opts := options.Client().ApplyURI(uri)
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("driver rejects %s: %v", uri, err)
+	}
+	if opts.Auth == nil || opts.Auth.Username != m.Username || opts.Auth.Password != m.Password || opts.Auth.AuthSource != "admin" {
+		t.Errorf("driver parsed credentials %+v from %s", opts.Auth, uri)
+	}
+	if len(opts.Hosts) != 2 || opts.ReplicaSet == nil || *opts.ReplicaSet != "rs0" || opts.TLSConfig == nil {
+		t.Errorf("driver parsed hosts %v, replica set %v, TLS %v", opts.Hosts, opts.ReplicaSet, opts.TLSConfig != nil)
+	}
+}
+
+func TestMongoURIErrors(t *testing.T) {
+	for _, m := range []*Mongo{
+		{Database: "app"},
+		{Hosts: []string{"a.example.com", "b.example.com"}, SRV: true, Database: "app"},
+		{Hosts: []string{"cluster.example.com:27017"}, SRV: true, Database: "app"},
+	} {
+		if uri, err := m.URI(); err == nil {
+			t.Errorf("URI() of %+v = %s, want an error", m, uri)
+		}
+	}
+	if _, err := (&Config{}).OpenMongo(context.Background()); !errors.Is(err, ErrNoMongoConfig) {
+		t.Errorf("OpenMongo() error = %v, want ErrNoMongoConfig", err)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+type Option func(*loadOptions)
+
+type loadOptions struct {
+	strict      bool
+	devFallback bool
+	softFail    bool
+}
+
+func newLoadOptions(opts []Option) *loadOptions {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithStrict makes loading fail when the file has keys the Config struct
+// doesn't know, or the struct has fields the file doesn't set.
+func WithStrict() Option {
+	return func(o *loadOptions) {
+		o.strict = true
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ProblemKind string
+
+const (
+	ProblemMissing    ProblemKind = "missing"
+	ProblemUnknown    ProblemKind = "unknown"
+	ProblemConversion ProblemKind = "conversion"
+	ProblemValidation ProblemKind = "validation"
+)
+
+// Problem is one thing wrong with a config file. Messages never include
+// the value, which may be a secret.
+type Problem struct {
+	Path    string      `json:"path"`
+	Line    int         `json:"line,omitempty"`
+	Kind    ProblemKind `json:"kind"`
+	Message string      `json:"message"`
+}
+
+// LoadReport lists every problem found by a WithSoftFail load.
+type LoadReport struct {
+	File     string    `json:"file"`
+	Problems []Problem `json:"problems"`
+}
+
+func (r *LoadReport) Error() string {
+	lines := make([]string, 0, len(r.Problems))
+	for _, p := range r.Problems {
+		path := p.Path
+		if p.Line > 0 {
+			path += fmt.Sprintf(" (line %d)", p.Line)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", path, p.Message))
+	}
+	noun := "problems"
+	if len(r.Problems) == 1 {
+		noun = "problem"
+	}
+	return fmt.Sprintf("%d %s in %s:\n  - %s", len(r.Problems), noun, r.File, strings.Join(lines, "\n  - "))
+}
+
+// Of returns the problems of one kind.
+func (r *LoadReport) Of(kind ProblemKind) []Problem {
+	var problems []Problem
+	for _, p := range r.Problems {
+		if p.Kind == kind {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// add records p unless its path already has a problem: a value that
+// doesn't convert is also empty, and would fail its required rule too.
+func (r *LoadReport) add(p Problem, lines map[string]int) {
+	for _, seen := range r.Problems {
+		if seen.Path == p.Path {
+			return
+		}
+	}
+	if p.Line == 0 {
+		p.Line = lines[p.Path]
+	}
+	r.Problems = append(r.Problems, p)
+}
+
+// WithSoftFail makes LoadSOPSConfig fill in as much of the config as it
+// can instead of stopping at the first problem. It returns the partial
+// config together with a *LoadReport listing every missing key, value of
+// the wrong type and failed validation rule, or a nil error if there were
+// none. Unknown keys are reported when WithStrict is set too. A file that
+// doesn't decrypt or parse still fails with no config.
+func WithSoftFail() Option {
+	return func(o *loadOptions) {
+		o.softFail = true
+	}
+}
+
+// typeErrorRegexp matches yaml.v3's conversion errors, whose value part is
+// dropped.
+var typeErrorRegexp = regexp.MustCompile("^line (\\d+): cannot unmarshal (\\S+)(?: `.*`)? into (.+)$")
+
+func loadWithReport(filename string, data []byte, options *loadOptions) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	keyLines := make(map[string]int)
+	valuePaths := make(map[int]string)
+	if len(doc.Content) > 0 {
+		indexNode(doc.Content[0], "", keyLines, valuePaths)
+	}
+
+	var config Config
+	report := &LoadReport{File: filename}
+	if options.strict {
+		var strictErr *StrictError
+		if err := checkStrict(data, &config); errors.As(err, &strictErr) {
+			for _, key := range strictErr.Unknown {
+				path, lineSuffix, _ := strings.Cut(key, " (line ")
+				line, _ := strconv.Atoi(strings.TrimSuffix(lineSuffix, ")"))
+				report.add(Problem{Path: path, Line: line, Kind: ProblemUnknown, Message: "unknown key"}, keyLines)
+			}
+			for _, key := range strictErr.Missing {
+				report.add(Problem{Path: key, Kind: ProblemMissing, Message: "missing from file"}, keyLines)
+			}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&config)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			report.add(conversionProblem(msg, valuePaths), keyLines)
+		}
+	} else if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	problems, err := validationProblems(&config)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range problems {
+		report.add(p, keyLines)
+	}
+
+	if len(report.Problems) == 0 {
+		return &config, nil
+	}
+	return &config, report
+}
+
+func conversionProblem(msg string, valuePaths map[int]string) Problem {
+	m := typeErrorRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return Problem{Kind: ProblemConversion, Message: "invalid value"}
+	}
+	line, _ := strconv.Atoi(m[1])
+	path := valuePaths[line]
+	if path == "" {
+		path = "line " + m[1]
+	}
+	return Problem{
+		Path:    path,
+		Line:    line,
+		Kind:    ProblemConversion,
+		Message: fmt.Sprintf("cannot convert %s to %s", m[2], m[3]),
+	}
+}
+
+// indexNode records the line of every key, and for each line the path of
+// the first value on it, so decoder errors can be mapped back to keys.
+func indexNode(node *yaml.Node, path string, keyLines map[string]int, valuePaths map[int]string) {
+	if node.Kind != yaml.MappingNode {
+		if _, ok := valuePaths[node.Line]; !ok && path != "" {
+			valuePaths[node.Line] = path
+		}
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := joinPath(path, node.Content[i].Value)
+		keyLines[key] = node.Content[i].Line
+		indexNode(node.Content[i+1], key, keyLines, valuePaths)
+	}
+}
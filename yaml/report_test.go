@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSoftFail(t *testing.T) {
+	installFakeSOPS(t)
+	content := strings.NewReplacer(
+		"port: 5432", "port: fifty",
+		"port: 6379", "port: 70000",
+		"  auth: jwt-signing-secret\n", "  auth: \"\"\n",
+		"host: 127.0.0.1", "hots: 127.0.0.1",
+	).Replace(validConfig)
+	filename := writeConfig(t, content)
+
+	config, err := LoadSOPSConfig(filename, WithSoftFail(), WithStrict())
+	var report *LoadReport
+	if !errors.As(err, &report) {
+		t.Fatalf("LoadSOPSConfig() = %v, want a *LoadReport", err)
+	}
+	if config == nil || config.Storage.PSQL.Password != "hunter22" || config.Storage.Redis.Addr != "localhost" {
+		t.Errorf("partial config = %+v", config)
+	}
+
+	want := []Problem{
+		{Path: "storage.psql.hots", Line: 3, Kind: ProblemUnknown, Message: "unknown key"},
+		{Path: "storage.psql.host", Kind: ProblemMissing, Message: "missing from file"},
+		{Path: "storage.psql.port", Line: 4, Kind: ProblemConversion, Message: "cannot convert !!str to int"},
+	}
+	if !reflect.DeepEqual(report.Problems[:3], want) {
+		t.Errorf("problems = %+v, want %+v first", report.Problems, want)
+	}
+	if len(report.Of(ProblemValidation)) != 1 || report.Of(ProblemValidation)[0].Path != "storage.redis.port" {
+		t.Errorf("validation problems = %+v", report.Of(ProblemValidation))
+	}
+	if auth := report.Problems[len(report.Problems)-1]; auth.Path != "jwt.auth" || auth.Line != 16 {
+		t.Errorf("last problem = %+v, want jwt.auth on line 16", auth)
+	}
+	if !strings.HasPrefix(report.Error(), "5 problems in "+filename+":\n  - storage.psql.hots (line 3): unknown key\n") {
+		t.Errorf("Error() = %q", report.Error())
+	}
+	if strings.Contains(report.Error(), "fifty") {
+		t.Errorf("Error() includes a value: %q", report.Error())
+	}
+	if hint := startupHint(filename, err); !strings.HasPrefix(hint, "fix the keys above") {
+		t.Errorf("startupHint() = %q", hint)
+	}
+
+	if _, err := LoadSOPSConfig(filename); errors.As(err, &report) {
+		t.Errorf("LoadSOPSConfig() without WithSoftFail = %v, want the first error", err)
+	}
+	if config, err := LoadSOPSConfig(writeConfig(t, validConfig), WithSoftFail()); err != nil || config == nil {
+		t.Errorf("LoadSOPSConfig() on a valid file = %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SMTP struct {
+	Host     string `yaml:"host" validate:"required,hostname_rfc1123|ip"`
+	Port     int    `yaml:"port" validate:"min=1,max=65535"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// AuthMechanism is PLAIN, the default when a username is set, or CRAM-MD5.
+	AuthMechanism string `yaml:"auth_mechanism,omitempty" validate:"omitempty,oneof=PLAIN CRAM-MD5"`
+	// TLSPolicy is starttls, the default, tls for implicit TLS on port 465, or
+	// none for a local relay.
+	TLSPolicy string `yaml:"tls_policy,omitempty" validate:"omitempty,oneof=starttls tls none"`
+	From      string `yaml:"from" validate:"required,email"`
+}
+
+var ErrNoSMTPConfig = errors.New("config has no smtp section")
+
+// Mailer sends mail through the server in an SMTP section. It opens a new
+// connection per message.
+type Mailer struct {
+	cfg     SMTP
+	timeout time.Duration
+}
+
+// Mailer returns a sender for the smtp section.
+func (c *Config) Mailer() (*Mailer, error) {
+	if c.SMTP == nil {
+		return nil, ErrNoSMTPConfig
+	}
+	return &Mailer{cfg: *c.SMTP, timeout: 30 * time.Second}, nil
+}
+
+// Send delivers msg, a complete RFC 5322 message with headers, from the
+// configured From address to the recipients.
+func (m *Mailer) Send(ctx context.Context, to []string, msg []byte) error {
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.cfg.Username != "" {
+		var auth smtp.Auth
+		switch m.cfg.AuthMechanism {
+		case "", "PLAIN":
+			// PlainAuth refuses to send the password over an unencrypted
+			// connection to anything but localhost.
+			auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		case "CRAM-MD5":
+			auth = smtp.CRAMMD5Auth(m.cfg.Username, m.cfg.Password)
+		default:
+			return fmt.Errorf("unsupported smtp.auth_mechanism %q", m.cfg.AuthMechanism)
+		}
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *Mailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	if m.cfg.TLSPolicy == "tls" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if m.cfg.TLSPolicy == "" || m.cfg.TLSPolicy == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP server does not offer STARTTLS; set smtp.tls_policy to none to allow plaintext")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	return client, nil
+}
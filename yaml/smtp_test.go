@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a plaintext SMTP server that accepts everything and records
+// the commands and message it received.
+type fakeSMTP struct {
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func startFakeSMTP(t *testing.T) (*fakeSMTP, int) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	s := &fakeSMTP{}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, lis.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN CRAM-MD5")
+		case "AUTH":
+			reply("235 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = b.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestMailerSend(t *testing.T) {
+	server, port := startFakeSMTP(t)
+	config := &Config{SMTP: &SMTP{Host: "127.0.0.1", Port: port, Username: "mailer", Password: "hunter22", TLSPolicy: "none", From: "app@example.com"}}
+	mailer, err := config.Mailer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Subject: hi\r\n\r\nhello\r\n"
+	if err := mailer.Send(context.Background(), []string{"a@example.com", "b@example.com"}, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	got := strings.Join(server.commands, "\n")
+	for _, want := range []string{
+		"AUTH PLAIN AG1haWxlcgBodW50ZXIyMg==", // \x00mailer\x00hunter22
+		"MAIL FROM:<app@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("server did not get %q:\n%s", want, got)
+		}
+	}
+	if server.data != msg {
+		t.Errorf("message = %q, want %q", server.data, msg)
+	}
+}
+
+func TestMailerRequiresSTARTTLS(t *testing.T) {
+	server, port := startFakeSMTP(t)
+	config := &Config{SMTP: &SMTP{Host: "127.0.0.1", Port: port, Username: "mailer", Password: "hunter22", From: "app@example.com"}}
+	mailer, _ := config.Mailer()
+	err := mailer.Send(context.Background(), []string{"a@example.com"}, []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send() error = %v, want STARTTLS required", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, command := range server.commands {
+		if strings.HasPrefix(command, "AUTH") {
+			t.Errorf("credentials sent over plaintext: %q", command)
+		}
+	}
+}
+
+func TestMailerErrors(t *testing.T) {
+	if _, err := (&Config{}).Mailer(); !errors.Is(err, ErrNoSMTPConfig) {
+		t.Errorf("Mailer() error = %v, want ErrNoSMTPConfig", err)
+	}
+	mailer, _ := (&Config{SMTP: &SMTP{Host: "127.0.0.1", Port: 1}}).Mailer()
+	if err := mailer.Send(context.Background(), nil, nil); err == nil || err.Error() != "no recipients" {
+		t.Errorf("Send() without recipients: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type StrictError struct {
+	Unknown []string
+	Missing []string
+}
+
+func (e *StrictError) Error() string {
+	var lines []string
+	for _, key := range e.Unknown {
+		lines = append(lines, key+": unknown key")
+	}
+	for _, key := range e.Missing {
+		lines = append(lines, key+": missing from file")
+	}
+	return "strict mode:\n  - " + strings.Join(lines, "\n  - ")
+}
+
+func checkStrict(data []byte, target any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	strictErr := &StrictError{}
+	walkStrict(doc.Content[0], reflect.TypeOf(target), "", strictErr)
+	if len(strictErr.Unknown) == 0 && len(strictErr.Missing) == 0 {
+		return nil
+	}
+	return strictErr
+}
+
+func walkStrict(node *yaml.Node, t reflect.Type, path string, strictErr *StrictError) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	keys := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys[node.Content[i].Value] = node.Content[i+1]
+	}
+
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		known[name] = true
+
+		value, ok := keys[name]
+		if !ok {
+			if !strings.Contains(opts, "omitempty") {
+				strictErr.Missing = append(strictErr.Missing, joinPath(path, name))
+			}
+			continue
+		}
+		walkStrict(value, field.Type, joinPath(path, name), strictErr)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			strictErr.Unknown = append(strictErr.Unknown, fmt.Sprintf("%s (line %d)", joinPath(path, key.Value), key.Line))
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadStrict(t *testing.T) {
+	installFakeSOPS(t)
+	typo := strings.Replace(validConfig, "    password: hunter22", "    pasword: hunter22", 1)
+	filename := writeConfig(t, typo)
+
+	// Without WithStrict the typo decodes, then fails validation.
+	if _, err := LoadSOPSConfig(filename); err == nil || errors.As(err, new(*StrictError)) {
+		t.Errorf("LoadSOPSConfig() error = %v, want a validation error", err)
+	}
+
+	_, err := LoadSOPSConfig(filename, WithStrict())
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("LoadSOPSConfig(WithStrict()) error = %v, want a *StrictError", err)
+	}
+	if want := []string{"storage.psql.pasword (line 7)"}; !slices.Equal(strictErr.Unknown, want) {
+		t.Errorf("Unknown = %q, want %q", strictErr.Unknown, want)
+	}
+	if want := []string{"storage.psql.password"}; !slices.Equal(strictErr.Missing, want) {
+		t.Errorf("Missing = %q, want %q", strictErr.Missing, want)
+	}
+}
+
+func TestLoadStrictAcceptsCompleteFile(t *testing.T) {
+	installFakeSOPS(t)
+	config, err := LoadSOPSConfig(writeConfig(t, validConfig), WithStrict())
+	if err != nil {
+		t.Fatalf("LoadSOPSConfig(WithStrict()) error = %v", err)
+	}
+	// Optional sections tagged omitempty may be left out.
+	if config.SMTP != nil || config.Storage.Mongo != nil {
+		t.Errorf("optional sections set: %+v", config)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The config types implement String and GoString with secret fields masked
+// by DefaultMaskPolicy, so that printing them with %v, %+v or %#v is safe.
+// They use value receivers to cover both T and *T.
+
+func (c Config) String() string   { return formatRedacted(reflect.ValueOf(c), "", false) }
+func (c Config) GoString() string { return formatRedacted(reflect.ValueOf(c), "", true) }
+
+func (s Storage) String() string   { return formatRedacted(reflect.ValueOf(s), "storage", false) }
+func (s Storage) GoString() string { return formatRedacted(reflect.ValueOf(s), "storage", true) }
+
+func (p PSQL) String() string   { return formatRedacted(reflect.ValueOf(p), "storage.psql", false) }
+func (p PSQL) GoString() string { return formatRedacted(reflect.ValueOf(p), "storage.psql", true) }
+
+func (r Redis) String() string   { return formatRedacted(reflect.ValueOf(r), "storage.redis", false) }
+func (r Redis) GoString() string { return formatRedacted(reflect.ValueOf(r), "storage.redis", true) }
+
+func (m Mongo) String() string   { return formatRedacted(reflect.ValueOf(m), "storage.mongo", false) }
+func (m Mongo) GoString() string { return formatRedacted(reflect.ValueOf(m), "storage.mongo", true) }
+
+func (k Kafka) String() string   { return formatRedacted(reflect.ValueOf(k), "storage.kafka", false) }
+func (k Kafka) GoString() string { return formatRedacted(reflect.ValueOf(k), "storage.kafka", true) }
+
+func (m SMTP) String() string   { return formatRedacted(reflect.ValueOf(m), "smtp", false) }
+func (m SMTP) GoString() string { return formatRedacted(reflect.ValueOf(m), "smtp", true) }
+
+func (j JWT) String() string   { return formatRedacted(reflect.ValueOf(j), "jwt", false) }
+func (j JWT) GoString() string { return formatRedacted(reflect.ValueOf(j), "jwt", true) }
+
+// formatRedacted formats a struct like %+v (or %#v when goSyntax is set),
+// masking fields by their dotted YAML path below prefix.
+func formatRedacted(v reflect.Value, prefix string, goSyntax bool) string {
+	t := v.Type()
+
+	var b strings.Builder
+	sep := " "
+	if goSyntax {
+		b.WriteString(t.String())
+		sep = ", "
+	}
+	b.WriteString("{")
+
+	first := true
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if !first {
+			b.WriteString(sep)
+		}
+		first = false
+
+		path := joinPath(prefix, yamlName(field))
+		fv := v.Field(i)
+
+		var value string
+		switch {
+		case fv.Kind() == reflect.Struct:
+			value = formatRedacted(fv, path, goSyntax)
+		case fv.Kind() == reflect.String && DefaultMaskPolicy.IsSecretValue(path, fv.String()):
+			value = DefaultMaskPolicy.Mask(fv.String())
+			if goSyntax {
+				value = fmt.Sprintf("%q", value)
+			}
+		case goSyntax:
+			value = fmt.Sprintf("%#v", fv.Interface())
+		default:
+			value = fmt.Sprint(fv.Interface())
+		}
+		fmt.Fprintf(&b, "%s:%s", field.Name, value)
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
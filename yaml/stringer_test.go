@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConfigFormatMasksSecrets(t *testing.T) {
+	config := &Config{
+		Storage: Storage{
+			PSQL:  PSQL{Host: "db.internal", Password: "hunter22"},
+			Redis: Redis{Addr: "cache", Password: "redis-pass"},
+		},
+		JWT: JWT{Auth: "jwt-signing-secret"},
+	}
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		for _, v := range []any{config, *config, config.Storage.PSQL} {
+			out := fmt.Sprintf(format, v)
+			for _, secret := range []string{"hunter22", "redis-pass", "jwt-signing-secret"} {
+				if strings.Contains(out, secret) {
+					t.Errorf("Sprintf(%q, %T) shows %q: %s", format, v, secret, out)
+				}
+			}
+			if !strings.Contains(out, "db.internal") {
+				t.Errorf("Sprintf(%q, %T) = %s, want the host shown", format, v, out)
+			}
+		}
+	}
+}
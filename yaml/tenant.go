@@ -0,0 +1,203 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// DefaultTenantCapacity is how many tenants NewTenantConfigs keeps
+// decrypted when given a capacity of zero.
+const DefaultTenantCapacity = 128
+
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+
+	tenantPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// TenantConfigs loads <root>/<tenant>/config.sops.yaml on first use and
+// keeps the most recently used tenants decrypted in memory. Concurrent
+// requests for a tenant that isn't loaded yet share one decryption, and a
+// Reload runs after any load already in flight for the tenant.
+type TenantConfigs struct {
+	root     string
+	opts     []Option
+	capacity int
+	decrypt  func(tenant string) (*Config, error)
+
+	mu      sync.Mutex
+	lru     *list.List // of *tenantEntry, most recently used first
+	entries map[string]*list.Element
+	loading map[string]*tenantLoad
+	// generations counts the stores and evictions of each tenant that is
+	// cached or loading. A load only stores its config if the generation
+	// is still the one it started at, so it can't bring back an evicted
+	// tenant.
+	generations map[string]uint64
+}
+
+type tenantEntry struct {
+	tenant string
+	config *Config
+}
+
+type tenantLoad struct {
+	done       chan struct{}
+	generation uint64
+	config     *Config
+	err        error
+}
+
+func NewTenantConfigs(root string, capacity int, opts ...Option) *TenantConfigs {
+	if capacity <= 0 {
+		capacity = DefaultTenantCapacity
+	}
+	t := &TenantConfigs{
+		root:        root,
+		opts:        opts,
+		capacity:    capacity,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+		loading:     make(map[string]*tenantLoad),
+		generations: make(map[string]uint64),
+	}
+	t.decrypt = t.load
+	return t
+}
+
+func (t *TenantConfigs) filename(tenant string) (string, error) {
+	if !tenantPattern.MatchString(tenant) {
+		return "", fmt.Errorf("%w: invalid tenant name %q", ErrUnknownTenant, tenant)
+	}
+	filename := filepath.Join(t.root, tenant, "config.sops.yaml")
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTenant, tenant)
+	}
+	return filename, nil
+}
+
+// Get returns the tenant's config, decrypting it if it isn't cached.
+func (t *TenantConfigs) Get(tenant string) (*Config, error) {
+	t.mu.Lock()
+	if elem, ok := t.entries[tenant]; ok {
+		t.lru.MoveToFront(elem)
+		config := elem.Value.(*tenantEntry).config
+		t.mu.Unlock()
+		return config, nil
+	}
+	if load, ok := t.loading[tenant]; ok {
+		t.mu.Unlock()
+		<-load.done
+		return load.config, load.err
+	}
+	load := t.startLoad(tenant)
+	t.mu.Unlock()
+	return t.finishLoad(tenant, load)
+}
+
+// Reload decrypts the tenant's file again, e.g. after its credentials were
+// rotated. It waits for a load already in flight, since that one may have
+// read the file before the rotation. On failure the cached config, if any,
+// is kept.
+func (t *TenantConfigs) Reload(tenant string) error {
+	t.mu.Lock()
+	for {
+		running, ok := t.loading[tenant]
+		if !ok {
+			break
+		}
+		t.mu.Unlock()
+		<-running.done
+		t.mu.Lock()
+	}
+	load := t.startLoad(tenant)
+	t.mu.Unlock()
+	_, err := t.finishLoad(tenant, load)
+	return err
+}
+
+// startLoad must be called with t.mu held and no load of tenant running.
+func (t *TenantConfigs) startLoad(tenant string) *tenantLoad {
+	load := &tenantLoad{done: make(chan struct{}), generation: t.generations[tenant]}
+	t.loading[tenant] = load
+	return load
+}
+
+func (t *TenantConfigs) finishLoad(tenant string, load *tenantLoad) (*Config, error) {
+	load.config, load.err = t.decrypt(tenant)
+
+	t.mu.Lock()
+	delete(t.loading, tenant)
+	if load.err == nil && t.generations[tenant] == load.generation {
+		t.store(tenant, load.config)
+	}
+	if _, ok := t.entries[tenant]; !ok {
+		delete(t.generations, tenant)
+	}
+	t.mu.Unlock()
+	close(load.done)
+	return load.config, load.err
+}
+
+// Evict drops the tenant's config from memory, e.g. when it is deleted.
+func (t *TenantConfigs) Evict(tenant string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[tenant]; ok {
+		t.lru.Remove(elem)
+		delete(t.entries, tenant)
+	}
+	t.forget(tenant)
+}
+
+// Len returns how many tenants are cached.
+func (t *TenantConfigs) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lru.Len()
+}
+
+func (t *TenantConfigs) load(tenant string) (*Config, error) {
+	filename, err := t.filename(tenant)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadSOPSConfig(filename, t.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+	}
+	return config, nil
+}
+
+// store must be called with t.mu held.
+func (t *TenantConfigs) store(tenant string, config *Config) {
+	t.generations[tenant]++
+	if elem, ok := t.entries[tenant]; ok {
+		elem.Value.(*tenantEntry).config = config
+		t.lru.MoveToFront(elem)
+		return
+	}
+	t.entries[tenant] = t.lru.PushFront(&tenantEntry{tenant: tenant, config: config})
+	for t.lru.Len() > t.capacity {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		evicted := oldest.Value.(*tenantEntry).tenant
+		delete(t.entries, evicted)
+		t.forget(evicted)
+	}
+}
+
+// forget must be called with t.mu held once tenant is out of the cache. A
+// load in flight sees the generation change and doesn't store its config.
+func (t *TenantConfigs) forget(tenant string) {
+	if _, ok := t.loading[tenant]; ok {
+		t.generations[tenant]++
+	} else {
+		delete(t.generations, tenant)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingDecrypt hands out configs whose JWT auth counts the
+// decryptions. The first one waits until release is closed.
+type blockingDecrypt struct {
+	mu      sync.Mutex
+	calls   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingDecrypt() *blockingDecrypt {
+	return &blockingDecrypt{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (d *blockingDecrypt) decrypt(string) (*Config, error) {
+	d.mu.Lock()
+	d.calls++
+	n := d.calls
+	d.mu.Unlock()
+	if n == 1 {
+		close(d.started)
+		<-d.release
+	}
+	config := &Config{}
+	config.JWT.Auth = strconv.Itoa(n)
+	return config, nil
+}
+
+func newTestTenants(d *blockingDecrypt) *TenantConfigs {
+	t := NewTenantConfigs("tenants", 0)
+	t.decrypt = d.decrypt
+	return t
+}
+
+func TestTenantConfigsEvictDuringLoad(t *testing.T) {
+	d := newBlockingDecrypt()
+	tenants := newTestTenants(d)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := tenants.Get("acme"); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-d.started
+	tenants.Evict("acme")
+	close(d.release)
+	<-done
+
+	if n := tenants.Len(); n != 0 {
+		t.Fatalf("Len() = %d after an evict during the load, want 0", n)
+	}
+	config, err := tenants.Get("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.JWT.Auth != "2" {
+		t.Errorf("Get() after the evict returned decryption %s, want a new one", config.JWT.Auth)
+	}
+}
+
+func TestTenantConfigsReloadDuringLoad(t *testing.T) {
+	d := newBlockingDecrypt()
+	tenants := newTestTenants(d)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := tenants.Get("acme"); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-d.started
+	reloaded := make(chan error)
+	go func() { reloaded <- tenants.Reload("acme") }()
+	// Let the Reload start; before the fix it decrypted and stored at once,
+	// and the Get's older result then overwrote it.
+	time.Sleep(20 * time.Millisecond)
+	close(d.release)
+	<-done
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := tenants.Get("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.JWT.Auth != "2" {
+		t.Errorf("Get() after Reload returned decryption %s, want the reload's", config.JWT.Auth)
+	}
+}
+
+func TestTenantConfigsShareLoads(t *testing.T) {
+	d := newBlockingDecrypt()
+	tenants := newTestTenants(d)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tenants.Get("acme"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	<-d.started
+	close(d.release)
+	wg.Wait()
+	if d.calls != 1 {
+		t.Errorf("%d decryptions for concurrent Gets, want 1", d.calls)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// normalizeText prepares decrypted data for parsing: it drops a UTF-8 BOM,
+// turns Windows CRLF line endings into LF so no value ends in \r, and
+// rejects bytes that aren't UTF-8 with the line they are on. It reuses
+// data's memory.
+func normalizeText(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return nil, errors.New("file is UTF-16, save it as UTF-8")
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("line %d: invalid UTF-8, save the file as UTF-8", invalidUTF8Line(data))
+	}
+	if !bytes.Contains(data, []byte("\r\n")) {
+		return data, nil
+	}
+
+	n := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		data[n] = data[i]
+		n++
+	}
+	return data[:n], nil
+}
+
+// invalidUTF8Line returns the 1-based line of the first invalid byte.
+func invalidUTF8Line(data []byte) int {
+	line := 1
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		data = data[size:]
+	}
+	return line
+}
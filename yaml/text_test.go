@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSOPSConfigWindowsText(t *testing.T) {
+	installFakeSOPS(t)
+	content := "\ufeff" + strings.ReplaceAll(validConfig, "\n", "\r\n")
+	config, err := LoadSOPSConfig(writeConfig(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Storage.PSQL.Password != "hunter22" || config.JWT.Auth != "jwt-signing-secret" {
+		t.Errorf("loaded password %q, jwt %q", config.Storage.PSQL.Password, config.JWT.Auth)
+	}
+
+	_, err = LoadSOPSConfig(writeConfig(t, strings.Replace(validConfig, "hunter22", "caf\xe9", 1)))
+	if err == nil || !strings.Contains(err.Error(), "line 7: invalid UTF-8") {
+		t.Errorf("LoadSOPSConfig() with Latin-1 text = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Violations, "\n  - ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+func validateConfig(config *Config) error {
+	problems, err := validationProblems(config)
+	if err != nil || len(problems) == 0 {
+		return err
+	}
+
+	violations := make([]string, 0, len(problems))
+	for _, p := range problems {
+		violations = append(violations, p.Path+": "+p.Message)
+	}
+	return &ValidationError{Violations: violations}
+}
+
+// validationProblems runs the validator tags. A failed required rule is
+// reported as a missing key.
+func validationProblems(config *Config) ([]Problem, error) {
+	err := validate.Struct(config)
+	if err == nil {
+		return nil, nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	problems := make([]Problem, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		kind := ProblemValidation
+		if fe.Tag() == "required" {
+			kind = ProblemMissing
+		}
+		problems = append(problems, Problem{Path: yamlPath(fe.Namespace()), Kind: kind, Message: describeViolation(fe)})
+	}
+	return problems, nil
+}
+
+// yamlPath drops the root struct name so paths read like the file.
+func yamlPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
+}
+
+func describeViolation(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "required_with":
+		return "is required with " + fe.Param()
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "hostname_rfc1123", "hostname":
+		return "must be a valid hostname"
+	case "ip":
+		return "must be a valid IP address"
+	case "hostname_rfc1123|ip":
+		return "must be a valid hostname or IP address"
+	default:
+		return fmt.Sprintf("failed %q validation", fe.Tag())
+	}
+}
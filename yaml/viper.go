@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ReadSOPSConfig decrypts filename and loads it into v, replacing what v
+// read before, so existing v.Get* call sites work unchanged. The format
+// comes from the extension: config.sops.yaml is read as YAML, .json as
+// JSON and .env as dotenv.
+func ReadSOPSConfig(v *viper.Viper, filename string) error {
+	return readSOPSIntoViper(v, filename, v.ReadConfig)
+}
+
+// MergeSOPSConfig is like ReadSOPSConfig but merges into v's existing
+// config, for layering an encrypted file over plaintext defaults.
+func MergeSOPSConfig(v *viper.Viper, filename string) error {
+	return readSOPSIntoViper(v, filename, v.MergeConfig)
+}
+
+func readSOPSIntoViper(v *viper.Viper, filename string, read func(in io.Reader) error) error {
+	configType, err := viperConfigType(filename)
+	if err != nil {
+		return err
+	}
+
+	decryptedData, err := decryptSOPSFile(filename)
+	if err != nil {
+		return err
+	}
+	decryptedData, err = normalizeText(decryptedData)
+	if err != nil {
+		return fmt.Errorf("failed to read %s into viper: %w", filename, err)
+	}
+
+	v.SetConfigType(configType)
+	if err := read(bytes.NewReader(decryptedData)); err != nil {
+		return fmt.Errorf("failed to read %s into viper: %w", filename, err)
+	}
+	return nil
+}
+
+func viperConfigType(filename string) (string, error) {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	switch ext {
+	case "yaml", "yml", "json", "toml", "ini":
+		return ext, nil
+	case "env", "dotenv":
+		return "dotenv", nil
+	default:
+		return "", fmt.Errorf("cannot tell the config format of %s from its extension", filename)
+	}
+}